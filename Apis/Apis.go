@@ -106,7 +106,7 @@ func SetCarDriverPair(c *fiber.Ctx) error {
 		log.Println(err.Error())
 		return err
 	}
-	if err := Models.DB.Model(&Models.Car{}).Where("id = ?", input.CarID).Update("driver_id", input.DriverID).Error; err != nil {
+	if err := Models.AssignDriver(Models.DB, input.CarID, input.DriverID, Models.WallClockNow(), Models.AssignmentSourcePairing); err != nil {
 		log.Println(err.Error())
 		return err
 	}
@@ -121,6 +121,11 @@ func GetFuelEvents(c *fiber.Ctx) error {
 	}
 	// If methodFilter is "all" or empty, no additional filter is applied
 
+	// Filter by fill location check: verified, unverified or mismatched
+	if locationStatus := c.Query("location_status"); locationStatus != "" {
+		query = query.Where("location_status = ?", locationStatus)
+	}
+
 	// Execute the query
 	if err := query.Find(&FuelEvents).Error; err != nil {
 		log.Println(err.Error())
@@ -365,6 +370,9 @@ func AddFuelEvent(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+	if _, err := Models.CheckFuelEvent(Models.DB, inputJson); err != nil {
+		log.Println(err.Error())
+	}
 	PetroApp.UpdatePetroAppOdometerFromManualFuelEvent(inputJson.CarNoPlate, inputJson.OdometerAfter)
 	return c.JSON(inputJson)
 }
@@ -392,6 +400,8 @@ func EditFuelEvent(c *fiber.Ctx) error {
 		return err
 	}
 	inputJson.CreatedAt = fuelEvent.CreatedAt
+	inputJson.LocationStatus = fuelEvent.LocationStatus
+	inputJson.LocationDistanceKm = fuelEvent.LocationDistanceKm
 
 	if err := Models.DB.Save(&inputJson).Error; err != nil {
 		log.Println(err.Error())
@@ -411,6 +421,10 @@ func EditFuelEvent(c *fiber.Ctx) error {
 		return err
 	}
 
+	if _, err := Models.CheckFuelEvent(Models.DB, inputJson); err != nil {
+		log.Println(err.Error())
+	}
+
 	if lastFuelEvent.ID == inputJson.ID {
 		// If the edited fuel event is the last fuel event, call the PetroApp function
 		PetroApp.UpdatePetroAppOdometerFromManualFuelEvent(inputJson.CarNoPlate, inputJson.OdometerAfter)
@@ -438,6 +452,9 @@ func DeleteFuelEvent(c *fiber.Ctx) error {
 		log.Println(err.Error())
 		return err
 	}
+	if err := Models.DB.Where("fuel_event_id = ?", fuelEvent.ID).Delete(&Models.FuelAnomaly{}).Error; err != nil {
+		log.Println(err.Error())
+	}
 	return c.JSON(fiber.Map{
 		"message": "Fuel Event Deleted Successfully",
 	})
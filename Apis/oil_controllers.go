@@ -28,6 +28,15 @@ func CreateOilChange(c *fiber.Ctx) error {
 			}
 			input.CarNoPlate = car.CarNoPlate
 			input.Transporter = Controllers.CurrentUser.Name
+			if input.DriverName == "" {
+				if at, err := Models.DateMidday(input.Date); err == nil {
+					driver, err := Models.ResolveDriver(Models.DB, car.ID, at)
+					if err != nil {
+						log.Println(err)
+					}
+					input.DriverName = driver.Name
+				}
+			}
 
 			input, err := input.Add()
 
@@ -23,3 +23,6 @@ const WhatsappGoService = "http://localhost:3000"
 const WhatsAppAlertNumber = "+201061856523"
 const WhatsAppGPIDFuel = "120363420442360151@g.us"
 const WhatsAppGPIDEtit = "120363418908976957@g.us"
+
+// WhatsAppGPIDMaintenance receives the preventive maintenance alerts, in the fuel group for now
+const WhatsAppGPIDMaintenance = WhatsAppGPIDFuel
@@ -0,0 +1,94 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// costWorkbooks maps the workbook names of the routes to their files
+var costWorkbooks = map[string]string{
+	"fuel":    Models.FuelWorkbookName,
+	"service": Models.ServiceWorkbookName,
+	"costs":   Models.CarCostsWorkbookName,
+}
+
+// CostExportHandler contains handler methods for the fuel, service and car costs workbooks
+type CostExportHandler struct {
+	DB *gorm.DB
+}
+
+// NewCostExportHandler creates a new cost export handler
+func NewCostExportHandler(db *gorm.DB) *CostExportHandler {
+	return &CostExportHandler{
+		DB: db,
+	}
+}
+
+// DownloadCostWorkbook builds a cost workbook from the database, of one car when car_id is
+// given
+func (h *CostExportHandler) DownloadCostWorkbook(c *fiber.Ctx) error {
+	name, ok := costWorkbooks[c.Params("workbook")]
+	if !ok {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"message": "Unknown workbook, expected fuel, service or costs",
+		})
+	}
+
+	var carID uint64
+	if param := c.Query("car_id"); param != "" {
+		var err error
+		if carID, err = strconv.ParseUint(param, 10, 64); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid car ID",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	export, err := Models.LoadCostExport(h.DB, uint(carID))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch cost records",
+			"error":   err.Error(),
+		})
+	}
+
+	f, err := Models.BuildCostWorkbook(name, export)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to build workbook",
+			"error":   err.Error(),
+		})
+	}
+	defer f.Close()
+
+	buffer, err := f.WriteToBuffer()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to write workbook",
+			"error":   err.Error(),
+		})
+	}
+
+	c.Attachment(name)
+	return c.Status(http.StatusOK).Send(buffer.Bytes())
+}
+
+// RebuildCostWorkbooks rebuilds the cost workbooks kept on disk without waiting for the
+// scheduled export
+func (h *CostExportHandler) RebuildCostWorkbooks(c *fiber.Ctx) error {
+	if err := Models.ExportCostWorkbooks(h.DB, Models.CostWorkbookDir); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to rebuild cost workbooks",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Cost workbooks rebuilt successfully",
+	})
+}
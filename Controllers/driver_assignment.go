@@ -0,0 +1,86 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// DriverAssignmentHandler contains handler methods for the car to driver assignment history
+type DriverAssignmentHandler struct {
+	DB *gorm.DB
+}
+
+// NewDriverAssignmentHandler creates a new driver assignment handler
+func NewDriverAssignmentHandler(db *gorm.DB) *DriverAssignmentHandler {
+	return &DriverAssignmentHandler{
+		DB: db,
+	}
+}
+
+// GetDriverAssignments returns the assignments of a car or driver, newest first
+func (h *DriverAssignmentHandler) GetDriverAssignments(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.DriverAssignment{})
+
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+	if driverID := c.Query("driver_id"); driverID != "" {
+		query = query.Where("driver_id = ?", driverID)
+	}
+
+	var assignments []Models.DriverAssignment
+	if err := query.Order("started_at DESC").Find(&assignments).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch driver assignments",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Driver assignments retrieved successfully",
+		"data":    assignments,
+	})
+}
+
+// GetDriverAt returns the driver assigned to a car at a time, now by default
+func (h *DriverAssignmentHandler) GetDriverAt(c *fiber.Ctx) error {
+	carID, err := strconv.ParseUint(c.Query("car_id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid car ID",
+			"error":   err.Error(),
+		})
+	}
+
+	at := Models.WallClockNow()
+	if value := c.Query("at"); value != "" {
+		if at, err = parsePositionTime(value); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid time",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	driver, err := Models.ResolveDriver(h.DB, uint(carID), at)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to resolve driver",
+			"error":   err.Error(),
+		})
+	}
+	if driver.ID == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"message": "No driver was assigned to the car at that time",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Driver retrieved successfully",
+		"data":    driver,
+	})
+}
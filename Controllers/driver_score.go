@@ -0,0 +1,194 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// DriverScoreHandler contains handler methods for the driver safety scorecard
+type DriverScoreHandler struct {
+	DB *gorm.DB
+}
+
+// NewDriverScoreHandler creates a new driver score handler
+func NewDriverScoreHandler(db *gorm.DB) *DriverScoreHandler {
+	return &DriverScoreHandler{
+		DB: db,
+	}
+}
+
+// maxTrendPeriods bounds the number of periods of a trend request
+const maxTrendPeriods = 24
+
+// scorePeriod parses the period and date query parameters, the week of today by default.
+// It returns the start of the period.
+func scorePeriod(c *fiber.Ctx) (string, time.Time, error) {
+	period := c.Query("period", Models.ScorePeriodWeek)
+
+	day, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return period, day, err
+		}
+		day = parsed
+	}
+
+	start, _, err := Models.ScorePeriodBounds(period, day)
+	return period, start, err
+}
+
+// GetDriverScores returns the ranking of the drivers over a week or month. Periods not
+// scored yet are scored on the fly.
+func (h *DriverScoreHandler) GetDriverScores(c *fiber.Ctx) error {
+	period, start, err := scorePeriod(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid period",
+			"error":   err.Error(),
+		})
+	}
+
+	var scores []Models.DriverScore
+	if err := h.DB.Where("period = ? AND period_start = ?", period, start.Format("2006-01-02")).
+		Order("rank ASC").Find(&scores).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch driver scores",
+			"error":   err.Error(),
+		})
+	}
+
+	if len(scores) == 0 {
+		if scores, err = Models.ComputeDriverScores(h.DB, period, start); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to compute driver scores",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Driver scores retrieved successfully",
+		"data":    scores,
+	})
+}
+
+// ComputeDriverScores scores the drivers over a week or month again
+func (h *DriverScoreHandler) ComputeDriverScores(c *fiber.Ctx) error {
+	period, start, err := scorePeriod(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid period",
+			"error":   err.Error(),
+		})
+	}
+
+	scores, err := Models.ComputeDriverScores(h.DB, period, start)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to compute driver scores",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Driver scores computed successfully",
+		"data":    scores,
+	})
+}
+
+// trendStart returns the start of the oldest of the last count periods ending with the
+// requested one
+func trendStart(c *fiber.Ctx) (string, time.Time, time.Time, error) {
+	period, start, err := scorePeriod(c)
+	if err != nil {
+		return period, start, start, err
+	}
+
+	count, err := strconv.Atoi(c.Query("count", "8"))
+	if err != nil || count < 1 || count > maxTrendPeriods {
+		count = 8
+	}
+
+	from := start
+	for i := 1; i < count; i++ {
+		from, _, _ = Models.ScorePeriodBounds(period, from.AddDate(0, 0, -1))
+	}
+	return period, from, start, nil
+}
+
+// GetDriverScoreTrend returns the scores of a driver over the last count periods, oldest first
+func (h *DriverScoreHandler) GetDriverScoreTrend(c *fiber.Ctx) error {
+	driverID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid driver ID",
+			"error":   err.Error(),
+		})
+	}
+
+	period, from, to, err := trendStart(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid period",
+			"error":   err.Error(),
+		})
+	}
+
+	var scores []Models.DriverScore
+	if err := h.DB.Where("driver_id = ? AND period = ? AND period_start BETWEEN ? AND ?",
+		driverID, period, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("period_start ASC").Find(&scores).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch driver scores",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Driver score trend retrieved successfully",
+		"data":    scores,
+	})
+}
+
+// GetFleetScoreTrend returns the average driver score over the last count periods, oldest first
+func (h *DriverScoreHandler) GetFleetScoreTrend(c *fiber.Ctx) error {
+	period, from, to, err := trendStart(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid period",
+			"error":   err.Error(),
+		})
+	}
+
+	var trend []struct {
+		PeriodStart     string  `json:"period_start"`
+		Drivers         int     `json:"drivers"`
+		AverageScore    float64 `json:"average_score"`
+		MinScore        float64 `json:"min_score"`
+		SpeedViolations int     `json:"speed_violations"`
+		DistanceKm      float64 `json:"distance_km"`
+	}
+	if err := h.DB.Model(&Models.DriverScore{}).
+		Select("period_start, COUNT(*) AS drivers, ROUND(AVG(score), 1) AS average_score, MIN(score) AS min_score, "+
+			"SUM(speed_violations) AS speed_violations, SUM(distance_km) AS distance_km").
+		Where("period = ? AND period_start BETWEEN ? AND ?", period, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Group("period_start").
+		Order("period_start ASC").
+		Scan(&trend).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch driver score trend",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Fleet score trend retrieved successfully",
+		"data":    trend,
+	})
+}
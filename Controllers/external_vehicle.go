@@ -0,0 +1,257 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ExternalVehicleHandler contains handler methods for the links between cars and their IDs in
+// external systems
+type ExternalVehicleHandler struct {
+	DB *gorm.DB
+}
+
+// NewExternalVehicleHandler creates a new external vehicle handler
+func NewExternalVehicleHandler(db *gorm.DB) *ExternalVehicleHandler {
+	return &ExternalVehicleHandler{
+		DB: db,
+	}
+}
+
+// GetExternalVehicles returns the external vehicle identities
+func (h *ExternalVehicleHandler) GetExternalVehicles(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.ExternalVehicleIdentity{})
+
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var identities []Models.ExternalVehicleIdentity
+	if err := query.Order("source ASC, car_no_plate ASC").Find(&identities).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch external vehicles",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "External vehicles retrieved successfully",
+		"data":    identities,
+	})
+}
+
+// CreateExternalVehicle links a car to an external vehicle, confirmed unless stated otherwise
+func (h *ExternalVehicleHandler) CreateExternalVehicle(c *fiber.Ctx) error {
+	identity := Models.ExternalVehicleIdentity{Status: Models.ExternalIdentityConfirmed}
+	if err := c.BodyParser(&identity); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := identity.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid external vehicle",
+			"error":   err.Error(),
+		})
+	}
+
+	var existing Models.ExternalVehicleIdentity
+	if err := h.DB.Where("source = ? AND external_id = ?", identity.Source, identity.ExternalID).
+		Limit(1).Find(&existing).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch external vehicle",
+			"error":   err.Error(),
+		})
+	}
+	if existing.ID != 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "The external vehicle is already linked to " + existing.CarNoPlate,
+			"data":    existing,
+		})
+	}
+
+	if err := Models.SaveExternalVehicle(h.DB, &identity); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create external vehicle",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "External vehicle created successfully",
+		"data":    identity,
+	})
+}
+
+// UpdateExternalVehicle updates the fields of an external vehicle identity present in the
+// request body
+func (h *ExternalVehicleHandler) UpdateExternalVehicle(c *fiber.Ctx) error {
+	identity, err := h.findIdentity(c)
+	if identity == nil {
+		return err
+	}
+
+	id := identity.ID
+	if err := c.BodyParser(identity); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	identity.ID = id
+
+	if err := identity.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid external vehicle",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := Models.SaveExternalVehicle(h.DB, identity); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update external vehicle",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "External vehicle updated successfully",
+		"data":    identity,
+	})
+}
+
+// ConfirmExternalVehicle accepts a link proposed by the plate matcher
+func (h *ExternalVehicleHandler) ConfirmExternalVehicle(c *fiber.Ctx) error {
+	identity, err := h.findIdentity(c)
+	if identity == nil {
+		return err
+	}
+
+	if identity.Status != Models.ExternalIdentityProposed {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Only proposed external vehicles can be confirmed",
+		})
+	}
+
+	identity.Status = Models.ExternalIdentityConfirmed
+	if err := Models.SaveExternalVehicle(h.DB, identity); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to confirm external vehicle",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "External vehicle confirmed",
+		"data":    identity,
+	})
+}
+
+// DeleteExternalVehicle removes a link, rejecting it when it was only proposed. The external
+// vehicle shows as unmatched again.
+func (h *ExternalVehicleHandler) DeleteExternalVehicle(c *fiber.Ctx) error {
+	identity, err := h.findIdentity(c)
+	if identity == nil {
+		return err
+	}
+
+	if err := h.DB.Unscoped().Delete(identity).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete external vehicle",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "External vehicle deleted successfully",
+	})
+}
+
+// GetUnmatchedVehicles lists the PetroApp vehicles with records but no confirmed car, with the
+// cars their plate matches
+func (h *ExternalVehicleHandler) GetUnmatchedVehicles(c *fiber.Ctx) error {
+	vehicles, err := Models.UnmatchedPetroAppVehicles(h.DB)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch unmatched vehicles",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Unmatched vehicles retrieved successfully",
+		"data":    vehicles,
+	})
+}
+
+// MatchUnmatchedVehicles runs the plate matcher over the unmatched PetroApp vehicles and
+// returns the links it proposed
+func (h *ExternalVehicleHandler) MatchUnmatchedVehicles(c *fiber.Ctx) error {
+	vehicles, err := Models.UnmatchedPetroAppVehicles(h.DB)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch unmatched vehicles",
+			"error":   err.Error(),
+		})
+	}
+
+	proposals := []Models.ExternalVehicleIdentity{}
+	for _, vehicle := range vehicles {
+		if vehicle.Proposal != nil {
+			continue
+		}
+		identity, err := Models.ProposeExternalVehicle(h.DB, vehicle.Source, vehicle.ExternalID, vehicle.ExternalPlate)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to match vehicle",
+				"error":   err.Error(),
+			})
+		}
+		if identity != nil {
+			proposals = append(proposals, *identity)
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Unmatched vehicles matched successfully",
+		"data":    proposals,
+	})
+}
+
+func (h *ExternalVehicleHandler) findIdentity(c *fiber.Ctx) (*Models.ExternalVehicleIdentity, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var identity Models.ExternalVehicleIdentity
+	if err := h.DB.First(&identity, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "External vehicle not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch external vehicle",
+			"error":   err.Error(),
+		})
+	}
+
+	return &identity, nil
+}
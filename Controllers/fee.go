@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -101,12 +102,21 @@ func (h *FeeMappingHandler) CreateFeeMapping(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create the new mapping
-	result = h.DB.Create(&mapping)
-	if result.Error != nil {
+	// Create the new mapping with its opening rate
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(mapping).Error; err != nil {
+			return err
+		}
+		return tx.Create(&Models.FeeMappingRate{
+			FeeMappingID: mapping.ID,
+			Fee:          mapping.Fee,
+			Distance:     mapping.Distance,
+		}).Error
+	})
+	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to create fee mapping",
-			"error":   result.Error.Error(),
+			"error":   err.Error(),
 		})
 	}
 
@@ -192,8 +202,6 @@ func (h *FeeMappingHandler) UpdateFeeMapping(c *fiber.Ctx) error {
 	if updatedMapping.DropOffPoint != "" {
 		existingMapping.DropOffPoint = updatedMapping.DropOffPoint
 	}
-	existingMapping.Distance = updatedMapping.Distance
-	existingMapping.Fee = updatedMapping.Fee
 
 	result = h.DB.Save(&existingMapping)
 	if result.Error != nil {
@@ -203,12 +211,120 @@ func (h *FeeMappingHandler) UpdateFeeMapping(c *fiber.Ctx) error {
 		})
 	}
 
+	// Fee and distance changes take effect today, earlier trips keep their old rate
+	if updatedMapping.Fee != existingMapping.Fee || updatedMapping.Distance != existingMapping.Distance {
+		today := time.Now().Format("2006-01-02")
+		if _, err := Models.ScheduleFeeMappingRate(h.DB, &existingMapping, updatedMapping.Fee, updatedMapping.Distance, today); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to update fee mapping rate",
+				"error":   err.Error(),
+			})
+		}
+	}
+
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"message": "Fee mapping updated successfully",
 		"data":    existingMapping,
 	})
 }
 
+// GetFeeMappingRates returns the rate history of a fee mapping, oldest first
+func (h *FeeMappingHandler) GetFeeMappingRates(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var mapping Models.FeeMapping
+	if err := h.DB.First(&mapping, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Fee mapping not found",
+			})
+		}
+
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch fee mapping",
+			"error":   err.Error(),
+		})
+	}
+
+	var rates []Models.FeeMappingRate
+	if err := h.DB.Where("fee_mapping_id = ?", mapping.ID).Order("valid_from ASC").Find(&rates).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch fee mapping rates",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Fee mapping rates retrieved successfully",
+		"mapping": mapping,
+		"data":    rates,
+	})
+}
+
+// ScheduleFeeMappingRate records a fee and distance taking effect on a given date,
+// which may be in the past to correct history or in the future to schedule a change
+func (h *FeeMappingHandler) ScheduleFeeMappingRate(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var mapping Models.FeeMapping
+	if err := h.DB.First(&mapping, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Fee mapping not found",
+			})
+		}
+
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch fee mapping",
+			"error":   err.Error(),
+		})
+	}
+
+	var input struct {
+		Fee       float64 `json:"fee"`
+		Distance  float64 `json:"distance"`
+		ValidFrom string  `json:"valid_from"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if _, err := time.Parse("2006-01-02", input.ValidFrom); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "valid_from must be a date in YYYY-MM-DD format",
+		})
+	}
+
+	rate, err := Models.ScheduleFeeMappingRate(h.DB, &mapping, input.Fee, input.Distance, input.ValidFrom)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to schedule fee mapping rate",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Fee mapping rate scheduled successfully",
+		"data":    rate,
+		"mapping": mapping,
+	})
+}
+
 // DeleteFeeMapping deletes a fee mapping
 func (h *FeeMappingHandler) DeleteFeeMapping(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
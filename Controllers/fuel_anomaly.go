@@ -0,0 +1,201 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// FuelAnomalyHandler contains handler methods for the review queue of suspicious fill-ups
+type FuelAnomalyHandler struct {
+	DB *gorm.DB
+}
+
+// NewFuelAnomalyHandler creates a new fuel anomaly handler
+func NewFuelAnomalyHandler(db *gorm.DB) *FuelAnomalyHandler {
+	return &FuelAnomalyHandler{
+		DB: db,
+	}
+}
+
+// GetFuelAnomalies lists the suspicious fill-ups, pending ones by default
+func (h *FuelAnomalyHandler) GetFuelAnomalies(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.FuelAnomaly{}).Where("status = ?", c.Query("status", Models.FuelAnomalyPending))
+
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kinds LIKE ?", "%"+kind+"%")
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("date >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("date <= ?", endDate)
+	}
+
+	var anomalies []Models.FuelAnomaly
+	if err := query.Order("date DESC, car_no_plate ASC").Find(&anomalies).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch fuel anomalies",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Fuel anomalies retrieved successfully",
+		"data":    anomalies,
+	})
+}
+
+// GetFuelAnomaly returns a suspicious fill-up with its fuel event
+func (h *FuelAnomalyHandler) GetFuelAnomaly(c *fiber.Ctx) error {
+	anomaly, err := h.findAnomaly(c)
+	if anomaly == nil {
+		return err
+	}
+
+	var fuelEvent Models.FuelEvent
+	if err := h.DB.Limit(1).Find(&fuelEvent, anomaly.FuelEventID).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch fuel event",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Fuel anomaly retrieved successfully",
+		"data": fiber.Map{
+			"anomaly":    anomaly,
+			"fuel_event": fuelEvent,
+		},
+	})
+}
+
+// ApproveFuelAnomaly marks a suspicious fill-up as legitimate
+func (h *FuelAnomalyHandler) ApproveFuelAnomaly(c *fiber.Ctx) error {
+	return h.review(c, Models.FuelAnomalyApproved)
+}
+
+// RejectFuelAnomaly confirms a suspicious fill-up, leaving it out of the car's baseline consumption
+func (h *FuelAnomalyHandler) RejectFuelAnomaly(c *fiber.Ctx) error {
+	return h.review(c, Models.FuelAnomalyRejected)
+}
+
+func (h *FuelAnomalyHandler) review(c *fiber.Ctx, status string) error {
+	anomaly, err := h.findAnomaly(c)
+	if anomaly == nil {
+		return err
+	}
+
+	if anomaly.Status != Models.FuelAnomalyPending {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Only pending fuel anomalies can be reviewed",
+		})
+	}
+
+	var input struct {
+		Note string `json:"note"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	now := time.Now()
+	anomaly.Status = status
+	anomaly.ReviewNote = input.Note
+	anomaly.ReviewedAt = &now
+	if user, ok := c.Locals("user").(Models.User); ok {
+		anomaly.ReviewedBy = user.Name
+	}
+	if err := h.DB.Save(anomaly).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to review fuel anomaly",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Fuel anomaly " + status,
+		"data":    anomaly,
+	})
+}
+
+// ScanFuelAnomalies checks the fill-ups of a date range again, queueing those that look
+// suspicious. Reviewed anomalies are left as they are.
+func (h *FuelAnomalyHandler) ScanFuelAnomalies(c *fiber.Ctx) error {
+	from, to, err := eventRange(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+
+	var fuelEvents []Models.FuelEvent
+	if err := h.DB.Where("date >= ? AND date < ?", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("date ASC, id ASC").Find(&fuelEvents).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch fuel events",
+			"error":   err.Error(),
+		})
+	}
+
+	anomalies := []Models.FuelAnomaly{}
+	for _, fuelEvent := range fuelEvents {
+		anomaly, err := Models.CheckFuelEvent(h.DB, fuelEvent)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to check fuel event",
+				"error":   err.Error(),
+			})
+		}
+		if anomaly != nil {
+			anomalies = append(anomalies, *anomaly)
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Fuel events checked successfully",
+		"data": fiber.Map{
+			"checked":   len(fuelEvents),
+			"anomalies": anomalies,
+		},
+	})
+}
+
+func (h *FuelAnomalyHandler) findAnomaly(c *fiber.Ctx) (*Models.FuelAnomaly, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var anomaly Models.FuelAnomaly
+	if err := h.DB.First(&anomaly, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Fuel anomaly not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch fuel anomaly",
+			"error":   err.Error(),
+		})
+	}
+
+	return &anomaly, nil
+}
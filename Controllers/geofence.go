@@ -0,0 +1,214 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GeofenceHandler contains handler methods for geofence routes
+type GeofenceHandler struct {
+	DB *gorm.DB
+}
+
+// NewGeofenceHandler creates a new geofence handler
+func NewGeofenceHandler(db *gorm.DB) *GeofenceHandler {
+	return &GeofenceHandler{
+		DB: db,
+	}
+}
+
+// GetGeofences returns the geofences, optionally of one type or company
+func (h *GeofenceHandler) GetGeofences(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.Geofence{})
+
+	if geofenceType := c.Query("type"); geofenceType != "" {
+		query = query.Where("type = ?", geofenceType)
+	}
+	if company := c.Query("company"); company != "" {
+		query = query.Where("company = ? OR company = ''", company)
+	}
+
+	var geofences []Models.Geofence
+	if err := query.Order("type ASC, priority ASC, name ASC").Find(&geofences).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch geofences",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Geofences retrieved successfully",
+		"data":    geofences,
+	})
+}
+
+// GetGeofence returns a single geofence
+func (h *GeofenceHandler) GetGeofence(c *fiber.Ctx) error {
+	geofence, err := h.findGeofence(c)
+	if geofence == nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Geofence retrieved successfully",
+		"data":    geofence,
+	})
+}
+
+// CreateGeofence creates a circle or polygon geofence, enabled unless stated otherwise
+func (h *GeofenceHandler) CreateGeofence(c *fiber.Ctx) error {
+	geofence := Models.Geofence{Enabled: true}
+	if err := c.BodyParser(&geofence); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := geofence.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid geofence",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := h.DB.Create(&geofence).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create geofence",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Geofence created successfully",
+		"data":    geofence,
+	})
+}
+
+// UpdateGeofence updates the fields of a geofence present in the request body
+func (h *GeofenceHandler) UpdateGeofence(c *fiber.Ctx) error {
+	geofence, err := h.findGeofence(c)
+	if geofence == nil {
+		return err
+	}
+
+	id := geofence.ID
+	if err := c.BodyParser(geofence); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	geofence.ID = id
+
+	if err := geofence.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid geofence",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := h.DB.Save(geofence).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update geofence",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Geofence updated successfully",
+		"data":    geofence,
+	})
+}
+
+// DeleteGeofence deletes a geofence
+func (h *GeofenceHandler) DeleteGeofence(c *fiber.Ctx) error {
+	geofence, err := h.findGeofence(c)
+	if geofence == nil {
+		return err
+	}
+
+	if err := h.DB.Delete(geofence).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete geofence",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Geofence deleted successfully",
+	})
+}
+
+// CheckGeofence returns the geofence a car of the company would be reported in at a
+// coordinate and time, to try out fence changes
+func (h *GeofenceHandler) CheckGeofence(c *fiber.Ctx) error {
+	var input struct {
+		Latitude  float64 `json:"lat"`
+		Longitude float64 `json:"lng"`
+		Company   string  `json:"company"`
+		At        string  `json:"at"` // "2006-01-02 15:04:05", now when empty
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	at := Models.WallClockNow()
+	if input.At != "" {
+		parsed, err := time.Parse(Models.PositionTimeLayout, input.At)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid time",
+				"error":   err.Error(),
+			})
+		}
+		at = parsed
+	}
+
+	geofences, err := Models.LoadGeofences(h.DB)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch geofences",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Geofence checked successfully",
+		"data":    Models.MatchGeofence(geofences, input.Company, input.Latitude, input.Longitude, at),
+	})
+}
+
+func (h *GeofenceHandler) findGeofence(c *fiber.Ctx) (*Models.Geofence, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var geofence Models.Geofence
+	if err := h.DB.First(&geofence, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Geofence not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch geofence",
+			"error":   err.Error(),
+		})
+	}
+
+	return &geofence, nil
+}
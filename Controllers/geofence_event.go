@@ -0,0 +1,230 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GeofenceEventHandler contains handler methods for the geofence event log and dwell analytics
+type GeofenceEventHandler struct {
+	DB *gorm.DB
+}
+
+// NewGeofenceEventHandler creates a new geofence event handler
+func NewGeofenceEventHandler(db *gorm.DB) *GeofenceEventHandler {
+	return &GeofenceEventHandler{
+		DB: db,
+	}
+}
+
+// maxEventRange bounds the date range of a single geofence event request
+const maxEventRange = 92 * 24 * time.Hour
+
+// defaultOutlierHours is the dwell time from which a stay is reported as an outlier
+const defaultOutlierHours = 4.0
+
+// eventRange parses the start_date and end_date query parameters, both inclusive. The
+// last 7 days are used when they are missing.
+func eventRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	from, to := today.AddDate(0, 0, -6), today.AddDate(0, 0, 1)
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		parsed, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid start_date %q, expected YYYY-MM-DD", startDate)
+		}
+		from = parsed
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		parsed, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid end_date %q, expected YYYY-MM-DD", endDate)
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	if !to.After(from) || to.Sub(from) > maxEventRange {
+		return from, to, fmt.Errorf("end_date must follow start_date by at most 92 days")
+	}
+	return from, to, nil
+}
+
+func (h *GeofenceEventHandler) filteredEvents(c *fiber.Ctx) *gorm.DB {
+	query := h.DB.Model(&Models.GeofenceEvent{})
+
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+	if company := c.Query("company"); company != "" {
+		query = query.Where("company = ?", company)
+	}
+	if geofence := c.Query("geofence"); geofence != "" {
+		query = query.Where("geofence_name = ?", geofence)
+	}
+	if geofenceType := c.Query("type"); geofenceType != "" {
+		query = query.Where("geofence_type = ?", geofenceType)
+	}
+	return query
+}
+
+// loadVisits returns the visits matching the request filters, nil with the error response
+// sent when the request is invalid
+func (h *GeofenceEventHandler) loadVisits(c *fiber.Ctx) ([]Models.GeofenceVisit, error) {
+	from, to, err := eventRange(c)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+
+	visits, err := Models.LoadGeofenceVisits(h.filteredEvents(c), from, to)
+	if err != nil {
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch geofence events",
+			"error":   err.Error(),
+		})
+	}
+	return visits, nil
+}
+
+// GetGeofenceEvents returns the logged enter and exit events, newest first
+func (h *GeofenceEventHandler) GetGeofenceEvents(c *fiber.Ctx) error {
+	from, to, err := eventRange(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+
+	var events []Models.GeofenceEvent
+	if err := h.filteredEvents(c).Where("recorded_at >= ? AND recorded_at < ?", from, to).
+		Order("recorded_at DESC").Find(&events).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch geofence events",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Geofence events retrieved successfully",
+		"data":    events,
+	})
+}
+
+// GetGeofenceVisits returns the stays paired from the enter and exit events
+func (h *GeofenceEventHandler) GetGeofenceVisits(c *fiber.Ctx) error {
+	visits, err := h.loadVisits(c)
+	if visits == nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Geofence visits retrieved successfully",
+		"data":    visits,
+	})
+}
+
+// GetDwellTimes returns the average dwell time per geofence, terminals and drop-offs unless
+// a type is requested
+func (h *GeofenceEventHandler) GetDwellTimes(c *fiber.Ctx) error {
+	visits, err := h.loadVisits(c)
+	if visits == nil {
+		return err
+	}
+
+	if c.Query("type") == "" {
+		visits = filterVisits(visits, Models.GeofenceTerminal, Models.GeofenceDropOff)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Dwell times retrieved successfully",
+		"data": Models.SummarizeDwell(visits, func(v Models.GeofenceVisit) string {
+			return v.GeofenceName
+		}),
+	})
+}
+
+// GetTerminalQueueTimes returns the time trucks spend at terminals per company, overall
+// and per terminal
+func (h *GeofenceEventHandler) GetTerminalQueueTimes(c *fiber.Ctx) error {
+	visits, err := h.loadVisits(c)
+	if visits == nil {
+		return err
+	}
+	visits = filterVisits(visits, Models.GeofenceTerminal)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Terminal queue times retrieved successfully",
+		"data": fiber.Map{
+			"per_company": Models.SummarizeDwell(visits, func(v Models.GeofenceVisit) string {
+				return v.Company
+			}),
+			"per_terminal": Models.SummarizeDwell(visits, func(v Models.GeofenceVisit) string {
+				return v.Company + " / " + v.GeofenceName
+			}),
+		},
+	})
+}
+
+// GetTurnaroundTimes returns the time each truck takes between consecutive terminal arrivals
+func (h *GeofenceEventHandler) GetTurnaroundTimes(c *fiber.Ctx) error {
+	visits, err := h.loadVisits(c)
+	if visits == nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Turnaround times retrieved successfully",
+		"data":    Models.SummarizeTurnaround(visits),
+	})
+}
+
+// GetDwellOutliers returns the stays longer than min_hours, drop-offs unless a type is
+// requested, as evidence for demurrage disputes
+func (h *GeofenceEventHandler) GetDwellOutliers(c *fiber.Ctx) error {
+	minHours := defaultOutlierHours
+	if value := c.Query("min_hours"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid min_hours",
+			})
+		}
+		minHours = parsed
+	}
+
+	visits, err := h.loadVisits(c)
+	if visits == nil {
+		return err
+	}
+	if c.Query("type") == "" {
+		visits = filterVisits(visits, Models.GeofenceDropOff)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Dwell outliers retrieved successfully",
+		"data":    Models.DwellOutliers(visits, time.Duration(minHours*float64(time.Hour))),
+	})
+}
+
+func filterVisits(visits []Models.GeofenceVisit, geofenceTypes ...string) []Models.GeofenceVisit {
+	filtered := []Models.GeofenceVisit{}
+	for _, visit := range visits {
+		for _, geofenceType := range geofenceTypes {
+			if visit.GeofenceType == geofenceType {
+				filtered = append(filtered, visit)
+				break
+			}
+		}
+	}
+	return filtered
+}
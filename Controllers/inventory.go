@@ -0,0 +1,547 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// InventoryHandler contains handler methods for the spare parts catalogue, stock locations,
+// stock movements and the stock reports
+type InventoryHandler struct {
+	DB *gorm.DB
+}
+
+// NewInventoryHandler creates a new inventory handler
+func NewInventoryHandler(db *gorm.DB) *InventoryHandler {
+	return &InventoryHandler{
+		DB: db,
+	}
+}
+
+// StockMovementInput is the body of purchases, issues, adjustments and transfers
+type StockMovementInput struct {
+	PartID         uint    `json:"part_id"`
+	LocationID     uint    `json:"location_id"`
+	ToLocationID   uint    `json:"to_location_id"` // Transfers only
+	Quantity       float64 `json:"quantity"`       // Signed for adjustments
+	UnitCost       float64 `json:"unit_cost"`      // Purchases, and stock found by adjustments
+	Date           string  `json:"date"`           // YYYY-MM-DD, today when empty
+	VendorID       *uint   `json:"vendor_id"`
+	WorkOrderID    *uint   `json:"work_order_id"`
+	TirePositionID *uint   `json:"tire_position_id"`
+	Reference      string  `json:"reference"`
+	Notes          string  `json:"notes"`
+}
+
+// GetParts returns the parts catalogue, optionally of one category
+func (h *InventoryHandler) GetParts(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.Part{})
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("part_number LIKE ? OR name LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	var parts []Models.Part
+	if err := query.Order("part_number ASC").Find(&parts).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch parts",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Parts retrieved successfully",
+		"data":    parts,
+	})
+}
+
+// CreatePart adds a part to the catalogue
+func (h *InventoryHandler) CreatePart(c *fiber.Ctx) error {
+	var part Models.Part
+	if err := c.BodyParser(&part); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := part.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid part",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := h.DB.Create(&part).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create part",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Part created successfully",
+		"data":    part,
+	})
+}
+
+// UpdatePart updates the fields of a part present in the request body
+func (h *InventoryHandler) UpdatePart(c *fiber.Ctx) error {
+	part, err := h.findPart(c)
+	if part == nil {
+		return err
+	}
+
+	id := part.ID
+	if err := c.BodyParser(part); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	part.ID = id
+
+	if err := part.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid part",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := h.DB.Save(part).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update part",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Part updated successfully",
+		"data":    part,
+	})
+}
+
+// DeletePart removes a part that never moved in or out of stock
+func (h *InventoryHandler) DeletePart(c *fiber.Ctx) error {
+	part, err := h.findPart(c)
+	if part == nil {
+		return err
+	}
+
+	if h.hasMovements("part_id", part.ID) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "The part has stock movements and cannot be deleted",
+		})
+	}
+	// Unscoped as nothing refers to it, so the name can be used again
+	if err := h.DB.Unscoped().Delete(part).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete part",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Part deleted successfully",
+	})
+}
+
+// GetStockLocations returns the stock locations
+func (h *InventoryHandler) GetStockLocations(c *fiber.Ctx) error {
+	var locations []Models.StockLocation
+	if err := h.DB.Order("name ASC").Find(&locations).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch stock locations",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Stock locations retrieved successfully",
+		"data":    locations,
+	})
+}
+
+// CreateStockLocation adds a garage or store parts are kept in
+func (h *InventoryHandler) CreateStockLocation(c *fiber.Ctx) error {
+	var location Models.StockLocation
+	if err := c.BodyParser(&location); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	if location.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "name is required",
+		})
+	}
+
+	if err := h.DB.Create(&location).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create stock location",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Stock location created successfully",
+		"data":    location,
+	})
+}
+
+// UpdateStockLocation updates the fields of a location present in the request body
+func (h *InventoryHandler) UpdateStockLocation(c *fiber.Ctx) error {
+	location, err := h.findStockLocation(c)
+	if location == nil {
+		return err
+	}
+
+	id := location.ID
+	if err := c.BodyParser(location); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	location.ID = id
+	if location.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "name is required",
+		})
+	}
+
+	if err := h.DB.Save(location).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update stock location",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Stock location updated successfully",
+		"data":    location,
+	})
+}
+
+// DeleteStockLocation removes a location that never held stock
+func (h *InventoryHandler) DeleteStockLocation(c *fiber.Ctx) error {
+	location, err := h.findStockLocation(c)
+	if location == nil {
+		return err
+	}
+
+	if h.hasMovements("location_id", location.ID) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "The location has stock movements and cannot be deleted",
+		})
+	}
+	// Unscoped as nothing refers to it, so the name can be used again
+	if err := h.DB.Unscoped().Delete(location).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete stock location",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Stock location deleted successfully",
+	})
+}
+
+// GetStockMovements returns the stock movements, newest first, optionally of one part,
+// location, type or work order
+func (h *InventoryHandler) GetStockMovements(c *fiber.Ctx) error {
+	from, to, err := costRange(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+
+	query := h.DB.Model(&Models.StockMovement{}).Preload("Part").Preload("Location")
+	for _, filter := range []string{"part_id", "location_id", "type", "work_order_id"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("date < ?", to)
+	}
+
+	var movements []Models.StockMovement
+	if err := query.Order("date DESC, id DESC").Find(&movements).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch stock movements",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Stock movements retrieved successfully",
+		"data":    movements,
+	})
+}
+
+// PurchaseStock receives parts bought from a vendor, recording the purchase on the vendor's
+// transactions
+func (h *InventoryHandler) PurchaseStock(c *fiber.Ctx) error {
+	return h.moveStock(c, "purchase", func(movement *Models.StockMovement, _ StockMovementInput) (interface{}, error) {
+		return movement, Models.PurchaseStock(h.DB, movement)
+	})
+}
+
+// IssueStock takes parts out of stock for a work order or a tire position
+func (h *InventoryHandler) IssueStock(c *fiber.Ctx) error {
+	return h.moveStock(c, "issue", func(movement *Models.StockMovement, _ StockMovementInput) (interface{}, error) {
+		return movement, Models.IssueStock(h.DB, movement)
+	})
+}
+
+// AdjustStock corrects the stock of a part after a count
+func (h *InventoryHandler) AdjustStock(c *fiber.Ctx) error {
+	return h.moveStock(c, "adjustment", func(movement *Models.StockMovement, _ StockMovementInput) (interface{}, error) {
+		return movement, Models.AdjustStock(h.DB, movement)
+	})
+}
+
+// TransferStock moves parts from one location to another
+func (h *InventoryHandler) TransferStock(c *fiber.Ctx) error {
+	return h.moveStock(c, "transfer", func(movement *Models.StockMovement, input StockMovementInput) (interface{}, error) {
+		in, err := Models.TransferStock(h.DB, movement, input.ToLocationID)
+		return fiber.Map{"out": movement, "in": in}, err
+	})
+}
+
+// GetStockLevels returns the stock of each part in each location, valued at average cost
+func (h *InventoryHandler) GetStockLevels(c *fiber.Ctx) error {
+	levels, err := h.stockLevels(time.Time{})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to compute stock levels",
+			"error":   err.Error(),
+		})
+	}
+
+	partID, _ := strconv.ParseUint(c.Query("part_id"), 10, 64)
+	locationID, _ := strconv.ParseUint(c.Query("location_id"), 10, 64)
+	filtered := make([]Models.StockLevel, 0, len(levels))
+	for _, level := range levels {
+		if (partID == 0 || level.PartID == uint(partID)) && (locationID == 0 || level.LocationID == uint(locationID)) {
+			filtered = append(filtered, level)
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Stock levels retrieved successfully",
+		"data":    filtered,
+	})
+}
+
+// GetReorderList returns the parts whose stock fell to their reorder level
+func (h *InventoryHandler) GetReorderList(c *fiber.Ctx) error {
+	balances, err := Models.LoadStockBalances(h.DB, time.Time{})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to compute stock levels",
+			"error":   err.Error(),
+		})
+	}
+	var parts []Models.Part
+	if err := h.DB.Order("part_number ASC").Find(&parts).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch parts",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Reorder list retrieved successfully",
+		"data":    Models.ReorderList(parts, balances),
+	})
+}
+
+// GetStockValuation returns the value of the stock at the end of a date, today by default,
+// per location and in total
+func (h *InventoryHandler) GetStockValuation(c *fiber.Ctx) error {
+	asOf := time.Now()
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid date, expected YYYY-MM-DD",
+				"error":   err.Error(),
+			})
+		}
+		asOf = parsed
+	}
+
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	levels, err := h.stockLevels(day.AddDate(0, 0, 1))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to compute stock levels",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Stock valuation retrieved successfully",
+		"data":    Models.ValueStock(levels, day),
+	})
+}
+
+// moveStock parses a movement from the body and records it with move
+func (h *InventoryHandler) moveStock(c *fiber.Ctx, kind string, move func(*Models.StockMovement, StockMovementInput) (interface{}, error)) error {
+	var input StockMovementInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	if input.PartID == 0 || input.LocationID == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "part_id and location_id are required",
+		})
+	}
+
+	date := time.Now()
+	if input.Date != "" {
+		parsed, err := time.Parse("2006-01-02", input.Date)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid date, expected YYYY-MM-DD",
+				"error":   err.Error(),
+			})
+		}
+		date = parsed
+	}
+
+	movement := Models.StockMovement{
+		PartID:         input.PartID,
+		LocationID:     input.LocationID,
+		Date:           date,
+		Quantity:       input.Quantity,
+		UnitCost:       input.UnitCost,
+		VendorID:       input.VendorID,
+		WorkOrderID:    input.WorkOrderID,
+		TirePositionID: input.TirePositionID,
+		Reference:      input.Reference,
+		Notes:          input.Notes,
+	}
+	if user, ok := c.Locals("user").(Models.User); ok {
+		movement.CreatedBy = user.Name
+	}
+
+	data, err := move(&movement, input)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("Failed to record stock %s", kind),
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": fmt.Sprintf("Stock %s recorded successfully", kind),
+		"data":    data,
+	})
+}
+
+// stockLevels names and values the stock balances up to a time, the current stock when
+// before is zero
+func (h *InventoryHandler) stockLevels(before time.Time) ([]Models.StockLevel, error) {
+	balances, err := Models.LoadStockBalances(h.DB, before)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []Models.Part
+	if err := h.DB.Unscoped().Find(&parts).Error; err != nil {
+		return nil, err
+	}
+	partsByID := make(map[uint]Models.Part, len(parts))
+	for _, part := range parts {
+		partsByID[part.ID] = part
+	}
+	var locations []Models.StockLocation
+	if err := h.DB.Unscoped().Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	locationsByID := make(map[uint]Models.StockLocation, len(locations))
+	for _, location := range locations {
+		locationsByID[location.ID] = location
+	}
+
+	return Models.StockLevels(balances, partsByID, locationsByID), nil
+}
+
+func (h *InventoryHandler) hasMovements(column string, id uint) bool {
+	var count int64
+	h.DB.Model(&Models.StockMovement{}).Where(column+" = ?", id).Count(&count)
+	return count > 0
+}
+
+func (h *InventoryHandler) findPart(c *fiber.Ctx) (*Models.Part, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var part Models.Part
+	if err := h.DB.First(&part, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Part not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch part",
+			"error":   err.Error(),
+		})
+	}
+
+	return &part, nil
+}
+
+func (h *InventoryHandler) findStockLocation(c *fiber.Ctx) (*Models.StockLocation, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var location Models.StockLocation
+	if err := h.DB.First(&location, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Stock location not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch stock location",
+			"error":   err.Error(),
+		})
+	}
+
+	return &location, nil
+}
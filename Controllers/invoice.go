@@ -0,0 +1,632 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// InvoiceHandler contains handler methods for customer invoice routes
+type InvoiceHandler struct {
+	DB *gorm.DB
+}
+
+// NewInvoiceHandler creates a new invoice handler
+func NewInvoiceHandler(db *gorm.DB) *InvoiceHandler {
+	return &InvoiceHandler{
+		DB: db,
+	}
+}
+
+// BuildInvoice prices the trips not yet invoiced for a company over a period and
+// freezes them into a draft invoice, without saving it
+func (e *PricingEngine) BuildInvoice(company, startDate, endDate string) (*Models.Invoice, error) {
+	trips, err := e.LoadTrips(company, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Trips already billed by another invoice are left out
+	var invoicedIDs []uint
+	err = e.DB.Model(&Models.InvoiceTrip{}).
+		Joins("JOIN invoices ON invoices.id = invoice_trips.invoice_id").
+		Where("invoice_trips.credit_note_id IS NULL AND invoices.type = ? AND invoices.status <> ?",
+			Models.InvoiceTypeInvoice, Models.InvoiceCancelled).
+		Pluck("invoice_trips.trip_id", &invoicedIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	invoiced := make(map[uint]bool, len(invoicedIDs))
+	for _, id := range invoicedIDs {
+		invoiced[id] = true
+	}
+
+	var billable []Models.TripStruct
+	for _, trip := range trips {
+		if !invoiced[trip.ID] {
+			billable = append(billable, trip)
+		}
+	}
+
+	if len(billable) == 0 {
+		return nil, fmt.Errorf("no uninvoiced trips for %s between %s and %s", company, startDate, endDate)
+	}
+
+	priced, err := e.Price(company, billable)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice := &Models.Invoice{
+		Type:        Models.InvoiceTypeInvoice,
+		Status:      Models.InvoiceDraft,
+		Company:     company,
+		PeriodStart: startDate,
+		PeriodEnd:   endDate,
+	}
+
+	grouping := priced.Contract.RouteGrouping
+	if grouping == "" {
+		grouping = Models.GroupByRoute
+	}
+
+	for _, group := range priced.Group(priced.Units, grouping) {
+		summary := priced.Summarize(group.Units)
+
+		line := Models.InvoiceLine{
+			LineType:     Models.InvoiceLineTrips,
+			Description:  invoiceLineDescription(grouping, group),
+			Terminal:     group.Terminal,
+			DropOffPoint: group.DropOffPoint,
+			Trips:        summary.TotalTrips,
+			Volume:       summary.TotalVolume,
+			Distance:     summary.TotalDistance,
+			Rate:         group.Rate,
+		}
+
+		for _, unit := range group.Units {
+			line.Amount += unit.Revenue
+			line.VAT += unit.Revenue * unit.VATRate
+			line.InvoiceTrips = append(line.InvoiceTrips, invoiceTrips(unit)...)
+		}
+
+		line.Amount = roundMoney(line.Amount)
+		line.VAT = roundMoney(line.VAT)
+		invoice.Lines = append(invoice.Lines, line)
+	}
+
+	// Car rent is owed per car and month, following the contract's rental rule
+	rents, err := e.monthRents(company, priced.carMonthRents(), endDate)
+	if err != nil {
+		return nil, err
+	}
+	billed, err := e.billedRents(company)
+	if err != nil {
+		return nil, err
+	}
+
+	months := make([]Models.CarRentMonth, 0, len(priced.carMonthRents()))
+	for month := range priced.carMonthRents() {
+		months = append(months, month)
+	}
+	sort.Slice(months, func(i, j int) bool {
+		if months[i].CarNoPlate != months[j].CarNoPlate {
+			return months[i].CarNoPlate < months[j].CarNoPlate
+		}
+		return months[i].Month < months[j].Month
+	})
+
+	for _, month := range months {
+		rent := rents[month]
+		if rent == nil {
+			continue
+		}
+		amount := roundMoney(rent.Amount - billed[month])
+		if amount <= 0 {
+			continue
+		}
+
+		var trips int64
+		for _, count := range priced.carMonthRents()[month].Trips {
+			trips += count
+		}
+		invoice.Lines = append(invoice.Lines, Models.InvoiceLine{
+			LineType:    Models.InvoiceLineCarRental,
+			Description: fmt.Sprintf("Car rent %s for %s (%d working days)", month.CarNoPlate, month.Month, len(rent.Trips)),
+			CarNoPlate:  month.CarNoPlate,
+			RentMonth:   month.Month,
+			Trips:       trips,
+			Amount:      amount,
+			VAT:         roundMoney(amount * rent.VATRate),
+		})
+	}
+
+	invoice.Totalize()
+	return invoice, nil
+}
+
+// monthRents prices the rent of the given car months from all the company's trips of each
+// month up to endDate, so a month invoiced over several periods adds up to one month's rent
+func (e *PricingEngine) monthRents(company string, months map[Models.CarRentMonth]*carMonthRent, endDate string) (map[Models.CarRentMonth]*carMonthRent, error) {
+	from := endDate
+	for month := range months {
+		if month.Month+"-01" < from {
+			from = month.Month + "-01"
+		}
+	}
+
+	trips, err := e.LoadTrips(company, from, endDate)
+	if err != nil {
+		return nil, err
+	}
+	priced, err := e.Price(company, trips)
+	if err != nil {
+		return nil, err
+	}
+	return priced.carMonthRents(), nil
+}
+
+// billedRents returns the rent invoices already billed each car month of a company, net of
+// the credit notes reversing it
+func (e *PricingEngine) billedRents(company string) (map[Models.CarRentMonth]float64, error) {
+	var rows []struct {
+		CarNoPlate string
+		RentMonth  string
+		Amount     float64
+	}
+	err := e.DB.Model(&Models.InvoiceLine{}).
+		Select("invoice_lines.car_no_plate, invoice_lines.rent_month, SUM(invoice_lines.amount) AS amount").
+		Joins("JOIN invoices ON invoices.id = invoice_lines.invoice_id AND invoices.deleted_at IS NULL").
+		Where("invoices.company = ? AND invoices.status <> ? AND invoice_lines.rent_month <> ''", company, Models.InvoiceCancelled).
+		Group("invoice_lines.car_no_plate, invoice_lines.rent_month").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	billed := make(map[Models.CarRentMonth]float64, len(rows))
+	for _, row := range rows {
+		billed[Models.CarRentMonth{CarNoPlate: row.CarNoPlate, Month: row.RentMonth}] = row.Amount
+	}
+	return billed, nil
+}
+
+// invoiceTrips splits a priced unit's revenue over its trips by tank capacity
+func invoiceTrips(unit PricedUnit) []Models.InvoiceTrip {
+	trips := make([]Models.InvoiceTrip, 0, len(unit.Trips))
+	for _, trip := range unit.Trips {
+		share := 1 / float64(len(unit.Trips))
+		if unit.Volume > 0 {
+			share = float64(trip.TankCapacity) / unit.Volume
+		}
+
+		trips = append(trips, Models.InvoiceTrip{
+			TripID:       trip.ID,
+			UnitTripID:   unit.Trips[0].ID,
+			Date:         trip.Date,
+			CarNoPlate:   trip.CarNoPlate,
+			Terminal:     trip.Terminal,
+			DropOffPoint: trip.DropOffPoint,
+			Volume:       float64(trip.TankCapacity),
+			Distance:     unit.Distance,
+			Amount:       roundMoney(unit.Revenue * share),
+			VAT:          roundMoney(unit.Revenue * share * unit.VATRate),
+		})
+	}
+	return trips
+}
+
+func invoiceLineDescription(grouping string, group UnitGroup) string {
+	switch grouping {
+	case Models.GroupByFeeTier:
+		return fmt.Sprintf("Fee category %d", group.FeeTier)
+	case Models.GroupByTerminal:
+		return group.Terminal
+	case Models.GroupByDropOffPoint:
+		return group.DropOffPoint
+	default:
+		return fmt.Sprintf("%s to %s", group.Terminal, group.DropOffPoint)
+	}
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// saveInvoice numbers and stores an invoice with its lines and trips
+func saveInvoice(tx *gorm.DB, invoice *Models.Invoice) error {
+	number, err := Models.NextInvoiceNumber(tx, invoice.Type, time.Now().Year())
+	if err != nil {
+		return err
+	}
+	invoice.Number = number
+
+	lines := invoice.Lines
+	if err := tx.Omit("Lines").Create(invoice).Error; err != nil {
+		return err
+	}
+
+	for i := range lines {
+		lines[i].InvoiceID = invoice.ID
+		for j := range lines[i].InvoiceTrips {
+			lines[i].InvoiceTrips[j].InvoiceID = invoice.ID
+		}
+		if err := tx.Create(&lines[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	invoice.Lines = lines
+	return nil
+}
+
+// GetInvoices returns invoices and credit notes, optionally filtered by company, status and type
+func (h *InvoiceHandler) GetInvoices(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.Invoice{})
+
+	if company := c.Query("company"); company != "" {
+		query = query.Where("company = ?", company)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if invoiceType := c.Query("type"); invoiceType != "" {
+		query = query.Where("type = ?", invoiceType)
+	}
+
+	var invoices []Models.Invoice
+	if err := query.Order("created_at DESC").Find(&invoices).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch invoices",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Invoices retrieved successfully",
+		"data":    invoices,
+	})
+}
+
+// GetInvoice returns an invoice with its lines and trips
+func (h *InvoiceHandler) GetInvoice(c *fiber.Ctx) error {
+	invoice, err := h.findInvoice(c)
+	if invoice == nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Invoice retrieved successfully",
+		"data":    invoice,
+	})
+}
+
+// PreviewInvoice prices a company's uninvoiced trips over a period without saving the invoice
+func (h *InvoiceHandler) PreviewInvoice(c *fiber.Ctx) error {
+	company := c.Query("company")
+	startDate := c.Query("start_date")
+	endDate := c.Query("end_date")
+
+	if company == "" || startDate == "" || endDate == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "company, start_date and end_date are required",
+		})
+	}
+
+	invoice, err := NewPricingEngine(h.DB).BuildInvoice(company, startDate, endDate)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to build invoice",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Invoice preview generated successfully",
+		"data":    invoice,
+	})
+}
+
+// GenerateInvoice freezes a company's uninvoiced trips over a period into a draft invoice
+func (h *InvoiceHandler) GenerateInvoice(c *fiber.Ctx) error {
+	var input struct {
+		Company   string `json:"company"`
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		Notes     string `json:"notes"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if input.Company == "" || input.StartDate == "" || input.EndDate == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "company, start_date and end_date are required",
+		})
+	}
+
+	var invoice *Models.Invoice
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = NewPricingEngine(tx).BuildInvoice(input.Company, input.StartDate, input.EndDate)
+		if err != nil {
+			return err
+		}
+		invoice.Notes = input.Notes
+		return saveInvoice(tx, invoice)
+	})
+
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to generate invoice",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Invoice generated successfully",
+		"data":    invoice,
+	})
+}
+
+// IssueInvoice moves a draft invoice to issued
+func (h *InvoiceHandler) IssueInvoice(c *fiber.Ctx) error {
+	return h.transition(c, Models.InvoiceIssued, map[string]bool{Models.InvoiceDraft: true}, "issue_date")
+}
+
+// PayInvoice marks an issued invoice as paid
+func (h *InvoiceHandler) PayInvoice(c *fiber.Ctx) error {
+	return h.transition(c, Models.InvoicePaid, map[string]bool{Models.InvoiceIssued: true}, "paid_date")
+}
+
+// CancelInvoice cancels a draft or issued invoice, releasing its trips for invoicing again
+func (h *InvoiceHandler) CancelInvoice(c *fiber.Ctx) error {
+	return h.transition(c, Models.InvoiceCancelled, map[string]bool{Models.InvoiceDraft: true, Models.InvoiceIssued: true}, "")
+}
+
+// transition moves an invoice to a new status if its current status allows it,
+// stamping today's date on dateColumn when given
+func (h *InvoiceHandler) transition(c *fiber.Ctx, status string, from map[string]bool, dateColumn string) error {
+	invoice, err := h.findInvoice(c)
+	if invoice == nil {
+		return err
+	}
+
+	if invoice.Type == Models.InvoiceTypeCreditNote && status == Models.InvoiceCancelled {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Credit notes cannot be cancelled",
+		})
+	}
+
+	if !from[invoice.Status] {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": fmt.Sprintf("Cannot move a %s invoice to %s", invoice.Status, status),
+		})
+	}
+
+	if status == Models.InvoiceCancelled {
+		var credits int64
+		h.DB.Model(&Models.Invoice{}).Where("original_invoice_id = ?", invoice.ID).Count(&credits)
+		if credits > 0 {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"message": "Invoices with credit notes cannot be cancelled",
+			})
+		}
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if dateColumn != "" {
+		updates[dateColumn] = time.Now().Format("2006-01-02")
+	}
+
+	if err := h.DB.Model(invoice).Updates(updates).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update invoice",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Invoice updated successfully",
+		"data":    invoice,
+	})
+}
+
+// CreateCreditNote reverses trips of an issued or paid invoice so they can be
+// corrected and invoiced again. The rent of their cars' months is reversed with them.
+func (h *InvoiceHandler) CreateCreditNote(c *fiber.Ctx) error {
+	invoice, err := h.findInvoice(c)
+	if invoice == nil {
+		return err
+	}
+
+	if invoice.Type != Models.InvoiceTypeInvoice || (invoice.Status != Models.InvoiceIssued && invoice.Status != Models.InvoicePaid) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Credit notes can only be issued against issued or paid invoices",
+		})
+	}
+
+	var input struct {
+		TripIDs []uint `json:"trip_ids"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if len(input.TripIDs) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "trip_ids is required",
+		})
+	}
+
+	var invoiceTrips []Models.InvoiceTrip
+	for _, line := range invoice.Lines {
+		invoiceTrips = append(invoiceTrips, line.InvoiceTrips...)
+	}
+
+	requested := make(map[uint]bool, len(input.TripIDs))
+	for _, trip := range invoiceTrips {
+		if trip.CreditNoteID == nil {
+			requested[trip.TripID] = true
+		}
+	}
+	for _, id := range input.TripIDs {
+		if !requested[id] {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Some trips are not billed by this invoice or were already credited",
+			})
+		}
+	}
+
+	// Rent lines of invoices made before rent was billed per month cover the car's whole period
+	var rentLines []Models.InvoiceLine
+	rented := make(map[Models.CarRentMonth]bool)
+	for _, line := range invoice.Lines {
+		if line.LineType != Models.InvoiceLineCarRental {
+			continue
+		}
+		rentLines = append(rentLines, line)
+		for _, trip := range invoiceTrips {
+			if trip.CarNoPlate == line.CarNoPlate && (line.RentMonth == "" || Models.TripMonth(trip.Date) == line.RentMonth) {
+				rented[Models.CarRentMonth{CarNoPlate: trip.CarNoPlate, Month: Models.TripMonth(trip.Date)}] = true
+			}
+		}
+	}
+
+	// Whole priced units are credited, and a car month's rent with all its trips, so they are
+	// priced again together when invoiced again
+	credited := Models.CreditedInvoiceTrips(invoiceTrips, input.TripIDs, rented)
+
+	creditNote := &Models.Invoice{
+		Type:              Models.InvoiceTypeCreditNote,
+		Status:            Models.InvoiceIssued,
+		Company:           invoice.Company,
+		PeriodStart:       invoice.PeriodStart,
+		PeriodEnd:         invoice.PeriodEnd,
+		IssueDate:         time.Now().Format("2006-01-02"),
+		OriginalInvoiceID: &invoice.ID,
+		Notes:             input.Reason,
+	}
+
+	for _, trip := range credited {
+		reversal := trip
+		reversal.ID = 0
+		reversal.Amount = -trip.Amount
+		reversal.VAT = -trip.VAT
+
+		creditNote.Lines = append(creditNote.Lines, Models.InvoiceLine{
+			LineType:     Models.InvoiceLineCredit,
+			Description:  fmt.Sprintf("Reversal of trip %d on %s (%s to %s)", trip.TripID, trip.Date, trip.Terminal, trip.DropOffPoint),
+			Terminal:     trip.Terminal,
+			DropOffPoint: trip.DropOffPoint,
+			CarNoPlate:   trip.CarNoPlate,
+			Trips:        1,
+			Volume:       trip.Volume,
+			Distance:     trip.Distance,
+			Amount:       -trip.Amount,
+			VAT:          -trip.VAT,
+			InvoiceTrips: []Models.InvoiceTrip{reversal},
+		})
+	}
+	for _, rent := range rentLines {
+		reversed := false
+		for _, trip := range credited {
+			if trip.CarNoPlate == rent.CarNoPlate && (rent.RentMonth == "" || Models.TripMonth(trip.Date) == rent.RentMonth) {
+				reversed = true
+				break
+			}
+		}
+		if !reversed {
+			continue
+		}
+
+		description := fmt.Sprintf("Reversal of car rent %s", rent.CarNoPlate)
+		if rent.RentMonth != "" {
+			description += " for " + rent.RentMonth
+		}
+		creditNote.Lines = append(creditNote.Lines, Models.InvoiceLine{
+			LineType:    Models.InvoiceLineCredit,
+			Description: description,
+			CarNoPlate:  rent.CarNoPlate,
+			RentMonth:   rent.RentMonth,
+			Trips:       rent.Trips,
+			Amount:      -rent.Amount,
+			VAT:         -rent.VAT,
+		})
+	}
+	creditNote.Totalize()
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveInvoice(tx, creditNote); err != nil {
+			return err
+		}
+
+		ids := make([]uint, 0, len(credited))
+		for _, trip := range credited {
+			ids = append(ids, trip.ID)
+		}
+		return tx.Model(&Models.InvoiceTrip{}).Where("id IN ?", ids).Update("credit_note_id", creditNote.ID).Error
+	})
+
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create credit note",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Credit note created successfully",
+		"data":    creditNote,
+	})
+}
+
+// findInvoice loads the invoice named by the :id route parameter. When it cannot,
+// it writes the error response and returns a nil invoice.
+func (h *InvoiceHandler) findInvoice(c *fiber.Ctx) (*Models.Invoice, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var invoice Models.Invoice
+	if err := h.DB.Preload("Lines.InvoiceTrips").First(&invoice, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Invoice not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch invoice",
+			"error":   err.Error(),
+		})
+	}
+
+	return &invoice, nil
+}
+
+// tripLockedResponse rejects changes to trips billed by an invoice
+func tripLockedResponse(c *fiber.Ctx, invoice *Models.Invoice) error {
+	return c.Status(http.StatusConflict).JSON(fiber.Map{
+		"message": fmt.Sprintf("Trip is billed by invoice %s, issue a credit note or cancel the invoice before changing it", invoice.Number),
+	})
+}
@@ -0,0 +1,375 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// MaintenanceHandler contains handler methods for preventive maintenance plans, records and
+// the due lists computed from them
+type MaintenanceHandler struct {
+	DB *gorm.DB
+}
+
+// NewMaintenanceHandler creates a new maintenance handler
+func NewMaintenanceHandler(db *gorm.DB) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		DB: db,
+	}
+}
+
+// GetMaintenancePlans returns the plans, optionally of one vehicle type
+func (h *MaintenanceHandler) GetMaintenancePlans(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.MaintenancePlan{})
+	if c.Context().QueryArgs().Has("car_type") {
+		query = query.Where("car_type = ?", c.Query("car_type"))
+	}
+
+	var plans []Models.MaintenancePlan
+	if err := query.Order("car_type ASC, task ASC").Find(&plans).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch maintenance plans",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Maintenance plans retrieved successfully",
+		"data":    plans,
+	})
+}
+
+// CreateMaintenancePlan creates the plan of a task for a vehicle type, or for every type
+// without a plan of its own when car_type is empty
+func (h *MaintenanceHandler) CreateMaintenancePlan(c *fiber.Ctx) error {
+	var plan Models.MaintenancePlan
+	if err := c.BodyParser(&plan); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := plan.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid maintenance plan",
+			"error":   err.Error(),
+		})
+	}
+
+	var count int64
+	if err := h.DB.Model(&Models.MaintenancePlan{}).
+		Where("car_type = ? AND task = ?", plan.CarType, plan.Task).Count(&count).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to check maintenance plans",
+			"error":   err.Error(),
+		})
+	}
+	if count > 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "A plan for this task and vehicle type already exists",
+		})
+	}
+
+	if err := h.DB.Create(&plan).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create maintenance plan",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Maintenance plan created successfully",
+		"data":    plan,
+	})
+}
+
+// UpdateMaintenancePlan updates the intervals of a plan. Its task and vehicle type stay.
+func (h *MaintenanceHandler) UpdateMaintenancePlan(c *fiber.Ctx) error {
+	plan, err := h.findMaintenancePlan(c)
+	if plan == nil {
+		return err
+	}
+
+	id, carType, task := plan.ID, plan.CarType, plan.Task
+	if err := c.BodyParser(plan); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	plan.ID, plan.CarType, plan.Task = id, carType, task
+
+	if err := plan.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid maintenance plan",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := h.DB.Save(plan).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update maintenance plan",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Maintenance plan updated successfully",
+		"data":    plan,
+	})
+}
+
+// DeleteMaintenancePlan deletes a plan. Cars of its type fall back to the plan for every type.
+func (h *MaintenanceHandler) DeleteMaintenancePlan(c *fiber.Ctx) error {
+	plan, err := h.findMaintenancePlan(c)
+	if plan == nil {
+		return err
+	}
+
+	// Unscoped so the unique task and vehicle type can be planned again
+	if err := h.DB.Unscoped().Delete(plan).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete maintenance plan",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Maintenance plan deleted successfully",
+	})
+}
+
+// GetMaintenanceRecords returns the recorded maintenance, newest first, optionally of one car
+// or task
+func (h *MaintenanceHandler) GetMaintenanceRecords(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.MaintenanceRecord{})
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+	if task := c.Query("task"); task != "" {
+		query = query.Where("task = ?", task)
+	}
+
+	var records []Models.MaintenanceRecord
+	if err := query.Order("date DESC, id DESC").Find(&records).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch maintenance records",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Maintenance records retrieved successfully",
+		"data":    records,
+	})
+}
+
+// CreateMaintenanceRecord records a task as done, restarting its interval. The odometer
+// defaults to the car's latest odometer and the date to today.
+func (h *MaintenanceHandler) CreateMaintenanceRecord(c *fiber.Ctx) error {
+	var record Models.MaintenanceRecord
+	if err := c.BodyParser(&record); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	if record.Date == "" {
+		record.Date = time.Now().Format("2006-01-02")
+	}
+
+	if err := record.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid maintenance record",
+			"error":   err.Error(),
+		})
+	}
+
+	var car Models.Car
+	if err := h.DB.First(&car, record.CarID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Car not found",
+			})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch car",
+			"error":   err.Error(),
+		})
+	}
+	record.CarNoPlate = car.CarNoPlate
+
+	if record.OdometerKm == 0 {
+		var estimate Models.VehicleOdometer
+		if err := h.DB.Where("car_id = ?", car.ID).Limit(1).Find(&estimate).Error; err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to fetch odometer",
+				"error":   err.Error(),
+			})
+		}
+		record.OdometerKm, _ = Models.CarOdometer(car, &estimate)
+	}
+	if user, ok := c.Locals("user").(Models.User); ok {
+		record.RecordedBy = user.Name
+	}
+
+	if err := h.DB.Create(&record).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create maintenance record",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Maintenance record created successfully",
+		"data":    record,
+	})
+}
+
+// DeleteMaintenanceRecord deletes a record entered by mistake
+func (h *MaintenanceHandler) DeleteMaintenanceRecord(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	result := h.DB.Delete(&Models.MaintenanceRecord{}, id)
+	if result.Error != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete maintenance record",
+			"error":   result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"message": "Maintenance record not found",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Maintenance record deleted successfully",
+	})
+}
+
+// GetMaintenanceDue returns the tasks due soon and overdue across the fleet, overdue first.
+// status narrows the list to one status, or widens it to every task with "all".
+func (h *MaintenanceHandler) GetMaintenanceDue(c *fiber.Ctx) error {
+	status := c.Query("status")
+	switch status {
+	case "", "all", Models.MaintenanceOK, Models.MaintenanceDueSoon, Models.MaintenanceOverdue, Models.MaintenanceUnknown:
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid status",
+		})
+	}
+
+	dues, err := Models.MaintenanceStatuses(h.DB, 0, time.Now())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to compute maintenance statuses",
+			"error":   err.Error(),
+		})
+	}
+
+	filtered := make([]Models.MaintenanceDue, 0, len(dues))
+	for _, order := range []string{Models.MaintenanceOverdue, Models.MaintenanceDueSoon, Models.MaintenanceUnknown, Models.MaintenanceOK} {
+		for _, due := range dues {
+			if due.Status != order {
+				continue
+			}
+			if status == "all" || status == due.Status ||
+				(status == "" && (due.Status == Models.MaintenanceOverdue || due.Status == Models.MaintenanceDueSoon)) {
+				filtered = append(filtered, due)
+			}
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Maintenance due list retrieved successfully",
+		"data":    filtered,
+	})
+}
+
+// GetCarMaintenance returns where a car stands on every planned task
+func (h *MaintenanceHandler) GetCarMaintenance(c *fiber.Ctx) error {
+	carID, err := strconv.ParseUint(c.Params("car_id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid car ID",
+			"error":   err.Error(),
+		})
+	}
+
+	dues, err := Models.MaintenanceStatuses(h.DB, uint(carID), time.Now())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to compute maintenance statuses",
+			"error":   err.Error(),
+		})
+	}
+	if len(dues) == 0 {
+		var count int64
+		h.DB.Model(&Models.Car{}).Where("id = ?", carID).Count(&count)
+		if count == 0 {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Car not found",
+			})
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Car maintenance retrieved successfully",
+		"data":    dues,
+	})
+}
+
+// GetOdometers returns the GPS odometer estimates of the cars
+func (h *MaintenanceHandler) GetOdometers(c *fiber.Ctx) error {
+	var estimates []Models.VehicleOdometer
+	if err := h.DB.Order("car_id ASC").Find(&estimates).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch odometers",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Odometers retrieved successfully",
+		"data":    estimates,
+	})
+}
+
+func (h *MaintenanceHandler) findMaintenancePlan(c *fiber.Ctx) (*Models.MaintenancePlan, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var plan Models.MaintenancePlan
+	if err := h.DB.First(&plan, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Maintenance plan not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch maintenance plan",
+			"error":   err.Error(),
+		})
+	}
+
+	return &plan, nil
+}
@@ -17,9 +17,11 @@ func GetTruckPositions(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(positions)
 }
 
-// AssignTireToPosition assigns a tire to a specific position
+// AssignTireToPosition mounts a tire on a position, recording the mount, rotation and
+// dismount events it takes
 func AssignTireToPosition(c *fiber.Ctx) error {
 	var request struct {
+		TireEventInput
 		TireID     uint `json:"tire_id"`
 		PositionID uint `json:"position_id"`
 	}
@@ -40,28 +42,19 @@ func AssignTireToPosition(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Position not found"})
 	}
 
-	// Begin a transaction
-	tx := Models.DB.Begin()
-
-	// Clear the tire from any previous position
-	if err := tx.Model(&Models.TirePosition{}).Where("tire_id = ?", request.TireID).Update("tire_id", nil).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reset previous tire position"})
+	template, err := tireEvent(c, request.TireEventInput)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-
-	// Assign the tire to the new position
-	if err := tx.Model(&position).Update("tire_id", request.TireID).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to assign tire to position"})
+	events, err := Models.MountTire(Models.DB, tire.ID, position.ID, template)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Commit the transaction
-	tx.Commit()
-
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Tire assigned successfully"})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Tire assigned successfully", "events": events})
 }
 
-// RemoveTireFromPosition removes a tire from its position
+// RemoveTireFromPosition dismounts the tire of a position, keeping it as a spare
 func RemoveTireFromPosition(c *fiber.Ctx) error {
 	positionID := c.Params("id")
 
@@ -71,10 +64,23 @@ func RemoveTireFromPosition(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Position not found"})
 	}
 
+	// The body is optional, it only sets the date and odometer of the dismount
+	var input TireEventInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	template, err := tireEvent(c, input)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	// Remove the tire from the position
-	if err := Models.DB.Model(&position).Update("tire_id", nil).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to remove tire from position"})
+	event, err := Models.DismountTire(Models.DB, position.ID, template)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Tire removed successfully"})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Tire removed successfully", "event": event})
 }
@@ -0,0 +1,938 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// PricingEngine evaluates customer pricing contracts against trips
+type PricingEngine struct {
+	DB *gorm.DB
+}
+
+// NewPricingEngine creates a new pricing engine
+func NewPricingEngine(db *gorm.DB) *PricingEngine {
+	return &PricingEngine{
+		DB: db,
+	}
+}
+
+// PricedUnit is one billable unit: a single trip, or a tank load of
+// consecutive trips for contracts billed per capacity group
+type PricedUnit struct {
+	Trips        []Models.TripStruct
+	CarNoPlate   string
+	Date         string
+	Terminal     string
+	DropOffPoint string
+	FeeTier      int     // Fee stored on the fee mapping
+	Mapped       bool    // False when no fee mapping exists for the route
+	Rate         float64 // Rate of the matched pricing rule
+	VATRate      float64 // VAT rate of the contract in force on the unit's date
+	Distance     float64 // Billable distance
+	Volume       float64
+	Revenue      float64 // Revenue before rentals and VAT
+}
+
+// PricingSummary aggregates a set of priced units
+type PricingSummary struct {
+	TotalTrips    int64
+	TotalVolume   float64
+	TotalDistance float64
+	BaseRevenue   float64
+	CarRental     float64
+	VAT           float64
+	Total         float64
+	DistinctCars  int64
+	DistinctDays  int64
+	CarDays       int64
+	CarWorkDays   map[string]int64
+
+	baseVAT float64 // VAT on BaseRevenue alone
+}
+
+// UnitGroup is a set of priced units sharing a statistics grouping key
+type UnitGroup struct {
+	Key          string
+	Terminal     string
+	DropOffPoint string
+	FeeTier      int
+	Rate         float64
+	Units        []PricedUnit
+}
+
+// PricedTrips is the evaluation of a company's contracts over a set of trips
+type PricedTrips struct {
+	Contract Models.PricingContract // Version in force today, which drives the statistics groupings
+	Units    []PricedUnit
+
+	engine     *PricingEngine
+	trips      []Models.TripStruct
+	contracts  []Models.PricingContract
+	fallback   Models.PricingContract
+	mappings   map[string]*feeMappingVersions
+	capacities map[string]int
+	rents      map[Models.CarRentMonth]*carMonthRent // Built on first use from all the trips
+}
+
+// carMonthRent is the rent a car owes for a month, with its trips on each working day of it
+type carMonthRent struct {
+	Amount  float64
+	VATRate float64
+	Trips   map[string]int64 // Standalone trips by date
+}
+
+// feeMappingVersions holds a fee mapping with its effective-dated rates
+type feeMappingVersions struct {
+	mapping Models.FeeMapping
+	rates   []Models.FeeMappingRate
+}
+
+// on returns the fee and distance in force on a date, falling back to the
+// mapping's current values for mappings without rate history
+func (v *feeMappingVersions) on(date string) (float64, float64) {
+	for _, rate := range v.rates {
+		if rate.InForce(date) {
+			return rate.Fee, rate.Distance
+		}
+	}
+	return v.mapping.Fee, v.mapping.Distance
+}
+
+// LoadContracts returns every version of a company's pricing contract, oldest first
+func (e *PricingEngine) LoadContracts(company string) ([]Models.PricingContract, error) {
+	var contracts []Models.PricingContract
+	err := e.DB.Preload("Rules").Where("company = ?", company).Order("valid_from ASC").Find(&contracts).Error
+	return contracts, err
+}
+
+// LoadContract returns the pricing contract of a company in force today,
+// or the default contract if none is configured
+func (e *PricingEngine) LoadContract(company string) Models.PricingContract {
+	contracts, err := e.LoadContracts(company)
+	if err != nil || len(contracts) == 0 {
+		var mappings []Models.FeeMapping
+		e.DB.Where("company = ?", company).Find(&mappings)
+		return Models.DefaultPricingContract(company, averageFee(mappings))
+	}
+	return currentContract(contracts)
+}
+
+// averageFee returns the average fee of a company's fee mappings, zero without any
+func averageFee(mappings []Models.FeeMapping) float64 {
+	if len(mappings) == 0 {
+		return 0
+	}
+	var total float64
+	for _, mapping := range mappings {
+		total += mapping.Fee
+	}
+	return total / float64(len(mappings))
+}
+
+// currentContract picks the version in force today, or the latest one
+func currentContract(contracts []Models.PricingContract) Models.PricingContract {
+	today := time.Now().Format("2006-01-02")
+	for i := len(contracts) - 1; i >= 0; i-- {
+		if contracts[i].InForce(today) {
+			return contracts[i]
+		}
+	}
+	return contracts[len(contracts)-1]
+}
+
+// LoadTrips returns the trips of a company within a date range, both bounds optional
+func (e *PricingEngine) LoadTrips(company, startDate, endDate string) ([]Models.TripStruct, error) {
+	var trips []Models.TripStruct
+	query := e.DB.Where("company = ?", company)
+
+	if startDate != "" {
+		query = query.Where("date >= ?", startDate)
+	}
+	if endDate != "" {
+		query = query.Where("date <= ?", endDate)
+	}
+
+	err := query.Order("date ASC, id ASC").Find(&trips).Error
+	return trips, err
+}
+
+// Price evaluates the company's contracts over the given trips, each trip
+// priced with the contract and fee mapping rates in force on its date
+func (e *PricingEngine) Price(company string, trips []Models.TripStruct) (*PricedTrips, error) {
+	contracts, err := e.LoadContracts(company)
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings []Models.FeeMapping
+	if err := e.DB.Where("company = ?", company).Find(&mappings).Error; err != nil {
+		return nil, err
+	}
+
+	fallback := Models.DefaultPricingContract(company, averageFee(mappings))
+	contract := fallback
+	if len(contracts) > 0 {
+		contract = currentContract(contracts)
+	}
+
+	mappingIndex := make(map[string]*feeMappingVersions, len(mappings))
+	mappingsByID := make(map[uint]*feeMappingVersions, len(mappings))
+	var mappingIDs []uint
+	for _, mapping := range mappings {
+		versions := &feeMappingVersions{mapping: mapping}
+		mappingIndex[mapping.Terminal+"|"+mapping.DropOffPoint] = versions
+		mappingsByID[mapping.ID] = versions
+		mappingIDs = append(mappingIDs, mapping.ID)
+	}
+
+	if len(mappingIDs) > 0 {
+		var rates []Models.FeeMappingRate
+		if err := e.DB.Where("fee_mapping_id IN ?", mappingIDs).Order("valid_from ASC").Find(&rates).Error; err != nil {
+			return nil, err
+		}
+		for _, rate := range rates {
+			mappingsByID[rate.FeeMappingID].rates = append(mappingsByID[rate.FeeMappingID].rates, rate)
+		}
+	}
+
+	var plates []string
+	for _, trip := range trips {
+		plates = append(plates, trip.CarNoPlate)
+	}
+
+	var cars []Models.Car
+	if len(plates) > 0 {
+		if err := e.DB.Where("car_no_plate IN ?", plates).Find(&cars).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	capacities := make(map[string]int, len(cars))
+	for _, car := range cars {
+		capacities[car.CarNoPlate] = car.TankCapacity
+	}
+
+	priced := &PricedTrips{
+		Contract:   contract,
+		engine:     e,
+		contracts:  contracts,
+		fallback:   fallback,
+		mappings:   mappingIndex,
+		capacities: capacities,
+	}
+	priced.evaluate(trips)
+
+	return priced, nil
+}
+
+// evaluate prices the trips and replaces the units of p
+func (p *PricedTrips) evaluate(trips []Models.TripStruct) {
+	p.trips = trips
+	p.Units = nil
+	p.rents = nil
+
+	for _, group := range p.billableGroups(trips) {
+		p.Units = append(p.Units, p.priceGroup(group))
+	}
+}
+
+// ContractOn returns the contract version in force on a date
+func (p *PricedTrips) ContractOn(date string) *Models.PricingContract {
+	for i := len(p.contracts) - 1; i >= 0; i-- {
+		if p.contracts[i].InForce(date) {
+			return &p.contracts[i]
+		}
+	}
+	return &p.fallback
+}
+
+// billableGroups splits trips into billable units according to the contracts
+func (p *PricedTrips) billableGroups(trips []Models.TripStruct) [][]Models.TripStruct {
+	capacityGrouped := false
+	for _, contract := range p.contracts {
+		for _, rule := range contract.Rules {
+			if rule.RuleType == Models.RulePerKm && rule.DistanceBasis == Models.DistanceBasisCapacityGroup {
+				capacityGrouped = true
+			}
+		}
+	}
+
+	if !capacityGrouped {
+		groups := make([][]Models.TripStruct, 0, len(trips))
+		for _, trip := range trips {
+			groups = append(groups, []Models.TripStruct{trip})
+		}
+		return groups
+	}
+
+	ordered := make([]Models.TripStruct, len(trips))
+	copy(ordered, trips)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].CarNoPlate != ordered[j].CarNoPlate {
+			return ordered[i].CarNoPlate < ordered[j].CarNoPlate
+		}
+		return ordered[i].ReceiptNo < ordered[j].ReceiptNo
+	})
+
+	return groupTripsByCapacity(ordered, p.capacities)
+}
+
+// priceGroup applies the first matching revenue rule to a billable group
+func (p *PricedTrips) priceGroup(group []Models.TripStruct) PricedUnit {
+	first := group[0]
+	unit := PricedUnit{
+		Trips:        group,
+		CarNoPlate:   first.CarNoPlate,
+		Date:         first.Date,
+		Terminal:     first.Terminal,
+		DropOffPoint: first.DropOffPoint,
+	}
+
+	var fee float64
+	if versions, mapped := p.mappings[first.Terminal+"|"+first.DropOffPoint]; mapped {
+		unit.Mapped = true
+		fee, _ = versions.on(first.Date)
+		unit.FeeTier = int(fee)
+	}
+
+	// A capacity group is billed the longest distance among its trips
+	for _, trip := range group {
+		unit.Volume += float64(trip.TankCapacity)
+
+		if versions, exists := p.mappings[trip.Terminal+"|"+trip.DropOffPoint]; exists {
+			if _, distance := versions.on(trip.Date); distance > unit.Distance {
+				unit.Distance = distance
+			}
+		}
+	}
+
+	contract := p.ContractOn(unit.Date)
+	unit.VATRate = contract.VATRate()
+
+	for _, rule := range contract.Rules {
+		if !rule.Matches(unit.Terminal, unit.DropOffPoint) {
+			continue
+		}
+
+		volumeUnit := rule.VolumeUnit
+		if volumeUnit == 0 {
+			volumeUnit = 1000
+		}
+
+		switch rule.RuleType {
+		case Models.RulePerKm:
+			unit.Rate = rule.Rate
+			unit.Revenue = unit.Distance * rule.Rate
+			return unit
+
+		case Models.RulePerVolumeTier:
+			if rule.Tier != unit.FeeTier {
+				continue
+			}
+			unit.Rate = rule.Rate
+			unit.Revenue = unit.Volume * rule.Rate / volumeUnit
+			return unit
+
+		case Models.RulePerVolumeMappingFee:
+			unit.Rate = fee
+			if unit.Rate == 0 {
+				unit.Rate = rule.Rate
+			}
+			unit.Revenue = unit.Volume * unit.Rate / volumeUnit
+			return unit
+
+		case Models.RulePerVolume:
+			unit.Rate = rule.Rate
+			unit.Revenue = unit.Volume * rule.Rate / volumeUnit
+			return unit
+		}
+	}
+
+	return unit
+}
+
+// Summarize aggregates units into totals, applying the contract's rental and VAT rules.
+// Units can be any subset of the priced units: a car's rent is owed once per month, so a
+// subset is charged the share of it its trips make on each of the car's working days.
+func (p *PricedTrips) Summarize(units []PricedUnit) PricingSummary {
+	summary := PricingSummary{
+		CarWorkDays: make(map[string]int64),
+	}
+
+	var trips []Models.TripStruct
+	for _, unit := range units {
+		summary.TotalVolume += unit.Volume
+		summary.TotalDistance += unit.Distance
+		summary.BaseRevenue += unit.Revenue
+		summary.baseVAT += unit.Revenue * unit.VATRate
+		trips = append(trips, unit.Trips...)
+	}
+
+	summary.TotalTrips = countTrips(trips)
+
+	type carDay struct {
+		CarNoPlate string
+		Date       string
+	}
+
+	cars := make(map[string]bool)
+	days := make(map[string]bool)
+	carDayTrips := make(map[carDay]int64)
+	for _, trip := range trips {
+		cars[trip.CarNoPlate] = true
+		days[trip.Date] = true
+
+		// Containers are worked on their parent trip's day
+		if containerTrip(trip) {
+			continue
+		}
+
+		key := carDay{trip.CarNoPlate, trip.Date}
+		if carDayTrips[key] == 0 {
+			summary.CarWorkDays[trip.CarNoPlate]++
+		}
+		carDayTrips[key]++
+	}
+
+	summary.DistinctCars = int64(len(cars))
+	summary.DistinctDays = int64(len(days))
+	summary.CarDays = int64(len(carDayTrips))
+
+	rents := p.carMonthRents()
+	workedDays := make(map[Models.CarRentMonth]float64) // Working days of each car month covered by the units
+	for key, count := range carDayTrips {
+		month := Models.CarRentMonth{CarNoPlate: key.CarNoPlate, Month: Models.TripMonth(key.Date)}
+		if rent, exists := rents[month]; exists {
+			workedDays[month] += float64(count) / float64(rent.Trips[key.Date])
+		}
+	}
+
+	var rentalVAT float64
+	for month, worked := range workedDays {
+		rent := rents[month]
+		amount := rent.Amount * worked / float64(len(rent.Trips))
+		summary.CarRental += amount
+		rentalVAT += amount * rent.VATRate
+	}
+
+	summary.VAT = summary.baseVAT + rentalVAT
+	summary.Total = summary.BaseRevenue + summary.CarRental + summary.VAT
+	return summary
+}
+
+// carMonthRents returns the rent each car owes per month over all the priced trips
+func (p *PricedTrips) carMonthRents() map[Models.CarRentMonth]*carMonthRent {
+	if p.rents != nil {
+		return p.rents
+	}
+
+	p.rents = make(map[Models.CarRentMonth]*carMonthRent)
+	lastDays := make(map[Models.CarRentMonth]string)
+	for _, trip := range p.trips {
+		if containerTrip(trip) {
+			continue
+		}
+
+		key := Models.CarRentMonth{CarNoPlate: trip.CarNoPlate, Month: Models.TripMonth(trip.Date)}
+		rent, exists := p.rents[key]
+		if !exists {
+			rent = &carMonthRent{Trips: make(map[string]int64)}
+			p.rents[key] = rent
+		}
+		rent.Trips[trip.Date]++
+		if trip.Date > lastDays[key] {
+			lastDays[key] = trip.Date
+		}
+	}
+
+	// Rent follows the contract in force on the car's last working day of the month
+	for key, rent := range p.rents {
+		contract := p.ContractOn(lastDays[key])
+		if rule := contract.RentalRule(); rule != nil {
+			rent.Amount = rule.CarRent(int64(len(rent.Trips)))
+			rent.VATRate = contract.VATRate()
+		}
+	}
+	return p.rents
+}
+
+// SummarizeDate summarizes a single day, prorating monthly car rent over the days of the whole period
+func (p *PricedTrips) SummarizeDate(date string) PricingSummary {
+	var dayTrips []Models.TripStruct
+	for _, trip := range p.trips {
+		if trip.Date == date {
+			dayTrips = append(dayTrips, trip)
+		}
+	}
+
+	day := &PricedTrips{
+		Contract:   p.Contract,
+		engine:     p.engine,
+		contracts:  p.contracts,
+		fallback:   p.fallback,
+		mappings:   p.mappings,
+		capacities: p.capacities,
+	}
+	day.evaluate(dayTrips)
+	summary := day.Summarize(day.Units)
+
+	contract := p.ContractOn(date)
+	if rule := contract.RentalRule(); rule != nil && rule.RuleType == Models.RulePerCarMonth {
+		period := p.Summarize(p.Units)
+		rental := 0.0
+		if period.DistinctDays > 0 {
+			rental = period.CarRental / float64(period.DistinctDays)
+		}
+		summary.CarRental = rental
+		summary.VAT = summary.baseVAT + rental*contract.VATRate()
+		summary.Total = summary.BaseRevenue + summary.CarRental + summary.VAT
+	}
+
+	return summary
+}
+
+// Dates returns the distinct trip dates in ascending order
+func (p *PricedTrips) Dates() []string {
+	seen := make(map[string]bool)
+	var dates []string
+	for _, trip := range p.trips {
+		if !seen[trip.Date] {
+			seen[trip.Date] = true
+			dates = append(dates, trip.Date)
+		}
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// Group splits the priced units by one of the Models.GroupBy* groupings, or by car for "car"
+func (p *PricedTrips) Group(units []PricedUnit, grouping string) []UnitGroup {
+	index := make(map[string]int)
+	var groups []UnitGroup
+
+	for _, unit := range units {
+		group := UnitGroup{Rate: unit.Rate}
+
+		switch grouping {
+		case Models.GroupByDropOffPoint:
+			group.Key = unit.DropOffPoint
+			group.DropOffPoint = unit.DropOffPoint
+		case Models.GroupByTerminal:
+			group.Key = unit.Terminal
+			group.Terminal = unit.Terminal
+		case Models.GroupByFeeTier:
+			group.Key = fmt.Sprintf("%05d", unit.FeeTier)
+			group.FeeTier = unit.FeeTier
+		case Models.GroupByRoute:
+			group.Key = unit.Terminal + "|" + unit.DropOffPoint
+			group.Terminal = unit.Terminal
+			group.DropOffPoint = unit.DropOffPoint
+		default:
+			group.Key = unit.CarNoPlate
+		}
+
+		i, exists := index[group.Key]
+		if !exists {
+			i = len(groups)
+			index[group.Key] = i
+			groups = append(groups, group)
+		}
+		groups[i].Units = append(groups[i].Units, unit)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Key < groups[j].Key
+	})
+
+	return groups
+}
+
+// countTrips counts standalone trips plus distinct parent trips of multi-container trips
+func countTrips(trips []Models.TripStruct) int64 {
+	var total int64 = 0
+	parentTripIDs := make(map[uint]bool)
+
+	for _, trip := range trips {
+		if containerTrip(trip) {
+			parentTripIDs[*trip.ParentTripID] = true
+		} else {
+			total++
+		}
+	}
+
+	return total + int64(len(parentTripIDs))
+}
+
+// containerTrip reports whether a trip is a container of a multi-container trip
+func containerTrip(trip Models.TripStruct) bool {
+	return trip.ParentTripID != nil && *trip.ParentTripID != 0
+}
+
+// groupTripsByCapacity groups each car's consecutive trips until they fill the car's tank.
+// Trips must be ordered by car and receipt number.
+func groupTripsByCapacity(trips []Models.TripStruct, carCapacities map[string]int) [][]Models.TripStruct {
+	if len(trips) == 0 {
+		return nil
+	}
+
+	// Group trips by car, keeping the first-seen car order
+	var carOrder []string
+	tripsByCar := make(map[string][]Models.TripStruct)
+	for _, trip := range trips {
+		if _, exists := tripsByCar[trip.CarNoPlate]; !exists {
+			carOrder = append(carOrder, trip.CarNoPlate)
+		}
+		tripsByCar[trip.CarNoPlate] = append(tripsByCar[trip.CarNoPlate], trip)
+	}
+
+	var allGroups [][]Models.TripStruct
+
+	for _, carPlate := range carOrder {
+		carTrips := tripsByCar[carPlate]
+		carTankCapacity, carExists := carCapacities[carPlate]
+
+		// If we don't have the car info, treat each trip individually
+		if !carExists || carTankCapacity == 0 {
+			for _, trip := range carTrips {
+				allGroups = append(allGroups, []Models.TripStruct{trip})
+			}
+			continue
+		}
+
+		i := 0
+		for i < len(carTrips) {
+			currentGroup := []Models.TripStruct{}
+			totalTankCapacity := 0
+
+			// If the first trip already equals or exceeds car capacity, it's a standalone trip
+			if carTrips[i].TankCapacity >= carTankCapacity {
+				allGroups = append(allGroups, []Models.TripStruct{carTrips[i]})
+				i++
+				continue
+			}
+
+			// Collect consecutive trips until we reach car tank capacity
+			for i < len(carTrips) {
+				trip := carTrips[i]
+
+				// If adding this trip would exceed car capacity and we already have trips, stop
+				if totalTankCapacity+trip.TankCapacity > carTankCapacity && len(currentGroup) > 0 {
+					break
+				}
+
+				currentGroup = append(currentGroup, trip)
+				totalTankCapacity += trip.TankCapacity
+				i++
+
+				// If we've reached exactly the car capacity, this group is complete
+				if totalTankCapacity == carTankCapacity {
+					break
+				}
+			}
+
+			if len(currentGroup) > 0 {
+				allGroups = append(allGroups, currentGroup)
+			}
+		}
+	}
+
+	return allGroups
+}
+
+// PricingContractHandler contains handler methods for pricing contract routes
+type PricingContractHandler struct {
+	DB *gorm.DB
+}
+
+// NewPricingContractHandler creates a new pricing contract handler
+func NewPricingContractHandler(db *gorm.DB) *PricingContractHandler {
+	return &PricingContractHandler{
+		DB: db,
+	}
+}
+
+var validPricingRuleTypes = map[string]bool{
+	Models.RulePerKm:               true,
+	Models.RulePerVolumeTier:       true,
+	Models.RulePerVolumeMappingFee: true,
+	Models.RulePerVolume:           true,
+	Models.RulePerCarDay:           true,
+	Models.RulePerCarMonth:         true,
+	Models.RuleVAT:                 true,
+}
+
+func validatePricingContract(contract *Models.PricingContract) error {
+	if contract.Company == "" {
+		return fmt.Errorf("company is required")
+	}
+
+	rentalRules := 0
+	for _, rule := range contract.Rules {
+		if !validPricingRuleTypes[rule.RuleType] {
+			return fmt.Errorf("unknown rule type %q", rule.RuleType)
+		}
+		if rule.RuleType == Models.RulePerCarDay || rule.RuleType == Models.RulePerCarMonth {
+			rentalRules++
+		}
+		if rule.RuleType == Models.RulePerKm && rule.DistanceBasis != "" &&
+			rule.DistanceBasis != Models.DistanceBasisTrip && rule.DistanceBasis != Models.DistanceBasisCapacityGroup {
+			return fmt.Errorf("unknown distance basis %q", rule.DistanceBasis)
+		}
+	}
+
+	if rentalRules > 1 {
+		return fmt.Errorf("a contract can have only one car rental rule")
+	}
+
+	return nil
+}
+
+// GetAllPricingContracts returns all pricing contracts with their rules
+func (h *PricingContractHandler) GetAllPricingContracts(c *fiber.Ctx) error {
+	var contracts []Models.PricingContract
+
+	if err := h.DB.Preload("Rules").Order("company ASC").Find(&contracts).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch pricing contracts",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Pricing contracts retrieved successfully",
+		"data":    contracts,
+	})
+}
+
+// GetPricingContract returns a specific pricing contract by ID
+func (h *PricingContractHandler) GetPricingContract(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var contract Models.PricingContract
+	if err := h.DB.Preload("Rules").First(&contract, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Pricing contract not found",
+			})
+		}
+
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Pricing contract retrieved successfully",
+		"data":    contract,
+	})
+}
+
+// CreatePricingContract creates a new pricing contract with its rules
+func (h *PricingContractHandler) CreatePricingContract(c *fiber.Ctx) error {
+	contract := new(Models.PricingContract)
+
+	if err := c.BodyParser(contract); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := validatePricingContract(contract); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	var count int64
+	h.DB.Model(&Models.PricingContract{}).Where("company = ?", contract.Company).Count(&count)
+	if count > 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "A pricing contract for this company already exists",
+		})
+	}
+
+	if err := h.DB.Create(contract).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Pricing contract created successfully",
+		"data":    contract,
+	})
+}
+
+// SchedulePricingContract adds a new version of a company's contract taking effect on
+// its valid_from date, closing the version in force the day before
+func (h *PricingContractHandler) SchedulePricingContract(c *fiber.Ctx) error {
+	contract := new(Models.PricingContract)
+
+	if err := c.BodyParser(contract); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := validatePricingContract(contract); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	if _, err := time.Parse("2006-01-02", contract.ValidFrom); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "valid_from must be a date in YYYY-MM-DD format",
+		})
+	}
+
+	if err := Models.ScheduleContractVersion(h.DB, contract); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to schedule pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Pricing contract scheduled successfully",
+		"data":    contract,
+	})
+}
+
+// UpdatePricingContract writes the updated contract as a new version of it, in force from
+// valid_from or from today, so that trips already priced keep the rules they were priced with.
+// A version starting that day is replaced.
+func (h *PricingContractHandler) UpdatePricingContract(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var existing Models.PricingContract
+	if err := h.DB.First(&existing, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Pricing contract not found",
+			})
+		}
+
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	updated := new(Models.PricingContract)
+	if err := c.BodyParser(updated); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if updated.Company == "" {
+		updated.Company = existing.Company
+	}
+	if updated.Company != existing.Company {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "The company of a pricing contract cannot be changed",
+		})
+	}
+
+	if err := validatePricingContract(updated); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	// A version not in force yet is replaced on its own start date
+	if updated.ValidFrom == "" {
+		updated.ValidFrom = time.Now().Format("2006-01-02")
+		if existing.ValidFrom > updated.ValidFrom {
+			updated.ValidFrom = existing.ValidFrom
+		}
+	} else if _, err := time.Parse("2006-01-02", updated.ValidFrom); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "valid_from must be a date in YYYY-MM-DD format",
+		})
+	}
+
+	if err := Models.ScheduleContractVersion(h.DB, updated); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Pricing contract updated successfully",
+		"data":    updated,
+	})
+}
+
+// DeletePricingContract deletes a pricing contract and its rules
+func (h *PricingContractHandler) DeletePricingContract(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var contract Models.PricingContract
+	if err := h.DB.First(&contract, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Pricing contract not found",
+			})
+		}
+
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("contract_id = ?", contract.ID).Delete(&Models.PricingRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&contract).Error
+	})
+
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete pricing contract",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Pricing contract deleted successfully",
+	})
+}
@@ -0,0 +1,253 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TripProfit is the revenue, allocated operating cost and margin of a trip
+type TripProfit struct {
+	TripID       uint   `json:"trip_id"`
+	Date         string `json:"date"`
+	CarNoPlate   string `json:"car_no_plate"`
+	DriverName   string `json:"driver_name"`
+	Company      string `json:"company"`
+	Terminal     string `json:"terminal"`
+	DropOffPoint string `json:"drop_off_point"`
+	Models.ProfitLine
+}
+
+// ProfitabilityReport is the margin of the trips of a period, per trip, route, truck and
+// customer
+type ProfitabilityReport struct {
+	StartDate   string              `json:"start_date"`
+	EndDate     string              `json:"end_date"`
+	Totals      Models.ProfitLine   `json:"totals"`
+	Unallocated Models.TripCost     `json:"unallocated"` // Costs of the period no trip carries
+	Trips       []TripProfit        `json:"trips"`
+	Routes      []Models.ProfitLine `json:"routes"`
+	Trucks      []Models.ProfitLine `json:"trucks"`
+	Customers   []Models.ProfitLine `json:"customers"`
+}
+
+// tripDistance returns the distance of a trip's route in force on its date, or the trip's
+// own mileage when the route is not mapped
+func (p *PricedTrips) tripDistance(trip Models.TripStruct) float64 {
+	if versions, mapped := p.mappings[trip.Terminal+"|"+trip.DropOffPoint]; mapped {
+		if _, distance := versions.on(trip.Date); distance > 0 {
+			return distance
+		}
+	}
+	return trip.Mileage
+}
+
+// tripRevenues splits the revenue of each billable unit among its trips by volume, and the
+// car rental of each car evenly among its trips. VAT is left out.
+func (p *PricedTrips) tripRevenues() map[uint]float64 {
+	revenues := make(map[uint]float64)
+	carUnits := make(map[string][]PricedUnit)
+
+	for _, unit := range p.Units {
+		carUnits[unit.CarNoPlate] = append(carUnits[unit.CarNoPlate], unit)
+		for _, trip := range unit.Trips {
+			share := 1 / float64(len(unit.Trips))
+			if unit.Volume > 0 {
+				share = float64(trip.TankCapacity) / unit.Volume
+			}
+			revenues[trip.ID] += unit.Revenue * share
+		}
+	}
+
+	for _, units := range carUnits {
+		rental := p.Summarize(units).CarRental
+		if rental == 0 {
+			continue
+		}
+		var trips []Models.TripStruct
+		for _, unit := range units {
+			trips = append(trips, unit.Trips...)
+		}
+		for _, trip := range trips {
+			revenues[trip.ID] += rental / float64(len(trips))
+		}
+	}
+	return revenues
+}
+
+// GetTripProfitability returns the margin of the trips between start_date and end_date, with
+// fuel and maintenance allocated by distance and driver salary and car rent by day
+func (h *TripHandler) GetTripProfitability(c *fiber.Ctx) error {
+	from, to, err := eventRange(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+	startDate, endDate := from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02")
+
+	// Costs are shared among the trips of every company, the company filter only applies to
+	// the trips reported
+	var companies []string
+	if err := h.DB.Model(&Models.TripStruct{}).Where("date >= ? AND date <= ?", startDate, endDate).
+		Distinct("company").Pluck("company", &companies).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch companies",
+			"error":   err.Error(),
+		})
+	}
+
+	// Revenue as the pricing contracts bill it
+	engine := NewPricingEngine(h.DB)
+	var trips []Models.TripStruct
+	revenues := make(map[uint]float64)
+	distances := make(map[uint]float64)
+	for _, company := range companies {
+		companyTrips, err := engine.LoadTrips(company, startDate, endDate)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to fetch trips",
+				"error":   err.Error(),
+			})
+		}
+		priced, err := engine.Price(company, companyTrips)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to price trips",
+				"error":   err.Error(),
+			})
+		}
+
+		for tripID, revenue := range priced.tripRevenues() {
+			revenues[tripID] = revenue
+		}
+		for _, trip := range companyTrips {
+			distances[trip.ID] = priced.tripDistance(trip)
+		}
+		trips = append(trips, companyTrips...)
+	}
+
+	report, err := h.profitabilityReport(trips, revenues, distances, c.Query("company"), from, to)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to compute operating costs",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Trip profitability retrieved successfully",
+		"data":    report,
+	})
+}
+
+// profitabilityReport allocates the operating costs of the period to the trips and groups
+// the margins of the trips of company, or of every trip when it is empty
+func (h *TripHandler) profitabilityReport(trips []Models.TripStruct, revenues, distances map[uint]float64, company string, from, to time.Time) (*ProfitabilityReport, error) {
+	// Fuel and maintenance come from the vehicle cost ledger
+	var entries []Models.VehicleCostEntry
+	if err := Models.VehicleCosts(h.DB, 0, from, to).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	pools := make(map[uint]Models.CarCostPool)
+	for _, entry := range entries {
+		pool := pools[entry.CarID]
+		if entry.Source == Models.CostSourceFuel {
+			pool.Fuel += entry.Amount
+		} else {
+			pool.Maintenance += entry.Amount
+		}
+		pools[entry.CarID] = pool
+	}
+
+	bases := make([]Models.TripCostBasis, 0, len(trips))
+	for _, trip := range trips {
+		bases = append(bases, Models.TripCostBasis{
+			TripID:   trip.ID,
+			CarID:    trip.CarID,
+			DriverID: trip.DriverID,
+			Date:     trip.Date,
+			Distance: distances[trip.ID],
+		})
+	}
+
+	var cars []Models.Car
+	if err := h.DB.Select("id", "monthly_rent").Where("monthly_rent > 0").Find(&cars).Error; err != nil {
+		return nil, err
+	}
+	rents := make(map[uint]float64, len(cars))
+	for _, car := range cars {
+		rents[car.ID] = car.MonthlyRent
+	}
+
+	startDate, endDate := from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02")
+	var salaryRows []Models.Salary
+	if err := h.DB.Where("start_date <= ? AND close_date >= ?", endDate, startDate).Find(&salaryRows).Error; err != nil {
+		return nil, err
+	}
+	salaries := make(map[uint][]Models.Salary)
+	for _, salary := range salaryRows {
+		salaries[salary.DriverID] = append(salaries[salary.DriverID], salary)
+	}
+
+	costs := Models.AllocateTripCosts(bases, pools, salaries, rents)
+
+	report := &ProfitabilityReport{
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Totals:      Models.ProfitLine{Key: "total"},
+		Unallocated: Models.UnallocatedCosts(bases, pools, salaries, rents, from, to),
+		Trips:       make([]TripProfit, 0, len(trips)),
+	}
+	routes := make(map[string]*Models.ProfitLine)
+	trucks := make(map[string]*Models.ProfitLine)
+	customers := make(map[string]*Models.ProfitLine)
+	add := func(lines map[string]*Models.ProfitLine, key string, trip Models.TripStruct) {
+		if lines[key] == nil {
+			lines[key] = &Models.ProfitLine{Key: key}
+		}
+		lines[key].Add(distances[trip.ID], revenues[trip.ID], costs[trip.ID])
+	}
+
+	for _, trip := range trips {
+		if company != "" && trip.Company != company {
+			continue
+		}
+
+		profit := TripProfit{
+			TripID:       trip.ID,
+			Date:         trip.Date,
+			CarNoPlate:   trip.CarNoPlate,
+			DriverName:   trip.DriverName,
+			Company:      trip.Company,
+			Terminal:     trip.Terminal,
+			DropOffPoint: trip.DropOffPoint,
+			ProfitLine:   Models.ProfitLine{Key: trip.ReceiptNo},
+		}
+		profit.Add(distances[trip.ID], revenues[trip.ID], costs[trip.ID])
+		report.Trips = append(report.Trips, profit)
+
+		report.Totals.Add(distances[trip.ID], revenues[trip.ID], costs[trip.ID])
+		add(routes, trip.Terminal+" → "+trip.DropOffPoint, trip)
+		add(trucks, trip.CarNoPlate, trip)
+		add(customers, trip.Company, trip)
+	}
+
+	report.Routes = profitLines(routes)
+	report.Trucks = profitLines(trucks)
+	report.Customers = profitLines(customers)
+	return report, nil
+}
+
+// profitLines returns the grouped lines, lowest margin first
+func profitLines(grouped map[string]*Models.ProfitLine) []Models.ProfitLine {
+	lines := make([]Models.ProfitLine, 0, len(grouped))
+	for _, line := range grouped {
+		lines = append(lines, *line)
+	}
+	Models.SortProfitLines(lines)
+	return lines
+}
@@ -93,12 +93,13 @@ func CreateServiceInvoice(c *fiber.Ctx) error {
 
 	// Create inspection items
 	for i, item := range req.InspectionItems {
-		if item.Service != "" || item.Notes != "" {
+		if item.Service != "" || item.Notes != "" || item.Cost != 0 {
 			inspectionItem := Models.InspectionItem{
 				ServiceInvoiceID: invoice.ID,
 				Service:          item.Service,
 				Notes:            item.Notes,
 				ItemOrder:        i + 1,
+				Cost:             item.Cost,
 			}
 			if err := tx.Create(&inspectionItem).Error; err != nil {
 				tx.Rollback()
@@ -339,12 +340,13 @@ func UpdateServiceInvoice(c *fiber.Ctx) error {
 
 	// Create new inspection items
 	for i, item := range req.InspectionItems {
-		if item.Service != "" || item.Notes != "" {
+		if item.Service != "" || item.Notes != "" || item.Cost != 0 {
 			inspectionItem := Models.InspectionItem{
 				ServiceInvoiceID: invoice.ID,
 				Service:          item.Service,
 				Notes:            item.Notes,
 				ItemOrder:        i + 1,
+				Cost:             item.Cost,
 			}
 			if err := tx.Create(&inspectionItem).Error; err != nil {
 				tx.Rollback()
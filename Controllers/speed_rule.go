@@ -0,0 +1,193 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SpeedRuleHandler contains handler methods for speed rules and the violations they detect
+type SpeedRuleHandler struct {
+	DB *gorm.DB
+}
+
+// NewSpeedRuleHandler creates a new speed rule handler
+func NewSpeedRuleHandler(db *gorm.DB) *SpeedRuleHandler {
+	return &SpeedRuleHandler{
+		DB: db,
+	}
+}
+
+// GetSpeedRules returns every speed rule
+func (h *SpeedRuleHandler) GetSpeedRules(c *fiber.Ctx) error {
+	var rules []Models.SpeedRule
+	if err := h.DB.Order("priority ASC, id ASC").Find(&rules).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch speed rules",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Speed rules retrieved successfully",
+		"data":    rules,
+	})
+}
+
+// CreateSpeedRule creates a speed rule, enabled unless stated otherwise
+func (h *SpeedRuleHandler) CreateSpeedRule(c *fiber.Ctx) error {
+	rule := Models.SpeedRule{Enabled: true}
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := rule.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid speed rule",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := h.DB.Create(&rule).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create speed rule",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Speed rule created successfully",
+		"data":    rule,
+	})
+}
+
+// UpdateSpeedRule updates the fields of a speed rule present in the request body
+func (h *SpeedRuleHandler) UpdateSpeedRule(c *fiber.Ctx) error {
+	rule, err := h.findRule(c)
+	if rule == nil {
+		return err
+	}
+
+	id := rule.ID
+	if err := c.BodyParser(rule); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	rule.ID = id
+
+	if err := rule.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid speed rule",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := h.DB.Save(rule).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update speed rule",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Speed rule updated successfully",
+		"data":    rule,
+	})
+}
+
+// DeleteSpeedRule deletes a speed rule
+func (h *SpeedRuleHandler) DeleteSpeedRule(c *fiber.Ctx) error {
+	rule, err := h.findRule(c)
+	if rule == nil {
+		return err
+	}
+
+	if err := h.DB.Delete(rule).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete speed rule",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Speed rule deleted successfully",
+	})
+}
+
+// GetSpeedViolations returns the detected speed violations, newest first
+func (h *SpeedRuleHandler) GetSpeedViolations(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.SpeedViolationEvent{})
+
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+	if company := c.Query("company"); company != "" {
+		query = query.Where("company = ?", company)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		from, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid start_date, expected YYYY-MM-DD",
+			})
+		}
+		query = query.Where("started_at >= ?", from)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		to, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid end_date, expected YYYY-MM-DD",
+			})
+		}
+		query = query.Where("started_at < ?", to.AddDate(0, 0, 1))
+	}
+
+	var events []Models.SpeedViolationEvent
+	if err := query.Order("started_at DESC").Find(&events).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch speed violations",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Speed violations retrieved successfully",
+		"data":    events,
+	})
+}
+
+func (h *SpeedRuleHandler) findRule(c *fiber.Ctx) (*Models.SpeedRule, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var rule Models.SpeedRule
+	if err := h.DB.First(&rule, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Speed rule not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch speed rule",
+			"error":   err.Error(),
+		})
+	}
+
+	return &rule, nil
+}
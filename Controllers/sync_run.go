@@ -0,0 +1,98 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SyncRunHandler contains handler methods for the history of the external source syncs
+type SyncRunHandler struct {
+	DB *gorm.DB
+}
+
+// NewSyncRunHandler creates a new sync run handler
+func NewSyncRunHandler(db *gorm.DB) *SyncRunHandler {
+	return &SyncRunHandler{
+		DB: db,
+	}
+}
+
+// GetSyncRuns returns the latest sync runs, without their errors
+func (h *SyncRunHandler) GetSyncRuns(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.SyncRun{})
+
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+
+	var runs []Models.SyncRun
+	if err := query.Order("id DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch sync runs",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Sync runs retrieved successfully",
+		"data":    runs,
+	})
+}
+
+// GetSyncRun returns a sync run with its errors
+func (h *SyncRunHandler) GetSyncRun(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var run Models.SyncRun
+	if err := h.DB.Preload("Errors").First(&run, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Sync run not found",
+			})
+		}
+
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch sync run",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Sync run retrieved successfully",
+		"data":    run,
+	})
+}
+
+// GetSyncCursors returns how far the records of each external source were fetched
+func (h *SyncRunHandler) GetSyncCursors(c *fiber.Ctx) error {
+	var cursors []Models.SyncCursor
+	if err := h.DB.Order("source ASC").Find(&cursors).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch sync cursors",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Sync cursors retrieved successfully",
+		"data":    cursors,
+	})
+}
@@ -47,6 +47,9 @@ func CreateTire(c *fiber.Ctx) error {
 	if err := c.BodyParser(&tire); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if err := tire.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	if err := Models.DB.Create(&tire).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
@@ -71,6 +74,12 @@ func UpdateTire(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	if updateData.Cost < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cost must not be negative"})
+	}
+	// The status follows the tire's events
+	updateData.Status = ""
+
 	// Update tire fields
 	Models.DB.Model(&tire).Updates(updateData)
 	return c.Status(fiber.StatusOK).JSON(tire)
@@ -113,6 +122,112 @@ func SearchTires(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(tires)
 }
 
+// TireEventInput is the body of a tire event. Date is "2006-01-02 15:04:05" or "2006-01-02",
+// now when empty, and the odometer is read from the truck when not given.
+type TireEventInput struct {
+	Type         string  `json:"type"`
+	Date         string  `json:"date"`
+	OdometerKm   int     `json:"odometer_km"`
+	TreadDepthMm float64 `json:"tread_depth_mm"`
+	PressurePsi  float64 `json:"pressure_psi"`
+	Cost         float64 `json:"cost"`
+	Reason       string  `json:"reason"`
+	Notes        string  `json:"notes"`
+}
+
+// tireEvent returns the event described by the input, recorded by the current user
+func tireEvent(c *fiber.Ctx, input TireEventInput) (Models.TireEvent, error) {
+	event := Models.TireEvent{
+		Type:         input.Type,
+		OdometerKm:   input.OdometerKm,
+		TreadDepthMm: input.TreadDepthMm,
+		PressurePsi:  input.PressurePsi,
+		Cost:         input.Cost,
+		Reason:       input.Reason,
+		Notes:        input.Notes,
+	}
+	if input.OdometerKm < 0 {
+		return event, fmt.Errorf("odometer_km must not be negative")
+	}
+	if input.Date != "" {
+		date, err := time.Parse(Models.PositionTimeLayout, input.Date)
+		if err != nil {
+			if date, err = time.Parse("2006-01-02", input.Date); err != nil {
+				return event, fmt.Errorf("invalid date %q", input.Date)
+			}
+		}
+		event.Date = date
+	}
+	if user, ok := c.Locals("user").(Models.User); ok {
+		event.RecordedBy = user.Name
+	}
+	return event, nil
+}
+
+// GetTireEvents fetches the history of a tire, oldest first
+func GetTireEvents(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var tire Models.Tire
+	if err := Models.DB.First(&tire, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tire not found"})
+	}
+
+	var events []Models.TireEvent
+	if err := Models.DB.Where("tire_id = ?", tire.ID).Order("date ASC, id ASC").Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(events)
+}
+
+// CreateTireEvent records an inspection, retread or retirement of a tire
+func CreateTireEvent(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid tire ID"})
+	}
+	var input TireEventInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	event, err := tireEvent(c, input)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	event.TireID = uint(id)
+
+	if err := Models.RecordTireEvent(Models.DB, &event); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(event)
+}
+
+// GetTireUsage reports the distance run, cost and cost per km of every tire
+func GetTireUsage(c *fiber.Ctx) error {
+	usages, err := Models.TireUsages(Models.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if brand := strings.TrimSpace(c.Query("brand")); brand != "" {
+		filtered := usages[:0]
+		for _, usage := range usages {
+			if strings.EqualFold(strings.TrimSpace(usage.Brand), brand) {
+				filtered = append(filtered, usage)
+			}
+		}
+		usages = filtered
+	}
+	return c.Status(fiber.StatusOK).JSON(usages)
+}
+
+// GetTireBrandUsage compares the distance run and cost per km of the tire brands
+func GetTireBrandUsage(c *fiber.Ctx) error {
+	usages, err := Models.TireUsages(Models.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(Models.SummarizeTireBrands(usages))
+}
+
 type OCRRequest struct {
 	Image string `json:"image"` // base64 encoded image
 }
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
 	"net/url"
 	"os"
@@ -134,8 +133,6 @@ type CarTotal struct {
 // GetTripStatsByTime - Daily statistics breakdown
 // =============================================================================
 func (h *TripHandler) GetTripStatsByTime(StartDate, EndDate, CompanyFilter string, hasFinancialAccess bool) []TripRevenueDateResponse {
-	var output []TripRevenueDateResponse
-
 	query := h.DB.Model(&Models.TripStruct{})
 
 	if StartDate != "" && EndDate != "" {
@@ -146,245 +143,81 @@ func (h *TripHandler) GetTripStatsByTime(StartDate, EndDate, CompanyFilter strin
 		query = query.Where("company = ?", CompanyFilter)
 	}
 
-	var dates []string
-	if err := query.Distinct("date").Order("date ASC").Pluck("date", &dates).Error; err != nil {
-		return output
+	var companies []string
+	if err := query.Distinct("company").Pluck("company", &companies).Error; err != nil {
+		return nil
 	}
 
-	for _, date := range dates {
-		dateStats := TripRevenueDateResponse{
-			Date: date,
-		}
+	engine := NewPricingEngine(h.DB)
+	pricedCompanies := make([]*PricedTrips, 0, len(companies))
 
-		var companies []string
-		dateQuery := h.DB.Model(&Models.TripStruct{}).Where("date = ?", date)
-
-		if CompanyFilter != "" {
-			dateQuery = dateQuery.Where("company = ?", CompanyFilter)
-		}
-
-		if err := dateQuery.Distinct("company").Pluck("company", &companies).Error; err != nil {
+	for _, company := range companies {
+		trips, err := engine.LoadTrips(company, StartDate, EndDate)
+		if err != nil {
+			log.Printf("Failed to load trips for %s: %v", company, err)
 			continue
 		}
 
-		if CompanyFilter != "" && len(companies) == 0 {
+		priced, err := engine.Price(company, trips)
+		if err != nil {
+			log.Printf("Failed to price trips for %s: %v", company, err)
 			continue
 		}
 
-		dateStats.CompanyDetails = make([]CompanyRevenueDetails, 0, len(companies))
-		dateStats.TotalTrips = 0
-		dateStats.TotalVolume = 0
-		dateStats.TotalDistance = 0
-		dateStats.TotalRevenue = 0
-
-		for _, company := range companies {
-			companyDetail := CompanyRevenueDetails{
-				Company: company,
-			}
-
-			// Count trips properly - standalone trips + count of distinct parent_trip_ids
-			var tripCount int64
-			h.DB.Raw(`
-				SELECT COUNT(*) FROM (
-					SELECT DISTINCT parent_trip_id 
-					FROM trips 
-					WHERE company = ? AND date = ? AND deleted_at IS NULL
-					AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-					UNION ALL
-					SELECT ID
-					FROM trips
-					WHERE company = ? AND date = ? AND deleted_at IS NULL
-					AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-				) AS trip_count
-			`, company, date, company, date).Row().Scan(&tripCount)
-			companyDetail.TotalTrips = tripCount
-			dateStats.TotalTrips += tripCount
-
-			// Sum ALL volumes
-			var volume float64
-			h.DB.Model(&Models.TripStruct{}).
-				Where("date = ?", date).
-				Where("company = ?", company).
-				Select("COALESCE(SUM(tank_capacity), 0)").
-				Row().Scan(&volume)
-			companyDetail.TotalVolume = volume
-			dateStats.TotalVolume += volume
-
-			// Sum ALL distances
-			var distance float64
-			h.DB.Raw(`
-				SELECT COALESCE(SUM(fm.distance), 0) as total_distance
-				FROM trips t
-				LEFT JOIN fee_mappings fm 
-					ON t.company = fm.company 
-					AND t.terminal = fm.terminal 
-					AND t.drop_off_point = fm.drop_off_point
-				WHERE t.company = ? AND t.date = ? AND t.deleted_at IS NULL
-			`, company, date).Row().Scan(&distance)
-			companyDetail.TotalDistance = distance
-			dateStats.TotalDistance += distance
-
-			if hasFinancialAccess {
-				var revenue float64 = 0
-
-				switch company {
-				case "Petrol Arrows":
-					h.DB.Raw(`
-						SELECT COALESCE(SUM(fm.fee * t.tank_capacity / 1000), 0) as total_revenue
-						FROM trips t
-						LEFT JOIN fee_mappings fm 
-							ON t.company = fm.company 
-							AND t.terminal = fm.terminal 
-							AND t.drop_off_point = fm.drop_off_point
-						WHERE t.company = ? AND t.date = ? AND t.deleted_at IS NULL
-					`, company, date).Row().Scan(&revenue)
-
-				case "TAQA":
-					var baseRevenue float64 = 0
-					h.DB.Raw(`
-						SELECT 
-							COALESCE(SUM(
-								CASE 
-									WHEN t.terminal = 'Alex' THEN fm.distance * 40.7
-									WHEN t.terminal = 'Suez' THEN fm.distance * 40.7
-									ELSE 0
-								END
-							), 0) as base_revenue
-						FROM trips t
-						LEFT JOIN fee_mappings fm 
-							ON t.company = fm.company 
-							AND t.terminal = fm.terminal 
-							AND t.drop_off_point = fm.drop_off_point
-						WHERE t.company = ? AND t.date = ? AND t.deleted_at IS NULL
-					`, company, date).Row().Scan(&baseRevenue)
-
-					// CORRECTED: Calculate total rental for entire period, then prorate per day
-					var totalPeriodRental float64 = 0
-
-					// Get working days per car for the ENTIRE period
-					var carWorkingDays []struct {
-						CarNoPlate  string
-						WorkingDays int64
-					}
-
-					h.DB.Raw(`
-						SELECT 
-							car_no_plate,
-							COUNT(DISTINCT date) as working_days
-						FROM trips
-						WHERE company = ? 
-						AND deleted_at IS NULL
-						AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-						AND (date >= ? OR ? = '')
-						AND (date <= ? OR ? = '')
-						GROUP BY car_no_plate
-					`, company, StartDate, StartDate, EndDate, EndDate).Scan(&carWorkingDays)
-
-					// Calculate total rental for the period
-					for _, car := range carWorkingDays {
-						var carRental float64
-						if car.WorkingDays >= 28 {
-							carRental = 43000.0
-						} else {
-							carRental = 43000.0 - (float64(28-car.WorkingDays) * 1433.0)
-							if carRental < 0 {
-								carRental = 0
-							}
-						}
-						totalPeriodRental += carRental
-					}
+		pricedCompanies = append(pricedCompanies, priced)
+	}
 
-					// Count distinct days in the period
-					var distinctDaysInPeriod int64
-					h.DB.Raw(`
-						SELECT COUNT(DISTINCT date)
-						FROM trips
-						WHERE company = ? 
-						AND deleted_at IS NULL
-						AND (date >= ? OR ? = '')
-						AND (date <= ? OR ? = '')
-					`, company, StartDate, StartDate, EndDate, EndDate).Row().Scan(&distinctDaysInPeriod)
-
-					// Prorate rental per day
-					var dailyRental float64 = 0
-					if distinctDaysInPeriod > 0 {
-						dailyRental = totalPeriodRental / float64(distinctDaysInPeriod)
-					}
+	return statsByDate(pricedCompanies, hasFinancialAccess)
+}
 
-					// VAT applied AFTER adding base revenue and daily rental
-					vat := (baseRevenue + dailyRental) * 0.14
-					revenue = baseRevenue + dailyRental + vat
+// statsByDate builds the daily breakdown from already priced company trips
+func statsByDate(pricedCompanies []*PricedTrips, hasFinancialAccess bool) []TripRevenueDateResponse {
+	sort.Slice(pricedCompanies, func(i, j int) bool {
+		return pricedCompanies[i].Contract.Company < pricedCompanies[j].Contract.Company
+	})
 
-				case "Petromin":
-					var dayTrips []Models.TripStruct
-					h.DB.Where("company = ? AND date = ? AND deleted_at IS NULL", company, date).
-						Order("car_no_plate ASC, receipt_no ASC").Find(&dayTrips)
+	byDate := make(map[string]*TripRevenueDateResponse)
+	var dates []string
 
-					dayGroupedTrips := h.groupTripsForSingleDate(dayTrips)
+	for _, priced := range pricedCompanies {
+		for _, date := range priced.Dates() {
+			dateStats, exists := byDate[date]
+			if !exists {
+				dateStats = &TripRevenueDateResponse{
+					Date:           date,
+					CompanyDetails: []CompanyRevenueDetails{},
+				}
+				byDate[date] = dateStats
+				dates = append(dates, date)
+			}
 
-					var baseRevenue float64 = 0
-					for _, tripGroup := range dayGroupedTrips {
-						maxDistance := h.getMaxDistanceFromTripGroup(tripGroup)
-						baseRevenue += maxDistance * 42.5
-					}
+			summary := priced.SummarizeDate(date)
 
-					var carCount int64
-					h.DB.Raw(`
-						SELECT COUNT(DISTINCT car_no_plate)
-						FROM trips
-						WHERE company = ? AND date = ? AND deleted_at IS NULL
-						AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					`, company, date).Row().Scan(&carCount)
-
-					carRentalFee := float64(carCount) * 2000.0
-					vat := (baseRevenue + carRentalFee) * 0.14
-					revenue = baseRevenue + carRentalFee + vat
-
-				case "Watanya":
-					h.DB.Raw(`
-						SELECT COALESCE(SUM(
-							CASE 
-								WHEN fm.fee = 1 THEN t.tank_capacity * 82.5 / 1000
-								WHEN fm.fee = 2 THEN t.tank_capacity * 104.5 / 1000
-								WHEN fm.fee = 3 THEN t.tank_capacity * 126.5 / 1000
-								WHEN fm.fee = 4 THEN t.tank_capacity * 148.5 / 1000
-								WHEN fm.fee = 5 THEN t.tank_capacity * 170.5 / 1000
-								ELSE 0
-							END
-						), 0) as total_revenue
-						FROM trips t
-						LEFT JOIN fee_mappings fm 
-							ON t.company = fm.company 
-							AND t.terminal = fm.terminal 
-							AND t.drop_off_point = fm.drop_off_point
-						WHERE t.company = ? AND t.date = ? AND t.deleted_at IS NULL
-					`, company, date).Row().Scan(&revenue)
-					revenue *= 1.14
-
-				default:
-					// Calculate revenue for default companies using average fee
-					var avgFee float64
-					h.DB.Raw(`
-						SELECT COALESCE(AVG(fee), 0)
-						FROM fee_mappings
-						WHERE company = ?
-					`, company).Row().Scan(&avgFee)
-
-					if avgFee == 0 {
-						avgFee = 50
-					}
+			companyDetail := CompanyRevenueDetails{
+				Company:       priced.Contract.Company,
+				TotalTrips:    summary.TotalTrips,
+				TotalVolume:   summary.TotalVolume,
+				TotalDistance: summary.TotalDistance,
+			}
 
-					revenue = volume * avgFee
-				}
+			dateStats.TotalTrips += summary.TotalTrips
+			dateStats.TotalVolume += summary.TotalVolume
+			dateStats.TotalDistance += summary.TotalDistance
 
-				companyDetail.TotalRevenue = revenue
-				dateStats.TotalRevenue += revenue
+			if hasFinancialAccess {
+				companyDetail.TotalRevenue = summary.Total
+				dateStats.TotalRevenue += summary.Total
 			}
 
 			dateStats.CompanyDetails = append(dateStats.CompanyDetails, companyDetail)
 		}
+	}
 
-		output = append(output, dateStats)
+	sort.Strings(dates)
+
+	output := make([]TripRevenueDateResponse, 0, len(dates))
+	for _, date := range dates {
+		output = append(output, *byDate[date])
 	}
 
 	return output
@@ -422,240 +255,6 @@ func GetCarTotals(statistics []TripStatistics) []CarTotal {
 	return result
 }
 
-// Helper function to calculate group statistics - CORRECTED TRIP COUNTING
-func (h *TripHandler) calculateGroupStats(tripGroup []Models.TripStruct) (int64, float64, float64, int64) {
-	if len(tripGroup) == 0 {
-		return 0, 0, 0, 0
-	}
-
-	// CORRECTED: Count trips properly - unique parent_trip_ids + standalone trips
-	var totalTrips int64 = 0
-	parentTripIDs := make(map[uint]bool)
-
-	for _, trip := range tripGroup {
-		if trip.ParentTripID != nil && *trip.ParentTripID != 0 {
-			// Has a parent - only count unique parent IDs
-			parentTripIDs[*trip.ParentTripID] = true
-		} else {
-			// Standalone trip - count each one
-			totalTrips++
-		}
-	}
-
-	// Add the count of unique parent trip IDs
-	totalTrips += int64(len(parentTripIDs))
-
-	var totalVolume float64 = 0
-	var maxDistance float64 = h.getMaxDistanceFromTripGroup(tripGroup)
-	var uniqueDates = make(map[string]bool)
-
-	for _, trip := range tripGroup {
-		totalVolume += float64(trip.TankCapacity)
-		uniqueDates[trip.Date] = true
-	}
-
-	workingDays := int64(len(uniqueDates))
-
-	return totalTrips, totalVolume, maxDistance, workingDays
-}
-
-// Helper function to get the maximum distance from a group of trips
-func (h *TripHandler) getMaxDistanceFromTripGroup(tripGroup []Models.TripStruct) float64 {
-	if len(tripGroup) == 0 {
-		return 0
-	}
-
-	var maxDistance float64 = 0
-
-	for _, trip := range tripGroup {
-		// Get distance from fee mapping
-		var mapping Models.FeeMapping
-		err := h.DB.Where("company = ? AND terminal = ? AND drop_off_point = ?",
-			trip.Company, trip.Terminal, trip.DropOffPoint).First(&mapping).Error
-
-		if err == nil && mapping.Distance > maxDistance {
-			maxDistance = mapping.Distance
-		}
-	}
-
-	return maxDistance
-}
-
-// groupPetrominTripsByCapacity - NO CHANGES NEEDED (grouping logic is fine)
-func (h *TripHandler) groupPetrominTripsByCapacity(company, startDate, endDate string) [][]Models.TripStruct {
-	// Get all Petromin trips ordered by car_no_plate and receipt_no
-	var trips []Models.TripStruct
-	query := h.DB.Where("company = ? AND deleted_at IS NULL", company).
-		Order("car_no_plate ASC, receipt_no ASC")
-
-	if startDate != "" && endDate != "" {
-		query = query.Where("date >= ? AND date <= ?", startDate, endDate)
-	}
-
-	query.Find(&trips)
-
-	if len(trips) == 0 {
-		return nil
-	}
-
-	// Get car tank capacities from Car model
-	var carPlates []string
-	for _, trip := range trips {
-		carPlates = append(carPlates, trip.CarNoPlate)
-	}
-
-	var cars []Models.Car
-	h.DB.Where("car_no_plate IN ?", carPlates).Find(&cars)
-
-	carCapacities := make(map[string]int)
-	for _, car := range cars {
-		carCapacities[car.CarNoPlate] = car.TankCapacity
-	}
-
-	// Group trips by car first
-	tripsByCar := make(map[string][]Models.TripStruct)
-	for _, trip := range trips {
-		tripsByCar[trip.CarNoPlate] = append(tripsByCar[trip.CarNoPlate], trip)
-	}
-
-	var allGroups [][]Models.TripStruct
-
-	// Process each car's trips
-	for carPlate, carTrips := range tripsByCar {
-		carTankCapacity, carExists := carCapacities[carPlate]
-
-		// If we don't have the car info, treat each trip individually
-		if !carExists || carTankCapacity == 0 {
-			for _, trip := range carTrips {
-				allGroups = append(allGroups, []Models.TripStruct{trip})
-			}
-			continue
-		}
-
-		// Group consecutive trips for this car
-		i := 0
-		for i < len(carTrips) {
-			currentGroup := []Models.TripStruct{}
-			totalTankCapacity := 0
-
-			// If the first trip already equals or exceeds car capacity, it's a standalone trip
-			if carTrips[i].TankCapacity >= carTankCapacity {
-				allGroups = append(allGroups, []Models.TripStruct{carTrips[i]})
-				i++
-				continue
-			}
-
-			// Collect consecutive trips until we reach car tank capacity
-			for i < len(carTrips) {
-				trip := carTrips[i]
-
-				// If adding this trip would exceed car capacity and we already have trips, stop
-				if totalTankCapacity+trip.TankCapacity > carTankCapacity && len(currentGroup) > 0 {
-					break
-				}
-
-				currentGroup = append(currentGroup, trip)
-				totalTankCapacity += trip.TankCapacity
-				i++
-
-				// If we've reached exactly the car capacity, this group is complete
-				if totalTankCapacity == carTankCapacity {
-					break
-				}
-			}
-
-			// Add the group if it has trips
-			if len(currentGroup) > 0 {
-				allGroups = append(allGroups, currentGroup)
-			}
-		}
-	}
-
-	return allGroups
-}
-
-// groupTripsForSingleDate - NO CHANGES NEEDED (grouping logic is fine)
-func (h *TripHandler) groupTripsForSingleDate(trips []Models.TripStruct) [][]Models.TripStruct {
-	if len(trips) == 0 {
-		return nil
-	}
-
-	// Get car tank capacities from Car model
-	var carPlates []string
-	for _, trip := range trips {
-		carPlates = append(carPlates, trip.CarNoPlate)
-	}
-
-	var cars []Models.Car
-	h.DB.Where("car_no_plate IN ?", carPlates).Find(&cars)
-
-	carCapacities := make(map[string]int)
-	for _, car := range cars {
-		carCapacities[car.CarNoPlate] = car.TankCapacity
-	}
-
-	// Group trips by car
-	tripsByCar := make(map[string][]Models.TripStruct)
-	for _, trip := range trips {
-		tripsByCar[trip.CarNoPlate] = append(tripsByCar[trip.CarNoPlate], trip)
-	}
-
-	var allGroups [][]Models.TripStruct
-
-	// Process each car's trips
-	for carPlate, carTrips := range tripsByCar {
-		carTankCapacity, carExists := carCapacities[carPlate]
-
-		// If we don't have the car info, treat each trip individually
-		if !carExists || carTankCapacity == 0 {
-			for _, trip := range carTrips {
-				allGroups = append(allGroups, []Models.TripStruct{trip})
-			}
-			continue
-		}
-
-		// Group consecutive trips for this car
-		i := 0
-		for i < len(carTrips) {
-			currentGroup := []Models.TripStruct{}
-			totalTankCapacity := 0
-
-			// If the first trip already equals or exceeds car capacity, it's a standalone trip
-			if carTrips[i].TankCapacity >= carTankCapacity {
-				allGroups = append(allGroups, []Models.TripStruct{carTrips[i]})
-				i++
-				continue
-			}
-
-			// Collect consecutive trips until we reach car tank capacity
-			for i < len(carTrips) {
-				trip := carTrips[i]
-
-				// If adding this trip would exceed car capacity and we already have trips, stop
-				if totalTankCapacity+trip.TankCapacity > carTankCapacity && len(currentGroup) > 0 {
-					break
-				}
-
-				currentGroup = append(currentGroup, trip)
-				totalTankCapacity += trip.TankCapacity
-				i++
-
-				// If we've reached exactly the car capacity, this group is complete
-				if totalTankCapacity == carTankCapacity {
-					break
-				}
-			}
-
-			// Add the group if it has trips
-			if len(currentGroup) > 0 {
-				allGroups = append(allGroups, currentGroup)
-			}
-		}
-	}
-
-	return allGroups
-}
-
 func (h *TripHandler) GetTripStatistics(c *fiber.Ctx) error {
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
@@ -682,514 +281,34 @@ func (h *TripHandler) GetTripStatistics(c *fiber.Ctx) error {
 		})
 	}
 
+	engine := NewPricingEngine(h.DB)
 	statistics := make([]TripStatistics, 0, len(companies))
+	pricedCompanies := make([]*PricedTrips, 0, len(companies))
 
 	for _, company := range companies {
-		companyStats := TripStatistics{
-			Company: company,
+		trips, err := engine.LoadTrips(company, startDate, endDate)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to fetch trips",
+				"error":   err.Error(),
+			})
 		}
 
-		// Count trips properly - standalone trips + count of distinct parent_trip_ids
-		var totalTrips int64
-		h.DB.Raw(`
-			SELECT COUNT(*) FROM (
-				SELECT DISTINCT parent_trip_id 
-				FROM trips 
-				WHERE company = ? 
-				AND deleted_at IS NULL
-				AND date >= ? AND date <= ?
-				AND parent_trip_id IS NOT NULL 
-				AND parent_trip_id != 0
-				UNION ALL
-				SELECT ID
-				FROM trips
-				WHERE company = ?
-				AND deleted_at IS NULL
-				AND date >= ? AND date <= ?
-				AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-			) AS trip_count
-		`, company, startDate, endDate, company, startDate, endDate).Row().Scan(&totalTrips)
-		companyStats.TotalTrips = totalTrips
-
-		// Sum ALL volumes
-		var totalVolume float64
-		h.DB.Model(&Models.TripStruct{}).
-			Where("company = ?", company).
-			Where("date >= ? AND date <= ?", startDate, endDate).
-			Select("COALESCE(SUM(tank_capacity), 0)").
-			Row().Scan(&totalVolume)
-		companyStats.TotalVolume = totalVolume
-
-		// Sum ALL distances
-		var totalDistance float64
-		h.DB.Raw(`
-			SELECT COALESCE(SUM(fm.distance), 0) as total_distance
-			FROM trips t
-			LEFT JOIN fee_mappings fm 
-				ON t.company = fm.company 
-				AND t.terminal = fm.terminal 
-				AND t.drop_off_point = fm.drop_off_point
-			WHERE t.company = ? AND t.deleted_at IS NULL
-			AND t.date >= ? AND t.date <= ?
-		`, company, startDate, endDate).Row().Scan(&totalDistance)
-		companyStats.TotalDistance = totalDistance
-
-		switch company {
-		case "Petrol Arrows":
-			var dropOffStats []struct {
-				DropOffPoint  string
-				TotalTrips    int64
-				TotalVolume   float64
-				TotalDistance float64
-				Fee           float64
-			}
-
-			h.DB.Raw(`
-				SELECT 
-					t.drop_off_point,
-					(SELECT COUNT(*) FROM (
-						SELECT DISTINCT parent_trip_id
-						FROM trips
-						WHERE company = t.company
-						AND drop_off_point = t.drop_off_point
-						AND deleted_at IS NULL
-						AND date >= ? AND date <= ?
-						AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-						UNION ALL
-						SELECT ID
-						FROM trips
-						WHERE company = t.company
-						AND drop_off_point = t.drop_off_point
-						AND deleted_at IS NULL
-						AND date >= ? AND date <= ?
-						AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					) AS counted_trips) as total_trips,
-					COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-					COALESCE(SUM(fm.distance), 0) as total_distance,
-					fm.fee
-				FROM trips t
-				LEFT JOIN fee_mappings fm 
-					ON t.company = fm.company 
-					AND t.terminal = fm.terminal 
-					AND t.drop_off_point = fm.drop_off_point
-				WHERE t.company = ? AND t.deleted_at IS NULL
-				AND t.date >= ? AND t.date <= ?
-				GROUP BY t.drop_off_point, fm.fee, t.company
-			`, startDate, endDate, startDate, endDate, company, startDate, endDate).Scan(&dropOffStats)
-
-			companyStats.Details = make([]TripStatisticsDetails, 0, len(dropOffStats))
-			companyStats.TotalRevenue = 0
-
-			for _, stat := range dropOffStats {
-				revenue := stat.Fee * stat.TotalVolume / 1000
-
-				detail := TripStatisticsDetails{
-					GroupName:     stat.DropOffPoint,
-					TotalTrips:    stat.TotalTrips,
-					TotalVolume:   stat.TotalVolume,
-					TotalDistance: stat.TotalDistance,
-					TotalRevenue:  revenue,
-					Fee:           stat.Fee,
-				}
-
-				if !hasFinancialAccess {
-					detail.TotalRevenue = 0
-					detail.Fee = 0
-				}
-
-				companyStats.Details = append(companyStats.Details, detail)
-				if hasFinancialAccess {
-					companyStats.TotalRevenue += revenue
-				}
-			}
-
-		case "TAQA":
-			var terminalStats []struct {
-				Terminal      string
-				TotalTrips    int64
-				TotalVolume   float64
-				TotalDistance float64
-				DistinctCars  int64
-			}
-
-			h.DB.Raw(`
-				SELECT 
-					t.terminal,
-					(SELECT COUNT(*) FROM (
-						SELECT DISTINCT parent_trip_id
-						FROM trips
-						WHERE company = t.company
-						AND terminal = t.terminal
-						AND deleted_at IS NULL
-						AND date >= ? AND date <= ?
-						AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-						UNION ALL
-						SELECT ID
-						FROM trips
-						WHERE company = t.company
-						AND terminal = t.terminal
-						AND deleted_at IS NULL
-						AND date >= ? AND date <= ?
-						AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					) AS counted_trips) as total_trips,
-					COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-					COALESCE(SUM(fm.distance), 0) as total_distance,
-					COUNT(DISTINCT t.car_no_plate) as distinct_cars
-				FROM trips t
-				LEFT JOIN fee_mappings fm 
-					ON t.company = fm.company 
-					AND t.terminal = fm.terminal 
-					AND t.drop_off_point = fm.drop_off_point
-				WHERE t.company = ? AND t.deleted_at IS NULL
-				AND t.date >= ? AND t.date <= ?
-				GROUP BY t.terminal, t.company
-			`, startDate, endDate, startDate, endDate, company, startDate, endDate).Scan(&terminalStats)
-
-			companyStats.Details = make([]TripStatisticsDetails, 0, len(terminalStats))
-			companyStats.TotalRevenue = 0
-			companyStats.TotalVAT = 0
-			companyStats.TotalCarRent = 0
-			companyStats.TotalAmount = 0
-
-			for _, stat := range terminalStats {
-				var ratePerKm float64
-				if stat.Terminal == "Alex" || stat.Terminal == "Suez" {
-					ratePerKm = 40.7
-				} else {
-					ratePerKm = 0
-				}
-
-				baseRevenue := stat.TotalDistance * ratePerKm
-
-				// CORRECTED: Calculate rental PER CAR, then sum
-				var carRentalFee float64 = 0
-
-				// Get working days per car in this terminal
-				var carWorkingDays []struct {
-					CarNoPlate  string
-					WorkingDays int64
-				}
-
-				h.DB.Raw(`
-					SELECT 
-						car_no_plate,
-						COUNT(DISTINCT date) as working_days
-					FROM trips
-					WHERE company = ? 
-					AND deleted_at IS NULL
-					AND terminal = ?
-					AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					AND date >= ? AND date <= ?
-					GROUP BY car_no_plate
-				`, company, stat.Terminal, startDate, endDate).Scan(&carWorkingDays)
-
-				var totalCarDays int64 = 0
-				for _, car := range carWorkingDays {
-					// Each car: 43000 if >= 28 days, else 43000 - ((28 - days) * 1433)
-					var carRental float64
-					if car.WorkingDays >= 28 {
-						carRental = 43000.0
-					} else {
-						carRental = 43000.0 - (float64(28-car.WorkingDays) * 1433.0)
-						if carRental < 0 {
-							carRental = 0
-						}
-					}
-					carRentalFee += carRental
-					totalCarDays += car.WorkingDays
-				}
-
-				// VAT applied AFTER adding base revenue and rental
-				vat := (baseRevenue + carRentalFee) * 0.14
-				totalRevenue := baseRevenue + carRentalFee + vat
-
-				var distinctDays int64
-				h.DB.Raw(`
-					SELECT COUNT(DISTINCT date)
-					FROM trips
-					WHERE company = ? 
-					AND deleted_at IS NULL
-					AND terminal = ?
-					AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					AND date >= ? AND date <= ?
-				`, company, stat.Terminal, startDate, endDate).Row().Scan(&distinctDays)
-
-				detail := TripStatisticsDetails{
-					GroupName:     stat.Terminal,
-					TotalTrips:    stat.TotalTrips,
-					TotalVolume:   stat.TotalVolume,
-					TotalDistance: stat.TotalDistance,
-					TotalRevenue:  baseRevenue,
-					CarRental:     carRentalFee,
-					VAT:           vat,
-					TotalWithVAT:  totalRevenue,
-					Fee:           ratePerKm,
-					DistinctCars:  stat.DistinctCars,
-					DistinctDays:  distinctDays,
-					CarDays:       totalCarDays,
-				}
-
-				if !hasFinancialAccess {
-					detail.TotalRevenue = 0
-					detail.CarRental = 0
-					detail.VAT = 0
-					detail.TotalWithVAT = 0
-					detail.Fee = 0
-				}
-
-				companyStats.Details = append(companyStats.Details, detail)
-				if hasFinancialAccess {
-					companyStats.TotalRevenue += baseRevenue
-					companyStats.TotalCarRent += carRentalFee
-					companyStats.TotalVAT += vat
-					companyStats.TotalAmount += totalRevenue
-				}
-			}
-
-		case "Petromin":
-			groupedTrips := h.groupPetrominTripsByCapacity(company, startDate, endDate)
-
-			var revenueDistance float64 = 0
-			for _, tripGroup := range groupedTrips {
-				maxDistance := h.getMaxDistanceFromTripGroup(tripGroup)
-				revenueDistance += maxDistance
-			}
-
-			var allWorkingDays []struct {
-				CarNoPlate string
-				Date       string
-			}
-
-			h.DB.Raw(`
-				SELECT DISTINCT car_no_plate, date
-				FROM trips
-				WHERE company = ? 
-				AND deleted_at IS NULL
-				AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-				AND date >= ? AND date <= ?
-				ORDER BY car_no_plate, date
-			`, company, startDate, endDate).Scan(&allWorkingDays)
-
-			var totalCarRentalFee float64 = float64(len(allWorkingDays)) * 2000.0
-
-			terminalStats := make(map[string]*struct {
-				Terminal        string
-				TotalTrips      int64
-				TotalVolume     float64
-				RevenueDistance float64
-				DistinctCars    map[string]bool
-				WorkingDays     map[string]bool
+		priced, err := engine.Price(company, trips)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to price trips",
+				"error":   err.Error(),
 			})
-
-			for _, tripGroup := range groupedTrips {
-				if len(tripGroup) == 0 {
-					continue
-				}
-
-				terminal := tripGroup[0].Terminal
-				carPlate := tripGroup[0].CarNoPlate
-
-				if terminalStats[terminal] == nil {
-					terminalStats[terminal] = &struct {
-						Terminal        string
-						TotalTrips      int64
-						TotalVolume     float64
-						RevenueDistance float64
-						DistinctCars    map[string]bool
-						WorkingDays     map[string]bool
-					}{
-						Terminal:     terminal,
-						DistinctCars: make(map[string]bool),
-						WorkingDays:  make(map[string]bool),
-					}
-				}
-
-				totalTrips, totalVolume, maxDistance, _ := h.calculateGroupStats(tripGroup)
-
-				terminalStats[terminal].TotalTrips += totalTrips
-				terminalStats[terminal].TotalVolume += totalVolume
-				terminalStats[terminal].RevenueDistance += maxDistance
-
-				terminalStats[terminal].DistinctCars[carPlate] = true
-				for _, trip := range tripGroup {
-					terminalStats[terminal].WorkingDays[trip.Date] = true
-				}
-			}
-
-			terminalCarDays := make(map[string]int)
-			for terminal := range terminalStats {
-				var terminalWorkingDays []struct {
-					CarNoPlate string
-					Date       string
-				}
-
-				h.DB.Raw(`
-					SELECT DISTINCT car_no_plate, date
-					FROM trips
-					WHERE company = ? 
-					AND deleted_at IS NULL
-					AND terminal = ?
-					AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					AND date >= ? AND date <= ?
-					ORDER BY car_no_plate, date
-				`, company, terminal, startDate, endDate).Scan(&terminalWorkingDays)
-
-				terminalCarDays[terminal] = len(terminalWorkingDays)
-			}
-
-			companyStats.Details = make([]TripStatisticsDetails, 0, len(terminalStats))
-			companyStats.TotalRevenue = 0
-			companyStats.TotalVAT = 0
-			companyStats.TotalCarRent = totalCarRentalFee
-			companyStats.TotalAmount = 0
-			companyStats.TotalDistance = revenueDistance
-
-			for _, stat := range terminalStats {
-				ratePerKm := 42.5
-				baseRevenue := stat.RevenueDistance * ratePerKm
-
-				carRentalFee := 0.0
-				if terminalCarDays[stat.Terminal] > 0 {
-					carRentalFee = float64(terminalCarDays[stat.Terminal]) * 2000.0
-				}
-
-				vat := (baseRevenue + carRentalFee) * 0.14
-				totalRevenue := baseRevenue + carRentalFee + vat
-
-				detail := TripStatisticsDetails{
-					GroupName:     stat.Terminal,
-					TotalTrips:    stat.TotalTrips,
-					TotalVolume:   stat.TotalVolume,
-					TotalDistance: stat.RevenueDistance,
-					TotalRevenue:  baseRevenue,
-					CarRental:     carRentalFee,
-					VAT:           vat,
-					TotalWithVAT:  totalRevenue,
-					Fee:           ratePerKm,
-					DistinctCars:  int64(len(stat.DistinctCars)),
-					DistinctDays:  int64(len(stat.WorkingDays)),
-					CarDays:       int64(terminalCarDays[stat.Terminal]),
-				}
-
-				if !hasFinancialAccess {
-					detail.TotalRevenue = 0
-					detail.CarRental = 0
-					detail.VAT = 0
-					detail.TotalWithVAT = 0
-					detail.Fee = 0
-				}
-
-				companyStats.Details = append(companyStats.Details, detail)
-				if hasFinancialAccess {
-					companyStats.TotalRevenue += baseRevenue
-					companyStats.TotalVAT += vat
-					companyStats.TotalAmount += totalRevenue
-				}
-			}
-
-		case "Watanya":
-			var feeStats []struct {
-				Fee           float64
-				TotalTrips    int64
-				TotalVolume   float64
-				TotalDistance float64
-			}
-
-			h.DB.Raw(`
-				SELECT f.fee, 
-					COUNT(*) as total_trips,
-					COALESCE(SUM(t.tank_capacity), 0) as total_volume, 
-					COALESCE(SUM(f.distance), 0) as total_distance
-				FROM trips t
-				LEFT JOIN fee_mappings f 
-					ON t.company = f.company 
-					AND t.terminal = f.terminal 
-					AND t.drop_off_point = f.drop_off_point
-				WHERE t.company = ? AND t.deleted_at IS NULL
-				AND t.date >= ? AND t.date <= ?
-				GROUP BY f.fee
-			`, company, startDate, endDate).Scan(&feeStats)
-
-			companyStats.Details = make([]TripStatisticsDetails, 0, len(feeStats))
-			companyStats.TotalRevenue = 0
-			companyStats.TotalVAT = 0
-			companyStats.TotalAmount = 0
-
-			for _, stat := range feeStats {
-				var ratePerVolume float64
-
-				switch int(stat.Fee) {
-				case 1:
-					ratePerVolume = 82.5
-				case 2:
-					ratePerVolume = 104.5
-				case 3:
-					ratePerVolume = 126.5
-				case 4:
-					ratePerVolume = 148.5
-				case 5:
-					ratePerVolume = 170.5
-				default:
-					ratePerVolume = 0
-				}
-
-				baseRevenue := stat.TotalVolume * ratePerVolume / 1000
-				vat := baseRevenue * 0.14
-				totalRevenue := baseRevenue + vat
-
-				detail := TripStatisticsDetails{
-					GroupName:     "Fee " + fmt.Sprintf("%.0f", stat.Fee),
-					TotalTrips:    stat.TotalTrips,
-					TotalVolume:   stat.TotalVolume,
-					TotalDistance: stat.TotalDistance,
-					TotalRevenue:  baseRevenue,
-					VAT:           vat,
-					TotalWithVAT:  totalRevenue,
-					Fee:           stat.Fee,
-				}
-
-				if !hasFinancialAccess {
-					detail.TotalRevenue = 0
-					detail.VAT = 0
-					detail.TotalWithVAT = 0
-					detail.Fee = 0
-				}
-
-				companyStats.Details = append(companyStats.Details, detail)
-				if hasFinancialAccess {
-					companyStats.TotalRevenue += baseRevenue
-					companyStats.TotalVAT += vat
-					companyStats.TotalAmount += totalRevenue
-				}
-			}
-
-		default:
-			var avgFee float64
-			h.DB.Raw(`
-				SELECT COALESCE(AVG(fee), 0)
-				FROM fee_mappings
-				WHERE company = ?
-			`, company).Row().Scan(&avgFee)
-
-			if avgFee == 0 {
-				avgFee = 50
-			}
-
-			revenue := companyStats.TotalVolume * avgFee
-
-			if hasFinancialAccess {
-				companyStats.TotalRevenue = revenue
-			} else {
-				companyStats.TotalRevenue = 0
-			}
 		}
+		pricedCompanies = append(pricedCompanies, priced)
 
-		routeStats := h.GetTripStatsByRoute(company, startDate, endDate, hasFinancialAccess)
-		companyStats.RouteDetails = routeStats
+		companyStats := companyStatistics(priced, hasFinancialAccess)
+		companyStats.RouteDetails = routeStatistics(priced, hasFinancialAccess)
 		statistics = append(statistics, companyStats)
 	}
 
-	statsByDate := h.GetTripStatsByTime(startDate, endDate, companyFilter, hasFinancialAccess)
+	statsByDate := statsByDate(pricedCompanies, hasFinancialAccess)
 	carTotals := GetCarTotals(statistics)
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
@@ -1201,639 +320,162 @@ func (h *TripHandler) GetTripStatistics(c *fiber.Ctx) error {
 	})
 }
 
-func (h *TripHandler) GetTripStatsByRoute(company, startDate, endDate string, hasFinancialAccess bool) []RouteRevenueStats {
-	var routeStats []RouteRevenueStats
+// companyStatistics builds the company totals and the details grouped as the contract specifies
+func companyStatistics(priced *PricedTrips, hasFinancialAccess bool) TripStatistics {
+	contract := priced.Contract
+	summary := priced.Summarize(priced.Units)
 
-	switch company {
-	case "Petrol Arrows":
-		var routeData []struct {
-			Terminal      string
-			DropOffPoint  string
-			TotalTrips    int64
-			TotalVolume   float64
-			TotalDistance float64
-			Fee           float64
-		}
+	companyStats := TripStatistics{
+		Company:       contract.Company,
+		TotalTrips:    summary.TotalTrips,
+		TotalVolume:   summary.TotalVolume,
+		TotalDistance: summary.TotalDistance,
+	}
 
-		h.DB.Raw(`
-			SELECT 
-				t.terminal, 
-				t.drop_off_point,
-				(SELECT COUNT(*) FROM (
-					SELECT DISTINCT parent_trip_id
-					FROM trips
-					WHERE company = t.company
-					AND terminal = t.terminal
-					AND drop_off_point = t.drop_off_point
-					AND deleted_at IS NULL
-					AND (date >= ? OR ? = '')
-					AND (date <= ? OR ? = '')
-					AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-					UNION ALL
-					SELECT ID
-					FROM trips
-					WHERE company = t.company
-					AND terminal = t.terminal
-					AND drop_off_point = t.drop_off_point
-					AND deleted_at IS NULL
-					AND (date >= ? OR ? = '')
-					AND (date <= ? OR ? = '')
-					AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-				) AS counted_trips) as total_trips,
-				COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-				COALESCE(SUM(fm.distance), 0) as total_distance,
-				fm.fee
-			FROM trips t
-			LEFT JOIN fee_mappings fm 
-				ON t.company = fm.company 
-				AND t.terminal = fm.terminal 
-				AND t.drop_off_point = fm.drop_off_point
-			WHERE t.company = ? AND t.deleted_at IS NULL
-			AND (t.date >= ? OR ? = '')
-			AND (t.date <= ? OR ? = '')
-			GROUP BY t.terminal, t.drop_off_point, fm.fee, t.company
-		`, startDate, startDate, endDate, endDate, startDate, startDate, endDate, endDate, company, startDate, startDate, endDate, endDate).Scan(&routeData)
-
-		for _, route := range routeData {
-			revenue := route.Fee * route.TotalVolume / 1000
-
-			routeStat := RouteRevenueStats{
-				RouteName:     fmt.Sprintf("%s to %s", route.Terminal, route.DropOffPoint),
-				Terminal:      route.Terminal,
-				DropOffPoint:  route.DropOffPoint,
-				RouteType:     "terminal-dropoff",
-				TotalTrips:    route.TotalTrips,
-				TotalVolume:   route.TotalVolume,
-				TotalDistance: route.TotalDistance,
-				Fee:           route.Fee,
-			}
+	if hasFinancialAccess {
+		companyStats.TotalRevenue = summary.BaseRevenue
+		companyStats.TotalCarRent = summary.CarRental
+		companyStats.TotalVAT = summary.VAT
+		companyStats.TotalAmount = summary.Total
+	}
 
-			if hasFinancialAccess {
-				routeStat.TotalRevenue = revenue
-				routeStat.TotalWithVAT = revenue
-			}
+	if contract.DetailGrouping == "" {
+		return companyStats
+	}
 
-			var carStats []CarStats
-			h.DB.Raw(`
-				SELECT 
-					t.car_no_plate,
-					(SELECT COUNT(*) FROM (
-						SELECT DISTINCT parent_trip_id
-						FROM trips
-						WHERE company = ? AND deleted_at IS NULL
-						AND terminal = ? AND drop_off_point = ?
-						AND car_no_plate = t.car_no_plate
-						AND (date >= ? OR ? = '')
-						AND (date <= ? OR ? = '')
-						AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-						UNION ALL
-						SELECT ID
-						FROM trips
-						WHERE company = ? AND deleted_at IS NULL
-						AND terminal = ? AND drop_off_point = ?
-						AND car_no_plate = t.car_no_plate
-						AND (date >= ? OR ? = '')
-						AND (date <= ? OR ? = '')
-						AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					) AS counted_trips) as total_trips,
-					COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-					COALESCE(SUM(fm.distance), 0) as total_distance,
-					COUNT(DISTINCT CASE WHEN (t.parent_trip_id IS NULL OR t.parent_trip_id = 0) THEN t.date END) as working_days
-				FROM trips t
-				LEFT JOIN fee_mappings fm 
-					ON t.company = fm.company 
-					AND t.terminal = fm.terminal 
-					AND t.drop_off_point = fm.drop_off_point
-				WHERE t.company = ? AND t.deleted_at IS NULL
-				AND t.terminal = ? AND t.drop_off_point = ?
-				AND (t.date >= ? OR ? = '')
-				AND (t.date <= ? OR ? = '')
-				GROUP BY t.car_no_plate
-			`, company, route.Terminal, route.DropOffPoint, startDate, startDate, endDate, endDate,
-				company, route.Terminal, route.DropOffPoint, startDate, startDate, endDate, endDate,
-				company, route.Terminal, route.DropOffPoint, startDate, startDate, endDate, endDate).Scan(&carStats)
-
-			for i := range carStats {
-				if hasFinancialAccess {
-					carStats[i].TotalRevenue = route.Fee * carStats[i].TotalVolume / 1000
-					carStats[i].TotalWithVAT = carStats[i].TotalRevenue
-				}
-			}
+	groups := priced.Group(priced.Units, contract.DetailGrouping)
+	companyStats.Details = make([]TripStatisticsDetails, 0, len(groups))
 
-			routeStat.Cars = carStats
-			routeStats = append(routeStats, routeStat)
-		}
+	for _, group := range groups {
+		groupSummary := priced.Summarize(group.Units)
 
-	case "TAQA":
-		var routeData []struct {
-			Terminal      string
-			TotalTrips    int64
-			TotalVolume   float64
-			TotalDistance float64
-			DistinctCars  int64
+		detail := TripStatisticsDetails{
+			TotalTrips:    groupSummary.TotalTrips,
+			TotalVolume:   groupSummary.TotalVolume,
+			TotalDistance: groupSummary.TotalDistance,
 		}
 
-		h.DB.Raw(`
-			SELECT 
-				t.terminal,
-				(SELECT COUNT(*) FROM (
-					SELECT DISTINCT parent_trip_id
-					FROM trips
-					WHERE company = t.company
-					AND terminal = t.terminal
-					AND deleted_at IS NULL
-					AND (date >= ? OR ? = '')
-					AND (date <= ? OR ? = '')
-					AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-					UNION ALL
-					SELECT ID
-					FROM trips
-					WHERE company = t.company
-					AND terminal = t.terminal
-					AND deleted_at IS NULL
-					AND (date >= ? OR ? = '')
-					AND (date <= ? OR ? = '')
-					AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-				) AS counted_trips) as total_trips,
-				COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-				COALESCE(SUM(fm.distance), 0) as total_distance,
-				COUNT(DISTINCT t.car_no_plate) as distinct_cars
-			FROM trips t
-			LEFT JOIN fee_mappings fm 
-				ON t.company = fm.company 
-				AND t.terminal = fm.terminal 
-				AND t.drop_off_point = fm.drop_off_point
-			WHERE t.company = ? AND t.deleted_at IS NULL
-			AND (t.date >= ? OR ? = '')
-			AND (t.date <= ? OR ? = '')
-			GROUP BY t.terminal, t.company
-		`, startDate, startDate, endDate, endDate, startDate, startDate, endDate, endDate, company, startDate, startDate, endDate, endDate).Scan(&routeData)
-
-		for _, route := range routeData {
-			var ratePerKm float64
-			if route.Terminal == "Alex" || route.Terminal == "Suez" {
-				ratePerKm = 40.7
-			} else {
-				ratePerKm = 0
-			}
-
-			baseRevenue := route.TotalDistance * ratePerKm
-
-			// CORRECTED: Calculate rental PER CAR, then sum
-			var carRentalFee float64 = 0
-
-			var carWorkingDays []struct {
-				CarNoPlate  string
-				WorkingDays int64
-			}
-
-			h.DB.Raw(`
-				SELECT 
-					car_no_plate,
-					COUNT(DISTINCT date) as working_days
-				FROM trips
-				WHERE company = ? 
-				AND deleted_at IS NULL
-				AND terminal = ?
-				AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-				AND (date >= ? OR ? = '')
-				AND (date <= ? OR ? = '')
-				GROUP BY car_no_plate
-			`, company, route.Terminal, startDate, startDate, endDate, endDate).Scan(&carWorkingDays)
-
-			for _, car := range carWorkingDays {
-				var carRental float64
-				if car.WorkingDays >= 28 {
-					carRental = 43000.0
-				} else {
-					carRental = 43000.0 - (float64(28-car.WorkingDays) * 1433.0)
-					if carRental < 0 {
-						carRental = 0
-					}
-				}
-				carRentalFee += carRental
-			}
-
-			// VAT applied AFTER adding base revenue and rental
-			vat := (baseRevenue + carRentalFee) * 0.14
-			totalRevenue := baseRevenue + carRentalFee + vat
-
-			routeStat := RouteRevenueStats{
-				RouteName:     route.Terminal,
-				Terminal:      route.Terminal,
-				RouteType:     "terminal",
-				TotalTrips:    route.TotalTrips,
-				TotalVolume:   route.TotalVolume,
-				TotalDistance: route.TotalDistance,
-				Fee:           ratePerKm,
-			}
-			if hasFinancialAccess {
-				routeStat.TotalRevenue = baseRevenue
-				routeStat.CarRental = carRentalFee
-				routeStat.VAT = vat
-				routeStat.TotalWithVAT = totalRevenue
-			}
-
-			// Get car stats with per-car rental calculation
-			var carStats []CarStats
-			h.DB.Raw(`
-				SELECT 
-					t.car_no_plate,
-					(SELECT COUNT(*) FROM (
-						SELECT DISTINCT parent_trip_id
-						FROM trips
-						WHERE company = ? AND deleted_at IS NULL
-						AND terminal = ?
-						AND car_no_plate = t.car_no_plate
-						AND (date >= ? OR ? = '')
-						AND (date <= ? OR ? = '')
-						AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-						UNION ALL
-						SELECT ID
-						FROM trips
-						WHERE company = ? AND deleted_at IS NULL
-						AND terminal = ?
-						AND car_no_plate = t.car_no_plate
-						AND (date >= ? OR ? = '')
-						AND (date <= ? OR ? = '')
-						AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					) AS counted_trips) as total_trips,
-					COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-					COALESCE(SUM(fm.distance), 0) as total_distance,
-					COUNT(DISTINCT CASE WHEN (t.parent_trip_id IS NULL OR t.parent_trip_id = 0) THEN t.date END) as working_days
-				FROM trips t
-				LEFT JOIN fee_mappings fm 
-					ON t.company = fm.company 
-					AND t.terminal = fm.terminal 
-					AND t.drop_off_point = fm.drop_off_point
-				WHERE t.company = ? AND t.deleted_at IS NULL
-				AND t.terminal = ?
-				AND (t.date >= ? OR ? = '')
-				AND (t.date <= ? OR ? = '')
-				GROUP BY t.car_no_plate
-			`, company, route.Terminal, startDate, startDate, endDate, endDate,
-				company, route.Terminal, startDate, startDate, endDate, endDate,
-				company, route.Terminal, startDate, startDate, endDate, endDate).Scan(&carStats)
-
-			for i := range carStats {
-				if hasFinancialAccess {
-					carBaseRevenue := carStats[i].TotalDistance * ratePerKm
-
-					// CORRECTED: Per-car rental calculation
-					var carRental float64
-					if carStats[i].WorkingDays >= 28 {
-						carRental = 43000.0
-					} else {
-						carRental = 43000.0 - (float64(28-carStats[i].WorkingDays) * 1433.0)
-						if carRental < 0 {
-							carRental = 0
-						}
-					}
-
-					// VAT applied AFTER adding base revenue and rental
-					carVAT := (carBaseRevenue + carRental) * 0.14
-					carTotal := carBaseRevenue + carRental + carVAT
-
-					carStats[i].TotalRevenue = carBaseRevenue
-					carStats[i].CarRental = carRental
-					carStats[i].VAT = carVAT
-					carStats[i].TotalWithVAT = carTotal
-				}
-			}
-
-			routeStat.Cars = carStats
-			routeStats = append(routeStats, routeStat)
+		switch contract.DetailGrouping {
+		case Models.GroupByDropOffPoint:
+			detail.GroupName = group.DropOffPoint
+		case Models.GroupByTerminal:
+			detail.GroupName = group.Terminal
+		case Models.GroupByFeeTier:
+			detail.GroupName = fmt.Sprintf("Fee %d", group.FeeTier)
+		case Models.GroupByRoute:
+			detail.GroupName = fmt.Sprintf("%s to %s", group.Terminal, group.DropOffPoint)
 		}
 
-	case "Petromin":
-		groupedTrips := h.groupPetrominTripsByCapacity(company, startDate, endDate)
-
-		terminalGroups := make(map[string][][]Models.TripStruct)
-		for _, tripGroup := range groupedTrips {
-			if len(tripGroup) > 0 {
-				terminal := tripGroup[0].Terminal
-				terminalGroups[terminal] = append(terminalGroups[terminal], tripGroup)
-			}
+		if contract.RentalRule() != nil {
+			detail.DistinctCars = groupSummary.DistinctCars
+			detail.DistinctDays = groupSummary.DistinctDays
+			detail.CarDays = groupSummary.CarDays
 		}
 
-		for terminal, groups := range terminalGroups {
-			var totalTrips int64 = 0
-			var totalVolume float64 = 0
-			var revenueTotalDistance float64 = 0
-			var distinctCars = make(map[string]bool)
-			var workingDaysSet = make(map[string]bool)
-
-			for _, tripGroup := range groups {
-				groupTrips, groupVolume, maxDistance, _ := h.calculateGroupStats(tripGroup)
-				totalTrips += groupTrips
-				totalVolume += groupVolume
-				revenueTotalDistance += maxDistance
-
-				for _, trip := range tripGroup {
-					distinctCars[trip.CarNoPlate] = true
-					workingDaysSet[trip.Date] = true
-				}
-			}
-
-			var terminalWorkingDays []struct {
-				CarNoPlate string
-				Date       string
-			}
-
-			h.DB.Raw(`
-				SELECT DISTINCT car_no_plate, date
-				FROM trips
-				WHERE company = ? AND deleted_at IS NULL
-				AND terminal = ?
-				AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-				AND (date >= ? OR ? = '')
-				AND (date <= ? OR ? = '')
-				ORDER BY car_no_plate, date
-			`, company, terminal, startDate, startDate, endDate, endDate).Scan(&terminalWorkingDays)
-
-			ratePerKm := 42.5
-			baseRevenue := revenueTotalDistance * ratePerKm
-			carRentalFee := float64(len(terminalWorkingDays)) * 2000.0
-			vat := (baseRevenue + carRentalFee) * 0.14
-			totalRevenue := baseRevenue + carRentalFee + vat
-
-			routeStat := RouteRevenueStats{
-				RouteName:     terminal,
-				Terminal:      terminal,
-				RouteType:     "terminal",
-				TotalTrips:    totalTrips,
-				TotalVolume:   totalVolume,
-				TotalDistance: revenueTotalDistance,
-				Fee:           ratePerKm,
-			}
-
-			if hasFinancialAccess {
-				routeStat.TotalRevenue = baseRevenue
-				routeStat.CarRental = carRentalFee
-				routeStat.VAT = vat
-				routeStat.TotalWithVAT = totalRevenue
+		if hasFinancialAccess {
+			detail.TotalRevenue = groupSummary.BaseRevenue
+			detail.CarRental = groupSummary.CarRental
+			detail.VAT = groupSummary.VAT
+			detail.TotalWithVAT = groupSummary.Total
+			detail.Fee = group.Rate
+			if contract.DetailGrouping == Models.GroupByFeeTier {
+				detail.Fee = float64(group.FeeTier)
 			}
+		}
 
-			var carStats []CarStats
-			carStatsMap := make(map[string]*CarStats)
+		companyStats.Details = append(companyStats.Details, detail)
+	}
 
-			for _, tripGroup := range groups {
-				if len(tripGroup) == 0 {
-					continue
-				}
+	return companyStats
+}
 
-				carPlate := tripGroup[0].CarNoPlate
-				groupTrips, groupVolume, maxDistance, groupWorkingDays := h.calculateGroupStats(tripGroup)
+func (h *TripHandler) GetTripStatsByRoute(company, startDate, endDate string, hasFinancialAccess bool) []RouteRevenueStats {
+	engine := NewPricingEngine(h.DB)
 
-				if carStatsMap[carPlate] == nil {
-					carStatsMap[carPlate] = &CarStats{
-						CarNoPlate: carPlate,
-					}
-				}
+	trips, err := engine.LoadTrips(company, startDate, endDate)
+	if err != nil {
+		log.Printf("Failed to load trips for %s: %v", company, err)
+		return nil
+	}
 
-				carStatsMap[carPlate].TotalTrips += groupTrips
-				carStatsMap[carPlate].TotalVolume += groupVolume
-				carStatsMap[carPlate].TotalDistance += maxDistance
-				carStatsMap[carPlate].WorkingDays += groupWorkingDays
-			}
+	priced, err := engine.Price(company, trips)
+	if err != nil {
+		log.Printf("Failed to price trips for %s: %v", company, err)
+		return nil
+	}
 
-			for _, carStat := range carStatsMap {
-				if hasFinancialAccess {
-					carBaseRevenue := carStat.TotalDistance * ratePerKm
-					carRental := float64(carStat.WorkingDays) * 2000.0
-					carVAT := (carBaseRevenue + carRental) * 0.14
-					carTotal := carBaseRevenue + carRental + carVAT
-
-					carStat.TotalRevenue = carBaseRevenue
-					carStat.CarRental = carRental
-					carStat.VAT = carVAT
-					carStat.TotalWithVAT = carTotal
-				}
+	return routeStatistics(priced, hasFinancialAccess)
+}
 
-				carStats = append(carStats, *carStat)
-			}
+// routeStatistics builds the route breakdown, with per-car figures, grouped as the contract specifies
+func routeStatistics(priced *PricedTrips, hasFinancialAccess bool) []RouteRevenueStats {
+	var routeStats []RouteRevenueStats
 
-			routeStat.Cars = carStats
-			routeStats = append(routeStats, routeStat)
+	grouping := priced.Contract.RouteGrouping
+	if grouping == "" {
+		grouping = Models.GroupByRoute
+	}
+
+	for _, group := range priced.Group(priced.Units, grouping) {
+		summary := priced.Summarize(group.Units)
+
+		routeStat := RouteRevenueStats{
+			TotalTrips:    summary.TotalTrips,
+			TotalVolume:   summary.TotalVolume,
+			TotalDistance: summary.TotalDistance,
+			Fee:           group.Rate,
+		}
+
+		switch grouping {
+		case Models.GroupByFeeTier:
+			routeStat.RouteName = fmt.Sprintf("Fee Category %d", group.FeeTier)
+			routeStat.RouteType = "fee"
+			routeStat.FeeCategory = group.FeeTier
+			routeStat.Fee = float64(group.FeeTier)
+		case Models.GroupByTerminal:
+			routeStat.RouteName = group.Terminal
+			routeStat.RouteType = "terminal"
+			routeStat.Terminal = group.Terminal
+		case Models.GroupByDropOffPoint:
+			routeStat.RouteName = group.DropOffPoint
+			routeStat.RouteType = "drop-off"
+			routeStat.DropOffPoint = group.DropOffPoint
+		default:
+			routeStat.RouteName = fmt.Sprintf("%s to %s", group.Terminal, group.DropOffPoint)
+			routeStat.RouteType = "terminal-dropoff"
+			routeStat.Terminal = group.Terminal
+			routeStat.DropOffPoint = group.DropOffPoint
 		}
 
-	case "Watanya":
-		var routeData []struct {
-			Fee           float64
-			TotalTrips    int64
-			TotalVolume   float64
-			TotalDistance float64
+		if hasFinancialAccess {
+			routeStat.TotalRevenue = summary.BaseRevenue
+			routeStat.CarRental = summary.CarRental
+			routeStat.VAT = summary.VAT
+			routeStat.TotalWithVAT = summary.Total
 		}
 
-		h.DB.Raw(`
-			SELECT 
-				f.fee, 
-				COUNT(*) as total_trips,
-				COALESCE(SUM(t.tank_capacity), 0) as total_volume, 
-				COALESCE(SUM(f.distance), 0) as total_distance
-			FROM trips t
-			LEFT JOIN fee_mappings f 
-				ON t.company = f.company 
-				AND t.terminal = f.terminal 
-				AND t.drop_off_point = f.drop_off_point
-			WHERE t.company = ? AND t.deleted_at IS NULL
-			AND (t.date >= ? OR ? = '')
-			AND (t.date <= ? OR ? = '')
-			GROUP BY f.fee
-		`, company, startDate, startDate, endDate, endDate).Scan(&routeData)
-
-		for _, route := range routeData {
-			var ratePerVolume float64
-
-			switch int(route.Fee) {
-			case 1:
-				ratePerVolume = 82.5
-			case 2:
-				ratePerVolume = 104.5
-			case 3:
-				ratePerVolume = 126.5
-			case 4:
-				ratePerVolume = 148.5
-			case 5:
-				ratePerVolume = 170.5
-			default:
-				ratePerVolume = 0
-			}
+		for _, carGroup := range priced.Group(group.Units, "car") {
+			carSummary := priced.Summarize(carGroup.Units)
 
-			baseRevenue := route.TotalVolume * ratePerVolume / 1000
-			vat := baseRevenue * 0.14
-			totalRevenue := baseRevenue + vat
-
-			routeStat := RouteRevenueStats{
-				RouteName:     fmt.Sprintf("Fee Category %d", int(route.Fee)),
-				RouteType:     "fee",
-				FeeCategory:   int(route.Fee),
-				TotalTrips:    route.TotalTrips,
-				TotalVolume:   route.TotalVolume,
-				TotalDistance: route.TotalDistance,
-				Fee:           route.Fee,
+			carStat := CarStats{
+				CarNoPlate:    carGroup.Key,
+				TotalTrips:    carSummary.TotalTrips,
+				TotalVolume:   carSummary.TotalVolume,
+				TotalDistance: carSummary.TotalDistance,
+				WorkingDays:   carSummary.CarDays,
 			}
 
 			if hasFinancialAccess {
-				routeStat.TotalRevenue = baseRevenue
-				routeStat.VAT = vat
-				routeStat.TotalWithVAT = totalRevenue
-			}
-
-			var carStats []CarStats
-			h.DB.Raw(`
-				SELECT 
-					t.car_no_plate,
-					COUNT(*) as total_trips,
-					COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-					COALESCE(SUM(f.distance), 0) as total_distance,
-					COUNT(DISTINCT t.date) as working_days
-				FROM trips t
-				LEFT JOIN fee_mappings f 
-					ON t.company = f.company 
-					AND t.terminal = f.terminal 
-					AND t.drop_off_point = f.drop_off_point
-				WHERE t.company = ? AND t.deleted_at IS NULL
-				AND f.fee = ?
-				AND (t.date >= ? OR ? = '')
-				AND (t.date <= ? OR ? = '')
-				GROUP BY t.car_no_plate
-			`, company, route.Fee, startDate, startDate, endDate, endDate).Scan(&carStats)
-
-			for i := range carStats {
-				if hasFinancialAccess {
-					carBaseRevenue := carStats[i].TotalVolume * ratePerVolume / 1000
-					carVAT := carBaseRevenue * 0.14
-					carTotal := carBaseRevenue + carVAT
-
-					carStats[i].TotalRevenue = carBaseRevenue
-					carStats[i].VAT = carVAT
-					carStats[i].TotalWithVAT = carTotal
-				}
+				carStat.TotalRevenue = carSummary.BaseRevenue
+				carStat.CarRental = carSummary.CarRental
+				carStat.VAT = carSummary.VAT
+				carStat.TotalWithVAT = carSummary.Total
 			}
 
-			routeStat.Cars = carStats
-			routeStats = append(routeStats, routeStat)
+			routeStat.Cars = append(routeStat.Cars, carStat)
 		}
 
-	default:
-		var routeData []struct {
-			Terminal      string
-			DropOffPoint  string
-			TotalTrips    int64
-			TotalVolume   float64
-			TotalDistance float64
-			AvgFee        float64
-		}
-
-		h.DB.Raw(`
-			SELECT 
-				t.terminal, 
-				t.drop_off_point,
-				(SELECT COUNT(*) FROM (
-					SELECT DISTINCT parent_trip_id
-					FROM trips
-					WHERE company = t.company
-					AND terminal = t.terminal
-					AND drop_off_point = t.drop_off_point
-					AND deleted_at IS NULL
-					AND (date >= ? OR ? = '')
-					AND (date <= ? OR ? = '')
-					AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-					UNION ALL
-					SELECT ID
-					FROM trips
-					WHERE company = t.company
-					AND terminal = t.terminal
-					AND drop_off_point = t.drop_off_point
-					AND deleted_at IS NULL
-					AND (date >= ? OR ? = '')
-					AND (date <= ? OR ? = '')
-					AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-				) AS counted_trips) as total_trips,
-				COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-				COALESCE(SUM(fm.distance), 0) as total_distance,
-				COALESCE(AVG(fm.fee), 50) as avg_fee
-			FROM trips t
-			LEFT JOIN fee_mappings fm 
-				ON t.company = fm.company 
-				AND t.terminal = fm.terminal 
-				AND t.drop_off_point = fm.drop_off_point
-			WHERE t.company = ? AND t.deleted_at IS NULL
-			AND (t.date >= ? OR ? = '')
-			AND (t.date <= ? OR ? = '')
-			GROUP BY t.terminal, t.drop_off_point, t.company
-		`, startDate, startDate, endDate, endDate, startDate, startDate, endDate, endDate, company, startDate, startDate, endDate, endDate).Scan(&routeData)
-
-		for _, route := range routeData {
-			revenue := route.TotalVolume * route.AvgFee
-
-			routeStat := RouteRevenueStats{
-				RouteName:     fmt.Sprintf("%s to %s", route.Terminal, route.DropOffPoint),
-				Terminal:      route.Terminal,
-				DropOffPoint:  route.DropOffPoint,
-				RouteType:     "terminal-dropoff",
-				TotalTrips:    route.TotalTrips,
-				TotalVolume:   route.TotalVolume,
-				TotalDistance: route.TotalDistance,
-				Fee:           route.AvgFee,
-			}
-
-			if hasFinancialAccess {
-				routeStat.TotalRevenue = revenue
-				routeStat.TotalWithVAT = revenue
-			}
-
-			var carStats []CarStats
-			h.DB.Raw(`
-				SELECT 
-					t.car_no_plate,
-					(SELECT COUNT(*) FROM (
-						SELECT DISTINCT parent_trip_id
-						FROM trips
-						WHERE company = ? AND deleted_at IS NULL
-						AND terminal = ? AND drop_off_point = ?
-						AND car_no_plate = t.car_no_plate
-						AND (date >= ? OR ? = '')
-						AND (date <= ? OR ? = '')
-						AND parent_trip_id IS NOT NULL AND parent_trip_id != 0
-						UNION ALL
-						SELECT ID
-						FROM trips
-						WHERE company = ? AND deleted_at IS NULL
-						AND terminal = ? AND drop_off_point = ?
-						AND car_no_plate = t.car_no_plate
-						AND (date >= ? OR ? = '')
-						AND (date <= ? OR ? = '')
-						AND (parent_trip_id IS NULL OR parent_trip_id = 0)
-					) AS counted_trips) as total_trips,
-					COALESCE(SUM(t.tank_capacity), 0) as total_volume,
-					COALESCE(SUM(fm.distance), 0) as total_distance,
-					COUNT(DISTINCT CASE WHEN (t.parent_trip_id IS NULL OR t.parent_trip_id = 0) THEN t.date END) as working_days
-				FROM trips t
-				LEFT JOIN fee_mappings fm 
-					ON t.company = fm.company 
-					AND t.terminal = fm.terminal 
-					AND t.drop_off_point = fm.drop_off_point
-				WHERE t.company = ? AND t.deleted_at IS NULL
-				AND t.terminal = ? AND t.drop_off_point = ?
-				AND (t.date >= ? OR ? = '')
-				AND (t.date <= ? OR ? = '')
-				GROUP BY t.car_no_plate
-			`, company, route.Terminal, route.DropOffPoint, startDate, startDate, endDate, endDate,
-				company, route.Terminal, route.DropOffPoint, startDate, startDate, endDate, endDate,
-				company, route.Terminal, route.DropOffPoint, startDate, startDate, endDate, endDate).Scan(&carStats)
-
-			for i := range carStats {
-				if hasFinancialAccess {
-					carStats[i].TotalRevenue = route.AvgFee * carStats[i].TotalVolume
-					carStats[i].TotalWithVAT = carStats[i].TotalRevenue
-				}
-			}
-
-			routeStat.Cars = carStats
-			routeStats = append(routeStats, routeStat)
-		}
+		routeStats = append(routeStats, routeStat)
 	}
 
 	return routeStats
@@ -1974,6 +616,36 @@ func (h *TripHandler) GetWatanyaDriverAnalytics(c *fiber.Ctx) error {
 		globalDistinctDays = 1
 	}
 
+	// Revenue (VAT included) comes from the Watanya pricing contract,
+	// indexed by driver, route and date
+	engine := NewPricingEngine(h.DB)
+	watanyaTrips, err := engine.LoadTrips("Watanya", startDate, endDate)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch Watanya trips",
+			"error":   err.Error(),
+		})
+	}
+
+	pricedTrips, err := engine.Price("Watanya", watanyaTrips)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to price Watanya trips",
+			"error":   err.Error(),
+		})
+	}
+
+	routeRevenues := make(map[string]float64)
+	for _, unit := range pricedTrips.Units {
+		if unit.Volume == 0 {
+			continue
+		}
+		for _, trip := range unit.Trips {
+			key := trip.DriverName + "|" + trip.Terminal + "|" + trip.DropOffPoint + "|" + trip.Date
+			routeRevenues[key] += unit.Revenue * float64(trip.TankCapacity) / unit.Volume * (1 + unit.VATRate)
+		}
+	}
+
 	type DriverPerformance struct {
 		Name    string
 		Revenue float64
@@ -2060,23 +732,6 @@ func (h *TripHandler) GetWatanyaDriverAnalytics(c *fiber.Ctx) error {
 				continue
 			}
 
-			var feeAmount float64
-			switch int64(feeMapping.Fee) {
-			case 1:
-				feeAmount = 82.5
-			case 2:
-				feeAmount = 104.5
-			case 3:
-				feeAmount = 126.5
-			case 4:
-				feeAmount = 148.5
-			case 5:
-				feeAmount = 170.5
-			default:
-				feeAmount = 0.0
-			}
-			feeAmount *= 1.14
-
 			// Get daily route details
 			// Count: unique parent_trip_id + standalone trips
 			// Sum: ALL volumes and distances (including all containers)
@@ -2139,8 +794,8 @@ func (h *TripHandler) GetWatanyaDriverAnalytics(c *fiber.Ctx) error {
 			}
 
 			for _, daily := range dailyRouteDetails {
-				// Calculate route revenue based on volume (all containers)
-				routeRevenue := (feeAmount * daily.Volume) / 1000.0
+				// Route revenue covers the volume of all containers
+				routeRevenue := routeRevenues[driverName+"|"+route.Terminal+"|"+route.DropOffPoint+"|"+daily.Date]
 
 				dailyRouteRevenuesMap[daily.Date] += routeRevenue
 				dailyTripsMap[daily.Date] += daily.Count
@@ -2422,13 +1077,19 @@ func (h *TripHandler) GetWatanyaTripsReport(c *fiber.Ctx) error {
 		})
 	}
 
-	// Define fee mapping (fee index to actual fee amount)
-	feeIndexToAmount := map[int]float64{
-		1: 82.5,
-		2: 104.5,
-		3: 126.5,
-		4: 148.5,
-		5: 170.5,
+	// Price the trips with the Watanya contract to get each trip's fee amount
+	pricedTrips, err := NewPricingEngine(h.DB).Price("Watanya", trips)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to price trips: " + err.Error(),
+		})
+	}
+
+	tripRates := make(map[uint]float64)
+	for _, unit := range pricedTrips.Units {
+		for _, trip := range unit.Trips {
+			tripRates[trip.ID] = unit.Rate
+		}
 	}
 
 	// Get the fee mappings from the database
@@ -2470,8 +1131,8 @@ func (h *TripHandler) GetWatanyaTripsReport(c *fiber.Ctx) error {
 			continue
 		}
 
-		// Determine the fee amount based on the fee index
-		actualFee := feeIndexToAmount[int(feeMapping.Fee)]
+		// Fee amount of the contract rule matching this route's fee index
+		actualFee := tripRates[trip.ID]
 
 		// Store fee information for this route (for the detailed sheet)
 		routeFeeInfo[key] = struct {
@@ -2813,8 +1474,7 @@ func (h *TripHandler) GetTrip(c *fiber.Ctx) error {
 		trip.Company, trip.Terminal, trip.DropOffPoint).First(&mapping)
 
 	if mapping.ID > 0 {
-		trip.Distance = mapping.Distance
-		trip.Fee = mapping.Fee
+		trip.Fee, trip.Distance = mapping.RateOn(h.DB, trip.Date)
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
@@ -3064,8 +1724,7 @@ func (h *TripHandler) GetTripDetails(c *fiber.Ctx) error {
 	}
 
 	// Add fee mapping data to trip (same as CreateTrip)
-	trip.Distance = mapping.Distance
-	trip.Fee = mapping.Fee
+	trip.Fee, trip.Distance = mapping.RateOn(h.DB, trip.Date)
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"message": "Trip details retrieved successfully",
@@ -3083,7 +1742,7 @@ func (h *TripHandler) GetTripDetails(c *fiber.Ctx) error {
 			if err != nil {
 				log.Printf("OSRM error: %v", err)
 				// Fallback to simple calculation
-				distance := calculateDistance(Terminal.Latitude, Terminal.Longitude, mapping.Latitude, mapping.Longitude)
+				distance := Models.DistanceKm(Terminal.Latitude, Terminal.Longitude, mapping.Latitude, mapping.Longitude)
 				return map[string]interface{}{
 					"distance": distance,
 					"duration": distance * 2 * 60, // rough estimate in seconds
@@ -3142,6 +1801,18 @@ func (h *TripHandler) CreateTrip(c *fiber.Ctx) error {
 		})
 	}
 
+	// Trips registered without a driver get the one assigned to the car that day
+	if trip.DriverID == 0 && trip.CarID != 0 {
+		if at, err := Models.DateMidday(trip.Date); err == nil {
+			if driver, err := Models.ResolveDriver(h.DB, trip.CarID, at); err != nil {
+				log.Println(err)
+			} else if driver.ID != 0 {
+				trip.DriverID = driver.ID
+				trip.DriverName = driver.Name
+			}
+		}
+	}
+
 	// Verify that the company, terminal, and drop-off point exist in mappings
 	var mapping Models.FeeMapping
 	result := h.DB.Where("company = ? AND terminal = ? AND drop_off_point = ?",
@@ -3170,11 +1841,12 @@ func (h *TripHandler) CreateTrip(c *fiber.Ctx) error {
 	}
 
 	// Add fee mapping data
-	trip.Distance = mapping.Distance
-	trip.Fee = mapping.Fee
+	trip.Fee, trip.Distance = mapping.RateOn(h.DB, trip.Date)
 
-	if err := Models.DB.Model(&Models.Car{}).Where("id = ?", trip.CarID).Update("driver_id", trip.DriverID).Error; err != nil {
-		log.Println(err)
+	if trip.DriverID != 0 {
+		if err := Models.AssignDriver(h.DB, trip.CarID, trip.DriverID, Models.WallClockNow(), Models.AssignmentSourceTrip); err != nil {
+			log.Println(err)
+		}
 	}
 
 	var Terminal Models.Terminal
@@ -3198,7 +1870,7 @@ func (h *TripHandler) CreateTrip(c *fiber.Ctx) error {
 			if err != nil {
 				log.Printf("OSRM error: %v", err)
 				// Fallback to simple calculation
-				distance := calculateDistance(Terminal.Latitude, Terminal.Longitude, mapping.Latitude, mapping.Longitude)
+				distance := Models.DistanceKm(Terminal.Latitude, Terminal.Longitude, mapping.Latitude, mapping.Longitude)
 				return map[string]interface{}{
 					"distance": distance,
 					"duration": distance * 2 * 60, // rough estimate in seconds
@@ -3220,31 +1892,6 @@ func (h *TripHandler) CreateTrip(c *fiber.Ctx) error {
 	})
 }
 
-func calculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
-	const R = 6371 // Earth's radius in kilometers
-
-	// Convert latitude and longitude from degrees to radians
-	lat1Rad := lat1 * math.Pi / 180
-	lng1Rad := lng1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	lng2Rad := lng2 * math.Pi / 180
-
-	// Haversine formula
-	dLat := lat2Rad - lat1Rad
-	dLng := lng2Rad - lng1Rad
-
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1Rad)*math.Cos(lat2Rad)*
-			math.Sin(dLng/2)*math.Sin(dLng/2)
-
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-
-	// Distance in kilometers
-	distance := R * c
-
-	return distance
-}
-
 // UpdateTrip updates an existing trip
 func (h *TripHandler) UpdateTrip(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
@@ -3271,6 +1918,15 @@ func (h *TripHandler) UpdateTrip(c *fiber.Ctx) error {
 		})
 	}
 
+	if invoice, err := Models.LockingInvoice(h.DB, existingTrip.ID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to check trip invoices",
+			"error":   err.Error(),
+		})
+	} else if invoice != nil {
+		return tripLockedResponse(c, invoice)
+	}
+
 	// Parse the update data
 	updatedTrip := new(Models.TripStruct)
 	if err := c.BodyParser(updatedTrip); err != nil {
@@ -3396,8 +2052,7 @@ func (h *TripHandler) UpdateTrip(c *fiber.Ctx) error {
 		existingTrip.Company, existingTrip.Terminal, existingTrip.DropOffPoint).First(&mapping)
 
 	if mapping.ID > 0 {
-		existingTrip.Distance = mapping.Distance
-		existingTrip.Fee = mapping.Fee
+		existingTrip.Fee, existingTrip.Distance = mapping.RateOn(h.DB, existingTrip.Date)
 	}
 	var Terminal Models.Terminal
 	if err := Models.DB.Model(&Models.Terminal{}).Where("name = ?", existingTrip.Terminal).First(&Terminal).Error; err != nil {
@@ -3420,7 +2075,7 @@ func (h *TripHandler) UpdateTrip(c *fiber.Ctx) error {
 			if err != nil {
 				log.Printf("OSRM error: %v", err)
 				// Fallback to simple calculation
-				distance := calculateDistance(Terminal.Latitude, Terminal.Longitude, mapping.Latitude, mapping.Longitude)
+				distance := Models.DistanceKm(Terminal.Latitude, Terminal.Longitude, mapping.Latitude, mapping.Longitude)
 				return map[string]interface{}{
 					"distance": distance,
 					"duration": distance * 2 * 60, // rough estimate in seconds
@@ -3467,6 +2122,15 @@ func (h *TripHandler) DeleteTrip(c *fiber.Ctx) error {
 		})
 	}
 
+	if invoice, err := Models.LockingInvoice(h.DB, trip.ID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to check trip invoices",
+			"error":   err.Error(),
+		})
+	} else if invoice != nil {
+		return tripLockedResponse(c, invoice)
+	}
+
 	// Perform soft delete (GORM default with DeletedAt field)
 	result = h.DB.Delete(&trip)
 	if result.Error != nil {
@@ -3693,8 +2357,7 @@ func (h *TripHandler) GetAllTrips(c *fiber.Ctx) error {
 			trips[i].Company, trips[i].Terminal, trips[i].DropOffPoint).First(&mapping)
 
 		if mapping.ID > 0 {
-			trips[i].Distance = mapping.Distance
-			trips[i].Fee = mapping.Fee
+			trips[i].Fee, trips[i].Distance = mapping.RateOn(h.DB, trips[i].Date)
 		}
 	}
 
@@ -3800,8 +2463,7 @@ func (h *TripHandler) GetTripsByCompany(c *fiber.Ctx) error {
 			trips[i].Company, trips[i].Terminal, trips[i].DropOffPoint).First(&mapping)
 
 		if mapping.ID > 0 {
-			trips[i].Distance = mapping.Distance
-			trips[i].Fee = mapping.Fee
+			trips[i].Fee, trips[i].Distance = mapping.RateOn(h.DB, trips[i].Date)
 		}
 	}
 
@@ -3919,8 +2581,7 @@ func (h *TripHandler) GetTripsByDate(c *fiber.Ctx) error {
 			trips[i].Company, trips[i].Terminal, trips[i].DropOffPoint).First(&mapping)
 
 		if mapping.ID > 0 {
-			trips[i].Distance = mapping.Distance
-			trips[i].Fee = mapping.Fee
+			trips[i].Fee, trips[i].Distance = mapping.RateOn(h.DB, trips[i].Date)
 		}
 	}
 
@@ -4066,9 +2727,11 @@ func (h *TripHandler) CreateMultiContainerTrip(c *fiber.Ctx) error {
 	}
 
 	// Update car's driver assignment
-	if err := tx.Model(&Models.Car{}).Where("id = ?", input.ParentTrip.CarID).
-		Update("driver_id", input.ParentTrip.DriverID).Error; err != nil {
-		log.Println("Warning: Failed to update car driver assignment:", err)
+	if input.ParentTrip.DriverID != 0 {
+		if err := Models.AssignDriver(tx, input.ParentTrip.CarID, input.ParentTrip.DriverID,
+			Models.WallClockNow(), Models.AssignmentSourceTrip); err != nil {
+			log.Println("Warning: Failed to update car driver assignment:", err)
+		}
 	}
 
 	// Commit transaction
@@ -4199,6 +2862,17 @@ func (h *TripHandler) UpdateParentTrip(c *fiber.Ctx) error {
 		})
 	}
 
+	var containerIDs []uint
+	h.DB.Model(&Models.TripStruct{}).Where("parent_trip_id = ?", uint(id)).Pluck("id", &containerIDs)
+	if invoice, err := Models.LockingInvoice(h.DB, containerIDs...); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to check trip invoices",
+			"error":   err.Error(),
+		})
+	} else if invoice != nil {
+		return tripLockedResponse(c, invoice)
+	}
+
 	// Begin transaction
 	tx := h.DB.Begin()
 	defer func() {
@@ -4512,6 +3186,17 @@ func (h *TripHandler) DeleteParentTrip(c *fiber.Ctx) error {
 		})
 	}
 
+	var containerIDs []uint
+	h.DB.Model(&Models.TripStruct{}).Where("parent_trip_id = ?", uint(id)).Pluck("id", &containerIDs)
+	if invoice, err := Models.LockingInvoice(h.DB, containerIDs...); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to check trip invoices",
+			"error":   err.Error(),
+		})
+	} else if invoice != nil {
+		return tripLockedResponse(c, invoice)
+	}
+
 	// Begin transaction
 	tx := h.DB.Begin()
 	defer func() {
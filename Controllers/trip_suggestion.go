@@ -0,0 +1,266 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// TripSuggestionHandler contains handler methods for the trips detected from GPS geofences
+type TripSuggestionHandler struct {
+	DB *gorm.DB
+}
+
+// NewTripSuggestionHandler creates a new trip suggestion handler
+func NewTripSuggestionHandler(db *gorm.DB) *TripSuggestionHandler {
+	return &TripSuggestionHandler{
+		DB: db,
+	}
+}
+
+func (h *TripSuggestionHandler) filteredSuggestions(c *fiber.Ctx) *gorm.DB {
+	query := h.DB.Model(&Models.TripSuggestion{})
+
+	if company := c.Query("company"); company != "" {
+		query = query.Where("company = ?", company)
+	}
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("date >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("date <= ?", endDate)
+	}
+	return query
+}
+
+// GetTripSuggestions lists the detected trips, pending ones by default
+func (h *TripSuggestionHandler) GetTripSuggestions(c *fiber.Ctx) error {
+	query := h.filteredSuggestions(c).Where("status = ?", c.Query("status", Models.TripSuggestionPending))
+
+	var suggestions []Models.TripSuggestion
+	if err := query.Order("date DESC, car_no_plate ASC").Find(&suggestions).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch trip suggestions",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Trip suggestions retrieved successfully",
+		"data":    suggestions,
+	})
+}
+
+// GetMissingTrips reports the detected trips no registered trip accounts for. Pending
+// suggestions are matched again first, since trips are often registered after the fact.
+func (h *TripSuggestionHandler) GetMissingTrips(c *fiber.Ctx) error {
+	var pending []Models.TripSuggestion
+	if err := h.filteredSuggestions(c).Where("status = ?", Models.TripSuggestionPending).
+		Order("date ASC, car_no_plate ASC").Find(&pending).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch trip suggestions",
+			"error":   err.Error(),
+		})
+	}
+
+	missing := []Models.TripSuggestion{}
+	missingByCar := make(map[string]int)
+	for i := range pending {
+		suggestion := &pending[i]
+		if err := Models.MatchTripSuggestion(h.DB, suggestion); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to match trip suggestions",
+				"error":   err.Error(),
+			})
+		}
+
+		if suggestion.Status == Models.TripSuggestionMatched {
+			if err := h.DB.Save(suggestion).Error; err != nil {
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"message": "Failed to update trip suggestion",
+					"error":   err.Error(),
+				})
+			}
+			continue
+		}
+
+		missing = append(missing, *suggestion)
+		missingByCar[suggestion.CarNoPlate]++
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Missing trips retrieved successfully",
+		"data": fiber.Map{
+			"trips":   missing,
+			"count":   len(missing),
+			"per_car": missingByCar,
+		},
+	})
+}
+
+// ConfirmTripSuggestion registers a pending suggestion as a trip. The dispatcher may correct
+// the suggested fields and adds those GPS cannot know.
+func (h *TripSuggestionHandler) ConfirmTripSuggestion(c *fiber.Ctx) error {
+	suggestion, err := h.findSuggestion(c)
+	if suggestion == nil {
+		return err
+	}
+
+	if suggestion.Status != Models.TripSuggestionPending {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Only pending trip suggestions can be confirmed",
+		})
+	}
+
+	var input struct {
+		DriverID     uint   `json:"driver_id"`
+		DriverName   string `json:"driver_name"`
+		Terminal     string `json:"terminal"`
+		DropOffPoint string `json:"drop_off_point"`
+		Date         string `json:"date"`
+		LocationName string `json:"location_name"`
+		Capacity     int    `json:"capacity"`
+		GasType      string `json:"gas_type"`
+		ReceiptNo    string `json:"receipt_no"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	trip := Models.TripStruct{
+		CarID:        suggestion.CarID,
+		DriverID:     suggestion.DriverID,
+		CarNoPlate:   suggestion.CarNoPlate,
+		DriverName:   suggestion.DriverName,
+		Transporter:  suggestion.Transporter,
+		TankCapacity: suggestion.TankCapacity,
+		Company:      suggestion.Company,
+		Terminal:     suggestion.Terminal,
+		DropOffPoint: suggestion.DropOffPoint,
+		LocationName: input.LocationName,
+		Capacity:     input.Capacity,
+		GasType:      input.GasType,
+		Date:         suggestion.Date,
+		Mileage:      suggestion.Mileage,
+		ReceiptNo:    input.ReceiptNo,
+	}
+	if input.DriverID != 0 {
+		trip.DriverID = input.DriverID
+		trip.DriverName = input.DriverName
+	}
+	if input.Terminal != "" {
+		trip.Terminal = input.Terminal
+	}
+	if input.DropOffPoint != "" {
+		trip.DropOffPoint = input.DropOffPoint
+	}
+	if input.Date != "" {
+		trip.Date = input.Date
+	}
+	if trip.Capacity == 0 {
+		trip.Capacity = trip.TankCapacity
+	}
+
+	// Same validation as a manually registered trip
+	var mapping Models.FeeMapping
+	if err := h.DB.Where("company = ? AND terminal = ? AND drop_off_point = ?",
+		trip.Company, trip.Terminal, trip.DropOffPoint).First(&mapping).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid mapping: the specified company, terminal, and drop-off point combination doesn't exist",
+			})
+		}
+
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to validate mapping",
+			"error":   err.Error(),
+		})
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&trip).Error; err != nil {
+			return err
+		}
+		suggestion.Status = Models.TripSuggestionConfirmed
+		suggestion.TripID = &trip.ID
+		return tx.Save(suggestion).Error
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create trip",
+			"error":   err.Error(),
+		})
+	}
+
+	trip.Fee, trip.Distance = mapping.RateOn(h.DB, trip.Date)
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Trip created successfully",
+		"data": fiber.Map{
+			"trip":       trip,
+			"suggestion": suggestion,
+		},
+	})
+}
+
+// DismissTripSuggestion discards a pending suggestion that was not a trip
+func (h *TripSuggestionHandler) DismissTripSuggestion(c *fiber.Ctx) error {
+	suggestion, err := h.findSuggestion(c)
+	if suggestion == nil {
+		return err
+	}
+
+	if suggestion.Status != Models.TripSuggestionPending {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Only pending trip suggestions can be dismissed",
+		})
+	}
+
+	suggestion.Status = Models.TripSuggestionDismissed
+	if err := h.DB.Save(suggestion).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to dismiss trip suggestion",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Trip suggestion dismissed",
+		"data":    suggestion,
+	})
+}
+
+func (h *TripSuggestionHandler) findSuggestion(c *fiber.Ctx) (*Models.TripSuggestion, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var suggestion Models.TripSuggestion
+	if err := h.DB.First(&suggestion, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Trip suggestion not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch trip suggestion",
+			"error":   err.Error(),
+		})
+	}
+
+	return &suggestion, nil
+}
@@ -2,8 +2,11 @@ package Controllers
 
 import (
 	"Falcon/Models"
+	"fmt"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 func GetAllTrucks(c *fiber.Ctx) error {
@@ -19,28 +22,37 @@ func GetTruck(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var truck Models.Truck
 
-	if err := Models.DB.Preload("Positions.Tire").First(&truck, id).Error; err != nil {
+	if err := Models.DB.Preload("Car").Preload("Positions.Tire").First(&truck, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Truck not found"})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(truck)
 }
 
-// CreateTruck creates a new truck with default tire positions
+// CreateTruck registers a unit and generates its tire positions from its axle layout
 func CreateTruck(c *fiber.Ctx) error {
 	var truck Models.Truck
 	if err := c.BodyParser(&truck); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-
-	// Create the truck
-	if err := Models.DB.Create(&truck).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	// Trailers are coupled through their own route, positions come from the layout
+	truck.HeadID = nil
+	truck.Positions = nil
+	if err := truck.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if status, err := checkTruckCar(truck.CarID, 0); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Create default positions for the truck
-	if err := Models.CreateDefaultPositions(Models.DB, truck.ID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create default positions"})
+	err := Models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&truck).Error; err != nil {
+			return err
+		}
+		return Models.CreateTemplatePositions(tx, truck)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(truck)
@@ -61,6 +73,14 @@ func UpdateTruck(c *fiber.Ctx) error {
 	if err := c.BodyParser(&updateData); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	// The unit type and layout are fixed by the positions generated, coupling has its own route
+	updateData.UnitType = ""
+	updateData.AxleLayout = ""
+	updateData.HeadID = nil
+	updateData.Positions = nil
+	if status, err := checkTruckCar(updateData.CarID, truck.ID); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	// Update truck fields
 	Models.DB.Model(&truck).Updates(updateData)
@@ -77,6 +97,12 @@ func DeleteTruck(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Truck not found"})
 	}
 
+	var pulling int64
+	Models.DB.Model(&Models.Truck{}).Where("head_id = ?", truck.ID).Count(&pulling)
+	if truck.HeadID != nil || pulling > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Decouple the trailer before deleting the unit"})
+	}
+
 	// Delete associated positions first
 	Models.DB.Where("truck_id = ?", id).Delete(&Models.TirePosition{})
 
@@ -85,3 +111,104 @@ func DeleteTruck(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Truck deleted successfully"})
 }
+
+// GetAxleTemplates lists the axle layouts units are registered with
+func GetAxleTemplates(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(Models.AxleTemplates)
+}
+
+// CoupleTrailer couples a trailer to a head
+func CoupleTrailer(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid truck ID"})
+	}
+	var request struct {
+		HeadID uint   `json:"head_id"`
+		Date   string `json:"date"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	at, by, err := couplingTime(c, request.Date)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	coupling, err := Models.CoupleTrailer(Models.DB, uint(id), request.HeadID, at, by)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(coupling)
+}
+
+// DecoupleTrailer takes a trailer off its head
+func DecoupleTrailer(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid truck ID"})
+	}
+	var request struct {
+		Date string `json:"date"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	at, by, err := couplingTime(c, request.Date)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	coupling, err := Models.DecoupleTrailer(Models.DB, uint(id), at, by)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(coupling)
+}
+
+// GetTruckCouplings fetches the couplings of a head or trailer, latest first
+func GetTruckCouplings(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var truck Models.Truck
+	if err := Models.DB.First(&truck, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Truck not found"})
+	}
+
+	var couplings []Models.TruckCoupling
+	if err := Models.DB.Where("head_id = ? OR trailer_id = ?", truck.ID, truck.ID).
+		Order("coupled_at DESC, id DESC").Find(&couplings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(couplings)
+}
+
+// checkTruckCar checks a car exists and is not linked to another unit
+func checkTruckCar(carID *uint, truckID uint) (int, error) {
+	if carID == nil {
+		return fiber.StatusOK, nil
+	}
+	var car Models.Car
+	if err := Models.DB.Select("id").First(&car, *carID).Error; err != nil {
+		return fiber.StatusBadRequest, fmt.Errorf("car %d not found", *carID)
+	}
+	var linked int64
+	Models.DB.Model(&Models.Truck{}).Where("car_id = ? AND id <> ?", car.ID, truckID).Count(&linked)
+	if linked > 0 {
+		return fiber.StatusConflict, fmt.Errorf("car %d is already linked to another unit", car.ID)
+	}
+	return fiber.StatusOK, nil
+}
+
+// couplingTime returns when a coupling happened, now unless a date is given, and who records it
+func couplingTime(c *fiber.Ctx, date string) (time.Time, string, error) {
+	event, err := tireEvent(c, TireEventInput{Date: date})
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	if event.Date.IsZero() {
+		event.Date = Models.WallClockNow()
+	}
+	return event.Date, event.RecordedBy, nil
+}
@@ -0,0 +1,148 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// VehicleCostHandler contains handler methods for the vehicle cost ledger and the cost of
+// ownership reports built on it
+type VehicleCostHandler struct {
+	DB *gorm.DB
+}
+
+// NewVehicleCostHandler creates a new vehicle cost handler
+func NewVehicleCostHandler(db *gorm.DB) *VehicleCostHandler {
+	return &VehicleCostHandler{
+		DB: db,
+	}
+}
+
+// costRange parses the optional start_date and end_date query parameters. A missing date
+// leaves the range open on that side.
+func costRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	var from, to time.Time
+	if startDate := c.Query("start_date"); startDate != "" {
+		parsed, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid start_date %q, expected YYYY-MM-DD", startDate)
+		}
+		from = parsed
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		parsed, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid end_date %q, expected YYYY-MM-DD", endDate)
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return from, to, fmt.Errorf("end_date must not be before start_date")
+	}
+	return from, to, nil
+}
+
+// GetVehicleCosts returns the ledger entries, newest first
+func (h *VehicleCostHandler) GetVehicleCosts(c *fiber.Ctx) error {
+	from, to, err := costRange(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+
+	carID, _ := strconv.ParseUint(c.Query("car_id"), 10, 64)
+	query := Models.VehicleCosts(h.DB, uint(carID), from, to)
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	var entries []Models.VehicleCostEntry
+	if err := query.Order("date DESC, id DESC").Find(&entries).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch vehicle costs",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Vehicle costs retrieved successfully",
+		"data":    entries,
+	})
+}
+
+// GetCostSummaries returns the cost of ownership, cost per km and cost per delivered liter of
+// every car, costliest first
+func (h *VehicleCostHandler) GetCostSummaries(c *fiber.Ctx) error {
+	return h.costSummaries(c, 0)
+}
+
+// GetCarCostSummary returns the cost of ownership, cost per km and cost per delivered liter
+// of a car
+func (h *VehicleCostHandler) GetCarCostSummary(c *fiber.Ctx) error {
+	carID, err := strconv.ParseUint(c.Params("car_id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid car ID",
+			"error":   err.Error(),
+		})
+	}
+	return h.costSummaries(c, uint(carID))
+}
+
+func (h *VehicleCostHandler) costSummaries(c *fiber.Ctx, carID uint) error {
+	from, to, err := costRange(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+
+	summaries, err := Models.VehicleCostSummaries(h.DB, carID, from, to)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to compute vehicle costs",
+			"error":   err.Error(),
+		})
+	}
+
+	if carID != 0 {
+		if len(summaries) == 0 {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "No costs recorded for the car",
+			})
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"message": "Vehicle cost summary retrieved successfully",
+			"data":    summaries[0],
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Vehicle cost summaries retrieved successfully",
+		"data":    summaries,
+	})
+}
+
+// RefreshVehicleCosts rebuilds the ledger without waiting for the scheduled refresh
+func (h *VehicleCostHandler) RefreshVehicleCosts(c *fiber.Ctx) error {
+	count, err := Models.RefreshVehicleCosts(h.DB)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to refresh vehicle costs",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": fmt.Sprintf("Vehicle costs refreshed, %d entries", count),
+	})
+}
@@ -0,0 +1,146 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// VehiclePositionHandler contains handler methods for the GPS position history routes
+type VehiclePositionHandler struct {
+	DB *gorm.DB
+}
+
+// NewVehiclePositionHandler creates a new vehicle position handler
+func NewVehiclePositionHandler(db *gorm.DB) *VehiclePositionHandler {
+	return &VehiclePositionHandler{
+		DB: db,
+	}
+}
+
+// maxTrackWindow bounds the time window of a single track request
+const maxTrackWindow = 31 * 24 * time.Hour
+
+var positionTimeLayouts = []string{
+	Models.PositionTimeLayout,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+func parsePositionTime(value string) (time.Time, error) {
+	for _, layout := range positionTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected YYYY-MM-DD HH:MM:SS", value)
+}
+
+// GetVehicleTrack returns the recorded positions of a car between the from and to times
+func (h *VehiclePositionHandler) GetVehicleTrack(c *fiber.Ctx) error {
+	carID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid car ID",
+			"error":   err.Error(),
+		})
+	}
+
+	from, err := parsePositionTime(c.Query("from"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid from time",
+			"error":   err.Error(),
+		})
+	}
+	to, err := parsePositionTime(c.Query("to"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid to time",
+			"error":   err.Error(),
+		})
+	}
+	if to.Before(from) || to.Sub(from) > maxTrackWindow {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid time window",
+			"error":   "to must follow from by at most 31 days",
+		})
+	}
+
+	var car Models.Car
+	if err := h.DB.First(&car, carID).Error; err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"message": "Car not found",
+			"error":   err.Error(),
+		})
+	}
+
+	positions, err := Models.VehicleTrack(h.DB, car.ID, from, to)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch vehicle track",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Vehicle track retrieved successfully",
+		"data": fiber.Map{
+			"car_id":       car.ID,
+			"car_no_plate": car.CarNoPlate,
+			"from":         from.Format(Models.PositionTimeLayout),
+			"to":           to.Format(Models.PositionTimeLayout),
+			"positions":    positions,
+		},
+	})
+}
+
+// GetFleetSnapshot returns where every car was at the given instant, defaulting to now.
+// max_age, in minutes, drops cars whose last position is older (default 60).
+func (h *VehiclePositionHandler) GetFleetSnapshot(c *fiber.Ctx) error {
+	// Positions keep the provider's wall clock time, so is "now"
+	at := Models.WallClockNow()
+	if value := c.Query("at"); value != "" {
+		parsed, err := parsePositionTime(value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid snapshot time",
+				"error":   err.Error(),
+			})
+		}
+		at = parsed
+	}
+
+	maxAge := 60
+	if value := c.Query("max_age"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid max_age",
+				"error":   "max_age must be a positive number of minutes",
+			})
+		}
+		maxAge = parsed
+	}
+
+	positions, err := Models.FleetSnapshot(h.DB, at, time.Duration(maxAge)*time.Minute)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch fleet snapshot",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Fleet snapshot retrieved successfully",
+		"data": fiber.Map{
+			"at":        at.Format(Models.PositionTimeLayout),
+			"positions": positions,
+		},
+	})
+}
@@ -64,6 +64,7 @@ type CreateTransactionInput struct {
 	Amount      float64 `json:"amount"`
 	Date        string  `json:"date"`
 	Type        string  `json:"type"`
+	CarID       *uint   `json:"car_id"`
 }
 
 func (c *TransactionController) CreateTransaction(ctx *fiber.Ctx) error {
@@ -103,6 +104,7 @@ func (c *TransactionController) CreateTransaction(ctx *fiber.Ctx) error {
 
 	transaction := Models.VendorTransaction{
 		VendorID:    uint(vendorID),
+		CarID:       input.CarID,
 		Date:        date,
 		Description: input.Description,
 		Amount:      input.Amount,
@@ -167,6 +169,13 @@ func (c *TransactionController) UpdateTransaction(ctx *fiber.Ctx) error {
 		updates["amount"] = amount
 	}
 
+	// A car ID of 0 unlinks the transaction from its car
+	if input.CarID != nil && *input.CarID == 0 {
+		updates["car_id"] = nil
+	} else if input.CarID != nil {
+		updates["car_id"] = *input.CarID
+	}
+
 	// Apply updates if any
 	if len(updates) > 0 {
 		c.DB.Model(&transaction).Updates(updates)
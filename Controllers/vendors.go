@@ -59,6 +59,7 @@ func (c *VendorController) CreateVendor(ctx *fiber.Ctx) error {
 	vendor := Models.Vendor{
 		Name:    input.Name,
 		Contact: input.Contact,
+		TaxID:   input.TaxID,
 		Notes:   input.Notes,
 	}
 
@@ -102,6 +103,7 @@ func (c *VendorController) UpdateVendor(ctx *fiber.Ctx) error {
 	c.DB.Model(&vendor).Updates(Models.Vendor{
 		Name:    input.Name,
 		Contact: input.Contact,
+		TaxID:   input.TaxID,
 		Notes:   input.Notes,
 	})
 
@@ -0,0 +1,402 @@
+package Controllers
+
+import (
+	"Falcon/Models"
+	"Falcon/Slack"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// WorkOrderHandler contains handler methods for maintenance work orders and the downtime
+// they record
+type WorkOrderHandler struct {
+	DB *gorm.DB
+}
+
+// NewWorkOrderHandler creates a new work order handler
+func NewWorkOrderHandler(db *gorm.DB) *WorkOrderHandler {
+	return &WorkOrderHandler{
+		DB: db,
+	}
+}
+
+// WorkOrderInput is the body of work order creation and updates
+type WorkOrderInput struct {
+	CarID            uint                   `json:"car_id"`
+	ServiceInvoiceID *uint                  `json:"service_invoice_id"` // 0 unlinks the invoice on update
+	Description      string                 `json:"description"`
+	OdometerKm       int                    `json:"odometer_km"`
+	OpenedAt         string                 `json:"opened_at"` // "2006-01-02 15:04:05", now when empty
+	LabourHours      float64                `json:"labour_hours"`
+	LabourCost       float64                `json:"labour_cost"`
+	Parts            []Models.WorkOrderPart `json:"parts"`
+}
+
+// GetWorkOrders returns the work orders, newest first, optionally of one car or status
+func (h *WorkOrderHandler) GetWorkOrders(c *fiber.Ctx) error {
+	query := h.DB.Model(&Models.WorkOrder{}).Preload("Parts")
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []Models.WorkOrder
+	if err := query.Order("opened_at DESC, id DESC").Find(&orders).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch work orders",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Work orders retrieved successfully",
+		"data":    orders,
+	})
+}
+
+// GetWorkOrder returns a work order with its parts and service invoice
+func (h *WorkOrderHandler) GetWorkOrder(c *fiber.Ctx) error {
+	order, err := h.findWorkOrder(c)
+	if order == nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Work order retrieved successfully",
+		"data":    order,
+	})
+}
+
+// CreateWorkOrder opens a work order and stops the car for maintenance
+func (h *WorkOrderHandler) CreateWorkOrder(c *fiber.Ctx) error {
+	var input WorkOrderInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	if input.CarID == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "car_id is required",
+		})
+	}
+
+	order := Models.WorkOrder{CarID: input.CarID}
+	if err := h.applyWorkOrderInput(&order, input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid work order",
+			"error":   err.Error(),
+		})
+	}
+	if input.OpenedAt != "" {
+		openedAt, err := time.Parse(Models.PositionTimeLayout, input.OpenedAt)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid opened_at",
+				"error":   err.Error(),
+			})
+		}
+		order.OpenedAt = openedAt
+	}
+	if user, ok := c.Locals("user").(Models.User); ok {
+		order.OpenedBy = user.Name
+	}
+
+	car, err := Models.OpenWorkOrder(h.DB, &order)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Car not found",
+			})
+		}
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Failed to open work order",
+			"error":   err.Error(),
+		})
+	}
+	Slack.QueueSlackUpdate(car.OperatingCompany)
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message": "Work order opened successfully",
+		"data":    order,
+	})
+}
+
+// UpdateWorkOrder updates the description, service invoice, labour and parts of a work order.
+// The parts in the body replace the order's bought parts, parts issued from stock stay.
+func (h *WorkOrderHandler) UpdateWorkOrder(c *fiber.Ctx) error {
+	order, err := h.findWorkOrder(c)
+	if order == nil {
+		return err
+	}
+
+	var input WorkOrderInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	if err := h.applyWorkOrderInput(order, input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid work order",
+			"error":   err.Error(),
+		})
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("work_order_id = ? AND stock_movement_id IS NULL", order.ID).
+			Delete(&Models.WorkOrderPart{}).Error; err != nil {
+			return err
+		}
+		for i := range order.Parts {
+			if order.Parts[i].StockMovementID == nil {
+				order.Parts[i].ID = 0
+			}
+			order.Parts[i].WorkOrderID = order.ID
+		}
+		order.ServiceInvoice = nil
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(order).Error
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update work order",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Work order updated successfully",
+		"data":    order,
+	})
+}
+
+// StartWorkOrder marks the repair of an open work order as started
+func (h *WorkOrderHandler) StartWorkOrder(c *fiber.Ctx) error {
+	order, err := h.findWorkOrder(c)
+	if order == nil {
+		return err
+	}
+
+	if err := order.Transition(Models.WorkOrderInProgress, time.Now()); err != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Failed to start work order",
+			"error":   err.Error(),
+		})
+	}
+	if err := h.DB.Omit("Parts", "ServiceInvoice").Save(order).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to start work order",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Work order started successfully",
+		"data":    order,
+	})
+}
+
+// CloseWorkOrder closes a work order and puts the car back in service
+func (h *WorkOrderHandler) CloseWorkOrder(c *fiber.Ctx) error {
+	order, err := h.findWorkOrder(c)
+	if order == nil {
+		return err
+	}
+
+	var input struct {
+		ClosedAt string `json:"closed_at"` // "2006-01-02 15:04:05", now when empty
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			})
+		}
+	}
+	closedAt := time.Now()
+	if input.ClosedAt != "" {
+		parsed, err := time.Parse(Models.PositionTimeLayout, input.ClosedAt)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid closed_at",
+				"error":   err.Error(),
+			})
+		}
+		closedAt = parsed
+	}
+
+	var closedBy string
+	if user, ok := c.Locals("user").(Models.User); ok {
+		closedBy = user.Name
+	}
+	car, err := Models.CloseWorkOrder(h.DB, order, closedAt, closedBy)
+	if err != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "Failed to close work order",
+			"error":   err.Error(),
+		})
+	}
+	Slack.QueueSlackUpdate(car.OperatingCompany)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Work order closed successfully",
+		"data":    order,
+	})
+}
+
+// DeleteWorkOrder deletes a work order opened by mistake. An active order is closed first so
+// the car goes back in service. Orders that took parts from stock are kept.
+func (h *WorkOrderHandler) DeleteWorkOrder(c *fiber.Ctx) error {
+	order, err := h.findWorkOrder(c)
+	if order == nil {
+		return err
+	}
+
+	for _, part := range order.Parts {
+		if part.StockMovementID != nil {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"message": "Parts were issued from stock to this work order, close it instead",
+			})
+		}
+	}
+
+	if order.IsActive() {
+		car, err := Models.CloseWorkOrder(h.DB, order, time.Now(), "")
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to close work order",
+				"error":   err.Error(),
+			})
+		}
+		Slack.QueueSlackUpdate(car.OperatingCompany)
+	}
+
+	if err := h.DB.Select("Parts").Delete(order).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete work order",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Work order deleted successfully",
+	})
+}
+
+// GetDowntime returns the hours each car spent on work orders between start_date and
+// end_date, with the repair cost of those orders, longest downtime first
+func (h *WorkOrderHandler) GetDowntime(c *fiber.Ctx) error {
+	from, to, err := costRange(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+
+	query := h.DB.Model(&Models.WorkOrder{})
+	if !to.IsZero() {
+		query = query.Where("opened_at < ?", to)
+	}
+	if !from.IsZero() {
+		query = query.Where("closed_at IS NULL OR closed_at > ?", from)
+	}
+	if carID := c.Query("car_id"); carID != "" {
+		query = query.Where("car_id = ?", carID)
+	}
+
+	var orders []Models.WorkOrder
+	if err := query.Find(&orders).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch work orders",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Downtime retrieved successfully",
+		"data":    Models.SummarizeDowntime(orders, from, to, time.Now()),
+	})
+}
+
+// applyWorkOrderInput copies the editable fields of the input onto the order, checking its
+// service invoice is of the same car and filling the vendor names of the parts
+func (h *WorkOrderHandler) applyWorkOrderInput(order *Models.WorkOrder, input WorkOrderInput) error {
+	if input.LabourHours < 0 || input.LabourCost < 0 {
+		return fmt.Errorf("labour must not be negative")
+	}
+
+	if input.ServiceInvoiceID != nil && *input.ServiceInvoiceID == 0 {
+		order.ServiceInvoiceID = nil
+	} else if input.ServiceInvoiceID != nil {
+		var invoice Models.ServiceInvoice
+		if err := h.DB.Select("id", "car_id").First(&invoice, *input.ServiceInvoiceID).Error; err != nil {
+			return fmt.Errorf("service invoice %d not found", *input.ServiceInvoiceID)
+		}
+		if invoice.CarID != order.CarID {
+			return fmt.Errorf("service invoice %d is of another car", invoice.ID)
+		}
+		order.ServiceInvoiceID = &invoice.ID
+	}
+
+	for i := range input.Parts {
+		part := &input.Parts[i]
+		if part.VendorID == nil {
+			continue
+		}
+		var vendor Models.Vendor
+		if err := h.DB.Select("id", "name").First(&vendor, *part.VendorID).Error; err != nil {
+			return fmt.Errorf("vendor %d not found", *part.VendorID)
+		}
+		part.VendorName = vendor.Name
+	}
+	if err := order.SetParts(input.Parts); err != nil {
+		return err
+	}
+
+	if input.Description != "" {
+		order.Description = input.Description
+	}
+	if input.OdometerKm > 0 {
+		order.OdometerKm = input.OdometerKm
+	}
+	order.LabourHours = input.LabourHours
+	order.LabourCost = input.LabourCost
+	return nil
+}
+
+func (h *WorkOrderHandler) findWorkOrder(c *fiber.Ctx) (*Models.WorkOrder, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var order Models.WorkOrder
+	if err := h.DB.Preload("Parts").Preload("ServiceInvoice.InspectionItems").First(&order, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"message": "Work order not found",
+			})
+		}
+
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch work order",
+			"error":   err.Error(),
+		})
+	}
+
+	return &order, nil
+}
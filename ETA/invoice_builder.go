@@ -0,0 +1,319 @@
+package ETA
+
+import (
+	"Falcon/Models"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+// IssuedDocument is an ETA v1.0 invoice, credit or debit note as submitted to the tax authority
+type IssuedDocument struct {
+	Issuer                   Party              `json:"issuer"`
+	Receiver                 Party              `json:"receiver"`
+	DocumentType             string             `json:"documentType"`
+	DocumentTypeVersion      string             `json:"documentTypeVersion"`
+	DateTimeIssued           string             `json:"dateTimeIssued"`
+	TaxpayerActivityCode     string             `json:"taxpayerActivityCode"`
+	InternalID               string             `json:"internalID"`
+	References               []string           `json:"references,omitempty"` // Original document UUIDs of credit and debit notes
+	InvoiceLines             []InvoiceLine      `json:"invoiceLines"`
+	TotalDiscountAmount      float64            `json:"totalDiscountAmount"`
+	TotalSalesAmount         float64            `json:"totalSalesAmount"`
+	NetAmount                float64            `json:"netAmount"`
+	TaxTotals                []DocumentTaxTotal `json:"taxTotals"`
+	TotalAmount              float64            `json:"totalAmount"`
+	ExtraDiscountAmount      float64            `json:"extraDiscountAmount"`
+	TotalItemsDiscountAmount float64            `json:"totalItemsDiscountAmount"`
+}
+
+type Party struct {
+	Address Address `json:"address"`
+	Type    string  `json:"type"`
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+}
+
+type Address struct {
+	BranchID       string `json:"branchID,omitempty"`
+	Country        string `json:"country"`
+	Governate      string `json:"governate"`
+	RegionCity     string `json:"regionCity"`
+	Street         string `json:"street"`
+	BuildingNumber string `json:"buildingNumber"`
+}
+
+type InvoiceLine struct {
+	Description      string        `json:"description"`
+	ItemType         string        `json:"itemType"`
+	ItemCode         string        `json:"itemCode"`
+	UnitType         string        `json:"unitType"`
+	Quantity         float64       `json:"quantity"`
+	InternalCode     string        `json:"internalCode"`
+	SalesTotal       float64       `json:"salesTotal"`
+	Total            float64       `json:"total"`
+	ValueDifference  float64       `json:"valueDifference"`
+	TotalTaxableFees float64       `json:"totalTaxableFees"`
+	NetTotal         float64       `json:"netTotal"`
+	ItemsDiscount    float64       `json:"itemsDiscount"`
+	UnitValue        LineUnitValue `json:"unitValue"`
+	Discount         Discount      `json:"discount"`
+	TaxableItems     []TaxableItem `json:"taxableItems"`
+}
+
+type LineUnitValue struct {
+	CurrencySold string  `json:"currencySold"`
+	AmountEGP    float64 `json:"amountEGP"`
+}
+
+type Discount struct {
+	Rate   float64 `json:"rate"`
+	Amount float64 `json:"amount"`
+}
+
+type TaxableItem struct {
+	TaxType string  `json:"taxType"`
+	Amount  float64 `json:"amount"`
+	SubType string  `json:"subType"`
+	Rate    float64 `json:"rate"`
+}
+
+type DocumentTaxTotal struct {
+	TaxType string  `json:"taxType"`
+	Amount  float64 `json:"amount"`
+}
+
+const (
+	documentTypeVersion = "1.0"
+	vatTaxType          = "T1"
+	vatSubType          = "V009" // General goods and services
+)
+
+// BuildDocument converts a customer invoice or credit note into an ETA v1.0 document.
+// Credit notes reference the UUIDs of the documents they correct, and line VAT is
+// declared at the given contract VAT rate.
+func BuildDocument(invoice *Models.Invoice, issuer, receiver *Models.ETATaxpayer, vatRate float64, references []string) (*IssuedDocument, error) {
+	issueDate, err := time.Parse("2006-01-02", invoice.IssueDate)
+	if err != nil {
+		return nil, fmt.Errorf("invoice %s has no valid issue date", invoice.Number)
+	}
+
+	doc := &IssuedDocument{
+		Issuer:               taxpayerParty(issuer),
+		Receiver:             taxpayerParty(receiver),
+		DocumentType:         "I",
+		DocumentTypeVersion:  documentTypeVersion,
+		DateTimeIssued:       issueDate.UTC().Format("2006-01-02T15:04:05Z"),
+		TaxpayerActivityCode: issuer.ActivityCode,
+		InternalID:           invoice.Number,
+	}
+
+	if invoice.Type == Models.InvoiceTypeCreditNote {
+		doc.DocumentType = "C"
+		doc.References = references
+	}
+
+	var vatTotal float64
+	for _, line := range invoice.Lines {
+		// Credit note amounts are stored negative, the document type carries the sign
+		amount := round5(math.Abs(line.Amount))
+		// The stored VAT is submitted as is so the document totals match the invoice
+		vat := round5(math.Abs(line.VAT))
+
+		rate := 0.0
+		if vat > 0 {
+			rate = round5(vatRate * 100)
+		}
+
+		doc.InvoiceLines = append(doc.InvoiceLines, InvoiceLine{
+			Description:  line.Description,
+			ItemType:     issuer.ItemType,
+			ItemCode:     issuer.ItemCode,
+			UnitType:     "EA",
+			Quantity:     1,
+			InternalCode: line.LineType,
+			SalesTotal:   amount,
+			NetTotal:     amount,
+			Total:        round5(amount + vat),
+			UnitValue:    LineUnitValue{CurrencySold: "EGP", AmountEGP: amount},
+			TaxableItems: []TaxableItem{
+				{TaxType: vatTaxType, Amount: vat, SubType: vatSubType, Rate: rate},
+			},
+		})
+
+		doc.TotalSalesAmount += amount
+		vatTotal += vat
+	}
+
+	doc.TotalSalesAmount = round5(doc.TotalSalesAmount)
+	doc.NetAmount = doc.TotalSalesAmount
+	doc.TaxTotals = []DocumentTaxTotal{{TaxType: vatTaxType, Amount: round5(vatTotal)}}
+	doc.TotalAmount = round5(doc.NetAmount + vatTotal)
+
+	return doc, nil
+}
+
+func taxpayerParty(taxpayer *Models.ETATaxpayer) Party {
+	return Party{
+		Type: taxpayer.Type,
+		ID:   taxpayer.TaxpayerID,
+		Name: taxpayer.Name,
+		Address: Address{
+			BranchID:       taxpayer.BranchID,
+			Country:        taxpayer.Country,
+			Governate:      taxpayer.Governate,
+			RegionCity:     taxpayer.RegionCity,
+			Street:         taxpayer.Street,
+			BuildingNumber: taxpayer.BuildingNumber,
+		},
+	}
+}
+
+var (
+	registrationNumberPattern = regexp.MustCompile(`^[0-9]{9}$`)
+	countryCodePattern        = regexp.MustCompile(`^[A-Z]{2}$`)
+	activityCodePattern       = regexp.MustCompile(`^[0-9]{4}$`)
+)
+
+// Validate checks a document against the ETA v1.0 schema rules that can be
+// verified locally and returns every violation found
+func (doc *IssuedDocument) Validate() []string {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	validateParty := func(role string, party Party) {
+		if party.Name == "" {
+			fail("%s name is required", role)
+		}
+		if party.Type != "B" && party.Type != "P" && party.Type != "F" {
+			fail("%s type must be B, P or F", role)
+		}
+		if party.Type == "B" && !registrationNumberPattern.MatchString(party.ID) {
+			fail("%s registration number must be 9 digits", role)
+		}
+		if !countryCodePattern.MatchString(party.Address.Country) {
+			fail("%s country must be a 2 letter code", role)
+		}
+		if party.Address.Governate == "" || party.Address.RegionCity == "" ||
+			party.Address.Street == "" || party.Address.BuildingNumber == "" {
+			fail("%s address requires governate, region/city, street and building number", role)
+		}
+	}
+
+	validateParty("issuer", doc.Issuer)
+	if doc.Issuer.Type != "B" {
+		fail("issuer type must be B")
+	}
+	if doc.Issuer.Address.BranchID == "" {
+		fail("issuer branch ID is required")
+	}
+	validateParty("receiver", doc.Receiver)
+
+	switch doc.DocumentType {
+	case "I":
+	case "C", "D":
+		if len(doc.References) == 0 {
+			fail("credit and debit notes must reference the original document UUID")
+		}
+	default:
+		fail("document type must be I, C or D")
+	}
+
+	if doc.DocumentTypeVersion != documentTypeVersion {
+		fail("document type version must be %s", documentTypeVersion)
+	}
+	if issued, err := time.Parse("2006-01-02T15:04:05Z", doc.DateTimeIssued); err != nil {
+		fail("dateTimeIssued must be a UTC timestamp")
+	} else if issued.After(time.Now().UTC()) {
+		fail("dateTimeIssued cannot be in the future")
+	}
+	if !activityCodePattern.MatchString(doc.TaxpayerActivityCode) {
+		fail("taxpayer activity code must be 4 digits")
+	}
+	if doc.InternalID == "" {
+		fail("internal ID is required")
+	}
+	if len(doc.InvoiceLines) == 0 {
+		fail("at least one invoice line is required")
+	}
+
+	var salesTotal, taxTotal float64
+	for i, line := range doc.InvoiceLines {
+		n := i + 1
+		if line.Description == "" {
+			fail("line %d description is required", n)
+		}
+		if line.ItemType != "EGS" && line.ItemType != "GS1" {
+			fail("line %d item type must be EGS or GS1", n)
+		}
+		if line.ItemCode == "" {
+			fail("line %d item code is required", n)
+		}
+		if line.Quantity <= 0 {
+			fail("line %d quantity must be positive", n)
+		}
+		if line.UnitValue.CurrencySold != "EGP" {
+			fail("line %d currency must be EGP", n)
+		}
+		if !amountsEqual(line.SalesTotal, line.Quantity*line.UnitValue.AmountEGP) {
+			fail("line %d sales total must equal quantity times unit value", n)
+		}
+		if !amountsEqual(line.NetTotal, line.SalesTotal-line.Discount.Amount) {
+			fail("line %d net total must equal sales total minus discount", n)
+		}
+
+		lineTaxes := 0.0
+		for _, item := range line.TaxableItems {
+			if item.TaxType == vatTaxType {
+				if item.Rate < 0 || item.Rate > 100 {
+					fail("line %d T1 rate must be between 0 and 100", n)
+				}
+				if math.Abs(item.Amount-line.NetTotal*item.Rate/100) > vatRoundingTolerance {
+					fail("line %d T1 amount must equal net total times rate", n)
+				}
+			}
+			lineTaxes += item.Amount
+		}
+		if !amountsEqual(line.Total, line.NetTotal+lineTaxes-line.ItemsDiscount) {
+			fail("line %d total must equal net total plus taxes", n)
+		}
+
+		salesTotal += line.SalesTotal
+		taxTotal += lineTaxes
+	}
+
+	if !amountsEqual(doc.TotalSalesAmount, salesTotal) {
+		fail("total sales amount must equal the sum of line sales totals")
+	}
+	if !amountsEqual(doc.NetAmount, doc.TotalSalesAmount-doc.TotalDiscountAmount) {
+		fail("net amount must equal total sales minus total discount")
+	}
+
+	documentTaxes := 0.0
+	for _, total := range doc.TaxTotals {
+		documentTaxes += total.Amount
+	}
+	if !amountsEqual(documentTaxes, taxTotal) {
+		fail("tax totals must equal the sum of line taxes")
+	}
+	if !amountsEqual(doc.TotalAmount, doc.NetAmount+documentTaxes-doc.ExtraDiscountAmount) {
+		fail("total amount must equal net amount plus taxes")
+	}
+
+	return errs
+}
+
+// ETA amounts carry up to five decimals
+func round5(amount float64) float64 {
+	return math.Round(amount*100000) / 100000
+}
+
+// vatRoundingTolerance allows line VAT rounded to piastres when invoiced
+const vatRoundingTolerance = 0.01
+
+func amountsEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.0001
+}
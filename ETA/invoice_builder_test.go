@@ -0,0 +1,100 @@
+package ETA
+
+import (
+	"Falcon/Models"
+	"testing"
+)
+
+func testTaxpayer(name, id string) *Models.ETATaxpayer {
+	return &Models.ETATaxpayer{
+		Type:           "B",
+		TaxpayerID:     id,
+		Name:           name,
+		BranchID:       "0",
+		Country:        "EG",
+		Governate:      "Cairo",
+		RegionCity:     "Nasr City",
+		Street:         "Abbas El Akkad",
+		BuildingNumber: "1",
+		ActivityCode:   "4923",
+		ItemType:       "EGS",
+		ItemCode:       "EG-123456789-1",
+	}
+}
+
+func TestBuildDocument(t *testing.T) {
+	invoice := &Models.Invoice{
+		Number:    "INV-2025-0001",
+		Type:      Models.InvoiceTypeInvoice,
+		IssueDate: "2025-01-31",
+		Lines: []Models.InvoiceLine{
+			{LineType: Models.InvoiceLineTrips, Description: "Alex", Amount: 12210, VAT: 1709.4},
+			{LineType: Models.InvoiceLineCarRental, Description: "Car rent", Amount: 7175, VAT: 1004.5},
+		},
+	}
+
+	doc, err := BuildDocument(invoice, testTaxpayer("Apex", "123456789"), testTaxpayer("TAQA", "987654321"), 0.14, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := doc.Validate(); len(errs) > 0 {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+	if doc.TotalAmount != 22098.9 {
+		t.Errorf("got total %f, wanted 22098.9", doc.TotalAmount)
+	}
+	if doc.InvoiceLines[0].TaxableItems[0].Rate != 14 {
+		t.Errorf("got VAT rate %f, wanted 14", doc.InvoiceLines[0].TaxableItems[0].Rate)
+	}
+}
+
+func TestBuildDocumentKeepsStoredVAT(t *testing.T) {
+	invoice := &Models.Invoice{
+		Number:    "INV-2025-0002",
+		Type:      Models.InvoiceTypeInvoice,
+		IssueDate: "2025-01-31",
+		Lines: []Models.InvoiceLine{
+			{LineType: Models.InvoiceLineTrips, Description: "Alex", Amount: 1000.03, VAT: 140},
+			{LineType: Models.InvoiceLineTrips, Description: "Suez", Amount: 35.5, VAT: 4.97},
+		},
+	}
+
+	doc, err := BuildDocument(invoice, testTaxpayer("Apex", "123456789"), testTaxpayer("TAQA", "987654321"), 0.14, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := doc.Validate(); len(errs) > 0 {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+	if doc.TotalAmount != 1180.5 {
+		t.Errorf("got total %f, wanted the invoice total 1180.5", doc.TotalAmount)
+	}
+	if doc.InvoiceLines[0].TaxableItems[0].Amount != 140 {
+		t.Errorf("got VAT %f, wanted the stored 140", doc.InvoiceLines[0].TaxableItems[0].Amount)
+	}
+}
+
+func TestCreditNoteRequiresReference(t *testing.T) {
+	creditNote := &Models.Invoice{
+		Number:    "CN-2025-0001",
+		Type:      Models.InvoiceTypeCreditNote,
+		IssueDate: "2025-02-01",
+		Lines: []Models.InvoiceLine{
+			{LineType: Models.InvoiceLineCredit, Description: "Reversal", Amount: -4070, VAT: -569.8},
+		},
+	}
+
+	doc, err := BuildDocument(creditNote, testTaxpayer("Apex", "123456789"), testTaxpayer("TAQA", "987654321"), 0.14, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.DocumentType != "C" || doc.TotalSalesAmount != 4070 {
+		t.Errorf("got type %s with sales %f", doc.DocumentType, doc.TotalSalesAmount)
+	}
+	if errs := doc.Validate(); len(errs) != 1 {
+		t.Errorf("expected only the missing reference error, got %v", errs)
+	}
+}
@@ -0,0 +1,457 @@
+package ETA
+
+import (
+	"Falcon/Models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Reconciliation statuses of an ETA document
+const (
+	ReconcileMatched   = "matched"   // Same taxpayer, date and amount
+	ReconcilePartial   = "partial"   // Linked record found but the date or amount differs
+	ReconcileUnmatched = "unmatched" // Nothing in our books corresponds to the document
+	ReconcileDuplicate = "duplicate" // Document repeated, or matching a record already claimed
+)
+
+// Kinds of records a document can be matched to
+const (
+	MatchVendorTransaction = "vendor_transaction"
+	MatchServiceInvoice    = "service_invoice"
+	MatchCustomerInvoice   = "customer_invoice"
+)
+
+const (
+	amountTolerance = 1.0 // EGP difference still considered the same amount
+	dateWindowDays  = 7   // Days either side searched for partial matches
+)
+
+type ReconciledDocument struct {
+	UUID                string   `json:"uuid"`
+	InternalID          string   `json:"internal_id"`
+	Direction           string   `json:"direction"` // "issued" by us or "received" from a vendor
+	DocumentStatus      string   `json:"document_status"`
+	IssuerTaxpayerID    string   `json:"issuer_taxpayer_id"`
+	RecipientTaxpayerID string   `json:"recipient_taxpayer_id"`
+	Counterparty        string   `json:"counterparty"`
+	IssueDate           string   `json:"issue_date"`
+	Amount              float64  `json:"amount"`
+	Status              string   `json:"status"`
+	MatchType           string   `json:"match_type,omitempty"`
+	MatchID             uint     `json:"match_id,omitempty"`
+	MatchDate           string   `json:"match_date,omitempty"`
+	MatchAmount         float64  `json:"match_amount,omitempty"`
+	Reasons             []string `json:"reasons,omitempty"`
+}
+
+type ReconciliationSummary struct {
+	Documents int `json:"documents"`
+	Matched   int `json:"matched"`
+	Partial   int `json:"partial"`
+	Unmatched int `json:"unmatched"`
+	Duplicate int `json:"duplicate"`
+}
+
+type ReconciliationReport struct {
+	Summary   ReconciliationSummary `json:"summary"`
+	Documents []ReconciledDocument  `json:"documents"`
+}
+
+// ledger holds the records ETA documents are reconciled against
+type ledger struct {
+	issuerTaxID     string
+	vendors         map[string]Models.Vendor // By tax ID
+	customers       map[string]string        // Company by tax ID
+	transactions    []Models.VendorTransaction
+	serviceInvoices []Models.ServiceInvoice
+	invoices        []Models.Invoice
+	submissions     map[string]Models.ETASubmission // By document UUID
+
+	claimed map[string]string // Record key to the UUID of the document that matched it
+}
+
+// loadLedger loads the records dated around the documents' issue dates
+func loadLedger(db *gorm.DB, docs []Document) (*ledger, error) {
+	l := &ledger{
+		vendors:     make(map[string]Models.Vendor),
+		customers:   make(map[string]string),
+		submissions: make(map[string]Models.ETASubmission),
+		claimed:     make(map[string]string),
+	}
+
+	var first, last string
+	for _, doc := range docs {
+		date := documentDate(doc)
+		if date == "" {
+			continue
+		}
+		if first == "" || date < first {
+			first = date
+		}
+		if date > last {
+			last = date
+		}
+	}
+	if first == "" {
+		return l, nil
+	}
+
+	from, _ := time.Parse("2006-01-02", first)
+	to, _ := time.Parse("2006-01-02", last)
+	from = from.AddDate(0, 0, -dateWindowDays)
+	to = to.AddDate(0, 0, dateWindowDays+1)
+
+	var taxpayers []Models.ETATaxpayer
+	if err := db.Find(&taxpayers).Error; err != nil {
+		return nil, err
+	}
+	for _, taxpayer := range taxpayers {
+		if taxpayer.IsIssuer {
+			l.issuerTaxID = taxpayer.TaxpayerID
+		} else if taxpayer.TaxpayerID != "" {
+			l.customers[taxpayer.TaxpayerID] = taxpayer.Company
+		}
+	}
+
+	var vendors []Models.Vendor
+	if err := db.Where("tax_id <> ''").Find(&vendors).Error; err != nil {
+		return nil, err
+	}
+	for _, vendor := range vendors {
+		l.vendors[vendor.TaxID] = vendor
+	}
+
+	if err := db.Where("date >= ? AND date < ?", from, to).Order("date ASC").Find(&l.transactions).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("date >= ? AND date < ?", from, to).Order("date ASC").Find(&l.serviceInvoices).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("status IN ?", []string{Models.InvoiceIssued, Models.InvoicePaid}).Find(&l.invoices).Error; err != nil {
+		return nil, err
+	}
+
+	var submissions []Models.ETASubmission
+	if err := db.Where("uuid <> ''").Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	for _, submission := range submissions {
+		l.submissions[submission.UUID] = submission
+	}
+
+	return l, nil
+}
+
+// reconcile classifies every document against the ledger
+func (l *ledger) reconcile(docs []Document) ReconciliationReport {
+	report := ReconciliationReport{Documents: make([]ReconciledDocument, 0, len(docs))}
+	seenUUIDs := make(map[string]bool)
+	seenInternalIDs := make(map[string]string)
+
+	for _, doc := range docs {
+		src := doc.Source
+		row := ReconciledDocument{
+			UUID:                src.UUID,
+			InternalID:          src.InternalID,
+			Direction:           "received",
+			DocumentStatus:      src.DocumentStatusEN,
+			IssuerTaxpayerID:    src.IssuerTaxPayerID,
+			RecipientTaxpayerID: src.RecipientTaxPayerID,
+			Counterparty:        src.SubmitterName,
+			IssueDate:           documentDate(doc),
+			Amount:              src.TotalInvoiceAmount,
+		}
+		if l.issuerTaxID != "" && src.IssuerTaxPayerID == l.issuerTaxID {
+			row.Direction = "issued"
+			row.Counterparty = src.RecipientName
+		}
+
+		internalKey := src.IssuerTaxPayerID + "|" + src.InternalID
+		switch {
+		case seenUUIDs[src.UUID]:
+			row.Status = ReconcileDuplicate
+			row.Reasons = append(row.Reasons, "document appears more than once in the upload")
+		case src.InternalID != "" && seenInternalIDs[internalKey] != "":
+			row.Status = ReconcileDuplicate
+			row.Reasons = append(row.Reasons, fmt.Sprintf("same issuer internal ID as document %s", seenInternalIDs[internalKey]))
+		case row.Direction == "issued":
+			l.matchIssued(&row)
+		default:
+			l.matchReceived(&row)
+		}
+
+		seenUUIDs[src.UUID] = true
+		if src.InternalID != "" && seenInternalIDs[internalKey] == "" {
+			seenInternalIDs[internalKey] = src.UUID
+		}
+
+		switch row.Status {
+		case ReconcileMatched:
+			report.Summary.Matched++
+		case ReconcilePartial:
+			report.Summary.Partial++
+		case ReconcileDuplicate:
+			report.Summary.Duplicate++
+		default:
+			report.Summary.Unmatched++
+		}
+		report.Documents = append(report.Documents, row)
+	}
+
+	report.Summary.Documents = len(report.Documents)
+	return report
+}
+
+// matchIssued links a document we issued to the customer invoice it was built from
+func (l *ledger) matchIssued(row *ReconciledDocument) {
+	var candidate *Models.Invoice
+	linkedBy := ""
+
+	if submission, exists := l.submissions[row.UUID]; exists {
+		candidate = l.invoiceByID(submission.InvoiceID)
+		linkedBy = "submission UUID"
+	}
+	if candidate == nil && row.InternalID != "" {
+		for i := range l.invoices {
+			if l.invoices[i].Number == row.InternalID {
+				candidate = &l.invoices[i]
+				linkedBy = "invoice number"
+				break
+			}
+		}
+	}
+	if candidate == nil {
+		company := l.customers[row.RecipientTaxpayerID]
+		for i := range l.invoices {
+			invoice := &l.invoices[i]
+			if company != "" && invoice.Company == company && invoice.IssueDate == row.IssueDate &&
+				sameAmount(invoice.Total, row.Amount) && !l.isClaimed(MatchCustomerInvoice, invoice.ID) {
+				candidate = invoice
+				linkedBy = "receiver, date and amount"
+				break
+			}
+		}
+	}
+
+	if candidate == nil {
+		row.Status = ReconcileUnmatched
+		row.Reasons = append(row.Reasons, "no customer invoice with this UUID, number, or receiver, date and amount")
+		return
+	}
+
+	row.MatchType = MatchCustomerInvoice
+	row.MatchID = candidate.ID
+	row.MatchDate = candidate.IssueDate
+	row.MatchAmount = candidate.Total
+	row.Reasons = append(row.Reasons, "linked by "+linkedBy)
+
+	if previous := l.claimed[claimKey(MatchCustomerInvoice, candidate.ID)]; previous != "" {
+		row.Status = ReconcileDuplicate
+		row.Reasons = append(row.Reasons, fmt.Sprintf("invoice %s already matched document %s", candidate.Number, previous))
+		return
+	}
+	l.claim(MatchCustomerInvoice, candidate.ID, row.UUID)
+
+	row.Status = ReconcileMatched
+	if !sameAmount(candidate.Total, row.Amount) {
+		row.Status = ReconcilePartial
+		row.Reasons = append(row.Reasons, fmt.Sprintf("amount differs by %.2f", math.Abs(row.Amount)-math.Abs(candidate.Total)))
+	}
+	if candidate.IssueDate != row.IssueDate {
+		row.Status = ReconcilePartial
+		row.Reasons = append(row.Reasons, "issue date differs")
+	}
+}
+
+// matchReceived links a vendor's document to a vendor transaction, falling back
+// to a service invoice on the same date
+func (l *ledger) matchReceived(row *ReconciledDocument) {
+	vendor, known := l.vendors[row.IssuerTaxpayerID]
+	if known {
+		row.Counterparty = vendor.Name
+
+		var exact, partial *Models.VendorTransaction
+		var duplicateOf string
+		for i := range l.transactions {
+			transaction := &l.transactions[i]
+			if transaction.VendorID != vendor.ID {
+				continue
+			}
+
+			date := transaction.Date.Format("2006-01-02")
+			amountMatches := sameAmount(transaction.Amount, row.Amount)
+			if date == row.IssueDate && amountMatches {
+				if previous := l.claimed[claimKey(MatchVendorTransaction, transaction.ID)]; previous != "" {
+					duplicateOf = previous
+					continue
+				}
+				exact = transaction
+				break
+			}
+			if partial == nil && !l.isClaimed(MatchVendorTransaction, transaction.ID) &&
+				(amountMatches || date == row.IssueDate) && daysApart(date, row.IssueDate) <= dateWindowDays {
+				partial = transaction
+			}
+		}
+
+		switch {
+		case exact != nil:
+			l.setVendorMatch(row, exact)
+			row.Status = ReconcileMatched
+			return
+		case duplicateOf != "":
+			row.Status = ReconcileDuplicate
+			row.Reasons = append(row.Reasons, fmt.Sprintf("vendor transaction already matched document %s", duplicateOf))
+			return
+		case partial != nil:
+			l.setVendorMatch(row, partial)
+			row.Status = ReconcilePartial
+			if !sameAmount(partial.Amount, row.Amount) {
+				row.Reasons = append(row.Reasons, fmt.Sprintf("amount differs by %.2f", math.Abs(row.Amount)-math.Abs(partial.Amount)))
+			}
+			if row.MatchDate != row.IssueDate {
+				row.Reasons = append(row.Reasons, fmt.Sprintf("date differs by %d days", daysApart(row.MatchDate, row.IssueDate)))
+			}
+			return
+		}
+		row.Reasons = append(row.Reasons, fmt.Sprintf("no transaction of vendor %s with this date and amount", vendor.Name))
+	} else {
+		row.Reasons = append(row.Reasons, "issuer tax ID is not registered on any vendor")
+	}
+
+	// Service invoices carry no amount, so a same-day service invoice is at best a partial match
+	for i := range l.serviceInvoices {
+		serviceInvoice := &l.serviceInvoices[i]
+		if serviceInvoice.Date.Format("2006-01-02") == row.IssueDate && !l.isClaimed(MatchServiceInvoice, serviceInvoice.ID) {
+			l.claim(MatchServiceInvoice, serviceInvoice.ID, row.UUID)
+			row.Status = ReconcilePartial
+			row.MatchType = MatchServiceInvoice
+			row.MatchID = serviceInvoice.ID
+			row.MatchDate = row.IssueDate
+			row.Reasons = append(row.Reasons, fmt.Sprintf("service invoice for %s on the same date, amount not recorded", serviceInvoice.PlateNumber))
+			return
+		}
+	}
+
+	row.Status = ReconcileUnmatched
+}
+
+func (l *ledger) setVendorMatch(row *ReconciledDocument, transaction *Models.VendorTransaction) {
+	l.claim(MatchVendorTransaction, transaction.ID, row.UUID)
+	row.MatchType = MatchVendorTransaction
+	row.MatchID = transaction.ID
+	row.MatchDate = transaction.Date.Format("2006-01-02")
+	row.MatchAmount = transaction.Amount
+}
+
+func (l *ledger) invoiceByID(id uint) *Models.Invoice {
+	for i := range l.invoices {
+		if l.invoices[i].ID == id {
+			return &l.invoices[i]
+		}
+	}
+	return nil
+}
+
+func (l *ledger) claim(kind string, id uint, uuid string) {
+	l.claimed[claimKey(kind, id)] = uuid
+}
+
+func (l *ledger) isClaimed(kind string, id uint) bool {
+	return l.claimed[claimKey(kind, id)] != ""
+}
+
+func claimKey(kind string, id uint) string {
+	return fmt.Sprintf("%s|%d", kind, id)
+}
+
+// documentDate returns the document's issue date in Cairo time
+func documentDate(doc Document) string {
+	issued, err := time.Parse(time.RFC3339, doc.Source.IssueDate)
+	if err != nil {
+		return ""
+	}
+	if cairo, err := time.LoadLocation("Africa/Cairo"); err == nil {
+		issued = issued.In(cairo)
+	}
+	return issued.Format("2006-01-02")
+}
+
+// sameAmount compares ledger and document amounts regardless of sign
+func sameAmount(a, b float64) bool {
+	return math.Abs(math.Abs(a)-math.Abs(b)) <= amountTolerance
+}
+
+func daysApart(a, b string) int {
+	first, err1 := time.Parse("2006-01-02", a)
+	second, err2 := time.Parse("2006-01-02", b)
+	if err1 != nil || err2 != nil {
+		return math.MaxInt32
+	}
+	return int(math.Abs(first.Sub(second).Hours()) / 24)
+}
+
+// ReconcileUpload matches an uploaded ETA JSON export against vendor transactions,
+// service invoices and customer invoices
+func ReconcileUpload(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "No file provided. Please upload a JSON file.",
+		})
+	}
+
+	if strings.ToLower(filepath.Ext(file.Filename)) != ".json" && file.Header.Get("Content-Type") != "application/json" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Invalid file type. Please upload a JSON file.",
+		})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Failed to open uploaded file.",
+		})
+	}
+	defer src.Close()
+
+	var invoiceData InvoiceData
+	if err := json.NewDecoder(src).Decode(&invoiceData); err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Invalid JSON format. Please check your file structure.",
+		})
+	}
+
+	if len(invoiceData.Result) == 0 {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "No invoice data found in the JSON file.",
+		})
+	}
+
+	l, err := loadLedger(Models.DB, invoiceData.Result)
+	if err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to load records: %v", err),
+		})
+	}
+
+	report := l.reconcile(invoiceData.Result)
+	return c.JSON(fiber.Map{
+		"success": true,
+		"summary": report.Summary,
+		"data":    report.Documents,
+	})
+}
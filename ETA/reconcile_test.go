@@ -0,0 +1,63 @@
+package ETA
+
+import (
+	"Falcon/Models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func testDocument(uuid, internalID, issuer, recipient, issueDate string, amount float64) Document {
+	return Document{Source: Source{
+		UUID:                uuid,
+		InternalID:          internalID,
+		IssuerTaxPayerID:    issuer,
+		RecipientTaxPayerID: recipient,
+		IssueDate:           issueDate,
+		TotalInvoiceAmount:  amount,
+	}}
+}
+
+func TestReconcile(t *testing.T) {
+	day := func(date string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", date)
+		return parsed
+	}
+
+	vendor := Models.Vendor{ID: 1, Name: "Tires Co", TaxID: "111111111"}
+	l := &ledger{
+		issuerTaxID: "999999999",
+		vendors:     map[string]Models.Vendor{vendor.TaxID: vendor},
+		customers:   map[string]string{"222222222": "TAQA"},
+		transactions: []Models.VendorTransaction{
+			{ID: 1, VendorID: 1, Date: day("2025-01-10"), Amount: 5000},
+			{ID: 2, VendorID: 1, Date: day("2025-01-20"), Amount: 800},
+		},
+		invoices: []Models.Invoice{
+			{Model: gorm.Model{ID: 7}, Number: "INV-2025-0001", Company: "TAQA", IssueDate: "2025-01-31", Total: 22098.9},
+		},
+		submissions: map[string]Models.ETASubmission{},
+		claimed:     make(map[string]string),
+	}
+
+	docs := []Document{
+		testDocument("A", "V-1", "111111111", "999999999", "2025-01-10T08:00:00Z", 5000),
+		testDocument("A", "V-1", "111111111", "999999999", "2025-01-10T08:00:00Z", 5000),
+		testDocument("B", "V-2", "111111111", "999999999", "2025-01-22T08:00:00Z", 800),
+		testDocument("C", "INV-2025-0001", "999999999", "222222222", "2025-01-31T08:00:00Z", 22098.9),
+		testDocument("D", "X-1", "333333333", "999999999", "2025-01-15T08:00:00Z", 100),
+	}
+
+	report := l.reconcile(docs)
+	expected := []string{ReconcileMatched, ReconcileDuplicate, ReconcilePartial, ReconcileMatched, ReconcileUnmatched}
+	for i, status := range expected {
+		if report.Documents[i].Status != status {
+			t.Errorf("document %d: got %s, wanted %s (%v)", i, report.Documents[i].Status, status, report.Documents[i].Reasons)
+		}
+	}
+
+	if report.Summary.Matched != 2 || report.Summary.Duplicate != 1 || report.Summary.Partial != 1 || report.Summary.Unmatched != 1 {
+		t.Errorf("unexpected summary %+v", report.Summary)
+	}
+}
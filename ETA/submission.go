@@ -0,0 +1,280 @@
+package ETA
+
+import (
+	"Falcon/Models"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ValidationResponse struct {
+	Success bool     `json:"success"`
+	Error   string   `json:"error"`
+	Errors  []string `json:"errors"`
+}
+
+// GetTaxpayers returns the issuer and receiver registrations used on ETA documents
+func GetTaxpayers(c *fiber.Ctx) error {
+	var taxpayers []Models.ETATaxpayer
+	if err := Models.DB.Order("is_issuer DESC, company ASC").Find(&taxpayers).Error; err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Failed to fetch taxpayers.",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    taxpayers,
+	})
+}
+
+// SaveTaxpayer creates or updates the ETA registration of the issuer or of a customer company
+func SaveTaxpayer(c *fiber.Ctx) error {
+	var taxpayer Models.ETATaxpayer
+	if err := c.BodyParser(&taxpayer); err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Invalid request body.",
+		})
+	}
+
+	if taxpayer.IsIssuer {
+		taxpayer.Company = ""
+	} else if taxpayer.Company == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Receiver taxpayers must name their company.",
+		})
+	}
+
+	// There is a single registration per company and a single issuer
+	var existing Models.ETATaxpayer
+	query := Models.DB.Where("company = ?", taxpayer.Company)
+	if taxpayer.IsIssuer {
+		query = Models.DB.Where("is_issuer = ?", true)
+	}
+	if err := query.First(&existing).Error; err == nil {
+		taxpayer.ID = existing.ID
+		taxpayer.CreatedAt = existing.CreatedAt
+	} else if err != gorm.ErrRecordNotFound {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Failed to fetch taxpayer.",
+		})
+	}
+
+	if err := Models.DB.Save(&taxpayer).Error; err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Failed to save taxpayer.",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    taxpayer,
+	})
+}
+
+// PrepareInvoiceDocument builds the ETA document of an issued invoice or credit note,
+// validates it and stores the payload to be signed and submitted
+func PrepareInvoiceDocument(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Invalid invoice ID.",
+		})
+	}
+
+	var invoice Models.Invoice
+	if err := Models.DB.Preload("Lines").First(&invoice, id).Error; err != nil {
+		return c.Status(404).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Invoice not found.",
+		})
+	}
+
+	if invoice.Status != Models.InvoiceIssued && invoice.Status != Models.InvoicePaid {
+		return c.Status(409).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Only issued invoices can be sent to the ETA.",
+		})
+	}
+
+	var issuer Models.ETATaxpayer
+	if err := Models.DB.Where("is_issuer = ?", true).First(&issuer).Error; err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "The issuer taxpayer registration is not configured.",
+		})
+	}
+
+	var receiver Models.ETATaxpayer
+	if err := Models.DB.Where("company = ? AND is_issuer = ?", invoice.Company, false).First(&receiver).Error; err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("No taxpayer registration configured for %s.", invoice.Company),
+		})
+	}
+
+	var references []string
+	if invoice.OriginalInvoiceID != nil {
+		Models.DB.Model(&Models.ETASubmission{}).
+			Where("invoice_id = ? AND uuid <> ''", *invoice.OriginalInvoiceID).
+			Pluck("uuid", &references)
+	}
+
+	doc, err := BuildDocument(&invoice, &issuer, &receiver, invoiceVATRate(&invoice), references)
+	if err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if errs := doc.Validate(); len(errs) > 0 {
+		return c.Status(422).JSON(ValidationResponse{
+			Success: false,
+			Error:   "The document does not satisfy the ETA v1.0 schema.",
+			Errors:  errs,
+		})
+	}
+
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Failed to encode the document.",
+		})
+	}
+
+	submission := Models.ETASubmission{
+		InvoiceID:    invoice.ID,
+		InternalID:   invoice.Number,
+		DocumentType: doc.DocumentType,
+		Payload:      string(payload),
+		Status:       Models.ETASubmissionPrepared,
+	}
+	if err := Models.DB.Create(&submission).Error; err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Failed to save the submission.",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":    true,
+		"submission": submission,
+		"document":   doc,
+	})
+}
+
+// GetSubmissions returns the ETA submissions, optionally of a single invoice
+func GetSubmissions(c *fiber.Ctx) error {
+	query := Models.DB.Model(&Models.ETASubmission{})
+	if invoiceID := c.Query("invoice_id"); invoiceID != "" {
+		query = query.Where("invoice_id = ?", invoiceID)
+	}
+
+	var submissions []Models.ETASubmission
+	if err := query.Order("created_at DESC").Find(&submissions).Error; err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Failed to fetch submissions.",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    submissions,
+	})
+}
+
+// UpdateSubmission records the identifiers and status the ETA returned for a submitted document
+func UpdateSubmission(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Invalid submission ID.",
+		})
+	}
+
+	var submission Models.ETASubmission
+	if err := Models.DB.First(&submission, id).Error; err != nil {
+		return c.Status(404).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Submission not found.",
+		})
+	}
+
+	var input struct {
+		SubmissionUUID string `json:"submission_uuid"`
+		UUID           string `json:"uuid"`
+		LongID         string `json:"long_id"`
+		Status         string `json:"status"`
+		Error          string `json:"error"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Invalid request body.",
+		})
+	}
+
+	switch input.Status {
+	case Models.ETASubmissionSubmitted, Models.ETASubmissionValid, Models.ETASubmissionInvalid,
+		Models.ETASubmissionRejected, Models.ETASubmissionCancelled:
+	default:
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Unknown submission status %q.", input.Status),
+		})
+	}
+
+	if input.SubmissionUUID != "" {
+		submission.SubmissionUUID = input.SubmissionUUID
+	}
+	if input.UUID != "" {
+		submission.UUID = input.UUID
+	}
+	if input.LongID != "" {
+		submission.LongID = input.LongID
+	}
+	submission.Status = input.Status
+	submission.Error = input.Error
+
+	if err := Models.DB.Save(&submission).Error; err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Failed to update the submission.",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    submission,
+	})
+}
+
+// invoiceVATRate returns the VAT rate of the company's contract in force at the end of the
+// invoiced period, zero for companies without a contract
+func invoiceVATRate(invoice *Models.Invoice) float64 {
+	var contracts []Models.PricingContract
+	Models.DB.Preload("Rules").Where("company = ?", invoice.Company).Order("valid_from ASC").Find(&contracts)
+
+	for i := len(contracts) - 1; i >= 0; i-- {
+		if contracts[i].InForce(invoice.PeriodEnd) {
+			return contracts[i].VATRate()
+		}
+	}
+	if len(contracts) > 0 {
+		return contracts[len(contracts)-1].VATRate()
+	}
+	return 0
+}
@@ -35,6 +35,23 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	transactionController := Controllers.NewTransactionController(db)
 	vendorAnalyticsController := Controllers.NewAnalyticsController(db)
 	receiptController := &Controllers.ReceiptController{DB: db}
+	pricingContractHandler := Controllers.NewPricingContractHandler(db)
+	invoiceHandler := Controllers.NewInvoiceHandler(db)
+	vehiclePositionHandler := Controllers.NewVehiclePositionHandler(db)
+	tripSuggestionHandler := Controllers.NewTripSuggestionHandler(db)
+	geofenceHandler := Controllers.NewGeofenceHandler(db)
+	geofenceEventHandler := Controllers.NewGeofenceEventHandler(db)
+	speedRuleHandler := Controllers.NewSpeedRuleHandler(db)
+	driverScoreHandler := Controllers.NewDriverScoreHandler(db)
+	driverAssignmentHandler := Controllers.NewDriverAssignmentHandler(db)
+	fuelAnomalyHandler := Controllers.NewFuelAnomalyHandler(db)
+	externalVehicleHandler := Controllers.NewExternalVehicleHandler(db)
+	syncRunHandler := Controllers.NewSyncRunHandler(db)
+	costExportHandler := Controllers.NewCostExportHandler(db)
+	vehicleCostHandler := Controllers.NewVehicleCostHandler(db)
+	maintenanceHandler := Controllers.NewMaintenanceHandler(db)
+	workOrderHandler := Controllers.NewWorkOrderHandler(db)
+	inventoryHandler := Controllers.NewInventoryHandler(db)
 	// API group
 	api := app.Group("/api")
 
@@ -88,16 +105,169 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 
 	// ID-based routes
 	mappings.Get("/:id", feeMappingHandler.GetFeeMapping)
+	mappings.Get("/:id/rates", feeMappingHandler.GetFeeMappingRates)
+	mappings.Post("/:id/rates", middleware.Verify(3), feeMappingHandler.ScheduleFeeMappingRate)
 	mappings.Post("/", middleware.Verify(3), feeMappingHandler.CreateFeeMapping)
 	mappings.Put("/:id", middleware.Verify(3), feeMappingHandler.UpdateFeeMapping)
 	mappings.Delete("/:id", middleware.Verify(3), feeMappingHandler.DeleteFeeMapping)
 
+	// Pricing contract routes
+	pricingContracts := api.Group("/pricing-contracts", middleware.Verify(3))
+	pricingContracts.Get("/", pricingContractHandler.GetAllPricingContracts)
+	pricingContracts.Get("/:id", pricingContractHandler.GetPricingContract)
+	pricingContracts.Post("/", pricingContractHandler.CreatePricingContract)
+	pricingContracts.Post("/schedule", pricingContractHandler.SchedulePricingContract)
+	pricingContracts.Put("/:id", pricingContractHandler.UpdatePricingContract)
+	pricingContracts.Delete("/:id", pricingContractHandler.DeletePricingContract)
+
+	// Customer invoice routes
+	invoices := api.Group("/invoices", middleware.Verify(3))
+	invoices.Get("/", invoiceHandler.GetInvoices)
+	invoices.Get("/preview", invoiceHandler.PreviewInvoice)
+	invoices.Post("/generate", invoiceHandler.GenerateInvoice)
+	invoices.Get("/:id", invoiceHandler.GetInvoice)
+	invoices.Post("/:id/issue", invoiceHandler.IssueInvoice)
+	invoices.Post("/:id/pay", invoiceHandler.PayInvoice)
+	invoices.Post("/:id/cancel", invoiceHandler.CancelInvoice)
+	invoices.Post("/:id/credit-notes", invoiceHandler.CreateCreditNote)
+
+	// GPS position history routes
+	vehiclePositions := api.Group("/vehicle-positions", middleware.Verify(1))
+	vehiclePositions.Get("/snapshot", vehiclePositionHandler.GetFleetSnapshot)
+	vehiclePositions.Get("/cars/:id", vehiclePositionHandler.GetVehicleTrack)
+
+	// Trips detected from GPS geofence transitions
+	tripSuggestions := api.Group("/trip-suggestions", middleware.Verify(1))
+	tripSuggestions.Get("/", tripSuggestionHandler.GetTripSuggestions)
+	tripSuggestions.Get("/missing", tripSuggestionHandler.GetMissingTrips)
+	tripSuggestions.Post("/:id/confirm", tripSuggestionHandler.ConfirmTripSuggestion)
+	tripSuggestions.Post("/:id/dismiss", tripSuggestionHandler.DismissTripSuggestion)
+
+	// Geofence routes
+	geofences := api.Group("/geofences", middleware.Verify(1))
+	geofences.Get("/", geofenceHandler.GetGeofences)
+	geofences.Post("/check", geofenceHandler.CheckGeofence)
+	geofences.Get("/:id", geofenceHandler.GetGeofence)
+	geofences.Post("/", middleware.Verify(3), geofenceHandler.CreateGeofence)
+	geofences.Put("/:id", middleware.Verify(3), geofenceHandler.UpdateGeofence)
+	geofences.Delete("/:id", middleware.Verify(3), geofenceHandler.DeleteGeofence)
+
+	// Geofence event log and dwell analytics
+	geofenceEvents := api.Group("/geofence-events", middleware.Verify(1))
+	geofenceEvents.Get("/", geofenceEventHandler.GetGeofenceEvents)
+	geofenceEvents.Get("/visits", geofenceEventHandler.GetGeofenceVisits)
+	geofenceEvents.Get("/dwell", geofenceEventHandler.GetDwellTimes)
+	geofenceEvents.Get("/queue", geofenceEventHandler.GetTerminalQueueTimes)
+	geofenceEvents.Get("/turnaround", geofenceEventHandler.GetTurnaroundTimes)
+	geofenceEvents.Get("/outliers", geofenceEventHandler.GetDwellOutliers)
+
+	// Speed rules and the violations they detect
+	speedRules := api.Group("/speed-rules", middleware.Verify(1))
+	speedRules.Get("/", speedRuleHandler.GetSpeedRules)
+	speedRules.Get("/violations", speedRuleHandler.GetSpeedViolations)
+	speedRules.Post("/", middleware.Verify(3), speedRuleHandler.CreateSpeedRule)
+	speedRules.Put("/:id", middleware.Verify(3), speedRuleHandler.UpdateSpeedRule)
+	speedRules.Delete("/:id", middleware.Verify(3), speedRuleHandler.DeleteSpeedRule)
+
+	// Driver safety scorecard
+	driverScores := api.Group("/driver-scores", middleware.Verify(1))
+	driverScores.Get("/", driverScoreHandler.GetDriverScores)
+	driverScores.Get("/trend", driverScoreHandler.GetFleetScoreTrend)
+	driverScores.Get("/drivers/:id/trend", driverScoreHandler.GetDriverScoreTrend)
+	driverScores.Post("/compute", middleware.Verify(3), driverScoreHandler.ComputeDriverScores)
+
+	// Car to driver assignment history
+	driverAssignments := api.Group("/driver-assignments", middleware.Verify(1))
+	driverAssignments.Get("/", driverAssignmentHandler.GetDriverAssignments)
+	driverAssignments.Get("/at", driverAssignmentHandler.GetDriverAt)
+
+	// Review queue of suspicious fill-ups
+	fuelAnomalies := api.Group("/fuel-anomalies", middleware.Verify(1))
+	fuelAnomalies.Get("/", fuelAnomalyHandler.GetFuelAnomalies)
+	fuelAnomalies.Get("/:id", fuelAnomalyHandler.GetFuelAnomaly)
+	fuelAnomalies.Post("/scan", middleware.Verify(3), fuelAnomalyHandler.ScanFuelAnomalies)
+	fuelAnomalies.Post("/:id/approve", middleware.Verify(3), fuelAnomalyHandler.ApproveFuelAnomaly)
+	fuelAnomalies.Post("/:id/reject", middleware.Verify(3), fuelAnomalyHandler.RejectFuelAnomaly)
+
+	// Links between cars and their IDs in PetroApp, ETIT and other external systems
+	externalVehicles := api.Group("/external-vehicles", middleware.Verify(1))
+	externalVehicles.Get("/", externalVehicleHandler.GetExternalVehicles)
+	externalVehicles.Get("/unmatched", externalVehicleHandler.GetUnmatchedVehicles)
+	externalVehicles.Post("/match", middleware.Verify(3), externalVehicleHandler.MatchUnmatchedVehicles)
+	externalVehicles.Post("/", middleware.Verify(3), externalVehicleHandler.CreateExternalVehicle)
+	externalVehicles.Put("/:id", middleware.Verify(3), externalVehicleHandler.UpdateExternalVehicle)
+	externalVehicles.Post("/:id/confirm", middleware.Verify(3), externalVehicleHandler.ConfirmExternalVehicle)
+	externalVehicles.Delete("/:id", middleware.Verify(3), externalVehicleHandler.DeleteExternalVehicle)
+
+	// History of the PetroApp and other external source syncs
+	syncRuns := api.Group("/sync-runs", middleware.Verify(1))
+	syncRuns.Get("/", syncRunHandler.GetSyncRuns)
+	syncRuns.Get("/cursors", syncRunHandler.GetSyncCursors)
+	syncRuns.Get("/:id", syncRunHandler.GetSyncRun)
+	syncRuns.Post("/petroapp/backfill", middleware.Verify(3), PetroApp.StartBackfill)
+
+	// Fuel, service and car costs workbooks built from the database
+	costExports := api.Group("/cost-exports", middleware.Verify(1))
+	costExports.Post("/rebuild", middleware.Verify(3), costExportHandler.RebuildCostWorkbooks)
+	costExports.Get("/:workbook", costExportHandler.DownloadCostWorkbook)
+
+	// Ledger of every cost of a car, with cost of ownership, per km and per delivered liter
+	vehicleCosts := api.Group("/vehicle-costs", middleware.Verify(1))
+	vehicleCosts.Get("/", vehicleCostHandler.GetVehicleCosts)
+	vehicleCosts.Get("/summary", vehicleCostHandler.GetCostSummaries)
+	vehicleCosts.Get("/summary/:car_id", vehicleCostHandler.GetCarCostSummary)
+	vehicleCosts.Post("/refresh", middleware.Verify(3), vehicleCostHandler.RefreshVehicleCosts)
+
+	// Preventive maintenance plans, records and due lists
+	maintenance := api.Group("/maintenance", middleware.Verify(1))
+	maintenance.Get("/plans", maintenanceHandler.GetMaintenancePlans)
+	maintenance.Post("/plans", middleware.Verify(3), maintenanceHandler.CreateMaintenancePlan)
+	maintenance.Put("/plans/:id", middleware.Verify(3), maintenanceHandler.UpdateMaintenancePlan)
+	maintenance.Delete("/plans/:id", middleware.Verify(3), maintenanceHandler.DeleteMaintenancePlan)
+	maintenance.Get("/records", maintenanceHandler.GetMaintenanceRecords)
+	maintenance.Post("/records", middleware.Verify(3), maintenanceHandler.CreateMaintenanceRecord)
+	maintenance.Delete("/records/:id", middleware.Verify(3), maintenanceHandler.DeleteMaintenanceRecord)
+	maintenance.Get("/due", maintenanceHandler.GetMaintenanceDue)
+	maintenance.Get("/cars/:car_id", maintenanceHandler.GetCarMaintenance)
+	maintenance.Get("/odometers", maintenanceHandler.GetOdometers)
+
+	// Work orders stopping a car for repair, with parts, labour and downtime
+	workOrders := api.Group("/work-orders", middleware.Verify(1))
+	workOrders.Get("/", workOrderHandler.GetWorkOrders)
+	workOrders.Get("/downtime", workOrderHandler.GetDowntime)
+	workOrders.Get("/:id", workOrderHandler.GetWorkOrder)
+	workOrders.Post("/", middleware.Verify(3), workOrderHandler.CreateWorkOrder)
+	workOrders.Put("/:id", middleware.Verify(3), workOrderHandler.UpdateWorkOrder)
+	workOrders.Post("/:id/start", middleware.Verify(3), workOrderHandler.StartWorkOrder)
+	workOrders.Post("/:id/close", middleware.Verify(3), workOrderHandler.CloseWorkOrder)
+	workOrders.Delete("/:id", middleware.Verify(3), workOrderHandler.DeleteWorkOrder)
+
+	// Spare parts catalogue, stock locations, movements and stock reports
+	inventory := api.Group("/inventory", middleware.Verify(1))
+	inventory.Get("/parts", inventoryHandler.GetParts)
+	inventory.Post("/parts", middleware.Verify(3), inventoryHandler.CreatePart)
+	inventory.Put("/parts/:id", middleware.Verify(3), inventoryHandler.UpdatePart)
+	inventory.Delete("/parts/:id", middleware.Verify(3), inventoryHandler.DeletePart)
+	inventory.Get("/locations", inventoryHandler.GetStockLocations)
+	inventory.Post("/locations", middleware.Verify(3), inventoryHandler.CreateStockLocation)
+	inventory.Put("/locations/:id", middleware.Verify(3), inventoryHandler.UpdateStockLocation)
+	inventory.Delete("/locations/:id", middleware.Verify(3), inventoryHandler.DeleteStockLocation)
+	inventory.Get("/movements", inventoryHandler.GetStockMovements)
+	inventory.Post("/purchases", middleware.Verify(3), inventoryHandler.PurchaseStock)
+	inventory.Post("/issues", middleware.Verify(3), inventoryHandler.IssueStock)
+	inventory.Post("/adjustments", middleware.Verify(3), inventoryHandler.AdjustStock)
+	inventory.Post("/transfers", middleware.Verify(3), inventoryHandler.TransferStock)
+	inventory.Get("/stock", inventoryHandler.GetStockLevels)
+	inventory.Get("/reorder", inventoryHandler.GetReorderList)
+	inventory.Get("/valuation", inventoryHandler.GetStockValuation)
+
 	// Trip routes
 	trips := api.Group("/trips", middleware.Verify(1))
 	trips.Get("/", tripHandler.GetAllTrips)
 	app.Get("/api/stats/widget-data", tripHandler.GetGlobalStats)
 	app.Post("/api/UpdateToken", Models.UpdateToken)
 	trips.Get("/statistics", tripHandler.GetTripStatistics)
+	trips.Get("/profitability", middleware.Verify(3), tripHandler.GetTripProfitability)
 	trips.Get("/watanya/driver-analytics", tripHandler.GetWatanyaDriverAnalytics)
 	trips.Get("/date", tripHandler.GetTripsByDate)
 	trips.Get("/:id", tripHandler.GetTrip)
@@ -221,6 +391,13 @@ func FiberConfig() {
 	protectedApis.Post("/RegisterDriver", Controllers.RegisterDriver)
 	protectedApis.Post("/UpdateDriver", Controllers.UpdateDriver)
 	app.Post("/convert/upload", ETA.ConvertToExcel)
+	eta := app.Group("/api/eta", middleware.Verify(3))
+	eta.Get("/taxpayers", ETA.GetTaxpayers)
+	eta.Post("/taxpayers", ETA.SaveTaxpayer)
+	eta.Post("/invoices/:id", ETA.PrepareInvoiceDocument)
+	eta.Get("/submissions", ETA.GetSubmissions)
+	eta.Put("/submissions/:id", ETA.UpdateSubmission)
+	eta.Post("/reconcile", ETA.ReconcileUpload)
 	//protectedApis.Use(middleware.Verify)
 	handler := &Apis.FuelHandler{DB: Models.DB}
 
@@ -263,14 +440,22 @@ func FiberConfig() {
 
 	// Truck routes
 	api.Get("/trucks", Controllers.GetAllTrucks)
+	api.Get("/trucks/templates", Controllers.GetAxleTemplates)
 	api.Get("/trucks/:id", Controllers.GetTruck)
 	api.Post("/trucks", Controllers.CreateTruck)
 	api.Put("/trucks/:id", Controllers.UpdateTruck)
 	api.Delete("/trucks/:id", Controllers.DeleteTruck)
+	api.Get("/trucks/:id/couplings", Controllers.GetTruckCouplings)
+	api.Post("/trucks/:id/couple", Controllers.CoupleTrailer)
+	api.Post("/trucks/:id/decouple", Controllers.DecoupleTrailer)
 
 	// Tire routes
 	api.Get("/tires", Controllers.GetAllTires)
+	api.Get("/tires/usage", Controllers.GetTireUsage)
+	api.Get("/tires/brands", Controllers.GetTireBrandUsage)
 	api.Get("/tires/:id", Controllers.GetTire)
+	api.Get("/tires/:id/events", Controllers.GetTireEvents)
+	api.Post("/tires/:id/events", Controllers.CreateTireEvent)
 	api.Post("/tires", Controllers.CreateTire)
 	api.Put("/tires/:id", Controllers.UpdateTire)
 	api.Delete("/tires/:id", Controllers.DeleteTire)
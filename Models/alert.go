@@ -22,12 +22,25 @@ type SpeedAlert struct {
 	Hash         string `gorm:"uniqueIndex;size:64"`
 }
 
+// ComputeHash returns the hash identifying the alert's speed point
+func (s *SpeedAlert) ComputeHash() string {
+	data := fmt.Sprintf("%s|%s|%s|%s", s.VehicleID, s.Latitude, s.Longitude, s.Timestamp)
+	hash := sha256.Sum256([]byte(data))
+	return fmt.Sprintf("%x", hash)
+}
+
+// SpeedViolationHash returns the hash identifying the alert of a speed violation by its car,
+// rule and start
+func SpeedViolationHash(event SpeedViolationEvent) string {
+	data := fmt.Sprintf("%s|%d|%d|%s", event.VehicleID, event.CarID, event.RuleID, event.StartedAt.Format(PositionTimeLayout))
+	hash := sha256.Sum256([]byte(data))
+	return fmt.Sprintf("%x", hash)
+}
+
 // BeforeCreate automatically generates hash before saving
 func (s *SpeedAlert) BeforeCreate(tx *gorm.DB) error {
 	if s.Hash == "" {
-		data := fmt.Sprintf("%s|%s|%s|%s", s.VehicleID, s.Latitude, s.Longitude, s.Timestamp)
-		hash := sha256.Sum256([]byte(data))
-		s.Hash = fmt.Sprintf("%x", hash)
+		s.Hash = s.ComputeHash()
 	}
 	return nil
 }
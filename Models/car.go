@@ -41,6 +41,8 @@ type Car struct {
 	OperatingCompany                string           `json:"operating_company"`
 	OperatingArea                   string           `json:"operating_area"`
 	GeoFence                        string           `json:"geo_fence"`
+	GeoFenceType                    string           `json:"geo_fence_type"` // Type of GeoFence, recorded when the car entered it
 	SlackStatus                     string           `json:"slack_status"`
 	LastUpdatedSlackStatus          time.Time        `json:"last_updated_slack_status"`
+	MonthlyRent                     float64          `json:"monthly_rent"` // Paid to the car's owner, 0 for owned cars
 }
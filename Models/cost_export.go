@@ -0,0 +1,265 @@
+package Models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// Cost workbooks rebuilt from the database
+const (
+	FuelWorkbookName     = "تفويلات.xlsx"         // A sheet of fuel events per car
+	ServiceWorkbookName  = "صيانة.xlsx"           // Every service
+	CarCostsWorkbookName = "تكاليف السيارات.xlsx" // Fuel, service and oil change sheets
+)
+
+// CostWorkbookDir is where the scheduled export keeps the cost workbooks
+const CostWorkbookDir = "."
+
+// Sheets of the car costs workbook
+const (
+	fuelCostSheet    = "تفويل"
+	serviceCostSheet = "صيانة"
+	oilCostSheet     = "زيت"
+)
+
+var fuelExportHeaders = []interface{}{
+	"التاريخ", "رقم العربية", "السائق", "العداد الحالي", "العداد السابق",
+	"فرق الكيلومتر", "المعدل", "كمية التفويل", "سعر اللتر", "التكلفة",
+}
+
+var serviceExportHeaders = []interface{}{
+	"التاريخ", "رقم العربية", "السائق", "وصف الصيانة", "عداد الصيانة", "المشرف", "التكلفة",
+}
+
+var oilExportHeaders = []interface{}{
+	"التاريخ", "رقم العربية", "السائق", "عداد التغير", "العداد الحالي",
+	"فرق الكيلومتر", "نوع الزيت", "متبقي", "المشرف", "التكلفة",
+}
+
+// CostExport is the cost records written to the workbooks
+type CostExport struct {
+	FuelEvents []FuelEvent
+	Services   []Service
+	OilChanges []OilChange
+}
+
+// FuelExportRow returns the cells of a fuel event under the fuel headers
+func FuelExportRow(event FuelEvent) []interface{} {
+	return []interface{}{
+		event.Date, event.CarNoPlate, event.DriverName, event.OdometerAfter, event.OdometerBefore,
+		event.OdometerAfter - event.OdometerBefore, event.FuelRate, event.Liters, event.PricePerLiter, event.Price,
+	}
+}
+
+// ServiceExportRow returns the cells of a service under the service headers
+func ServiceExportRow(service Service) []interface{} {
+	return []interface{}{
+		service.DateOfService, service.CarNoPlate, service.DriverName, service.ServiceType,
+		service.OdometerReading, service.SuperVisor, service.Cost,
+	}
+}
+
+// OilExportRow returns the cells of an oil change under the oil headers. The oil type is
+// the mileage the oil lasts, and the remaining mileage is counted from the current odometer.
+func OilExportRow(oil OilChange) []interface{} {
+	difference := oil.CurrentOdometer - oil.OdometerAtChange
+	return []interface{}{
+		oil.Date, oil.CarNoPlate, oil.DriverName, int(oil.OdometerAtChange), int(oil.CurrentOdometer),
+		int(difference), int(oil.Mileage), int(oil.Mileage - difference), oil.SuperVisor, oil.Cost,
+	}
+}
+
+// WorkbookSheetName makes a car plate usable as a sheet name, which Excel limits to 31
+// characters without []:*?/\
+func WorkbookSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "بدون رقم"
+	}
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
+
+// writeSheet fills a sheet with the headers and one row per record
+func writeSheet(f *excelize.File, sheet string, headers []interface{}, rows [][]interface{}) error {
+	if _, err := f.NewSheet(sheet); err != nil {
+		return err
+	}
+	if err := f.SetSheetRow(sheet, "A1", &headers); err != nil {
+		return err
+	}
+	for i := range rows {
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", i+2), &rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newWorkbook creates a workbook with the sheets written by build, dropping the default sheet
+// when build added others
+func newWorkbook(build func(f *excelize.File) error) (*excelize.File, error) {
+	f := excelize.NewFile()
+	if err := build(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if f.SheetCount > 1 {
+		if err := f.DeleteSheet("Sheet1"); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	f.SetActiveSheet(0)
+	return f, nil
+}
+
+// BuildFuelWorkbook builds the fuel workbook, a sheet per car with its fuel events
+func BuildFuelWorkbook(events []FuelEvent) (*excelize.File, error) {
+	carRows := make(map[string][][]interface{})
+	for _, event := range events {
+		sheet := WorkbookSheetName(event.CarNoPlate)
+		carRows[sheet] = append(carRows[sheet], FuelExportRow(event))
+	}
+
+	sheets := make([]string, 0, len(carRows))
+	for sheet := range carRows {
+		sheets = append(sheets, sheet)
+	}
+	sort.Strings(sheets)
+
+	return newWorkbook(func(f *excelize.File) error {
+		for _, sheet := range sheets {
+			if err := writeSheet(f, sheet, fuelExportHeaders, carRows[sheet]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BuildServiceWorkbook builds the service workbook, every service on one sheet
+func BuildServiceWorkbook(services []Service) (*excelize.File, error) {
+	rows := make([][]interface{}, 0, len(services))
+	for _, service := range services {
+		rows = append(rows, ServiceExportRow(service))
+	}
+
+	return newWorkbook(func(f *excelize.File) error {
+		return writeSheet(f, "Sheet1", serviceExportHeaders, rows)
+	})
+}
+
+// BuildCarCostsWorkbook builds the car costs workbook with a sheet per cost kind
+func BuildCarCostsWorkbook(export CostExport) (*excelize.File, error) {
+	fuelRows := make([][]interface{}, 0, len(export.FuelEvents))
+	for _, event := range export.FuelEvents {
+		fuelRows = append(fuelRows, FuelExportRow(event))
+	}
+	serviceRows := make([][]interface{}, 0, len(export.Services))
+	for _, service := range export.Services {
+		serviceRows = append(serviceRows, ServiceExportRow(service))
+	}
+	oilRows := make([][]interface{}, 0, len(export.OilChanges))
+	for _, oil := range export.OilChanges {
+		oilRows = append(oilRows, OilExportRow(oil))
+	}
+
+	return newWorkbook(func(f *excelize.File) error {
+		if err := writeSheet(f, fuelCostSheet, fuelExportHeaders, fuelRows); err != nil {
+			return err
+		}
+		if err := writeSheet(f, serviceCostSheet, serviceExportHeaders, serviceRows); err != nil {
+			return err
+		}
+		return writeSheet(f, oilCostSheet, oilExportHeaders, oilRows)
+	})
+}
+
+// LoadCostExport loads the cost records of a car, or of every car when carID is 0
+func LoadCostExport(db *gorm.DB, carID uint) (CostExport, error) {
+	var export CostExport
+	scope := func(query *gorm.DB) *gorm.DB {
+		if carID != 0 {
+			return query.Where("car_id = ?", carID)
+		}
+		return query
+	}
+
+	if err := scope(db.Model(&FuelEvent{})).Order("car_no_plate ASC, date ASC, id ASC").
+		Find(&export.FuelEvents).Error; err != nil {
+		return export, err
+	}
+	if err := scope(db.Model(&Service{})).Order("date_of_service ASC, id ASC").
+		Find(&export.Services).Error; err != nil {
+		return export, err
+	}
+	if err := scope(db.Model(&OilChange{})).Order("date ASC, id ASC").
+		Find(&export.OilChanges).Error; err != nil {
+		return export, err
+	}
+	return export, nil
+}
+
+// BuildCostWorkbook builds one of the cost workbooks by its file name
+func BuildCostWorkbook(name string, export CostExport) (*excelize.File, error) {
+	switch name {
+	case FuelWorkbookName:
+		return BuildFuelWorkbook(export.FuelEvents)
+	case ServiceWorkbookName:
+		return BuildServiceWorkbook(export.Services)
+	case CarCostsWorkbookName:
+		return BuildCarCostsWorkbook(export)
+	}
+	return nil, fmt.Errorf("unknown cost workbook %q", name)
+}
+
+// ExportCostWorkbooks rebuilds the cost workbooks of every car in dir. Each workbook is
+// written to a temporary file first, so a workbook open elsewhere is only replaced whole.
+func ExportCostWorkbooks(db *gorm.DB, dir string) error {
+	export, err := LoadCostExport(db, 0)
+	if err != nil {
+		return fmt.Errorf("failed to load cost records: %w", err)
+	}
+
+	var errs []error
+	for _, name := range []string{FuelWorkbookName, ServiceWorkbookName, CarCostsWorkbookName} {
+		if err := writeCostWorkbook(dir, name, export); err != nil {
+			errs = append(errs, fmt.Errorf("failed to export %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func writeCostWorkbook(dir, name string, export CostExport) error {
+	f, err := BuildCostWorkbook(name, export)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	path := filepath.Join(dir, name)
+	tmp := filepath.Join(dir, ".tmp-"+name)
+	if err := f.SaveAs(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package Models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkbookSheetName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ط ع ن 1234", "ط ع ن 1234"},
+		{"  ABC 12 ", "ABC 12"},
+		{"12/34:56", "12-34-56"},
+		{"", "بدون رقم"},
+		{"0123456789012345678901234567890123", "0123456789012345678901234567890"},
+	}
+
+	for _, tt := range tests {
+		if got := WorkbookSheetName(tt.name); got != tt.want {
+			t.Errorf("WorkbookSheetName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFuelExportRow(t *testing.T) {
+	event := FuelEvent{
+		Date: "2025-03-01", CarNoPlate: "ABC 123", DriverName: "Ahmed",
+		OdometerBefore: 1000, OdometerAfter: 1450, FuelRate: 2.5,
+		Liters: 180, PricePerLiter: 13.75, Price: 2475,
+	}
+	want := []interface{}{"2025-03-01", "ABC 123", "Ahmed", 1450, 1000, 450, 2.5, 180.0, 13.75, 2475.0}
+
+	if got := FuelExportRow(event); !reflect.DeepEqual(got, want) {
+		t.Errorf("FuelExportRow = %v, want %v", got, want)
+	}
+}
+
+func TestOilExportRow(t *testing.T) {
+	oil := OilChange{Date: "2025-03-01", CarNoPlate: "ABC 123", Mileage: 10000, OdometerAtChange: 50000, CurrentOdometer: 53000, Cost: 900}
+	got := OilExportRow(oil)
+
+	if got[5] != 3000 || got[7] != 7000 {
+		t.Errorf("difference = %v, remaining = %v, want 3000 and 7000", got[5], got[7])
+	}
+}
+
+func TestBuildFuelWorkbook(t *testing.T) {
+	events := []FuelEvent{
+		{CarNoPlate: "B 2", Date: "2025-03-01"},
+		{CarNoPlate: "A 1", Date: "2025-03-01"},
+		{CarNoPlate: "B 2", Date: "2025-03-02"},
+	}
+
+	f, err := BuildFuelWorkbook(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if got, want := f.GetSheetList(), []string{"A 1", "B 2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sheets = %v, want %v", got, want)
+	}
+	rows, err := f.GetRows("B 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "التاريخ" || rows[2][0] != "2025-03-02" {
+		t.Errorf("rows = %v", rows)
+	}
+}
+
+func TestBuildCarCostsWorkbook(t *testing.T) {
+	f, err := BuildCarCostsWorkbook(CostExport{Services: []Service{{DateOfService: "2025-03-01", Cost: 500}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if got, want := f.GetSheetList(), []string{"تفويل", "صيانة", "زيت"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sheets = %v, want %v", got, want)
+	}
+	rows, _ := f.GetRows("صيانة")
+	if len(rows) != 2 || rows[1][6] != "500" {
+		t.Errorf("rows = %v", rows)
+	}
+}
+
+func TestLoadCostExportBackfilledFuel(t *testing.T) {
+	db := openTestDB(t, &Car{}, &Driver{}, &FuelEvent{}, &Service{}, &OilChange{})
+
+	car := Car{CarNoPlate: "ف ع ص 4381"}
+	db.Create(&car)
+	db.Create(&FuelEvent{CarNoPlate: "فعص 4381", Date: "2025-03-01", Liters: 100})
+	db.Create(&FuelEvent{CarNoPlate: "ن ق ر 1234", Date: "2025-03-01", Liters: 80})
+
+	if err := BackfillFuelEventCars(db); err != nil {
+		t.Fatal(err)
+	}
+	export, err := LoadCostExport(db, car.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.FuelEvents) != 1 || export.FuelEvents[0].Liters != 100 {
+		t.Errorf("fuel events of the car = %+v, want the one synced under its plate", export.FuelEvents)
+	}
+}
@@ -0,0 +1,241 @@
+package Models
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Driver assignment sources
+const (
+	AssignmentSourcePairing  = "pairing"  // Set from the car and driver pairing screen
+	AssignmentSourceTrip     = "trip"     // A trip was registered with the driver
+	AssignmentSourceBackfill = "backfill" // Rebuilt from trips registered before assignments were kept
+)
+
+// DriverAssignment is a driver driving a car from StartedAt until EndedAt. Times are wall
+// clock times stored without a zone, like VehiclePosition.RecordedAt.
+type DriverAssignment struct {
+	gorm.Model
+	CarID     uint       `json:"car_id" gorm:"index"`
+	DriverID  uint       `json:"driver_id" gorm:"index"`
+	StartedAt time.Time  `json:"started_at" gorm:"index"`
+	EndedAt   *time.Time `json:"ended_at" gorm:"index"` // Nil while the driver still drives the car
+	Source    string     `json:"source"`
+}
+
+// WallClockNow returns the current wall clock time labelled UTC, the way GPS fix times and
+// driver assignments are stored
+func WallClockNow() time.Time {
+	now, _ := time.Parse(PositionTimeLayout, time.Now().Format(PositionTimeLayout))
+	return now
+}
+
+// DateMidday is the time date-only events, such as trips and oil changes, are resolved at
+func DateMidday(date string) (time.Time, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return day, err
+	}
+	return day.Add(12 * time.Hour), nil
+}
+
+// AssignDriver records a driver taking over a car at the given time, ending the previous
+// assignment, and sets Car.DriverID. Assigning the current driver again changes nothing and
+// driver 0 leaves the car without a driver.
+func AssignDriver(db *gorm.DB, carID, driverID uint, at time.Time, source string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current DriverAssignment
+		if err := tx.Where("car_id = ? AND ended_at IS NULL", carID).
+			Order("started_at DESC").Limit(1).Find(&current).Error; err != nil {
+			return err
+		}
+
+		if current.ID == 0 || current.DriverID != driverID {
+			if current.ID != 0 {
+				if at.Before(current.StartedAt) {
+					at = current.StartedAt
+				}
+				current.EndedAt = &at
+				if err := tx.Save(&current).Error; err != nil {
+					return err
+				}
+			}
+			if driverID != 0 {
+				assignment := DriverAssignment{CarID: carID, DriverID: driverID, StartedAt: at, Source: source}
+				if err := tx.Create(&assignment).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Model(&Car{}).Where("id = ?", carID).Update("driver_id", driverID).Error
+	})
+}
+
+// DriverAt returns the ID of the driver assigned to a car at a time. Cars without any
+// assignment at that time fall back to their current driver.
+func DriverAt(db *gorm.DB, carID uint, at time.Time) (uint, error) {
+	var assignment DriverAssignment
+	if err := db.Where("car_id = ? AND started_at <= ? AND (ended_at IS NULL OR ended_at > ?)", carID, at, at).
+		Order("started_at DESC").Limit(1).Find(&assignment).Error; err != nil {
+		return 0, err
+	}
+	if assignment.ID != 0 {
+		return assignment.DriverID, nil
+	}
+
+	var car Car
+	if err := db.Select("id", "driver_id").Limit(1).Find(&car, carID).Error; err != nil {
+		return 0, err
+	}
+	return car.DriverID, nil
+}
+
+// ResolveDriver returns the driver assigned to a car at a time, a zero Driver if there is none
+func ResolveDriver(db *gorm.DB, carID uint, at time.Time) (Driver, error) {
+	var driver Driver
+	driverID, err := DriverAt(db, carID, at)
+	if err != nil || driverID == 0 {
+		return driver, err
+	}
+	err = db.Limit(1).Find(&driver, driverID).Error
+	return driver, err
+}
+
+// AssignmentTimeline holds the assignments of cars by car, oldest first, to resolve many
+// drivers without a query each
+type AssignmentTimeline map[uint][]DriverAssignment
+
+// LoadAssignmentTimeline loads the assignments overlapping a time range
+func LoadAssignmentTimeline(db *gorm.DB, from, to time.Time) (AssignmentTimeline, error) {
+	var assignments []DriverAssignment
+	if err := db.Where("started_at < ? AND (ended_at IS NULL OR ended_at > ?)", to, from).
+		Order("started_at ASC").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+
+	timeline := make(AssignmentTimeline)
+	for _, assignment := range assignments {
+		timeline[assignment.CarID] = append(timeline[assignment.CarID], assignment)
+	}
+	return timeline, nil
+}
+
+// DriverAt returns the driver assigned to a car at a time, 0 if the timeline has none
+func (t AssignmentTimeline) DriverAt(carID uint, at time.Time) uint {
+	assignments := t[carID]
+	for i := len(assignments) - 1; i >= 0; i-- {
+		assignment := assignments[i]
+		if !assignment.StartedAt.After(at) && (assignment.EndedAt == nil || assignment.EndedAt.After(at)) {
+			return assignment.DriverID
+		}
+	}
+	return 0
+}
+
+// AssignmentWindow is the part of a time range a driver drove a car
+type AssignmentWindow struct {
+	DriverID uint
+	From     time.Time
+	To       time.Time
+}
+
+// Windows splits a time range by the drivers assigned to a car, leaving out the parts
+// without an assignment
+func (t AssignmentTimeline) Windows(carID uint, from, to time.Time) []AssignmentWindow {
+	var windows []AssignmentWindow
+	for _, assignment := range t[carID] {
+		start, end := assignment.StartedAt, to
+		if assignment.EndedAt != nil && assignment.EndedAt.Before(end) {
+			end = *assignment.EndedAt
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if start.Before(end) {
+			windows = append(windows, AssignmentWindow{DriverID: assignment.DriverID, From: start, To: end})
+		}
+	}
+	return windows
+}
+
+// BuildAssignments rebuilds the assignment history of a car from its trips, oldest first.
+// A new assignment starts at the trip date whenever the driver changes, the first one is
+// open-ended in the past. When the car's current driver is not the last trip's, an
+// assignment to them starts at since.
+func BuildAssignments(carID uint, trips []TripStruct, currentDriverID uint, since time.Time) []DriverAssignment {
+	sorted := make([]TripStruct, len(trips))
+	copy(sorted, trips)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date < sorted[j].Date
+	})
+
+	var assignments []DriverAssignment
+	change := func(driverID uint, at time.Time) {
+		if n := len(assignments); n > 0 {
+			if assignments[n-1].DriverID == driverID {
+				return
+			}
+			if at.Before(assignments[n-1].StartedAt) {
+				at = assignments[n-1].StartedAt
+			}
+			ended := at
+			assignments[n-1].EndedAt = &ended
+		} else {
+			at = time.Time{}
+		}
+		if driverID != 0 {
+			assignments = append(assignments, DriverAssignment{CarID: carID, DriverID: driverID,
+				StartedAt: at, Source: AssignmentSourceBackfill})
+		}
+	}
+
+	for _, trip := range sorted {
+		day, err := time.Parse("2006-01-02", trip.Date)
+		if err != nil || trip.DriverID == 0 {
+			continue
+		}
+		change(trip.DriverID, day)
+	}
+	if currentDriverID != 0 || len(assignments) > 0 {
+		change(currentDriverID, since)
+	}
+	return assignments
+}
+
+// BackfillDriverAssignments builds the assignment history of the cars that have none from
+// their trips and current driver
+func BackfillDriverAssignments(db *gorm.DB) error {
+	var carIDs []uint
+	if err := db.Model(&DriverAssignment{}).Unscoped().Distinct("car_id").Pluck("car_id", &carIDs).Error; err != nil {
+		return err
+	}
+
+	query := db.Select("id", "driver_id", "updated_at")
+	if len(carIDs) > 0 {
+		query = query.Where("id NOT IN ?", carIDs)
+	}
+	var cars []Car
+	if err := query.Find(&cars).Error; err != nil {
+		return err
+	}
+
+	for _, car := range cars {
+		var trips []TripStruct
+		if err := db.Select("driver_id", "date").Where("car_id = ?", car.ID).Find(&trips).Error; err != nil {
+			return err
+		}
+
+		since, _ := time.Parse(PositionTimeLayout, car.UpdatedAt.Format(PositionTimeLayout))
+		assignments := BuildAssignments(car.ID, trips, car.DriverID, since)
+		if len(assignments) == 0 {
+			continue
+		}
+		if err := db.Create(&assignments).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
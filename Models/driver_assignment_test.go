@@ -0,0 +1,86 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAssignmentTimeline(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, _ := time.Parse(PositionTimeLayout, value)
+		return parsed
+	}
+	ended := at("2025-03-03 08:00:00")
+	timeline := AssignmentTimeline{
+		1: {
+			{CarID: 1, DriverID: 10, StartedAt: at("2025-03-01 00:00:00"), EndedAt: &ended},
+			{CarID: 1, DriverID: 20, StartedAt: ended},
+		},
+	}
+
+	tests := []struct {
+		at       string
+		expected uint
+	}{
+		{"2025-02-28 23:59:59", 0},
+		{"2025-03-02 12:00:00", 10},
+		{"2025-03-03 08:00:00", 20},
+		{"2025-04-01 00:00:00", 20},
+	}
+	for _, test := range tests {
+		if got := timeline.DriverAt(1, at(test.at)); got != test.expected {
+			t.Errorf("at %s: got driver %d, wanted %d", test.at, got, test.expected)
+		}
+	}
+
+	windows := timeline.Windows(1, at("2025-03-02 00:00:00"), at("2025-03-04 00:00:00"))
+	if len(windows) != 2 {
+		t.Fatalf("got %d windows, wanted 2", len(windows))
+	}
+	if windows[0].DriverID != 10 || !windows[0].From.Equal(at("2025-03-02 00:00:00")) || !windows[0].To.Equal(ended) {
+		t.Errorf("got first window %+v", windows[0])
+	}
+	if windows[1].DriverID != 20 || !windows[1].From.Equal(ended) || !windows[1].To.Equal(at("2025-03-04 00:00:00")) {
+		t.Errorf("got second window %+v", windows[1])
+	}
+}
+
+func TestBuildAssignments(t *testing.T) {
+	since, _ := time.Parse(PositionTimeLayout, "2025-03-10 09:00:00")
+	trips := []TripStruct{
+		{DriverID: 2, Date: "2025-03-05"},
+		{DriverID: 1, Date: "2025-03-01"},
+		{DriverID: 1, Date: "2025-03-02"},
+		{DriverID: 0, Date: "2025-03-06"}, // No driver, ignored
+	}
+
+	assignments := BuildAssignments(7, trips, 3, since)
+	if len(assignments) != 3 {
+		t.Fatalf("got %d assignments, wanted 3: %+v", len(assignments), assignments)
+	}
+
+	expected := []struct {
+		driverID uint
+		start    string
+		end      string
+	}{
+		{1, "0001-01-01", "2025-03-05"},
+		{2, "2025-03-05", "2025-03-10"},
+		{3, "2025-03-10", ""},
+	}
+	for i, e := range expected {
+		a := assignments[i]
+		end := ""
+		if a.EndedAt != nil {
+			end = a.EndedAt.Format("2006-01-02")
+		}
+		if a.CarID != 7 || a.DriverID != e.driverID || a.StartedAt.Format("2006-01-02") != e.start || end != e.end {
+			t.Errorf("assignment %d: got driver %d from %s to %q", i, a.DriverID, a.StartedAt.Format("2006-01-02"), end)
+		}
+	}
+
+	// The current driver drove the last trip, the history stays open with them
+	if got := BuildAssignments(7, trips[:1], 2, since); len(got) != 1 || got[0].EndedAt != nil {
+		t.Errorf("got %+v, wanted a single open assignment", got)
+	}
+}
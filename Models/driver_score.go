@@ -0,0 +1,298 @@
+package Models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Driver score periods
+const (
+	ScorePeriodWeek  = "week"
+	ScorePeriodMonth = "month"
+)
+
+// Driving analysis settings
+const (
+	maxDrivingSampleGap = 20 * time.Minute // Longer gaps between fixes are not counted
+	harshSampleWindow   = 30 * time.Second // Harsh driving needs fixes at most this far apart
+	HarshSpeedChange    = 12.0             // km/h per second of acceleration or braking
+	NightStartHour      = 22
+	NightEndHour        = 5
+)
+
+// DrivingStats is what the GPS history tells about how a car was driven
+type DrivingStats struct {
+	DistanceKm     float64 `json:"distance_km"`
+	DrivingMinutes float64 `json:"driving_minutes"`
+	IdleMinutes    float64 `json:"idle_minutes"` // Engine on while standing
+	NightMinutes   float64 `json:"night_minutes"`
+	HarshEvents    int     `json:"harsh_events"`
+}
+
+// Add accumulates other into the stats
+func (s *DrivingStats) Add(other DrivingStats) {
+	s.DistanceKm += other.DistanceKm
+	s.DrivingMinutes += other.DrivingMinutes
+	s.IdleMinutes += other.IdleMinutes
+	s.NightMinutes += other.NightMinutes
+	s.HarshEvents += other.HarshEvents
+}
+
+func engineRunning(status string) bool {
+	status = strings.TrimSpace(status)
+	return status != "" && !strings.EqualFold(status, "off")
+}
+
+func isNight(at time.Time) bool {
+	return at.Hour() >= NightStartHour || at.Hour() < NightEndHour
+}
+
+// DailyDrivingStats analyses the track of a car, oldest fix first, by day of the fix
+// opening each interval
+func DailyDrivingStats(positions []VehiclePosition) map[string]DrivingStats {
+	days := make(map[string]DrivingStats)
+	for i := 1; i < len(positions); i++ {
+		a, b := positions[i-1], positions[i]
+		gap := b.RecordedAt.Sub(a.RecordedAt)
+		if gap <= 0 || gap > maxDrivingSampleGap {
+			continue
+		}
+
+		day := a.RecordedAt.Format("2006-01-02")
+		stats := days[day]
+		switch {
+		case a.Speed > 0 || b.Speed > 0:
+			stats.DrivingMinutes += gap.Minutes()
+			stats.DistanceKm += DistanceKm(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+			if isNight(a.RecordedAt) {
+				stats.NightMinutes += gap.Minutes()
+			}
+		case engineRunning(a.EngineStatus):
+			stats.IdleMinutes += gap.Minutes()
+		}
+		if gap <= harshSampleWindow && math.Abs(float64(b.Speed-a.Speed))/gap.Seconds() >= HarshSpeedChange {
+			stats.HarshEvents++
+		}
+		days[day] = stats
+	}
+	return days
+}
+
+// Score weights, the score starts at 100 and loses points for each behaviour
+const (
+	speedingWeight = 5.0  // Per weighted violation per 100 km
+	harshWeight    = 3.0  // Per harsh event per 100 km
+	idleWeight     = 20.0 // Times the share of engine time spent idling
+	nightWeight    = 15.0 // Times the share of driving time at night
+	minScoredKm    = 100.0
+)
+
+// SpeedingPoints weighs a violation by how far the limit was exceeded, 10 km/h over the
+// limit counting twice as much as just over it
+func SpeedingPoints(event SpeedViolationEvent) float64 {
+	return 1 + math.Max(0, float64(event.PeakSpeed-event.SpeedLimit))/10
+}
+
+// ScoreDriving rates driving between 0 and 100. Events are counted per 100 km, with at
+// least 100 km so short periods are not over-penalised.
+func ScoreDriving(stats DrivingStats, speedingPoints float64) float64 {
+	hundredKm := math.Max(stats.DistanceKm, minScoredKm) / 100
+
+	score := 100.0
+	score -= speedingWeight * speedingPoints / hundredKm
+	score -= harshWeight * float64(stats.HarshEvents) / hundredKm
+	if engineMinutes := stats.DrivingMinutes + stats.IdleMinutes; engineMinutes > 0 {
+		score -= idleWeight * stats.IdleMinutes / engineMinutes
+	}
+	if stats.DrivingMinutes > 0 {
+		score -= nightWeight * stats.NightMinutes / stats.DrivingMinutes
+	}
+	return math.Round(math.Max(0, score)*10) / 10
+}
+
+// ScorePeriodBounds returns the week, starting on Monday, or month containing a day
+func ScorePeriodBounds(period string, day time.Time) (time.Time, time.Time, error) {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case ScorePeriodWeek:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7), nil
+	case ScorePeriodMonth:
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q, expected week or month", period)
+}
+
+// DriverScore is the driving score of a driver over a week or month
+type DriverScore struct {
+	gorm.Model
+	DriverID        uint    `json:"driver_id" gorm:"uniqueIndex:idx_driver_score_period"`
+	DriverName      string  `json:"driver_name"`
+	Period          string  `json:"period" gorm:"uniqueIndex:idx_driver_score_period"`
+	PeriodStart     string  `json:"period_start" gorm:"uniqueIndex:idx_driver_score_period"` // "2006-01-02"
+	PeriodEnd       string  `json:"period_end"`                                              // Exclusive
+	SpeedViolations int     `json:"speed_violations"`
+	SpeedingPoints  float64 `json:"speeding_points"`
+	DrivingStats
+	Score float64 `json:"score"`
+	Rank  int     `json:"rank"`
+}
+
+// ComputeDriverScores scores every driver over the period containing a day, ranks them and
+// stores the scores, replacing those computed before
+func ComputeDriverScores(db *gorm.DB, period string, day time.Time) ([]DriverScore, error) {
+	start, end, err := ScorePeriodBounds(period, day)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline, err := LoadAssignmentTimeline(db, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var cars []Car
+	if err := db.Select("id", "driver_id").Find(&cars).Error; err != nil {
+		return nil, err
+	}
+	currentDriver := make(map[uint]uint, len(cars))
+	for _, car := range cars {
+		currentDriver[car.ID] = car.DriverID
+	}
+
+	// Cars without assignment history are credited to their current driver
+	driverAt := func(carID uint, at time.Time) uint {
+		if len(timeline[carID]) == 0 {
+			return currentDriver[carID]
+		}
+		return timeline.DriverAt(carID, at)
+	}
+
+	byDriver := make(map[uint]*DriverScore)
+	scoreOf := func(driverID uint) *DriverScore {
+		if byDriver[driverID] == nil {
+			byDriver[driverID] = &DriverScore{DriverID: driverID, Period: period,
+				PeriodStart: start.Format("2006-01-02"), PeriodEnd: end.Format("2006-01-02")}
+		}
+		return byDriver[driverID]
+	}
+
+	for _, car := range cars {
+		windows := timeline.Windows(car.ID, start, end)
+		if len(timeline[car.ID]) == 0 {
+			windows = []AssignmentWindow{{DriverID: car.DriverID, From: start, To: end}}
+		}
+
+		for _, window := range windows {
+			if window.DriverID == 0 {
+				continue
+			}
+			track, err := VehicleTrack(db, car.ID, window.From, window.To)
+			if err != nil {
+				return nil, err
+			}
+			for _, stats := range DailyDrivingStats(track) {
+				scoreOf(window.DriverID).DrivingStats.Add(stats)
+			}
+		}
+	}
+
+	var violations []SpeedViolationEvent
+	if err := db.Where("car_id <> 0 AND started_at >= ? AND started_at < ?", start, end).
+		Find(&violations).Error; err != nil {
+		return nil, err
+	}
+	for _, violation := range violations {
+		driverID := violation.DriverID
+		if driverID == 0 {
+			driverID = driverAt(violation.CarID, violation.StartedAt)
+		}
+		if driverID == 0 {
+			continue
+		}
+		score := scoreOf(driverID)
+		score.SpeedViolations++
+		score.SpeedingPoints += SpeedingPoints(violation)
+	}
+
+	var names []Driver
+	if err := db.Select("id", "name").Find(&names).Error; err != nil {
+		return nil, err
+	}
+	nameOf := make(map[uint]string, len(names))
+	for _, driver := range names {
+		nameOf[driver.ID] = driver.Name
+	}
+
+	scores := make([]DriverScore, 0, len(byDriver))
+	for _, score := range byDriver {
+		if score.DistanceKm == 0 && score.SpeedViolations == 0 {
+			continue
+		}
+		score.DriverName = nameOf[score.DriverID]
+		score.Score = ScoreDriving(score.DrivingStats, score.SpeedingPoints)
+		roundDrivingStats(&score.DrivingStats)
+		score.SpeedingPoints = math.Round(score.SpeedingPoints*10) / 10
+		scores = append(scores, *score)
+	}
+	RankDriverScores(scores)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("period = ? AND period_start = ?", period, start.Format("2006-01-02")).
+			Delete(&DriverScore{}).Error; err != nil {
+			return err
+		}
+		if len(scores) == 0 {
+			return nil
+		}
+		return tx.Create(&scores).Error
+	})
+	return scores, err
+}
+
+func roundDrivingStats(stats *DrivingStats) {
+	stats.DistanceKm = math.Round(stats.DistanceKm*10) / 10
+	stats.DrivingMinutes = math.Round(stats.DrivingMinutes)
+	stats.IdleMinutes = math.Round(stats.IdleMinutes)
+	stats.NightMinutes = math.Round(stats.NightMinutes)
+}
+
+// RankDriverScores orders scores best first and numbers them, equal scores sharing a rank
+func RankDriverScores(scores []DriverScore) {
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].DistanceKm > scores[j].DistanceKm
+	})
+	for i := range scores {
+		if i > 0 && scores[i].Score == scores[i-1].Score {
+			scores[i].Rank = scores[i-1].Rank
+		} else {
+			scores[i].Rank = i + 1
+		}
+	}
+}
+
+// RefreshDriverScores recomputes the weekly and monthly scores of the periods containing
+// today, and those just closed when today starts a new period
+func RefreshDriverScores(db *gorm.DB, today time.Time) error {
+	yesterday := today.AddDate(0, 0, -1)
+	for _, period := range []string{ScorePeriodWeek, ScorePeriodMonth} {
+		if _, err := ComputeDriverScores(db, period, today); err != nil {
+			return err
+		}
+		start, _, _ := ScorePeriodBounds(period, today)
+		if yesterday.Before(start) {
+			if _, err := ComputeDriverScores(db, period, yesterday); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
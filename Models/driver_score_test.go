@@ -0,0 +1,97 @@
+package Models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDailyDrivingStats(t *testing.T) {
+	start, _ := time.Parse(PositionTimeLayout, "2025-03-01 21:50:00")
+	fix := func(minutes float64, speed int, engine string, lat float64) VehiclePosition {
+		return VehiclePosition{RecordedAt: start.Add(time.Duration(minutes * float64(time.Minute))),
+			Speed: speed, EngineStatus: engine, Latitude: lat, Longitude: 31}
+	}
+
+	positions := []VehiclePosition{
+		fix(0, 0, "On", 30.00),   // Idle 5 minutes
+		fix(5, 0, "On", 30.00),   // Day driving 5 minutes
+		fix(10, 60, "On", 30.05), // Night driving from 22:00
+		fix(15, 80, "On", 30.10),
+		fix(15+5.0/60, 20, "On", 30.10), // 60 km/h lost in 5 seconds
+		fix(20, 0, "Off", 30.11),
+		fix(80, 0, "Off", 30.11), // Gap too long, ignored
+	}
+
+	days := DailyDrivingStats(positions)
+	stats := days["2025-03-01"]
+	if stats.IdleMinutes != 5 {
+		t.Errorf("got %.2f idle minutes, wanted 5", stats.IdleMinutes)
+	}
+	if math.Abs(stats.DrivingMinutes-15) > 1e-9 {
+		t.Errorf("got %.2f driving minutes, wanted 15", stats.DrivingMinutes)
+	}
+	if math.Abs(stats.NightMinutes-10) > 1e-9 {
+		t.Errorf("got %.2f night minutes, wanted 10", stats.NightMinutes)
+	}
+	if stats.HarshEvents != 1 {
+		t.Errorf("got %d harsh events, wanted 1", stats.HarshEvents)
+	}
+	if stats.DistanceKm < 12 || stats.DistanceKm > 13 {
+		t.Errorf("got %.2f km, wanted about 12.2", stats.DistanceKm)
+	}
+}
+
+func TestScoreDriving(t *testing.T) {
+	clean := DrivingStats{DistanceKm: 500, DrivingMinutes: 600}
+	if got := ScoreDriving(clean, 0); got != 100 {
+		t.Errorf("got %.1f for clean driving, wanted 100", got)
+	}
+
+	// 2 weighted violations and 1 harsh event per 100 km, a fifth of engine time idle
+	// and a tenth of driving at night
+	risky := DrivingStats{DistanceKm: 500, DrivingMinutes: 600, IdleMinutes: 150, NightMinutes: 60, HarshEvents: 5}
+	if got := ScoreDriving(risky, 10); got != 100-10-3-4-1.5 {
+		t.Errorf("got %.1f for risky driving", got)
+	}
+
+	// Short periods are scored as if 100 km were driven
+	if got := ScoreDriving(DrivingStats{DistanceKm: 10, DrivingMinutes: 20}, 1); got != 95 {
+		t.Errorf("got %.1f for a short period, wanted 95", got)
+	}
+
+	if got := SpeedingPoints(SpeedViolationEvent{SpeedLimit: 80, PeakSpeed: 105}); got != 3.5 {
+		t.Errorf("got %.1f speeding points, wanted 3.5", got)
+	}
+}
+
+func TestScorePeriodBounds(t *testing.T) {
+	day, _ := time.Parse("2006-01-02", "2025-03-05") // Wednesday
+
+	start, end, _ := ScorePeriodBounds(ScorePeriodWeek, day)
+	if start.Format("2006-01-02") != "2025-03-03" || end.Format("2006-01-02") != "2025-03-10" {
+		t.Errorf("got week %s - %s", start, end)
+	}
+
+	start, end, _ = ScorePeriodBounds(ScorePeriodMonth, day)
+	if start.Format("2006-01-02") != "2025-03-01" || end.Format("2006-01-02") != "2025-04-01" {
+		t.Errorf("got month %s - %s", start, end)
+	}
+
+	if _, _, err := ScorePeriodBounds("year", day); err == nil {
+		t.Error("expected an error for an unknown period")
+	}
+}
+
+func TestRankDriverScores(t *testing.T) {
+	scores := []DriverScore{{DriverID: 1, Score: 80}, {DriverID: 2, Score: 95}, {DriverID: 3, Score: 80}}
+	RankDriverScores(scores)
+
+	ranks := map[uint]int{}
+	for _, score := range scores {
+		ranks[score.DriverID] = score.Rank
+	}
+	if ranks[2] != 1 || ranks[1] != 2 || ranks[3] != 2 {
+		t.Errorf("got ranks %v", ranks)
+	}
+}
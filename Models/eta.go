@@ -0,0 +1,49 @@
+package Models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ETA submission statuses
+const (
+	ETASubmissionPrepared  = "prepared"  // Document built and validated locally, not yet sent
+	ETASubmissionSubmitted = "submitted" // Accepted by the ETA submission endpoint
+	ETASubmissionValid     = "valid"
+	ETASubmissionInvalid   = "invalid"
+	ETASubmissionRejected  = "rejected"
+	ETASubmissionCancelled = "cancelled"
+)
+
+// ETATaxpayer is the registration of a party on Egyptian Tax Authority documents.
+// The issuer row describes our own company, the others are keyed by the trip company name.
+type ETATaxpayer struct {
+	gorm.Model
+	Company        string `json:"company" gorm:"index"` // Trip company name, empty for the issuer
+	IsIssuer       bool   `json:"is_issuer"`
+	Type           string `json:"type"`        // B business, P person, F foreigner
+	TaxpayerID     string `json:"taxpayer_id"` // Registration number (RIN)
+	Name           string `json:"name"`
+	BranchID       string `json:"branch_id"`
+	Country        string `json:"country"`
+	Governate      string `json:"governate"`
+	RegionCity     string `json:"region_city"`
+	Street         string `json:"street"`
+	BuildingNumber string `json:"building_number"`
+	ActivityCode   string `json:"activity_code"` // Issuer's taxpayer activity code
+	ItemType       string `json:"item_type"`     // Issuer's registered item coding, EGS or GS1
+	ItemCode       string `json:"item_code"`     // Issuer's registered code for transport services
+}
+
+// ETASubmission keeps the document sent to the ETA for an invoice and the identifiers it returned
+type ETASubmission struct {
+	gorm.Model
+	InvoiceID      uint   `json:"invoice_id" gorm:"index"`
+	InternalID     string `json:"internal_id" gorm:"index"` // Invoice number, echoed back by ETA exports
+	DocumentType   string `json:"document_type"`
+	Payload        string `json:"payload" gorm:"type:text"`
+	Status         string `json:"status" gorm:"index"`
+	SubmissionUUID string `json:"submission_uuid"`
+	UUID           string `json:"uuid" gorm:"index"`
+	LongID         string `json:"long_id"`
+	Error          string `json:"error" gorm:"type:text"`
+}
@@ -0,0 +1,332 @@
+package Models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// External vehicle sources
+const (
+	ExternalSourcePetroApp = "petroapp" // PetroApp fuel cards, by PetroApp vehicle ID
+	ExternalSourceETIT     = "etit"     // ETIT telematics units, mirrored on Car.EtitCarID
+)
+
+// External vehicle identity statuses
+const (
+	ExternalIdentityConfirmed = "confirmed"
+	ExternalIdentityProposed  = "proposed" // Suggested by the plate matcher, waiting for confirmation
+)
+
+// ExternalVehicleIdentity links a car to its ID in an external system. Only confirmed
+// identities are used to attribute external records to cars.
+type ExternalVehicleIdentity struct {
+	gorm.Model
+	CarID         uint   `json:"car_id" gorm:"index"`
+	CarNoPlate    string `json:"car_no_plate"`
+	Source        string `json:"source" gorm:"uniqueIndex:idx_external_vehicle"`
+	ExternalID    string `json:"external_id" gorm:"uniqueIndex:idx_external_vehicle"`
+	ExternalPlate string `json:"external_plate"` // Plate as the external system writes it
+	Status        string `json:"status" gorm:"index"`
+}
+
+// Validate checks the settings of an external vehicle identity
+func (i *ExternalVehicleIdentity) Validate() error {
+	if i.CarID == 0 {
+		return errors.New("car_id is required")
+	}
+	if strings.TrimSpace(i.Source) == "" {
+		return errors.New("source is required")
+	}
+	if strings.TrimSpace(i.ExternalID) == "" {
+		return errors.New("external_id is required")
+	}
+	switch i.Status {
+	case ExternalIdentityConfirmed, ExternalIdentityProposed:
+	default:
+		return fmt.Errorf("unknown status %q", i.Status)
+	}
+	return nil
+}
+
+// latinPlateLetters maps the Latin letters of Egyptian plates to their Arabic letters
+var latinPlateLetters = map[rune]rune{
+	'A': 'ا', 'B': 'ب', 'G': 'ج', 'D': 'د', 'R': 'ر', 'S': 'س', 'C': 'ص', 'T': 'ط', 'E': 'ع',
+	'F': 'ف', 'Q': 'ق', 'K': 'ك', 'L': 'ل', 'M': 'م', 'N': 'ن', 'H': 'ه', 'W': 'و', 'Y': 'ى',
+}
+
+// normalizePlateRune folds the spellings of the same plate character together
+func normalizePlateRune(r rune) rune {
+	switch {
+	case r == 'أ' || r == 'إ' || r == 'آ':
+		return 'ا'
+	case r == 'ي':
+		return 'ى'
+	case r == 'ة':
+		return 'ه'
+	case r >= '٠' && r <= '٩':
+		return '0' + (r - '٠')
+	}
+	return r
+}
+
+// PlateKey reduces an Arabic plate to its letters and digits, so that plates spelled with
+// different spacing, alef or yeh forms compare equal
+func PlateKey(plate string) string {
+	var key strings.Builder
+	for _, r := range plate {
+		r = normalizePlateRune(r)
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			key.WriteRune(r)
+		}
+	}
+	return key.String()
+}
+
+// TransliteratePlate converts a Latin plate such as "C E F-4 3 8 1" to the Arabic form cars
+// are registered with, "ف ع ص 4381". The Latin letters read in the opposite order. It
+// returns false when the plate has letters with no Arabic equivalent.
+func TransliteratePlate(plate string) (string, bool) {
+	var letters []string
+	var digits strings.Builder
+	for _, r := range strings.ToUpper(plate) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			arabic, ok := latinPlateLetters[r]
+			if !ok {
+				return "", false
+			}
+			letters = append([]string{string(arabic)}, letters...)
+		case unicode.IsLetter(r):
+			return "", false
+		}
+	}
+	if len(letters) == 0 || digits.Len() == 0 {
+		return "", false
+	}
+	return strings.Join(letters, " ") + " " + digits.String(), true
+}
+
+// MatchPlate returns the cars whose plate is the external plate, given in Latin or Arabic
+func MatchPlate(plate string, cars []Car) []Car {
+	key := PlateKey(plate)
+	if arabic, ok := TransliteratePlate(plate); ok {
+		key = PlateKey(arabic)
+	}
+
+	var matches []Car
+	for _, car := range cars {
+		if key != "" && PlateKey(car.CarNoPlate) == key {
+			matches = append(matches, car)
+		}
+	}
+	return matches
+}
+
+// FindExternalVehicle returns the car confirmed for an external vehicle, nil if there is none
+func FindExternalVehicle(db *gorm.DB, source, externalID string) (*Car, error) {
+	var identity ExternalVehicleIdentity
+	if err := db.Where("source = ? AND external_id = ? AND status = ?", source, externalID, ExternalIdentityConfirmed).
+		Limit(1).Find(&identity).Error; err != nil || identity.ID == 0 {
+		return nil, err
+	}
+
+	var car Car
+	if err := db.Limit(1).Find(&car, identity.CarID).Error; err != nil || car.ID == 0 {
+		return nil, err
+	}
+	return &car, nil
+}
+
+// ExternalVehicleID returns the confirmed ID of a car in an external system, empty if it has none
+func ExternalVehicleID(db *gorm.DB, source string, carID uint) (string, error) {
+	var identity ExternalVehicleIdentity
+	err := db.Where("source = ? AND car_id = ? AND status = ?", source, carID, ExternalIdentityConfirmed).
+		Limit(1).Find(&identity).Error
+	return identity.ExternalID, err
+}
+
+// ConfirmedExternalIDs returns the external IDs of a source linked to a car
+func ConfirmedExternalIDs(db *gorm.DB, source string) ([]string, error) {
+	var ids []string
+	err := db.Model(&ExternalVehicleIdentity{}).
+		Where("source = ? AND status = ?", source, ExternalIdentityConfirmed).
+		Pluck("external_id", &ids).Error
+	return ids, err
+}
+
+// SaveExternalVehicle stores an identity with the plate of its car. Confirmed ETIT identities
+// are copied to Car.EtitCarID, which the telematics jobs read.
+func SaveExternalVehicle(db *gorm.DB, identity *ExternalVehicleIdentity) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var car Car
+		if err := tx.Select("id", "car_no_plate").Limit(1).Find(&car, identity.CarID).Error; err != nil {
+			return err
+		}
+		if car.ID == 0 {
+			return fmt.Errorf("car %d not found", identity.CarID)
+		}
+		identity.CarNoPlate = car.CarNoPlate
+
+		if err := tx.Save(identity).Error; err != nil {
+			return err
+		}
+		if identity.Source == ExternalSourceETIT && identity.Status == ExternalIdentityConfirmed {
+			return tx.Model(&Car{}).Where("id = ?", car.ID).Update("etit_car_id", identity.ExternalID).Error
+		}
+		return nil
+	})
+}
+
+// ProposeExternalVehicle looks for the car of an unlinked external vehicle by its plate and
+// proposes the link when exactly one car matches. It returns the identity of the vehicle, nil
+// when it has none.
+func ProposeExternalVehicle(db *gorm.DB, source, externalID, externalPlate string) (*ExternalVehicleIdentity, error) {
+	var identity ExternalVehicleIdentity
+	if err := db.Where("source = ? AND external_id = ?", source, externalID).Limit(1).Find(&identity).Error; err != nil {
+		return nil, err
+	}
+	if identity.ID != 0 {
+		return &identity, nil
+	}
+
+	var cars []Car
+	if err := db.Select("id", "car_no_plate").Find(&cars).Error; err != nil {
+		return nil, err
+	}
+	matches := MatchPlate(externalPlate, cars)
+	if len(matches) != 1 {
+		return nil, nil
+	}
+
+	identity = ExternalVehicleIdentity{CarID: matches[0].ID, CarNoPlate: matches[0].CarNoPlate, Source: source,
+		ExternalID: externalID, ExternalPlate: externalPlate, Status: ExternalIdentityProposed}
+	return &identity, db.Create(&identity).Error
+}
+
+// UnmatchedVehicle is an external vehicle with records but no confirmed car
+type UnmatchedVehicle struct {
+	Source        string                   `json:"source"`
+	ExternalID    string                   `json:"external_id"`
+	ExternalPlate string                   `json:"external_plate"`
+	Records       int                      `json:"records"`
+	Unsynced      int                      `json:"unsynced"`
+	FirstDate     string                   `json:"first_date"`
+	LastDate      string                   `json:"last_date"`
+	Proposal      *ExternalVehicleIdentity `json:"proposal" gorm:"-"`   // Link proposed by the plate matcher
+	Candidates    []Car                    `json:"candidates" gorm:"-"` // Cars whose plate matches
+}
+
+// UnmatchedPetroAppVehicles lists the PetroApp vehicles whose records no confirmed identity
+// attributes to a car
+func UnmatchedPetroAppVehicles(db *gorm.DB) ([]UnmatchedVehicle, error) {
+	var vehicles []UnmatchedVehicle
+	if err := db.Model(&PetroAppRecord{}).
+		Select("CAST(vehicle_id AS TEXT) AS external_id, MAX(vehicle) AS external_plate, COUNT(*) AS records, " +
+			"SUM(CASE WHEN is_synced THEN 0 ELSE 1 END) AS unsynced, MIN(date) AS first_date, MAX(date) AS last_date").
+		Group("vehicle_id").
+		Order("last_date DESC").
+		Scan(&vehicles).Error; err != nil {
+		return nil, err
+	}
+
+	confirmed, err := ConfirmedExternalIDs(db, ExternalSourcePetroApp)
+	if err != nil {
+		return nil, err
+	}
+	linked := make(map[string]bool, len(confirmed))
+	for _, id := range confirmed {
+		linked[id] = true
+	}
+
+	var cars []Car
+	if err := db.Select("id", "car_no_plate").Find(&cars).Error; err != nil {
+		return nil, err
+	}
+
+	unmatched := []UnmatchedVehicle{}
+	for _, vehicle := range vehicles {
+		if linked[vehicle.ExternalID] {
+			continue
+		}
+		vehicle.Source = ExternalSourcePetroApp
+		vehicle.Candidates = MatchPlate(vehicle.ExternalPlate, cars)
+
+		var proposal ExternalVehicleIdentity
+		if err := db.Where("source = ? AND external_id = ? AND status = ?", ExternalSourcePetroApp, vehicle.ExternalID,
+			ExternalIdentityProposed).Limit(1).Find(&proposal).Error; err != nil {
+			return nil, err
+		}
+		if proposal.ID != 0 {
+			vehicle.Proposal = &proposal
+		}
+		unmatched = append(unmatched, vehicle)
+	}
+	return unmatched, nil
+}
+
+// legacyPetroAppVehicles are the PetroApp vehicles that were mapped in code before identities
+// were stored, by PetroApp vehicle ID
+var legacyPetroAppVehicles = []struct {
+	ExternalID    string
+	ExternalPlate string
+	CarNoPlate    string
+}{
+	{"53008", "C E F-4 3 8 1", "ف ع ص 4381"},
+	{"53005", "C Q F-4 2 5 3", "ف ق ص 4253"},
+	{"53004", "R Y F-9 1 5 6", "ف ى ر 9156"},
+	{"53007", "N A F-5 1 3 9", "ف أ ن 5139"},
+	{"53010", "S M F-9 2 4 7", "ف م س 9247"},
+	{"53012", "S R F-4 5 9 3", "ف ر س 4593"},
+	{"53006", "Y D F-6 5 8 4", "ف د ى 6584"},
+	{"53011", "Y D F-6 8 3 4", "ف د ى 6834"},
+	{"53009", "N A F-7 4 2 1", "ف ا ن 7421"},
+}
+
+// SeedExternalVehicleIdentities confirms the identities known before they were stored: the
+// PetroApp vehicles mapped in code and the ETIT units set on cars
+func SeedExternalVehicleIdentities(db *gorm.DB) error {
+	var cars []Car
+	if err := db.Select("id", "car_no_plate", "etit_car_id").Find(&cars).Error; err != nil {
+		return err
+	}
+
+	var existing []ExternalVehicleIdentity
+	if err := db.Unscoped().Find(&existing).Error; err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, identity := range existing {
+		known[identity.Source+"/"+identity.ExternalID] = true
+	}
+
+	var identities []ExternalVehicleIdentity
+	for _, vehicle := range legacyPetroAppVehicles {
+		if known[ExternalSourcePetroApp+"/"+vehicle.ExternalID] {
+			continue
+		}
+		if matches := MatchPlate(vehicle.CarNoPlate, cars); len(matches) == 1 {
+			identities = append(identities, ExternalVehicleIdentity{CarID: matches[0].ID, CarNoPlate: matches[0].CarNoPlate,
+				Source: ExternalSourcePetroApp, ExternalID: vehicle.ExternalID, ExternalPlate: vehicle.ExternalPlate,
+				Status: ExternalIdentityConfirmed})
+		}
+	}
+	for _, car := range cars {
+		if car.EtitCarID == "" || known[ExternalSourceETIT+"/"+car.EtitCarID] {
+			continue
+		}
+		known[ExternalSourceETIT+"/"+car.EtitCarID] = true
+		identities = append(identities, ExternalVehicleIdentity{CarID: car.ID, CarNoPlate: car.CarNoPlate,
+			Source: ExternalSourceETIT, ExternalID: car.EtitCarID, Status: ExternalIdentityConfirmed})
+	}
+
+	if len(identities) == 0 {
+		return nil
+	}
+	return db.Create(&identities).Error
+}
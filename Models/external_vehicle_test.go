@@ -0,0 +1,59 @@
+package Models
+
+import "testing"
+
+func TestTransliteratePlate(t *testing.T) {
+	tests := []struct {
+		plate    string
+		expected string
+		ok       bool
+	}{
+		{"C E F-4 3 8 1", "ف ع ص 4381", true},
+		{"R Y F-9 1 5 6", "ف ى ر 9156", true},
+		{"n a f-7421", "ف ا ن 7421", true},
+		{"X Y F-1 2 3 4", "", false}, // X has no Arabic equivalent
+		{"4381", "", false},
+		{"ف ع ص 4381", "", false}, // Already Arabic
+	}
+
+	for _, test := range tests {
+		got, ok := TransliteratePlate(test.plate)
+		if got != test.expected || ok != test.ok {
+			t.Errorf("TransliteratePlate(%q) = %q, %v, wanted %q, %v", test.plate, got, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestMatchPlate(t *testing.T) {
+	cars := []Car{
+		{CarNoPlate: "ف أ ن 5139"},
+		{CarNoPlate: "ف د ي 6584"},
+		{CarNoPlate: "فدى ٦٨٣٤"},
+		{CarNoPlate: "ف ع ص 4381"},
+	}
+	cars[0].ID, cars[1].ID, cars[2].ID, cars[3].ID = 1, 2, 3, 4
+
+	tests := []struct {
+		plate    string
+		expected uint
+	}{
+		{"N A F-5 1 3 9", 1}, // Alef with hamza
+		{"Y D F-6 5 8 4", 2}, // Yeh spelled ي
+		{"Y D F-6 8 3 4", 3}, // Arabic-Indic digits, no spaces
+		{"ف ع ص  4381", 4},   // Arabic plate with extra spacing
+		{"S M F-9 2 4 7", 0}, // No such car
+	}
+
+	for _, test := range tests {
+		matches := MatchPlate(test.plate, cars)
+		if test.expected == 0 {
+			if len(matches) != 0 {
+				t.Errorf("MatchPlate(%q) = %v, wanted no match", test.plate, matches)
+			}
+			continue
+		}
+		if len(matches) != 1 || matches[0].ID != test.expected {
+			t.Errorf("MatchPlate(%q) = %v, wanted car %d", test.plate, matches, test.expected)
+		}
+	}
+}
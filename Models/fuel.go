@@ -1,31 +1,28 @@
 package Models
 
 import (
-	"fmt"
 	"log"
-	"strconv"
 
-	"github.com/360EntSecGroup-Skylar/excelize"
 	"gorm.io/gorm"
 )
 
 type FuelEvent struct {
 	gorm.Model
-	CarID              uint    `json:"car_id"`
-	CarNoPlate         string  `json:"car_no_plate"`
-	DriverName         string  `json:"driver_name"`
-	Date               string  `json:"date"`
-	Time               string  `json:"time"`
-	Liters             float64 `json:"liters"`
-	PricePerLiter      float64 `json:"price_per_liter"`
-	Price              float64 `json:"price"`
-	FuelRate           float64 `json:"fuel_rate"`
-	Transporter        string  `json:"transporter"`
-	OdometerBefore     int     `json:"odometer_before"`
-	OdometerAfter      int     `json:"odometer_after"`
-	ExcelIndex         int
-	ExcelIndexExpenses int
-	Method             string `json:"method"`
+	CarID              uint     `json:"car_id"`
+	CarNoPlate         string   `json:"car_no_plate"`
+	DriverName         string   `json:"driver_name"`
+	Date               string   `json:"date"`
+	Time               string   `json:"time"`
+	Liters             float64  `json:"liters"`
+	PricePerLiter      float64  `json:"price_per_liter"`
+	Price              float64  `json:"price"`
+	FuelRate           float64  `json:"fuel_rate"`
+	Transporter        string   `json:"transporter"`
+	OdometerBefore     int      `json:"odometer_before"`
+	OdometerAfter      int      `json:"odometer_after"`
+	Method             string   `json:"method"`
+	LocationStatus     string   `json:"location_status" gorm:"index"` // Fill location check, empty when not checked
+	LocationDistanceKm *float64 `json:"location_distance_km"`         // Distance between the car and the station
 }
 
 func (input *FuelEvent) Add() (*FuelEvent, error) {
@@ -60,7 +57,6 @@ func (input *FuelEvent) Edit() (*FuelEvent, error) {
 		log.Println(err.Error())
 		return &FuelEvent{}, err
 	}
-	fmt.Println(CurrentFuelEvent)
 	CurrentFuelEvent.CarNoPlate = input.CarNoPlate
 	CurrentFuelEvent.DriverName = input.DriverName
 	CurrentFuelEvent.Date = input.Date
@@ -71,92 +67,6 @@ func (input *FuelEvent) Edit() (*FuelEvent, error) {
 	CurrentFuelEvent.OdometerBefore = input.OdometerBefore
 	CurrentFuelEvent.OdometerAfter = input.OdometerAfter
 
-	f, err := excelize.OpenFile("تفويلات.xlsx")
-	if err != nil {
-		log.Println(err)
-		return &FuelEvent{}, err
-	}
-	sheets := f.GetSheetMap()
-	var carSheetExists bool
-	var activeSheet string
-	for _, sheet := range sheets {
-		if sheet == input.CarNoPlate {
-			carSheetExists = true
-		}
-	}
-	if !carSheetExists {
-		f.NewSheet(input.CarNoPlate)
-
-	}
-	activeSheet = input.CarNoPlate
-	index := strconv.Itoa(CurrentFuelEvent.ExcelIndex)
-
-	differenceKM := input.OdometerAfter - input.OdometerBefore
-	fuelRate := fmt.Sprintf("%v", input.FuelRate)
-	headers := map[string]string{
-		"A1":        "التاريخ",
-		"B1":        "رقم العربية",
-		"C1":        "السائق",
-		"D1":        "العداد الحالي",
-		"E1":        "العداد السابق",
-		"F1":        "فرق الكيلومتر",
-		"G1":        "المعدل",
-		"H1":        "كمية التفويل",
-		"I1":        "سعر اللتر",
-		"J1":        "التكلفة",
-		"A" + index: input.Date,
-		"B" + index: input.CarNoPlate,
-		"C" + index: input.DriverName,
-		"D" + index: strconv.Itoa(int(input.OdometerAfter)),
-		"E" + index: strconv.Itoa(int(input.OdometerBefore)),
-		"F" + index: strconv.Itoa(int(differenceKM)),
-		"G" + index: fuelRate,
-		"H" + index: fmt.Sprintf("%v", input.Liters),
-		"I" + index: fmt.Sprintf("%v", input.PricePerLiter),
-		"J" + index: fmt.Sprintf("%v", input.Price),
-	}
-	for k, v := range headers {
-		f.SetCellValue(activeSheet, k, v)
-	}
-	if err := f.Save(); err != nil {
-		return &FuelEvent{}, err
-	}
-
-	fExpenses, err := excelize.OpenFile("تكاليف السيارات.xlsx")
-	if err != nil {
-		log.Println(err)
-		return &FuelEvent{}, err
-	}
-	indexExpenses := strconv.Itoa(CurrentFuelEvent.ExcelIndexExpenses)
-	headersExpenses := map[string]string{
-		"A1":                "التاريخ",
-		"B1":                "رقم العربية",
-		"C1":                "السائق",
-		"D1":                "العداد الحالي",
-		"E1":                "العداد السابق",
-		"F1":                "فرق الكيلومتر",
-		"G1":                "المعدل",
-		"H1":                "كمية التفويل",
-		"I1":                "سعر اللتر",
-		"J1":                "التكلفة",
-		"A" + indexExpenses: input.Date,
-		"B" + indexExpenses: input.CarNoPlate,
-		"C" + indexExpenses: input.DriverName,
-		"D" + indexExpenses: strconv.Itoa(int(input.OdometerAfter)),
-		"E" + indexExpenses: strconv.Itoa(int(input.OdometerBefore)),
-		"F" + indexExpenses: strconv.Itoa(int(differenceKM)),
-		"G" + indexExpenses: fuelRate,
-		"H" + indexExpenses: fmt.Sprintf("%v", input.Liters),
-		"I" + indexExpenses: fmt.Sprintf("%v", input.PricePerLiter),
-		"J" + indexExpenses: fmt.Sprintf("%v", input.Price),
-	}
-	for k, v := range headersExpenses {
-		fExpenses.SetCellValue("تفويل", k, v)
-	}
-	if err := fExpenses.Save(); err != nil {
-		return &FuelEvent{}, err
-	}
-
 	if err := DB.Save(&CurrentFuelEvent).Error; err != nil {
 		log.Println(err.Error())
 		return &FuelEvent{}, err
@@ -165,60 +75,43 @@ func (input *FuelEvent) Edit() (*FuelEvent, error) {
 }
 
 func (input *FuelEvent) Delete() (*FuelEvent, error) {
-	f, err := excelize.OpenFile("تفويلات.xlsx")
-	if err != nil {
-		log.Println(err)
+	if err := DB.Delete(&FuelEvent{}, input).Error; err != nil {
+		log.Println(err.Error())
 		return &FuelEvent{}, err
 	}
-	index := strconv.Itoa(input.ExcelIndex)
-	headers := map[string]string{
-		"A" + index: "Deleted",
-		"B" + index: "Deleted",
-		"C" + index: "Deleted",
-		"D" + index: "Deleted",
-		"D" + index: "Deleted",
-		"E" + index: "Deleted",
-		"F" + index: "Deleted",
-		"G" + index: "Deleted",
-		"H" + index: "Deleted",
-		"I" + index: "Deleted",
-		"J" + index: "Deleted",
-	}
-	for k, v := range headers {
-		f.SetCellValue(input.CarNoPlate, k, v)
+	return input, nil
+}
+
+// BackfillFuelEventCars links the fuel events synced before cars were matched, stored without
+// a car, to the car registered with their plate
+func BackfillFuelEventCars(db *gorm.DB) error {
+	var events []FuelEvent
+	if err := db.Select("id", "car_no_plate").Where("car_id = 0 AND car_no_plate <> ''").Find(&events).Error; err != nil {
+		return err
 	}
-	if err := f.Save(); err != nil {
-		return &FuelEvent{}, err
+	if len(events) == 0 {
+		return nil
 	}
 
-	fExpenses, err := excelize.OpenFile("تكاليف السيارات.xlsx")
-	if err != nil {
-		log.Println(err)
-		return &FuelEvent{}, err
+	var cars []Car
+	if err := db.Select("id", "car_no_plate").Find(&cars).Error; err != nil {
+		return err
 	}
-	indexExpenses := strconv.Itoa(input.ExcelIndexExpenses)
-	headersExpenses := map[string]string{
-		"A" + indexExpenses: "Deleted",
-		"B" + indexExpenses: "Deleted",
-		"C" + indexExpenses: "Deleted",
-		"D" + indexExpenses: "Deleted",
-		"D" + indexExpenses: "Deleted",
-		"E" + indexExpenses: "Deleted",
-		"F" + indexExpenses: "Deleted",
-		"G" + indexExpenses: "Deleted",
-		"H" + indexExpenses: "Deleted",
-		"I" + indexExpenses: "Deleted",
-		"J" + indexExpenses: "Deleted",
+	carsByPlate := make(map[string]uint, len(cars))
+	for _, car := range cars {
+		carsByPlate[PlateKey(car.CarNoPlate)] = car.ID
 	}
-	for k, v := range headersExpenses {
-		fExpenses.SetCellValue("تفويل", k, v)
-	}
-	if err := fExpenses.Save(); err != nil {
-		return &FuelEvent{}, err
+
+	eventsByCar := make(map[uint][]uint)
+	for _, event := range events {
+		if carID, ok := carsByPlate[PlateKey(event.CarNoPlate)]; ok {
+			eventsByCar[carID] = append(eventsByCar[carID], event.ID)
+		}
 	}
-	if err := DB.Delete(&FuelEvent{}, input).Error; err != nil {
-		log.Println(err.Error())
-		return &FuelEvent{}, err
+	for carID, eventIDs := range eventsByCar {
+		if err := db.Model(&FuelEvent{}).Where("id IN ?", eventIDs).Update("car_id", carID).Error; err != nil {
+			return err
+		}
 	}
-	return input, nil
+	return nil
 }
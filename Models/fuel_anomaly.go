@@ -0,0 +1,299 @@
+package Models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Fuel anomaly review statuses
+const (
+	FuelAnomalyPending  = "pending"
+	FuelAnomalyApproved = "approved" // Reviewed, the fill-up is legitimate
+	FuelAnomalyRejected = "rejected" // Reviewed, the fill-up is confirmed suspicious
+)
+
+// Fuel anomaly kinds
+const (
+	FuelAnomalyOverCapacity    = "over_capacity"     // More liters than the tank holds
+	FuelAnomalyHighConsumption = "high_consumption"  // Far fewer km/L than the car's baseline
+	FuelAnomalyLowConsumption  = "low_consumption"   // Far more km/L than the car's baseline
+	FuelAnomalyOdometer        = "odometer_mismatch" // Odometer distance disagrees with the GPS track
+	FuelAnomalyAwayFromStation = "away_from_station" // The car was not at the station at fill time
+)
+
+// Fuel anomaly detection settings
+const (
+	fuelBaselineFills      = 10   // Previous fill-ups the baseline consumption is taken from
+	minFuelBaselineFills   = 3    // Fewer fill-ups give no baseline
+	FuelRateDeviation      = 0.25 // Allowed share of deviation from the baseline km/L
+	TankOverfillMargin     = 0.05 // Share of the tank capacity a fill-up may exceed it by
+	OdometerGPSDeviation   = 0.2  // Allowed share of deviation between odometer and GPS distance
+	minCheckedDistanceKm   = 50.0 // Shorter distances are too noisy to compare
+	MaxStationDistanceKm   = 2.0  // How far from the station the car may be at fill time
+	maxFuelRate            = 50.0 // Rates outside these bounds are data errors, not consumption
+	minFuelRate            = 0.1
+	fuelEventGPSCoverage   = time.Hour // The track must start and end this close to the fill-ups
+	fuelEventMaxGPSHistory = 7 * 24 * time.Hour
+)
+
+// FuelCheck is what a fill-up is checked against. Optional measures are nil when unknown.
+type FuelCheck struct {
+	Liters            float64
+	FuelRate          float64 // km/L since the previous fill-up
+	OdometerKm        float64 // Odometer distance since the previous fill-up
+	TankCapacity      float64 // 0 when unknown
+	BaselineRate      float64 // Usual km/L of the car, 0 when unknown
+	GPSDistanceKm     *float64
+	StationDistanceKm *float64 // Distance between the car and the station at fill time
+}
+
+// FuelFinding is one reason a fill-up looks suspicious
+type FuelFinding struct {
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// DetectFuelAnomalies returns the reasons a fill-up looks suspicious, none if it looks fine
+func DetectFuelAnomalies(check FuelCheck) []FuelFinding {
+	var findings []FuelFinding
+
+	if check.TankCapacity > 0 && check.Liters > check.TankCapacity*(1+TankOverfillMargin) {
+		findings = append(findings, FuelFinding{FuelAnomalyOverCapacity,
+			fmt.Sprintf("%.1f L filled into a %.0f L tank", check.Liters, check.TankCapacity)})
+	}
+
+	if check.BaselineRate > 0 && check.OdometerKm >= minCheckedDistanceKm && check.FuelRate > 0 {
+		deviation := (check.FuelRate - check.BaselineRate) / check.BaselineRate
+		switch {
+		case deviation < -FuelRateDeviation:
+			findings = append(findings, FuelFinding{FuelAnomalyHighConsumption,
+				fmt.Sprintf("%.2f km/L against a baseline of %.2f km/L", check.FuelRate, check.BaselineRate)})
+		case deviation > FuelRateDeviation:
+			findings = append(findings, FuelFinding{FuelAnomalyLowConsumption,
+				fmt.Sprintf("%.2f km/L against a baseline of %.2f km/L", check.FuelRate, check.BaselineRate)})
+		}
+	}
+
+	if gps := check.GPSDistanceKm; gps != nil && math.Max(*gps, check.OdometerKm) >= minCheckedDistanceKm {
+		if math.Abs(check.OdometerKm-*gps) > OdometerGPSDeviation*math.Max(*gps, check.OdometerKm) {
+			findings = append(findings, FuelFinding{FuelAnomalyOdometer,
+				fmt.Sprintf("odometer shows %.0f km since the previous fill-up, GPS %.0f km", check.OdometerKm, *gps)})
+		}
+	}
+
+	if station := check.StationDistanceKm; station != nil && *station > MaxStationDistanceKm {
+		findings = append(findings, FuelFinding{FuelAnomalyAwayFromStation,
+			fmt.Sprintf("car was %.1f km from the station at fill time", *station)})
+	}
+
+	return findings
+}
+
+// FuelBaseline is the median of the plausible fuel rates of previous fill-ups, 0 when there
+// are too few of them
+func FuelBaseline(rates []float64) float64 {
+	var plausible []float64
+	for _, rate := range rates {
+		if rate > minFuelRate && rate < maxFuelRate {
+			plausible = append(plausible, rate)
+		}
+	}
+	if len(plausible) < minFuelBaselineFills {
+		return 0
+	}
+
+	sort.Float64s(plausible)
+	middle := len(plausible) / 2
+	if len(plausible)%2 == 0 {
+		return (plausible[middle-1] + plausible[middle]) / 2
+	}
+	return plausible[middle]
+}
+
+// FuelEventTime returns the time of a fill-up. Fill-ups recorded without a time are not
+// precise enough for GPS checks and report false.
+func FuelEventTime(event FuelEvent) (time.Time, bool) {
+	for _, layout := range []string{"3:04 PM", "15:04", "15:04:05"} {
+		if at, err := time.Parse("2006-01-02 "+layout, event.Date+" "+strings.TrimSpace(event.Time)); err == nil {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FuelAnomaly is a suspicious fill-up waiting for, or after, review
+type FuelAnomaly struct {
+	gorm.Model
+	FuelEventID       uint       `json:"fuel_event_id" gorm:"uniqueIndex"`
+	CarID             uint       `json:"car_id" gorm:"index"`
+	CarNoPlate        string     `json:"car_no_plate"`
+	DriverName        string     `json:"driver_name"`
+	Date              string     `json:"date" gorm:"index"`
+	Liters            float64    `json:"liters"`
+	Kinds             string     `json:"kinds"`   // Comma separated anomaly kinds
+	Details           string     `json:"details"` // One line per finding
+	FuelRate          float64    `json:"fuel_rate"`
+	BaselineRate      float64    `json:"baseline_rate"`
+	OdometerKm        float64    `json:"odometer_km"`
+	GPSDistanceKm     *float64   `json:"gps_distance_km"`
+	TankCapacity      int        `json:"tank_capacity"`
+	StationDistanceKm *float64   `json:"station_distance_km"`
+	Status            string     `json:"status" gorm:"index"`
+	ReviewedBy        string     `json:"reviewed_by"`
+	ReviewedAt        *time.Time `json:"reviewed_at"`
+	ReviewNote        string     `json:"review_note"`
+}
+
+// fuelBaselineRate is the usual km/L of the car before a fill-up, leaving out fill-ups
+// rejected on review
+func fuelBaselineRate(db *gorm.DB, event FuelEvent) (float64, error) {
+	var rates []float64
+	err := db.Model(&FuelEvent{}).
+		Where("car_no_plate = ? AND id <> ? AND date <= ?", event.CarNoPlate, event.ID, event.Date).
+		Where("id NOT IN (?)", db.Model(&FuelAnomaly{}).Select("fuel_event_id").Where("status = ?", FuelAnomalyRejected)).
+		Order("date DESC, id DESC").
+		Limit(fuelBaselineFills).
+		Pluck("fuel_rate", &rates).Error
+	return FuelBaseline(rates), err
+}
+
+// fuelGPSDistance is the GPS distance driven between the previous fill-up of the car and this
+// one, nil when either time is unknown or the track does not cover the interval
+func fuelGPSDistance(db *gorm.DB, carID uint, event FuelEvent, at time.Time) (*float64, error) {
+	var candidates []FuelEvent
+	if err := db.Where("car_no_plate = ? AND id <> ? AND date <= ? AND date >= ?", event.CarNoPlate, event.ID,
+		event.Date, at.Add(-fuelEventMaxGPSHistory).Format("2006-01-02")).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	// Fill-ups without a time are placed at midday, a previous fill-up without one is too vague
+	var since time.Time
+	timed := false
+	for _, candidate := range candidates {
+		candidateAt, ok := FuelEventTime(candidate)
+		if !ok {
+			candidateAt, _ = DateMidday(candidate.Date)
+		}
+		if candidateAt.Before(at) && candidateAt.After(since) {
+			since, timed = candidateAt, ok
+		}
+	}
+	if !timed {
+		return nil, nil
+	}
+
+	track, err := VehicleTrack(db, carID, since, at)
+	if err != nil || len(track) < 2 {
+		return nil, err
+	}
+	if track[0].RecordedAt.Sub(since) > fuelEventGPSCoverage || at.Sub(track[len(track)-1].RecordedAt) > fuelEventGPSCoverage {
+		return nil, nil
+	}
+	distance := math.Round(TrackDistance(track)*10) / 10
+	return &distance, nil
+}
+
+// fuelStationDistance is the distance between the car and the station at fill time, nil when
+// the station or the car position is unknown. Fill-ups whose location was already verified
+// keep that distance.
+func fuelStationDistance(db *gorm.DB, carID uint, event FuelEvent, at time.Time) (*float64, error) {
+	if event.LocationStatus != "" {
+		return event.LocationDistanceKm, nil
+	}
+
+	var station PetroAppStation
+	if err := db.Where("name = ?", strings.TrimSpace(event.Transporter)).Limit(1).Find(&station).Error; err != nil {
+		return nil, err
+	}
+	if station.ID == 0 {
+		return nil, nil
+	}
+
+	track, err := VehicleTrack(db, carID, at.Add(-FillLocationWindow), at.Add(FillLocationWindow))
+	if err != nil {
+		return nil, err
+	}
+	_, distance := VerifyFillLocation(station.Lat, station.Lng, track, at)
+	return distance, nil
+}
+
+// CheckFuelEvent checks a fill-up and queues it for review when it looks suspicious. It
+// returns the anomaly, nil when the fill-up looks fine. Anomalies already reviewed are kept
+// as they are.
+func CheckFuelEvent(db *gorm.DB, event FuelEvent) (*FuelAnomaly, error) {
+	var car Car
+	query := db.Select("id", "car_no_plate", "tank_capacity").Limit(1)
+	if event.CarID != 0 {
+		query = query.Where("id = ?", event.CarID)
+	} else {
+		query = query.Where("car_no_plate = ?", event.CarNoPlate)
+	}
+	if err := query.Find(&car).Error; err != nil {
+		return nil, err
+	}
+
+	baseline, err := fuelBaselineRate(db, event)
+	if err != nil {
+		return nil, err
+	}
+	check := FuelCheck{
+		Liters:       event.Liters,
+		FuelRate:     event.FuelRate,
+		OdometerKm:   float64(event.OdometerAfter - event.OdometerBefore),
+		TankCapacity: float64(car.TankCapacity),
+		BaselineRate: baseline,
+	}
+	if at, ok := FuelEventTime(event); ok && car.ID != 0 {
+		if check.GPSDistanceKm, err = fuelGPSDistance(db, car.ID, event, at); err != nil {
+			return nil, err
+		}
+		if check.StationDistanceKm, err = fuelStationDistance(db, car.ID, event, at); err != nil {
+			return nil, err
+		}
+	}
+
+	var anomaly FuelAnomaly
+	if err := db.Where("fuel_event_id = ?", event.ID).Limit(1).Find(&anomaly).Error; err != nil {
+		return nil, err
+	}
+	if anomaly.ID != 0 && anomaly.Status != FuelAnomalyPending {
+		return &anomaly, nil
+	}
+
+	findings := DetectFuelAnomalies(check)
+	if len(findings) == 0 {
+		if anomaly.ID != 0 {
+			return nil, db.Unscoped().Delete(&anomaly).Error
+		}
+		return nil, nil
+	}
+
+	kinds := make([]string, len(findings))
+	details := make([]string, len(findings))
+	for i, finding := range findings {
+		kinds[i], details[i] = finding.Kind, finding.Detail
+	}
+
+	anomaly.FuelEventID = event.ID
+	anomaly.CarID = car.ID
+	anomaly.CarNoPlate = event.CarNoPlate
+	anomaly.DriverName = event.DriverName
+	anomaly.Date = event.Date
+	anomaly.Liters = event.Liters
+	anomaly.Kinds = strings.Join(kinds, ",")
+	anomaly.Details = strings.Join(details, "\n")
+	anomaly.FuelRate = event.FuelRate
+	anomaly.BaselineRate = math.Round(baseline*100) / 100
+	anomaly.OdometerKm = check.OdometerKm
+	anomaly.GPSDistanceKm = check.GPSDistanceKm
+	anomaly.TankCapacity = car.TankCapacity
+	anomaly.StationDistanceKm = check.StationDistanceKm
+	anomaly.Status = FuelAnomalyPending
+	return &anomaly, db.Save(&anomaly).Error
+}
@@ -0,0 +1,102 @@
+package Models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectFuelAnomalies(t *testing.T) {
+	km := func(value float64) *float64 { return &value }
+
+	tests := []struct {
+		name     string
+		check    FuelCheck
+		expected []string
+	}{
+		{
+			name:  "Normal fill-up",
+			check: FuelCheck{Liters: 200, FuelRate: 2.5, OdometerKm: 500, TankCapacity: 400, BaselineRate: 2.4, GPSDistanceKm: km(480), StationDistanceKm: km(0.3)},
+		},
+		{
+			name:     "Over tank capacity",
+			check:    FuelCheck{Liters: 450, TankCapacity: 400},
+			expected: []string{FuelAnomalyOverCapacity},
+		},
+		{
+			name:  "Within the overfill margin",
+			check: FuelCheck{Liters: 410, TankCapacity: 400},
+		},
+		{
+			name:     "Burning far more than usual",
+			check:    FuelCheck{Liters: 300, FuelRate: 1.5, OdometerKm: 450, BaselineRate: 2.5},
+			expected: []string{FuelAnomalyHighConsumption},
+		},
+		{
+			name:     "Odometer inflated",
+			check:    FuelCheck{Liters: 200, FuelRate: 4, OdometerKm: 800, BaselineRate: 2.5, GPSDistanceKm: km(500)},
+			expected: []string{FuelAnomalyLowConsumption, FuelAnomalyOdometer},
+		},
+		{
+			name:  "Short distances are not compared",
+			check: FuelCheck{Liters: 30, FuelRate: 1, OdometerKm: 30, BaselineRate: 2.5, GPSDistanceKm: km(10)},
+		},
+		{
+			name:     "Away from the station",
+			check:    FuelCheck{Liters: 200, StationDistanceKm: km(12.5)},
+			expected: []string{FuelAnomalyAwayFromStation},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var kinds []string
+			for _, finding := range DetectFuelAnomalies(test.check) {
+				kinds = append(kinds, finding.Kind)
+			}
+			if !reflect.DeepEqual(kinds, test.expected) {
+				t.Errorf("got %v, wanted %v", kinds, test.expected)
+			}
+		})
+	}
+}
+
+func TestFuelBaseline(t *testing.T) {
+	tests := []struct {
+		rates    []float64
+		expected float64
+	}{
+		{[]float64{2.4, 2.6}, 0},                  // Too few fill-ups
+		{[]float64{2.4, 2.6, 0, 75, 2.5}, 2.5},    // Implausible rates left out
+		{[]float64{2.2, 2.8, 2.4, 2.6}, 2.5},      // Even count
+		{[]float64{2.5, 2.5, 9, 2.4, 2.6}, 2.5},   // Median ignores the outlier
+		{[]float64{0.05, 2.5, 2.5, 60, 2.5}, 2.5}, // Bounds are exclusive
+		{[]float64{1.9, 2.1, 2.0, 2.2, 1.8}, 2.0}, // Odd count
+		{[]float64{3.0, 3.0, 3.0, 3.0, 3.0}, 3.0}, // Constant rates
+	}
+
+	for _, test := range tests {
+		if got := FuelBaseline(test.rates); got != test.expected {
+			t.Errorf("FuelBaseline(%v) = %v, wanted %v", test.rates, got, test.expected)
+		}
+	}
+}
+
+func TestFuelEventTime(t *testing.T) {
+	tests := []struct {
+		date, time string
+		expected   string
+		ok         bool
+	}{
+		{"2025-07-31", "12:35 PM", "2025-07-31 12:35:00", true},
+		{"2025-07-31", "3:04 AM", "2025-07-31 03:04:00", true},
+		{"2025-07-31", "21:10", "2025-07-31 21:10:00", true},
+		{"2025-07-31", "", "", false},
+	}
+
+	for _, test := range tests {
+		at, ok := FuelEventTime(FuelEvent{Date: test.date, Time: test.time})
+		if ok != test.ok || (ok && at.Format(PositionTimeLayout) != test.expected) {
+			t.Errorf("FuelEventTime(%s %s) = %v, %v, wanted %s, %v", test.date, test.time, at, ok, test.expected, test.ok)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package Models
+
+import (
+	"math"
+	"time"
+)
+
+// Fill location statuses of a fuel event, empty when the location was not checked
+const (
+	FillLocationVerified   = "verified"   // The car was at the station
+	FillLocationUnverified = "unverified" // No GPS fix or station location to compare
+	FillLocationMismatched = "mismatched" // The car was away from the station
+)
+
+// FillLocationWindow is how far from the fill time GPS fixes are looked at
+const FillLocationWindow = 15 * time.Minute
+
+// VerifyFillLocation compares a station location with the fixes of the car around the fill
+// time. The car is located by its closest fix to the station within FillLocationWindow, so
+// a fix taken while it queues or leaves still counts. It returns the status and the
+// distance in kilometers, nil when unverified.
+func VerifyFillLocation(lat, lng float64, track []VehiclePosition, at time.Time) (string, *float64) {
+	if lat == 0 && lng == 0 {
+		return FillLocationUnverified, nil
+	}
+
+	var closest *float64
+	for _, position := range track {
+		if math.Abs(position.RecordedAt.Sub(at).Seconds()) > FillLocationWindow.Seconds() {
+			continue
+		}
+		distance := DistanceKm(position.Latitude, position.Longitude, lat, lng)
+		if closest == nil || distance < *closest {
+			closest = &distance
+		}
+	}
+	if closest == nil {
+		return FillLocationUnverified, nil
+	}
+
+	distance := math.Round(*closest*10) / 10
+	if distance > MaxStationDistanceKm {
+		return FillLocationMismatched, &distance
+	}
+	return FillLocationVerified, &distance
+}
@@ -0,0 +1,45 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyFillLocation(t *testing.T) {
+	at, _ := time.Parse(PositionTimeLayout, "2025-07-31 12:35:00")
+	fix := func(minutes int, lat float64) VehiclePosition {
+		return VehiclePosition{Latitude: lat, Longitude: 31.2, RecordedAt: at.Add(time.Duration(minutes) * time.Minute)}
+	}
+
+	tests := []struct {
+		name     string
+		lat, lng float64
+		track    []VehiclePosition
+		status   string
+		distance float64
+	}{
+		{"At the station", 30.0, 31.2, []VehiclePosition{fix(-10, 30.1), fix(-2, 30.001), fix(8, 30.05)}, FillLocationVerified, 0.1},
+		{"Away from the station", 30.0, 31.2, []VehiclePosition{fix(-5, 30.2), fix(5, 30.21)}, FillLocationMismatched, 22.2},
+		{"Fixes outside the window", 30.0, 31.2, []VehiclePosition{fix(-40, 30.0), fix(20, 30.0)}, FillLocationUnverified, 0},
+		{"No GPS track", 30.0, 31.2, nil, FillLocationUnverified, 0},
+		{"No station location", 0, 0, []VehiclePosition{fix(0, 30.0)}, FillLocationUnverified, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, distance := VerifyFillLocation(test.lat, test.lng, test.track, at)
+			if status != test.status {
+				t.Errorf("got status %s, wanted %s", status, test.status)
+			}
+			if test.status == FillLocationUnverified {
+				if distance != nil {
+					t.Errorf("got distance %v, wanted none", *distance)
+				}
+				return
+			}
+			if distance == nil || *distance != test.distance {
+				t.Errorf("got distance %v, wanted %v", distance, test.distance)
+			}
+		})
+	}
+}
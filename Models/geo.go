@@ -0,0 +1,27 @@
+package Models
+
+import "math"
+
+// DistanceKm returns the great-circle distance between two coordinates in kilometers
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	const R = 6371 // Earth's radius in kilometers
+
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*
+			math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return R * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// TrackDistance returns the kilometers driven along a time ordered track
+func TrackDistance(positions []VehiclePosition) float64 {
+	var distance float64
+	for i := 1; i < len(positions); i++ {
+		previous, current := positions[i-1], positions[i]
+		distance += DistanceKm(previous.Latitude, previous.Longitude, current.Latitude, current.Longitude)
+	}
+	return distance
+}
@@ -0,0 +1,189 @@
+package Models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Geofence types
+const (
+	GeofenceGarage     = "garage"
+	GeofenceTerminal   = "terminal"
+	GeofenceDropOff    = "dropoff"
+	GeofenceStation    = "station"
+	GeofenceRestricted = "restricted"
+)
+
+// Geofence shapes
+const (
+	GeofenceCircle  = "circle"
+	GeofencePolygon = "polygon"
+)
+
+// DefaultDropOffRadius is the circle around fee mapping coordinates used for drop-off
+// points that have no geofence of their own
+const DefaultDropOffRadius = 0.5 // kilometers
+
+// GeofencePoint is a polygon vertex
+type GeofencePoint struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+}
+
+// Geofence is an area the telematics poll reports cars entering and leaving. Circles use
+// the center and radius, polygons their points. Company scopes the fence to one operating
+// company, ActiveFrom and ActiveTo ("15:04") restrict it to daily hours, wrapping past
+// midnight when ActiveTo is earlier than ActiveFrom.
+type Geofence struct {
+	gorm.Model
+	Name       string          `json:"name" gorm:"index"`
+	Type       string          `json:"type" gorm:"index"`
+	Shape      string          `json:"shape"`
+	Latitude   float64         `json:"lat"`
+	Longitude  float64         `json:"long"`
+	Radius     float64         `json:"radius"` // Kilometers, circles only
+	Points     []GeofencePoint `json:"points" gorm:"serializer:json"`
+	Company    string          `json:"company" gorm:"index"` // Empty for every company
+	ActiveFrom string          `json:"active_from"`
+	ActiveTo   string          `json:"active_to"`
+	Priority   int             `json:"priority"` // Lowest first when fences overlap
+	Enabled    bool            `json:"enabled"`  // CreateGeofence defaults it to true
+}
+
+// Validate checks the shape and settings of a geofence
+func (g *Geofence) Validate() error {
+	if g.Name == "" {
+		return errors.New("name is required")
+	}
+
+	switch g.Type {
+	case GeofenceGarage, GeofenceTerminal, GeofenceDropOff, GeofenceStation, GeofenceRestricted:
+	default:
+		return fmt.Errorf("unknown geofence type %q", g.Type)
+	}
+
+	switch g.Shape {
+	case GeofenceCircle:
+		if g.Radius <= 0 {
+			return errors.New("circles need a positive radius")
+		}
+		if g.Latitude < -90 || g.Latitude > 90 || g.Longitude < -180 || g.Longitude > 180 {
+			return errors.New("invalid circle center")
+		}
+	case GeofencePolygon:
+		if len(g.Points) < 3 {
+			return errors.New("polygons need at least 3 points")
+		}
+	default:
+		return fmt.Errorf("unknown geofence shape %q", g.Shape)
+	}
+
+	for _, value := range []string{g.ActiveFrom, g.ActiveTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", value); err != nil {
+			return fmt.Errorf("invalid active hour %q, expected HH:MM", value)
+		}
+	}
+	if (g.ActiveFrom == "") != (g.ActiveTo == "") {
+		return errors.New("active hours need both a start and an end")
+	}
+
+	return nil
+}
+
+// Contains reports whether a coordinate lies inside the geofence
+func (g *Geofence) Contains(lat, lng float64) bool {
+	if g.Shape == GeofencePolygon {
+		return polygonContains(g.Points, lat, lng)
+	}
+	return DistanceKm(lat, lng, g.Latitude, g.Longitude) <= g.Radius
+}
+
+// polygonContains casts a ray from the point and counts the edges it crosses
+func polygonContains(points []GeofencePoint, lat, lng float64) bool {
+	inside := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		a, b := points[i], points[j]
+		if (a.Latitude > lat) != (b.Latitude > lat) &&
+			lng < (b.Longitude-a.Longitude)*(lat-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ActiveAt reports whether the geofence applies at the wall clock time of at
+func (g *Geofence) ActiveAt(at time.Time) bool {
+	if !g.Enabled {
+		return false
+	}
+	if g.ActiveFrom == "" || g.ActiveTo == "" {
+		return true
+	}
+
+	clock := at.Format("15:04")
+	if g.ActiveFrom <= g.ActiveTo {
+		return clock >= g.ActiveFrom && clock < g.ActiveTo
+	}
+	return clock >= g.ActiveFrom || clock < g.ActiveTo
+}
+
+// AppliesTo reports whether the geofence covers cars of the operating company
+func (g *Geofence) AppliesTo(company string) bool {
+	return g.Company == "" || strings.EqualFold(g.Company, company)
+}
+
+// LoadGeofences returns the enabled geofences in evaluation order
+func LoadGeofences(db *gorm.DB) ([]Geofence, error) {
+	var geofences []Geofence
+	err := db.Where("enabled = ?", true).Order("priority ASC, id ASC").Find(&geofences).Error
+	return geofences, err
+}
+
+// MatchGeofence returns the first geofence containing the coordinate for a company's car at
+// the given time, nil if there is none
+func MatchGeofence(geofences []Geofence, company string, lat, lng float64, at time.Time) *Geofence {
+	for i := range geofences {
+		geofence := &geofences[i]
+		if geofence.AppliesTo(company) && geofence.ActiveAt(at) && geofence.Contains(lat, lng) {
+			return geofence
+		}
+	}
+	return nil
+}
+
+// SeedGeofences creates the garage and terminal circles the fleet started with, once
+func SeedGeofences(db *gorm.DB) error {
+	var count int64
+	if err := db.Unscoped().Model(&Geofence{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	circle := func(name, geofenceType string, lat, lng, radius float64) Geofence {
+		return Geofence{Name: name, Type: geofenceType, Shape: GeofenceCircle,
+			Latitude: lat, Longitude: lng, Radius: radius, Enabled: true}
+	}
+	geofences := []Geofence{
+		circle("garage", GeofenceGarage, 30.128955, 31.298539, 0.4),
+		circle("Badr Terminal", GeofenceTerminal, 30.1020583, 31.81396, 0.5),
+		circle("CPC Mostorod Terminal", GeofenceTerminal, 30.144197, 31.296322, 0.5),
+		circle("Fayoum Terminal", GeofenceTerminal, 29.3391616, 30.9257033, 0.5),
+		circle("Misr Petroleum Bor Saed Terminal", GeofenceTerminal, 31.235575, 32.301198, 0.5),
+		circle("Mobil Bor Saed Terminal", GeofenceTerminal, 31.23365, 32.298082, 0.5),
+		circle("Haykstep Terminal", GeofenceTerminal, 30.12486, 31.3580633, 0.5),
+		circle("Somed Terminal", GeofenceTerminal, 29.594416, 32.329073, 0.5),
+		circle("Agroud Terminal", GeofenceTerminal, 30.071958, 32.381296, 0.5),
+		circle("TAQA Suez Terminal", GeofenceTerminal, 29.964054, 32.515200, 0.5),
+		circle("TAQA Alex Terminal", GeofenceTerminal, 31.149223, 29.853037, 0.5),
+	}
+	return db.Create(&geofences).Error
+}
@@ -0,0 +1,249 @@
+package Models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GeofenceEvent is a car entering or leaving a geofence, as seen by the telematics poll.
+// RecordedAt is the fix time, stored without a zone like VehiclePosition.RecordedAt.
+type GeofenceEvent struct {
+	gorm.Model
+	CarID        uint      `json:"car_id" gorm:"index"`
+	CarNoPlate   string    `json:"car_no_plate"`
+	Company      string    `json:"company" gorm:"index"`
+	GeofenceName string    `json:"geofence_name" gorm:"index"`
+	GeofenceType string    `json:"geofence_type"`
+	Entered      bool      `json:"entered"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	RecordedAt   time.Time `json:"recorded_at" gorm:"index"`
+}
+
+// RecordGeofenceEvents stores the geofence transitions of a car
+func RecordGeofenceEvents(db *gorm.DB, car *Car, transitions []GeofenceTransition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	events := make([]GeofenceEvent, 0, len(transitions))
+	for _, t := range transitions {
+		events = append(events, GeofenceEvent{
+			CarID:        car.ID,
+			CarNoPlate:   car.CarNoPlate,
+			Company:      car.OperatingCompany,
+			GeofenceName: t.Name,
+			GeofenceType: t.Type,
+			Entered:      t.Entered,
+			Latitude:     t.Latitude,
+			Longitude:    t.Longitude,
+			RecordedAt:   t.At,
+		})
+	}
+	return db.Create(&events).Error
+}
+
+// GeofenceVisit is the stay of a car in a geofence, from an enter event to the matching exit
+type GeofenceVisit struct {
+	CarID        uint      `json:"car_id"`
+	CarNoPlate   string    `json:"car_no_plate"`
+	Company      string    `json:"company"`
+	GeofenceName string    `json:"geofence_name"`
+	GeofenceType string    `json:"geofence_type"`
+	EnteredAt    time.Time `json:"entered_at"`
+	ExitedAt     time.Time `json:"exited_at"`
+	DwellMinutes float64   `json:"dwell_minutes"`
+}
+
+// GeofenceVisits pairs the enter and exit events of each car into visits, ordered by entry.
+// Exits without a preceding enter and stays still in progress are left out.
+func GeofenceVisits(events []GeofenceEvent) []GeofenceVisit {
+	sorted := make([]GeofenceEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].CarID != sorted[j].CarID {
+			return sorted[i].CarID < sorted[j].CarID
+		}
+		return sorted[i].RecordedAt.Before(sorted[j].RecordedAt)
+	})
+
+	visits := []GeofenceVisit{}
+	open := make(map[uint]*GeofenceEvent)
+	for i := range sorted {
+		event := &sorted[i]
+		if event.Entered {
+			open[event.CarID] = event
+			continue
+		}
+
+		enter := open[event.CarID]
+		if enter == nil || enter.GeofenceName != event.GeofenceName {
+			continue
+		}
+		delete(open, event.CarID)
+
+		visits = append(visits, GeofenceVisit{
+			CarID:        enter.CarID,
+			CarNoPlate:   enter.CarNoPlate,
+			Company:      enter.Company,
+			GeofenceName: enter.GeofenceName,
+			GeofenceType: enter.GeofenceType,
+			EnteredAt:    enter.RecordedAt,
+			ExitedAt:     event.RecordedAt,
+			DwellMinutes: roundMinutes(event.RecordedAt.Sub(enter.RecordedAt)),
+		})
+	}
+
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].EnteredAt.Before(visits[j].EnteredAt)
+	})
+	return visits
+}
+
+// DwellSummary aggregates the visits of a group, a geofence, company or car
+type DwellSummary struct {
+	Key            string  `json:"key"`
+	GeofenceType   string  `json:"geofence_type,omitempty"`
+	Visits         int     `json:"visits"`
+	AverageMinutes float64 `json:"average_minutes"`
+	MaxMinutes     float64 `json:"max_minutes"`
+	TotalMinutes   float64 `json:"total_minutes"`
+}
+
+// SummarizeDwell groups visits by the given key and averages their dwell time, longest
+// average first
+func SummarizeDwell(visits []GeofenceVisit, key func(GeofenceVisit) string) []DwellSummary {
+	byKey := make(map[string]*DwellSummary)
+	var keys []string
+	for _, visit := range visits {
+		k := key(visit)
+		summary := byKey[k]
+		if summary == nil {
+			summary = &DwellSummary{Key: k, GeofenceType: visit.GeofenceType}
+			byKey[k] = summary
+			keys = append(keys, k)
+		}
+		if summary.GeofenceType != visit.GeofenceType {
+			summary.GeofenceType = ""
+		}
+		summary.Visits++
+		summary.TotalMinutes += visit.DwellMinutes
+		summary.MaxMinutes = math.Max(summary.MaxMinutes, visit.DwellMinutes)
+	}
+
+	summaries := make([]DwellSummary, 0, len(keys))
+	for _, k := range keys {
+		summary := byKey[k]
+		summary.AverageMinutes = math.Round(summary.TotalMinutes/float64(summary.Visits)*10) / 10
+		summary.TotalMinutes = math.Round(summary.TotalMinutes*10) / 10
+		summaries = append(summaries, *summary)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].AverageMinutes > summaries[j].AverageMinutes
+	})
+	return summaries
+}
+
+// maxTurnaround is the longest gap between two terminal arrivals still counted as one
+// round trip, longer gaps are days off or maintenance
+const maxTurnaround = 72 * time.Hour
+
+// TurnaroundSummary is the average time a truck takes from one terminal arrival to the next
+type TurnaroundSummary struct {
+	CarID          uint    `json:"car_id"`
+	CarNoPlate     string  `json:"car_no_plate"`
+	Company        string  `json:"company"`
+	RoundTrips     int     `json:"round_trips"`
+	AverageMinutes float64 `json:"average_minutes"`
+	MinMinutes     float64 `json:"min_minutes"`
+	MaxMinutes     float64 `json:"max_minutes"`
+}
+
+// SummarizeTurnaround measures the round trips of each truck between consecutive terminal
+// visits, fastest average first
+func SummarizeTurnaround(visits []GeofenceVisit) []TurnaroundSummary {
+	byCar := make(map[uint]*TurnaroundSummary)
+	lastArrival := make(map[uint]time.Time)
+	var cars []uint
+	total := make(map[uint]float64)
+
+	for _, visit := range visits {
+		if visit.GeofenceType != GeofenceTerminal {
+			continue
+		}
+
+		previous, seen := lastArrival[visit.CarID]
+		lastArrival[visit.CarID] = visit.EnteredAt
+		if !seen || visit.EnteredAt.Sub(previous) > maxTurnaround {
+			continue
+		}
+
+		minutes := roundMinutes(visit.EnteredAt.Sub(previous))
+		summary := byCar[visit.CarID]
+		if summary == nil {
+			summary = &TurnaroundSummary{CarID: visit.CarID, CarNoPlate: visit.CarNoPlate,
+				Company: visit.Company, MinMinutes: minutes}
+			byCar[visit.CarID] = summary
+			cars = append(cars, visit.CarID)
+		}
+		summary.RoundTrips++
+		total[visit.CarID] += minutes
+		summary.MinMinutes = math.Min(summary.MinMinutes, minutes)
+		summary.MaxMinutes = math.Max(summary.MaxMinutes, minutes)
+	}
+
+	summaries := make([]TurnaroundSummary, 0, len(cars))
+	for _, carID := range cars {
+		summary := byCar[carID]
+		summary.AverageMinutes = math.Round(total[carID]/float64(summary.RoundTrips)*10) / 10
+		summaries = append(summaries, *summary)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].AverageMinutes < summaries[j].AverageMinutes
+	})
+	return summaries
+}
+
+// DwellOutliers returns the visits that lasted at least the given duration, longest first
+func DwellOutliers(visits []GeofenceVisit, minimum time.Duration) []GeofenceVisit {
+	outliers := []GeofenceVisit{}
+	for _, visit := range visits {
+		if visit.DwellMinutes >= minimum.Minutes() {
+			outliers = append(outliers, visit)
+		}
+	}
+	sort.SliceStable(outliers, func(i, j int) bool {
+		return outliers[i].DwellMinutes > outliers[j].DwellMinutes
+	})
+	return outliers
+}
+
+func roundMinutes(d time.Duration) float64 {
+	return math.Round(d.Minutes()*10) / 10
+}
+
+// visitLookback is how long before a range geofence events are loaded, so stays that
+// started before the range are still paired
+const visitLookback = 24 * time.Hour
+
+// LoadGeofenceVisits returns the visits that started between two times. The query may
+// narrow the events further, by car or company.
+func LoadGeofenceVisits(query *gorm.DB, from, to time.Time) ([]GeofenceVisit, error) {
+	var events []GeofenceEvent
+	if err := query.Where("recorded_at BETWEEN ? AND ?", from.Add(-visitLookback), to.Add(visitLookback)).
+		Order("recorded_at ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	visits := []GeofenceVisit{}
+	for _, visit := range GeofenceVisits(events) {
+		if !visit.EnteredAt.Before(from) && visit.EnteredAt.Before(to) {
+			visits = append(visits, visit)
+		}
+	}
+	return visits, nil
+}
@@ -0,0 +1,88 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGeofenceVisits(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse(PositionTimeLayout, "2025-03-01 "+clock)
+		return parsed
+	}
+	event := func(carID uint, entered bool, name, geofenceType, clock string) GeofenceEvent {
+		return GeofenceEvent{CarID: carID, Company: "TAQA", GeofenceName: name,
+			GeofenceType: geofenceType, Entered: entered, RecordedAt: at(clock)}
+	}
+
+	events := []GeofenceEvent{
+		event(2, false, "Badr Terminal", GeofenceTerminal, "06:00:00"), // Exit without an enter
+		event(1, true, "Badr Terminal", GeofenceTerminal, "06:00:00"),
+		event(2, true, "Site A", GeofenceDropOff, "09:00:00"),
+		event(1, false, "Badr Terminal", GeofenceTerminal, "07:30:00"),
+		event(1, true, "Site A", GeofenceDropOff, "10:00:00"),
+		event(2, false, "Site A", GeofenceDropOff, "14:15:00"),
+		event(1, true, "Site B", GeofenceDropOff, "12:00:00"), // Still inside
+	}
+
+	visits := GeofenceVisits(events)
+	if len(visits) != 2 {
+		t.Fatalf("got %d visits, wanted 2: %+v", len(visits), visits)
+	}
+	if visits[0].CarID != 1 || visits[0].DwellMinutes != 90 {
+		t.Errorf("got %+v, wanted car 1 at the terminal for 90 minutes", visits[0])
+	}
+	if visits[1].CarID != 2 || visits[1].DwellMinutes != 315 {
+		t.Errorf("got %+v, wanted car 2 at Site A for 315 minutes", visits[1])
+	}
+
+	outliers := DwellOutliers(visits, 4*time.Hour)
+	if len(outliers) != 1 || outliers[0].GeofenceName != "Site A" {
+		t.Errorf("got outliers %+v, wanted the Site A stay", outliers)
+	}
+}
+
+func TestSummarizeDwell(t *testing.T) {
+	visits := []GeofenceVisit{
+		{GeofenceName: "Badr Terminal", GeofenceType: GeofenceTerminal, DwellMinutes: 60},
+		{GeofenceName: "Badr Terminal", GeofenceType: GeofenceTerminal, DwellMinutes: 90},
+		{GeofenceName: "Site A", GeofenceType: GeofenceDropOff, DwellMinutes: 240},
+	}
+
+	summaries := SummarizeDwell(visits, func(v GeofenceVisit) string { return v.GeofenceName })
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, wanted 2", len(summaries))
+	}
+	if summaries[0].Key != "Site A" {
+		t.Errorf("got %q first, wanted the longest average first", summaries[0].Key)
+	}
+	badr := summaries[1]
+	if badr.Visits != 2 || badr.AverageMinutes != 75 || badr.MaxMinutes != 90 || badr.TotalMinutes != 150 {
+		t.Errorf("got %+v for Badr Terminal", badr)
+	}
+}
+
+func TestSummarizeTurnaround(t *testing.T) {
+	start, _ := time.Parse(PositionTimeLayout, "2025-03-01 06:00:00")
+	terminal := func(carID uint, after time.Duration) GeofenceVisit {
+		return GeofenceVisit{CarID: carID, GeofenceType: GeofenceTerminal, EnteredAt: start.Add(after)}
+	}
+
+	visits := []GeofenceVisit{
+		terminal(1, 0),
+		{CarID: 1, GeofenceType: GeofenceDropOff, EnteredAt: start.Add(4 * time.Hour)},
+		terminal(1, 10*time.Hour),
+		terminal(1, 24*time.Hour),
+		terminal(1, 200*time.Hour), // After a week off, not a round trip
+		terminal(2, 0),
+	}
+
+	summaries := SummarizeTurnaround(visits)
+	if len(summaries) != 1 {
+		t.Fatalf("got %d summaries, wanted only car 1: %+v", len(summaries), summaries)
+	}
+	got := summaries[0]
+	if got.RoundTrips != 2 || got.AverageMinutes != 720 || got.MinMinutes != 600 || got.MaxMinutes != 840 {
+		t.Errorf("got %+v", got)
+	}
+}
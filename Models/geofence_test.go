@@ -0,0 +1,118 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGeofenceContains(t *testing.T) {
+	// Square of roughly 1.1 km around a depot
+	square := Geofence{Shape: GeofencePolygon, Points: []GeofencePoint{
+		{30.120, 31.290}, {30.120, 31.300}, {30.130, 31.300}, {30.130, 31.290},
+	}}
+	circle := Geofence{Shape: GeofenceCircle, Latitude: 30.128955, Longitude: 31.298539, Radius: 0.4}
+
+	tests := []struct {
+		geofence Geofence
+		lat, lng float64
+		expected bool
+	}{
+		{square, 30.125, 31.295, true},
+		{square, 30.135, 31.295, false},
+		{square, 30.125, 31.285, false},
+		{circle, 30.1290, 31.2990, true},
+		{circle, 30.1400, 31.2990, false},
+	}
+
+	for _, test := range tests {
+		if got := test.geofence.Contains(test.lat, test.lng); got != test.expected {
+			t.Errorf("%s contains %f,%f: got %t, wanted %t", test.geofence.Shape, test.lat, test.lng, got, test.expected)
+		}
+	}
+}
+
+func TestGeofenceActiveAt(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return parsed
+	}
+
+	always := Geofence{Enabled: true}
+	daytime := Geofence{Enabled: true, ActiveFrom: "08:00", ActiveTo: "18:00"}
+	overnight := Geofence{Enabled: true, ActiveFrom: "22:00", ActiveTo: "06:00"}
+	disabled := Geofence{}
+
+	tests := []struct {
+		geofence Geofence
+		clock    string
+		expected bool
+	}{
+		{always, "03:00", true},
+		{daytime, "08:00", true},
+		{daytime, "18:00", false},
+		{overnight, "23:30", true},
+		{overnight, "05:59", true},
+		{overnight, "12:00", false},
+		{disabled, "12:00", false},
+	}
+
+	for _, test := range tests {
+		if got := test.geofence.ActiveAt(at(test.clock)); got != test.expected {
+			t.Errorf("%q-%q at %s: got %t, wanted %t", test.geofence.ActiveFrom, test.geofence.ActiveTo, test.clock, got, test.expected)
+		}
+	}
+}
+
+func TestMatchGeofence(t *testing.T) {
+	now := time.Now()
+	geofences := []Geofence{
+		{Name: "TAQA yard", Shape: GeofenceCircle, Latitude: 30, Longitude: 31, Radius: 1, Company: "TAQA", Enabled: true},
+		{Name: "Zone", Shape: GeofenceCircle, Latitude: 30, Longitude: 31, Radius: 5, Enabled: true},
+	}
+
+	if got := MatchGeofence(geofences, "taqa", 30, 31, now); got == nil || got.Name != "TAQA yard" {
+		t.Errorf("got %v, wanted the company fence", got)
+	}
+	if got := MatchGeofence(geofences, "Watanya", 30, 31, now); got == nil || got.Name != "Zone" {
+		t.Errorf("got %v, wanted the shared fence", got)
+	}
+	if got := MatchGeofence(geofences, "Watanya", 31, 31, now); got != nil {
+		t.Errorf("got %v outside every fence", got)
+	}
+}
+
+func TestGeofenceValidate(t *testing.T) {
+	valid := Geofence{Name: "Depot", Type: GeofenceGarage, Shape: GeofenceCircle, Latitude: 30, Longitude: 31, Radius: 0.5}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	invalid := []Geofence{
+		{Name: "Depot", Type: "parking", Shape: GeofenceCircle, Radius: 0.5},
+		{Name: "Depot", Type: GeofenceGarage, Shape: GeofenceCircle},
+		{Name: "Depot", Type: GeofenceGarage, Shape: GeofencePolygon, Points: []GeofencePoint{{30, 31}, {30, 32}}},
+		{Name: "Depot", Type: GeofenceGarage, Shape: GeofenceCircle, Radius: 0.5, ActiveFrom: "08:00"},
+		{Name: "Depot", Type: GeofenceGarage, Shape: GeofenceCircle, Radius: 0.5, ActiveFrom: "8am", ActiveTo: "18:00"},
+	}
+	for _, geofence := range invalid {
+		if err := geofence.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", geofence)
+		}
+	}
+}
+
+func TestGeofenceDisabledSaved(t *testing.T) {
+	db := openTestDB(t, &Geofence{})
+
+	geofence := Geofence{Name: "Depot", Shape: GeofenceCircle, Latitude: 30.12, Longitude: 31.29, Radius: 1}
+	if err := db.Create(&geofence).Error; err != nil {
+		t.Fatal(err)
+	}
+	var saved Geofence
+	if err := db.First(&saved, geofence.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Enabled {
+		t.Error("a geofence created disabled was saved enabled")
+	}
+}
@@ -0,0 +1,463 @@
+package Models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stock movement types
+const (
+	StockPurchase    = "purchase"     // Bought from a vendor into a location
+	StockIssue       = "issue"        // Used on a work order or tire position
+	StockAdjustment  = "adjustment"   // Count correction
+	StockTransferOut = "transfer_out" // Moved to another location
+	StockTransferIn  = "transfer_in"  // Moved from another location
+)
+
+// Part is a spare part kept in stock
+type Part struct {
+	gorm.Model
+	PartNumber      string  `json:"part_number" gorm:"uniqueIndex"`
+	Name            string  `json:"name"`
+	Category        string  `json:"category"`
+	Unit            string  `json:"unit"`             // "piece", "liter", "set", ...
+	ReorderLevel    float64 `json:"reorder_level"`    // Reorder when the stock falls to this, 0 to never
+	ReorderQuantity float64 `json:"reorder_quantity"` // Suggested quantity to order
+	DefaultVendorID *uint   `json:"default_vendor_id"`
+	Notes           string  `json:"notes"`
+}
+
+// Validate checks the part has a number and name and sane reorder levels
+func (p *Part) Validate() error {
+	p.PartNumber = strings.TrimSpace(p.PartNumber)
+	p.Name = strings.TrimSpace(p.Name)
+	if p.PartNumber == "" || p.Name == "" {
+		return errors.New("part_number and name are required")
+	}
+	if p.ReorderLevel < 0 || p.ReorderQuantity < 0 {
+		return errors.New("reorder levels must not be negative")
+	}
+	return nil
+}
+
+// StockLocation is a garage or store the parts are kept in
+type StockLocation struct {
+	gorm.Model
+	Name    string `json:"name" gorm:"uniqueIndex"`
+	Address string `json:"address"`
+	Notes   string `json:"notes"`
+}
+
+// StockMovement is stock coming into or going out of a location. Quantity and value are
+// positive coming in and negative going out.
+type StockMovement struct {
+	gorm.Model
+	PartID              uint      `json:"part_id" gorm:"index"`
+	LocationID          uint      `json:"location_id" gorm:"index"`
+	Type                string    `json:"type" gorm:"index"`
+	Date                time.Time `json:"date" gorm:"index"`
+	Quantity            float64   `json:"quantity"`
+	UnitCost            float64   `json:"unit_cost"`
+	Value               float64   `json:"value"`
+	VendorID            *uint     `json:"vendor_id" gorm:"index"`
+	VendorTransactionID *uint     `json:"vendor_transaction_id"`
+	WorkOrderID         *uint     `json:"work_order_id" gorm:"index"`
+	TirePositionID      *uint     `json:"tire_position_id"`
+	CarID               *uint     `json:"car_id" gorm:"index"` // Car the stock was used on
+	TransferID          *uint     `json:"transfer_id"`         // The other side of a transfer
+	Reference           string    `json:"reference"`           // Vendor invoice number, count sheet, ...
+	Notes               string    `json:"notes"`
+	CreatedBy           string    `json:"created_by"`
+
+	Part     *Part          `json:"part,omitempty" gorm:"foreignKey:PartID"`
+	Location *StockLocation `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+}
+
+// StockBalance is the stock of a part in a location
+type StockBalance struct {
+	PartID     uint    `json:"part_id"`
+	LocationID uint    `json:"location_id"`
+	Quantity   float64 `json:"quantity"`
+	Value      float64 `json:"value"`
+}
+
+// StockLevel is the stock of a part in a location, valued at the part's average cost
+type StockLevel struct {
+	PartID       uint    `json:"part_id"`
+	PartNumber   string  `json:"part_number"`
+	PartName     string  `json:"part_name"`
+	LocationID   uint    `json:"location_id"`
+	LocationName string  `json:"location_name"`
+	Quantity     float64 `json:"quantity"`
+	AverageCost  float64 `json:"average_cost"`
+	Value        float64 `json:"value"`
+}
+
+// ReorderLine is a part whose stock fell to its reorder level
+type ReorderLine struct {
+	PartID          uint    `json:"part_id"`
+	PartNumber      string  `json:"part_number"`
+	PartName        string  `json:"part_name"`
+	Quantity        float64 `json:"quantity"`
+	ReorderLevel    float64 `json:"reorder_level"`
+	ReorderQuantity float64 `json:"reorder_quantity"`
+	DefaultVendorID *uint   `json:"default_vendor_id"`
+}
+
+// StockValuation is the value of the stock on a date, per location and in total
+type StockValuation struct {
+	AsOf       string             `json:"as_of"`
+	Total      float64            `json:"total"`
+	ByLocation map[string]float64 `json:"by_location"`
+	Levels     []StockLevel       `json:"levels"`
+}
+
+func roundCost(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// StockBalances totals the movements per part and location
+func StockBalances(movements []StockMovement) []StockBalance {
+	type key struct{ part, location uint }
+	totals := make(map[key]*StockBalance)
+	var order []key
+	for _, movement := range movements {
+		k := key{movement.PartID, movement.LocationID}
+		if totals[k] == nil {
+			totals[k] = &StockBalance{PartID: movement.PartID, LocationID: movement.LocationID}
+			order = append(order, k)
+		}
+		totals[k].Quantity += movement.Quantity
+		totals[k].Value += movement.Value
+	}
+
+	balances := make([]StockBalance, 0, len(order))
+	for _, k := range order {
+		balance := *totals[k]
+		balance.Quantity = math.Round(balance.Quantity*1000) / 1000
+		balance.Value = roundCost(balance.Value)
+		balances = append(balances, balance)
+	}
+	return balances
+}
+
+// AverageCosts returns the moving average unit cost of each part over every location, the
+// cost stock leaving a location is valued at
+func AverageCosts(balances []StockBalance) map[uint]float64 {
+	quantities := make(map[uint]float64)
+	values := make(map[uint]float64)
+	for _, balance := range balances {
+		quantities[balance.PartID] += balance.Quantity
+		values[balance.PartID] += balance.Value
+	}
+
+	costs := make(map[uint]float64, len(quantities))
+	for partID, quantity := range quantities {
+		if quantity > 0 {
+			costs[partID] = values[partID] / quantity
+		}
+	}
+	return costs
+}
+
+// StockLevels names the balances and values them at the part's average cost, by part then
+// location. Empty balances are left out.
+func StockLevels(balances []StockBalance, parts map[uint]Part, locations map[uint]StockLocation) []StockLevel {
+	costs := AverageCosts(balances)
+	levels := make([]StockLevel, 0, len(balances))
+	for _, balance := range balances {
+		if balance.Quantity == 0 {
+			continue
+		}
+		levels = append(levels, StockLevel{
+			PartID:       balance.PartID,
+			PartNumber:   parts[balance.PartID].PartNumber,
+			PartName:     parts[balance.PartID].Name,
+			LocationID:   balance.LocationID,
+			LocationName: locations[balance.LocationID].Name,
+			Quantity:     balance.Quantity,
+			AverageCost:  roundCost(costs[balance.PartID]),
+			Value:        roundCost(balance.Quantity * costs[balance.PartID]),
+		})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].PartNumber != levels[j].PartNumber {
+			return levels[i].PartNumber < levels[j].PartNumber
+		}
+		return levels[i].LocationName < levels[j].LocationName
+	})
+	return levels
+}
+
+// ReorderList returns the parts with a reorder level whose stock over every location fell to it
+func ReorderList(parts []Part, balances []StockBalance) []ReorderLine {
+	quantities := make(map[uint]float64)
+	for _, balance := range balances {
+		quantities[balance.PartID] += balance.Quantity
+	}
+
+	lines := make([]ReorderLine, 0)
+	for _, part := range parts {
+		if part.ReorderLevel <= 0 || quantities[part.ID] > part.ReorderLevel {
+			continue
+		}
+		lines = append(lines, ReorderLine{
+			PartID:          part.ID,
+			PartNumber:      part.PartNumber,
+			PartName:        part.Name,
+			Quantity:        quantities[part.ID],
+			ReorderLevel:    part.ReorderLevel,
+			ReorderQuantity: part.ReorderQuantity,
+			DefaultVendorID: part.DefaultVendorID,
+		})
+	}
+	return lines
+}
+
+// ValueStock builds the valuation of the stock levels
+func ValueStock(levels []StockLevel, asOf time.Time) StockValuation {
+	valuation := StockValuation{AsOf: asOf.Format("2006-01-02"), ByLocation: map[string]float64{}, Levels: levels}
+	for _, level := range levels {
+		valuation.Total += level.Value
+		valuation.ByLocation[level.LocationName] += level.Value
+	}
+	valuation.Total = roundCost(valuation.Total)
+	for name, value := range valuation.ByLocation {
+		valuation.ByLocation[name] = roundCost(value)
+	}
+	return valuation
+}
+
+// LoadStockBalances totals the movements up to a time, every movement when before is zero
+func LoadStockBalances(db *gorm.DB, before time.Time) ([]StockBalance, error) {
+	query := db.Model(&StockMovement{})
+	if !before.IsZero() {
+		query = query.Where("date < ?", before)
+	}
+	var movements []StockMovement
+	if err := query.Select("part_id", "location_id", "quantity", "value").Find(&movements).Error; err != nil {
+		return nil, err
+	}
+	return StockBalances(movements), nil
+}
+
+// partStock returns the stock of a part in a location and the part's average cost
+func partStock(tx *gorm.DB, partID, locationID uint) (float64, float64, error) {
+	var movements []StockMovement
+	if err := tx.Select("part_id", "location_id", "quantity", "value").
+		Where("part_id = ?", partID).Find(&movements).Error; err != nil {
+		return 0, 0, err
+	}
+	balances := StockBalances(movements)
+
+	var available float64
+	for _, balance := range balances {
+		if balance.LocationID == locationID {
+			available = balance.Quantity
+		}
+	}
+	return available, AverageCosts(balances)[partID], nil
+}
+
+// PurchaseStock receives parts bought from a vendor into a location and records the purchase
+// on the vendor's ledger
+func PurchaseStock(db *gorm.DB, movement *StockMovement) error {
+	if movement.VendorID == nil || movement.Quantity <= 0 || movement.UnitCost < 0 {
+		return errors.New("a purchase needs a vendor, a positive quantity and a unit cost")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var part Part
+		if err := tx.First(&part, movement.PartID).Error; err != nil {
+			return fmt.Errorf("part %d not found", movement.PartID)
+		}
+		if err := tx.First(&StockLocation{}, movement.LocationID).Error; err != nil {
+			return fmt.Errorf("location %d not found", movement.LocationID)
+		}
+
+		movement.Type = StockPurchase
+		movement.Value = roundCost(movement.Quantity * movement.UnitCost)
+		description := fmt.Sprintf("Parts purchase: %g x %s (%s)", movement.Quantity, part.Name, part.PartNumber)
+		if movement.Reference != "" {
+			description += " - " + movement.Reference
+		}
+		transaction := VendorTransaction{
+			VendorID:    *movement.VendorID,
+			Date:        movement.Date,
+			Description: description,
+			Amount:      movement.Value,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+		movement.VendorTransactionID = &transaction.ID
+		return tx.Create(movement).Error
+	})
+}
+
+// IssueStock takes parts out of a location for a work order or a tire position, valued at
+// the part's average cost. Parts issued to a work order are added to its parts.
+func IssueStock(db *gorm.DB, movement *StockMovement) error {
+	if movement.Quantity <= 0 {
+		return errors.New("the quantity to issue must be positive")
+	}
+	if (movement.WorkOrderID == nil) == (movement.TirePositionID == nil) {
+		return errors.New("stock is issued to either a work order or a tire position")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var part Part
+		if err := tx.First(&part, movement.PartID).Error; err != nil {
+			return fmt.Errorf("part %d not found", movement.PartID)
+		}
+		available, averageCost, err := partStock(tx, movement.PartID, movement.LocationID)
+		if err != nil {
+			return err
+		}
+		if available < movement.Quantity {
+			return fmt.Errorf("only %g of %s in stock at this location", available, part.PartNumber)
+		}
+
+		var order WorkOrder
+		if movement.WorkOrderID != nil {
+			if err := tx.First(&order, *movement.WorkOrderID).Error; err != nil {
+				return fmt.Errorf("work order %d not found", *movement.WorkOrderID)
+			}
+			movement.CarID = &order.CarID
+		} else {
+			var position TirePosition
+			if err := tx.Preload("Truck").First(&position, *movement.TirePositionID).Error; err != nil {
+				return fmt.Errorf("tire position %d not found", *movement.TirePositionID)
+			}
+			if position.Truck != nil {
+				carID, err := TruckCarID(tx, *position.Truck)
+				if err != nil {
+					return err
+				}
+				movement.CarID = carID
+			}
+		}
+
+		quantity := movement.Quantity
+		movement.Type = StockIssue
+		movement.UnitCost = roundCost(averageCost)
+		movement.Quantity = -quantity
+		movement.Value = -roundCost(quantity * averageCost)
+		if err := tx.Create(movement).Error; err != nil {
+			return err
+		}
+
+		if movement.WorkOrderID == nil {
+			return nil
+		}
+		issued := WorkOrderPart{
+			WorkOrderID:     order.ID,
+			Name:            part.Name,
+			PartNumber:      part.PartNumber,
+			Quantity:        quantity,
+			UnitCost:        movement.UnitCost,
+			Cost:            -movement.Value,
+			StockMovementID: &movement.ID,
+		}
+		if err := tx.Create(&issued).Error; err != nil {
+			return err
+		}
+		return tx.Model(&order).Update("parts_cost", gorm.Expr("parts_cost + ?", issued.Cost)).Error
+	})
+}
+
+// AdjustStock corrects the stock of a part in a location after a count. Stock found is
+// valued at the given unit cost, or the average cost when none is given, and stock lost at
+// the average cost.
+func AdjustStock(db *gorm.DB, movement *StockMovement) error {
+	if movement.Quantity == 0 {
+		return errors.New("the adjustment quantity must not be zero")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&Part{}, movement.PartID).Error; err != nil {
+			return fmt.Errorf("part %d not found", movement.PartID)
+		}
+		if err := tx.First(&StockLocation{}, movement.LocationID).Error; err != nil {
+			return fmt.Errorf("location %d not found", movement.LocationID)
+		}
+		available, averageCost, err := partStock(tx, movement.PartID, movement.LocationID)
+		if err != nil {
+			return err
+		}
+		if available+movement.Quantity < 0 {
+			return fmt.Errorf("only %g in stock at this location", available)
+		}
+
+		if movement.Quantity < 0 || movement.UnitCost <= 0 {
+			movement.UnitCost = roundCost(averageCost)
+		}
+		movement.Type = StockAdjustment
+		movement.Value = roundCost(movement.Quantity * movement.UnitCost)
+		return tx.Create(movement).Error
+	})
+}
+
+// TransferStock moves parts between locations at the part's average cost, returning the
+// movement out and the movement in
+func TransferStock(db *gorm.DB, out *StockMovement, toLocationID uint) (*StockMovement, error) {
+	if out.Quantity <= 0 {
+		return nil, errors.New("the quantity to transfer must be positive")
+	}
+	if out.LocationID == toLocationID {
+		return nil, errors.New("stock is transferred to another location")
+	}
+
+	in := *out
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&StockLocation{}, toLocationID).Error; err != nil {
+			return fmt.Errorf("location %d not found", toLocationID)
+		}
+		available, averageCost, err := partStock(tx, out.PartID, out.LocationID)
+		if err != nil {
+			return err
+		}
+		if available < out.Quantity {
+			return fmt.Errorf("only %g in stock at this location", available)
+		}
+
+		quantity, value := out.Quantity, roundCost(out.Quantity*averageCost)
+		out.Type, out.UnitCost, out.Quantity, out.Value = StockTransferOut, roundCost(averageCost), -quantity, -value
+		if err := tx.Create(out).Error; err != nil {
+			return err
+		}
+
+		in.Type, in.LocationID, in.UnitCost, in.Quantity, in.Value = StockTransferIn, toLocationID, out.UnitCost, quantity, value
+		in.TransferID = &out.ID
+		if err := tx.Create(&in).Error; err != nil {
+			return err
+		}
+		out.TransferID = &in.ID
+		return tx.Model(out).Update("transfer_id", in.ID).Error
+	})
+	return &in, err
+}
+
+// StockIssueCostEntry returns the ledger entry of stock used on a car outside a work order.
+// Stock issued to a work order is charged by the order.
+func StockIssueCostEntry(movement StockMovement, car Car) VehicleCostEntry {
+	description := "Stock issue"
+	if movement.Part != nil {
+		description = fmt.Sprintf("%g x %s", -movement.Quantity, movement.Part.Name)
+	}
+	return VehicleCostEntry{
+		CarID:       car.ID,
+		CarNoPlate:  car.CarNoPlate,
+		Source:      CostSourceStockIssue,
+		SourceID:    movement.ID,
+		Date:        movement.Date,
+		Amount:      -movement.Value,
+		Description: description,
+	}
+}
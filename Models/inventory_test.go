@@ -0,0 +1,110 @@
+package Models
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestStockBalances(t *testing.T) {
+	movements := []StockMovement{
+		{PartID: 1, LocationID: 1, Type: StockPurchase, Quantity: 10, Value: 1000},
+		{PartID: 1, LocationID: 1, Type: StockPurchase, Quantity: 10, Value: 1200},
+		{PartID: 1, LocationID: 1, Type: StockIssue, Quantity: -4, Value: -440},
+		{PartID: 1, LocationID: 1, Type: StockTransferOut, Quantity: -6, Value: -660},
+		{PartID: 1, LocationID: 2, Type: StockTransferIn, Quantity: 6, Value: 660},
+		{PartID: 2, LocationID: 1, Type: StockPurchase, Quantity: 3, Value: 90},
+	}
+
+	balances := StockBalances(movements)
+	want := []StockBalance{
+		{PartID: 1, LocationID: 1, Quantity: 10, Value: 1100},
+		{PartID: 1, LocationID: 2, Quantity: 6, Value: 660},
+		{PartID: 2, LocationID: 1, Quantity: 3, Value: 90},
+	}
+	if len(balances) != len(want) {
+		t.Fatalf("got %d balances, want %d", len(balances), len(want))
+	}
+	for i := range want {
+		if balances[i] != want[i] {
+			t.Errorf("balance %d = %+v, want %+v", i, balances[i], want[i])
+		}
+	}
+
+	costs := AverageCosts(balances)
+	if costs[1] != 110 || costs[2] != 30 {
+		t.Errorf("average costs = %v, want 110 and 30", costs)
+	}
+}
+
+func TestStockLevels(t *testing.T) {
+	balances := []StockBalance{
+		{PartID: 2, LocationID: 1, Quantity: 3, Value: 90},
+		{PartID: 1, LocationID: 2, Quantity: 6, Value: 600},
+		{PartID: 1, LocationID: 1, Quantity: 4, Value: 500},
+		{PartID: 3, LocationID: 1, Quantity: 0, Value: 0},
+	}
+	parts := map[uint]Part{
+		1: {PartNumber: "BP-100", Name: "Brake pads"},
+		2: {PartNumber: "AF-200", Name: "Air filter"},
+		3: {PartNumber: "OF-300", Name: "Oil filter"},
+	}
+	locations := map[uint]StockLocation{1: {Name: "Main garage"}, 2: {Name: "Alex garage"}}
+
+	levels := StockLevels(balances, parts, locations)
+	if len(levels) != 3 {
+		t.Fatalf("got %d levels, want 3 without the empty one", len(levels))
+	}
+	if levels[0].PartNumber != "AF-200" || levels[1].LocationName != "Alex garage" || levels[2].LocationName != "Main garage" {
+		t.Errorf("levels out of order: %+v", levels)
+	}
+	// Both locations are valued at the brake pads' average cost of 110
+	if levels[1].AverageCost != 110 || levels[1].Value != 660 || levels[2].Value != 440 {
+		t.Errorf("brake pad levels = %+v and %+v", levels[1], levels[2])
+	}
+
+	valuation := ValueStock(levels, time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC))
+	if valuation.AsOf != "2025-06-30" || valuation.Total != 1190 ||
+		valuation.ByLocation["Main garage"] != 530 || valuation.ByLocation["Alex garage"] != 660 {
+		t.Errorf("valuation = %+v", valuation)
+	}
+}
+
+func TestReorderList(t *testing.T) {
+	parts := []Part{
+		{Model: gorm.Model{ID: 1}, PartNumber: "BP-100", ReorderLevel: 10, ReorderQuantity: 20},
+		{Model: gorm.Model{ID: 2}, PartNumber: "AF-200", ReorderLevel: 5},
+		{Model: gorm.Model{ID: 3}, PartNumber: "OF-300"},
+		{Model: gorm.Model{ID: 4}, PartNumber: "FB-400", ReorderLevel: 2},
+	}
+	balances := []StockBalance{
+		{PartID: 1, LocationID: 1, Quantity: 6},
+		{PartID: 1, LocationID: 2, Quantity: 4},
+		{PartID: 2, LocationID: 1, Quantity: 8},
+	}
+
+	lines := ReorderList(parts, balances)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if lines[0].PartNumber != "BP-100" || lines[0].Quantity != 10 || lines[0].ReorderQuantity != 20 {
+		t.Errorf("first line = %+v, want BP-100 at its level of 10", lines[0])
+	}
+	if lines[1].PartNumber != "FB-400" || lines[1].Quantity != 0 {
+		t.Errorf("second line = %+v, want FB-400 out of stock", lines[1])
+	}
+}
+
+func TestPartValidate(t *testing.T) {
+	part := Part{PartNumber: " BP-100 ", Name: " Brake pads "}
+	if err := part.Validate(); err != nil || part.PartNumber != "BP-100" || part.Name != "Brake pads" {
+		t.Errorf("Validate() = %v, part = %+v", err, part)
+	}
+	if err := (&Part{Name: "Brake pads"}).Validate(); err == nil {
+		t.Error("expected an error without a part number")
+	}
+	if err := (&Part{PartNumber: "BP-100", Name: "Brake pads", ReorderLevel: -1}).Validate(); err == nil {
+		t.Error("expected an error for a negative reorder level")
+	}
+}
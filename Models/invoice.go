@@ -0,0 +1,239 @@
+package Models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Invoice statuses
+const (
+	InvoiceDraft     = "draft"
+	InvoiceIssued    = "issued"
+	InvoicePaid      = "paid"
+	InvoiceCancelled = "cancelled"
+)
+
+// Invoice types
+const (
+	InvoiceTypeInvoice    = "invoice"
+	InvoiceTypeCreditNote = "credit_note"
+)
+
+// Invoice line types
+const (
+	InvoiceLineTrips     = "trips"      // Trip revenue of one statistics group
+	InvoiceLineCarRental = "car_rental" // Rent owed for one car over a month, less what earlier invoices billed
+	InvoiceLineCredit    = "credit"     // Reversal of a trip or a car's rent in a credit note
+)
+
+// Invoice freezes the priced trips of a company over a period. Credit notes are
+// invoices of type credit_note pointing at the invoice they correct.
+type Invoice struct {
+	gorm.Model
+	Number            string        `json:"number" gorm:"uniqueIndex"`
+	Type              string        `json:"type" gorm:"index"`
+	Status            string        `json:"status" gorm:"index"`
+	Company           string        `json:"company" gorm:"index"`
+	PeriodStart       string        `json:"period_start"`
+	PeriodEnd         string        `json:"period_end"`
+	IssueDate         string        `json:"issue_date"`
+	PaidDate          string        `json:"paid_date"`
+	OriginalInvoiceID *uint         `json:"original_invoice_id" gorm:"index"` // Invoice corrected by a credit note
+	Subtotal          float64       `json:"subtotal"`
+	VAT               float64       `json:"vat"`
+	Total             float64       `json:"total"`
+	Notes             string        `json:"notes" gorm:"type:text"`
+	Lines             []InvoiceLine `json:"lines" gorm:"foreignKey:InvoiceID;constraint:OnDelete:CASCADE"`
+}
+
+// InvoiceLine is one billed amount of an invoice
+type InvoiceLine struct {
+	gorm.Model
+	InvoiceID    uint          `json:"invoice_id" gorm:"index"`
+	LineType     string        `json:"line_type"`
+	Description  string        `json:"description"`
+	Terminal     string        `json:"terminal"`
+	DropOffPoint string        `json:"drop_off_point"`
+	CarNoPlate   string        `json:"car_no_plate"`
+	Trips        int64         `json:"trips"`
+	Volume       float64       `json:"volume"`
+	Distance     float64       `json:"distance"`
+	Rate         float64       `json:"rate"`
+	RentMonth    string        `json:"rent_month"` // "2006-01" month of a car's rent and its reversal
+	Amount       float64       `json:"amount"`
+	VAT          float64       `json:"vat"`
+	InvoiceTrips []InvoiceTrip `json:"invoice_trips,omitempty" gorm:"foreignKey:InvoiceLineID;constraint:OnDelete:CASCADE"`
+}
+
+// InvoiceTrip records a trip frozen into an invoice line with its share of the line
+type InvoiceTrip struct {
+	gorm.Model
+	InvoiceID     uint    `json:"invoice_id" gorm:"index"`
+	InvoiceLineID uint    `json:"invoice_line_id" gorm:"index"`
+	TripID        uint    `json:"trip_id" gorm:"index"`
+	UnitTripID    uint    `json:"unit_trip_id"` // First trip of the priced unit, shared by the trips billed together
+	Date          string  `json:"date"`
+	CarNoPlate    string  `json:"car_no_plate"`
+	Terminal      string  `json:"terminal"`
+	DropOffPoint  string  `json:"drop_off_point"`
+	Volume        float64 `json:"volume"`
+	Distance      float64 `json:"distance"`
+	Amount        float64 `json:"amount"`
+	VAT           float64 `json:"vat"`
+	CreditNoteID  *uint   `json:"credit_note_id" gorm:"index"` // Set once the trip is reversed by a credit note
+}
+
+// InvoiceSequence holds the last number given out for an invoice number prefix
+type InvoiceSequence struct {
+	Prefix string `gorm:"primaryKey;size:16"` // e.g. "INV-2025-"
+	Last   int
+}
+
+// NextInvoiceNumber returns the next sequential number for the invoice type in a year,
+// e.g. INV-2025-0001 or CN-2025-0001. It must run in the transaction storing the invoice:
+// incrementing the sequence row locks it until that transaction ends, so concurrent
+// invoices wait for it and a rolled back invoice gives its number back.
+func NextInvoiceNumber(tx *gorm.DB, invoiceType string, year int) (string, error) {
+	prefix := "INV"
+	if invoiceType == InvoiceTypeCreditNote {
+		prefix = "CN"
+	}
+	prefix = fmt.Sprintf("%s-%d-", prefix, year)
+
+	var sequence InvoiceSequence
+	var count int64
+	if err := tx.Model(&InvoiceSequence{}).Where("prefix = ?", prefix).Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count == 0 {
+		// Sequences start after the numbers given out before they existed
+		var numbers []string
+		if err := tx.Unscoped().Model(&Invoice{}).Where("number LIKE ?", prefix+"%").Pluck("number", &numbers).Error; err != nil {
+			return "", err
+		}
+		sequence = InvoiceSequence{Prefix: prefix, Last: lastInvoiceNumber(prefix, numbers)}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&sequence).Error; err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Model(&InvoiceSequence{}).Where("prefix = ?", prefix).
+		Update("last", gorm.Expr("last + 1")).Error; err != nil {
+		return "", err
+	}
+	if err := tx.First(&sequence, "prefix = ?", prefix).Error; err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%04d", prefix, sequence.Last), nil
+}
+
+// lastInvoiceNumber returns the highest sequential part among invoice numbers with a prefix
+func lastInvoiceNumber(prefix string, numbers []string) int {
+	last := 0
+	for _, number := range numbers {
+		if n, err := strconv.Atoi(strings.TrimPrefix(number, prefix)); err == nil && n > last {
+			last = n
+		}
+	}
+	return last
+}
+
+// LockingInvoice returns the invoice that currently bills any of the given trips,
+// or nil when they are free to edit. Trips reversed by a credit note and trips of
+// cancelled invoices are released.
+func LockingInvoice(db *gorm.DB, tripIDs ...uint) (*Invoice, error) {
+	if len(tripIDs) == 0 {
+		return nil, nil
+	}
+
+	var invoice Invoice
+	err := db.Model(&Invoice{}).
+		Joins("JOIN invoice_trips ON invoice_trips.invoice_id = invoices.id AND invoice_trips.deleted_at IS NULL").
+		Where("invoice_trips.trip_id IN ? AND invoice_trips.credit_note_id IS NULL", tripIDs).
+		Where("invoices.type = ? AND invoices.status <> ?", InvoiceTypeInvoice, InvoiceCancelled).
+		First(&invoice).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// CarRentMonth identifies the month of a car's rent
+type CarRentMonth struct {
+	CarNoPlate string
+	Month      string // "2006-01"
+}
+
+// CreditedInvoiceTrips returns the uncredited trips of an invoice that crediting tripIDs
+// reverses. A trip is credited with the trips billed in the same priced unit, as they are
+// priced together, and with the car's other trips of a month whose rent the invoice bills,
+// as the rent is reversed and billed again with them.
+func CreditedInvoiceTrips(trips []InvoiceTrip, tripIDs []uint, rented map[CarRentMonth]bool) []InvoiceTrip {
+	credited := make(map[uint]bool, len(tripIDs))
+	for _, id := range tripIDs {
+		credited[id] = true
+	}
+
+	for changed := true; changed; {
+		changed = false
+		units := make(map[uint]bool)
+		months := make(map[CarRentMonth]bool)
+		for _, trip := range trips {
+			if credited[trip.TripID] {
+				if trip.UnitTripID != 0 {
+					units[trip.UnitTripID] = true
+				}
+				if month := (CarRentMonth{trip.CarNoPlate, TripMonth(trip.Date)}); rented[month] {
+					months[month] = true
+				}
+			}
+		}
+		for _, trip := range trips {
+			if credited[trip.TripID] {
+				continue
+			}
+			if (trip.UnitTripID != 0 && units[trip.UnitTripID]) || months[CarRentMonth{trip.CarNoPlate, TripMonth(trip.Date)}] {
+				credited[trip.TripID] = true
+				changed = true
+			}
+		}
+	}
+
+	var result []InvoiceTrip
+	for _, trip := range trips {
+		if credited[trip.TripID] && trip.CreditNoteID == nil {
+			result = append(result, trip)
+		}
+	}
+	return result
+}
+
+// tripMonth returns the "2006-01" month of a date
+func TripMonth(date string) string {
+	if len(date) >= 7 {
+		return date[:7]
+	}
+	return date
+}
+
+// Totalize recomputes the invoice totals from its lines
+func (i *Invoice) Totalize() {
+	i.Subtotal = 0
+	i.VAT = 0
+	for _, line := range i.Lines {
+		i.Subtotal += line.Amount
+		i.VAT += line.VAT
+	}
+	i.Subtotal = math.Round(i.Subtotal*100) / 100
+	i.VAT = math.Round(i.VAT*100) / 100
+	i.Total = i.Subtotal + i.VAT
+}
@@ -0,0 +1,104 @@
+package Models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestInvoiceTotalize(t *testing.T) {
+	invoice := Invoice{
+		Lines: []InvoiceLine{
+			{LineType: InvoiceLineTrips, Amount: 12210, VAT: 1709.4},
+			{LineType: InvoiceLineCarRental, Amount: 7175, VAT: 1004.5},
+			{LineType: InvoiceLineCredit, Amount: -4070, VAT: -569.8},
+		},
+	}
+
+	invoice.Totalize()
+
+	if invoice.Subtotal != 15315 || invoice.VAT != 2144.1 || invoice.Total != 17459.1 {
+		t.Errorf("got subtotal %f, VAT %f, total %f", invoice.Subtotal, invoice.VAT, invoice.Total)
+	}
+}
+
+func TestNextInvoiceNumber(t *testing.T) {
+	db := openTestDB(t, &Invoice{}, &InvoiceSequence{})
+
+	// Numbers given out before the sequence existed, one of them since deleted
+	for _, number := range []string{"INV-2025-0001", "INV-2025-0002", "INV-2025-0004"} {
+		db.Create(&Invoice{Number: number, Type: InvoiceTypeInvoice})
+	}
+	db.Where("number = ?", "INV-2025-0002").Delete(&Invoice{})
+
+	next := func(invoiceType string) string {
+		var number string
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			number, err = NextInvoiceNumber(tx, invoiceType, 2025)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return number
+	}
+
+	if number := next(InvoiceTypeInvoice); number != "INV-2025-0005" {
+		t.Errorf("first number = %s, want INV-2025-0005", number)
+	}
+	if number := next(InvoiceTypeCreditNote); number != "CN-2025-0001" {
+		t.Errorf("credit note number = %s, want CN-2025-0001", number)
+	}
+
+	// A rolled back invoice gives its number back
+	db.Transaction(func(tx *gorm.DB) error {
+		NextInvoiceNumber(tx, InvoiceTypeInvoice, 2025)
+		return errors.New("rollback")
+	})
+	if number := next(InvoiceTypeInvoice); number != "INV-2025-0006" {
+		t.Errorf("number after a rollback = %s, want INV-2025-0006", number)
+	}
+}
+
+func TestCreditedInvoiceTrips(t *testing.T) {
+	creditNote := uint(9)
+	trips := []InvoiceTrip{
+		// One capacity group of three trips, and a trip priced alone, for the first car
+		{TripID: 1, UnitTripID: 1, CarNoPlate: "ق ن ر 5921", Date: "2025-03-02"},
+		{TripID: 2, UnitTripID: 1, CarNoPlate: "ق ن ر 5921", Date: "2025-03-02"},
+		{TripID: 3, UnitTripID: 1, CarNoPlate: "ق ن ر 5921", Date: "2025-03-03"},
+		{TripID: 4, UnitTripID: 4, CarNoPlate: "ق ن ر 5921", Date: "2025-03-05"},
+		// A rented car with trips in two months and one already credited
+		{TripID: 5, UnitTripID: 5, CarNoPlate: "ط ه م 7310", Date: "2025-03-30"},
+		{TripID: 6, UnitTripID: 6, CarNoPlate: "ط ه م 7310", Date: "2025-03-31"},
+		{TripID: 7, UnitTripID: 7, CarNoPlate: "ط ه م 7310", Date: "2025-04-01"},
+		{TripID: 8, UnitTripID: 8, CarNoPlate: "ط ه م 7310", Date: "2025-03-29", CreditNoteID: &creditNote},
+	}
+	rented := map[CarRentMonth]bool{
+		{CarNoPlate: "ط ه م 7310", Month: "2025-03"}: true,
+		{CarNoPlate: "ط ه م 7310", Month: "2025-04"}: true,
+	}
+
+	tests := []struct {
+		name    string
+		tripIDs []uint
+		want    []uint
+	}{
+		{"part of a capacity group", []uint{2}, []uint{1, 2, 3}},
+		{"trip priced alone", []uint{4}, []uint{4}},
+		{"rented car month", []uint{6}, []uint{5, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint
+			for _, trip := range CreditedInvoiceTrips(trips, tt.tripIDs, rented) {
+				got = append(got, trip.TripID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("credited trips = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package Models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Preventive maintenance tasks
+const (
+	MaintenanceOil             = "oil"
+	MaintenanceFilters         = "filters"
+	MaintenanceBrakes          = "brakes"
+	MaintenanceTankCalibration = "tank_calibration"
+	MaintenanceTireRotation    = "tire_rotation"
+)
+
+// MaintenanceTasks lists the tasks in the order they are reported
+var MaintenanceTasks = []string{
+	MaintenanceOil, MaintenanceFilters, MaintenanceBrakes, MaintenanceTankCalibration, MaintenanceTireRotation,
+}
+
+// Maintenance statuses
+const (
+	MaintenanceOK      = "ok"
+	MaintenanceDueSoon = "due_soon"
+	MaintenanceOverdue = "overdue"
+	MaintenanceUnknown = "unknown" // Never recorded, so the due point is not known
+)
+
+// Odometer sources
+const (
+	OdometerSourceFuel = "fuel" // Car.LastFuelOdometer
+	OdometerSourceGPS  = "gps"  // Last fuel odometer plus the GPS mileage since
+)
+
+// MaintenancePlan is how often a task is due for a vehicle type, by distance, time or both
+type MaintenancePlan struct {
+	gorm.Model
+	CarType      string `json:"car_type" gorm:"uniqueIndex:idx_maintenance_plan"` // Empty for the types without a plan of their own
+	Task         string `json:"task" gorm:"uniqueIndex:idx_maintenance_plan"`
+	IntervalKm   int    `json:"interval_km"`   // 0 when not due by distance
+	IntervalDays int    `json:"interval_days"` // 0 when not due by time
+	WarnKm       int    `json:"warn_km"`       // Due soon within this distance
+	WarnDays     int    `json:"warn_days"`     // Due soon within this many days
+}
+
+// Validate checks the plan is for a known task and has an interval
+func (p *MaintenancePlan) Validate() error {
+	if !isMaintenanceTask(p.Task) {
+		return fmt.Errorf("unknown task %q", p.Task)
+	}
+	if p.IntervalKm <= 0 && p.IntervalDays <= 0 {
+		return errors.New("interval_km or interval_days is required")
+	}
+	if p.IntervalKm < 0 || p.IntervalDays < 0 || p.WarnKm < 0 || p.WarnDays < 0 {
+		return errors.New("intervals must not be negative")
+	}
+	return nil
+}
+
+// MaintenanceRecord is a task done on a car. Oil changes are also read from OilChange.
+type MaintenanceRecord struct {
+	gorm.Model
+	CarID      uint    `json:"car_id" gorm:"index"`
+	CarNoPlate string  `json:"car_no_plate"`
+	Task       string  `json:"task" gorm:"index"`
+	Date       string  `json:"date"`
+	OdometerKm int     `json:"odometer_km"`
+	Cost       float64 `json:"cost"`
+	Notes      string  `json:"notes"`
+	RecordedBy string  `json:"recorded_by"`
+}
+
+// Validate checks the record is for a known task and has a date
+func (r *MaintenanceRecord) Validate() error {
+	if r.CarID == 0 {
+		return errors.New("car_id is required")
+	}
+	if !isMaintenanceTask(r.Task) {
+		return fmt.Errorf("unknown task %q", r.Task)
+	}
+	if _, err := time.Parse("2006-01-02", r.Date); err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", r.Date)
+	}
+	if r.OdometerKm < 0 {
+		return errors.New("odometer_km must not be negative")
+	}
+	return nil
+}
+
+// VehicleOdometer is the latest odometer estimate of a car, the last fuel odometer plus the
+// GPS mileage driven since
+type VehicleOdometer struct {
+	gorm.Model
+	CarID      uint      `json:"car_id" gorm:"uniqueIndex"`
+	OdometerKm int       `json:"odometer_km"`
+	BaseKm     int       `json:"base_km"` // Odometer of the fill the mileage is counted from
+	BaseAt     time.Time `json:"base_at"` // Time of that fill
+	ReadAt     time.Time `json:"read_at"` // When the mileage was read
+}
+
+// MaintenanceNotice records that a car was reported for a task in a status, so each due
+// point is only reported once
+type MaintenanceNotice struct {
+	gorm.Model
+	CarID  uint   `gorm:"uniqueIndex:idx_maintenance_notice"`
+	Task   string `gorm:"uniqueIndex:idx_maintenance_notice"`
+	Status string `gorm:"uniqueIndex:idx_maintenance_notice"`
+	Cycle  string `gorm:"uniqueIndex:idx_maintenance_notice"`
+}
+
+// MaintenanceDone is the last time a task was done on a car
+type MaintenanceDone struct {
+	Date       time.Time
+	OdometerKm int
+	IntervalKm int       // Overrides the plan's, as oil changes record the oil's mileage
+	DueDate    time.Time // Overrides the date computed from the plan when set
+}
+
+// MaintenanceDue is where a car stands on a task
+type MaintenanceDue struct {
+	CarID          uint    `json:"car_id"`
+	CarNoPlate     string  `json:"car_no_plate"`
+	CarType        string  `json:"car_type"`
+	Task           string  `json:"task"`
+	Status         string  `json:"status"`
+	LastDate       *string `json:"last_date"`
+	LastOdometerKm *int    `json:"last_odometer_km"`
+	OdometerKm     int     `json:"odometer_km"`
+	OdometerSource string  `json:"odometer_source"`
+	DueKm          *int    `json:"due_km"`
+	RemainingKm    *int    `json:"remaining_km"`
+	DueDate        *string `json:"due_date"`
+	RemainingDays  *int    `json:"remaining_days"`
+	Cycle          string  `json:"-"` // Identifies the due point for notices
+}
+
+func isMaintenanceTask(task string) bool {
+	for _, known := range MaintenanceTasks {
+		if task == known {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenancePlanFor picks the plan of a task for a vehicle type, falling back to the plan
+// for every type
+func MaintenancePlanFor(plans []MaintenancePlan, carType, task string) *MaintenancePlan {
+	var fallback *MaintenancePlan
+	for i := range plans {
+		if plans[i].Task != task {
+			continue
+		}
+		if plans[i].CarType == carType {
+			return &plans[i]
+		}
+		if plans[i].CarType == "" {
+			fallback = &plans[i]
+		}
+	}
+	return fallback
+}
+
+// EvaluateMaintenance works out the remaining distance and days of a task and its status.
+// The task is overdue when either runs out and due soon when either is within the warning.
+func EvaluateMaintenance(plan MaintenancePlan, done *MaintenanceDone, odometerKm int, today time.Time) MaintenanceDue {
+	due := MaintenanceDue{Task: plan.Task, OdometerKm: odometerKm, Status: MaintenanceUnknown, Cycle: "never"}
+	if done == nil {
+		return due
+	}
+
+	if !done.Date.IsZero() {
+		lastDate := done.Date.Format("2006-01-02")
+		due.LastDate = &lastDate
+	}
+	if done.OdometerKm > 0 {
+		lastOdometer := done.OdometerKm
+		due.LastOdometerKm = &lastOdometer
+	}
+	due.Cycle = fmt.Sprintf("%s@%d", done.Date.Format("2006-01-02"), done.OdometerKm)
+
+	due.Status = MaintenanceOK
+	warn := false
+
+	intervalKm := plan.IntervalKm
+	if done.IntervalKm > 0 {
+		intervalKm = done.IntervalKm
+	}
+	if intervalKm > 0 && done.OdometerKm > 0 && odometerKm > 0 {
+		dueKm := done.OdometerKm + intervalKm
+		remainingKm := dueKm - odometerKm
+		due.DueKm, due.RemainingKm = &dueKm, &remainingKm
+		if remainingKm < 0 {
+			due.Status = MaintenanceOverdue
+		} else if remainingKm <= plan.WarnKm {
+			warn = true
+		}
+	}
+
+	dueDate := done.DueDate
+	if dueDate.IsZero() && plan.IntervalDays > 0 && !done.Date.IsZero() {
+		dueDate = done.Date.AddDate(0, 0, plan.IntervalDays)
+	}
+	if !dueDate.IsZero() {
+		day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+		remainingDays := int(dueDate.Sub(day).Hours() / 24)
+		dueDateText := dueDate.Format("2006-01-02")
+		due.DueDate, due.RemainingDays = &dueDateText, &remainingDays
+		if remainingDays < 0 {
+			due.Status = MaintenanceOverdue
+		} else if remainingDays <= plan.WarnDays {
+			warn = true
+		}
+	}
+
+	if warn && due.Status == MaintenanceOK {
+		due.Status = MaintenanceDueSoon
+	}
+	return due
+}
+
+// CarOdometer returns the latest odometer of a car: the GPS estimate when it is ahead of the
+// last fuel odometer, the fuel odometer otherwise
+func CarOdometer(car Car, estimate *VehicleOdometer) (int, string) {
+	if estimate != nil && estimate.OdometerKm > car.LastFuelOdometer {
+		return estimate.OdometerKm, OdometerSourceGPS
+	}
+	return car.LastFuelOdometer, OdometerSourceFuel
+}
+
+// SaveVehicleOdometer stores the odometer estimate of a car
+func SaveVehicleOdometer(db *gorm.DB, estimate VehicleOdometer) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "car_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"odometer_km", "base_km", "base_at", "read_at", "updated_at"}),
+	}).Create(&estimate).Error
+}
+
+// lastMaintenance returns when a task was last done on a car, nil if never
+func lastMaintenance(db *gorm.DB, car Car, task string) (*MaintenanceDone, error) {
+	var done *MaintenanceDone
+
+	var record MaintenanceRecord
+	if err := db.Where("car_id = ? AND task = ?", car.ID, task).
+		Order("date DESC, id DESC").Limit(1).Find(&record).Error; err != nil {
+		return nil, err
+	}
+	if record.ID != 0 {
+		date, _ := time.Parse("2006-01-02", record.Date)
+		done = &MaintenanceDone{Date: date, OdometerKm: record.OdometerKm}
+	}
+
+	switch task {
+	case MaintenanceOil:
+		var oil OilChange
+		if err := db.Where("car_id = ?", car.ID).Order("id DESC").Limit(1).Find(&oil).Error; err != nil {
+			return nil, err
+		}
+		if oil.ID != 0 && (done == nil || int(oil.OdometerAtChange) > done.OdometerKm) {
+			done = &MaintenanceDone{
+				Date:       ParseCostDate(oil.Date, oil.CreatedAt),
+				OdometerKm: int(oil.OdometerAtChange),
+				IntervalKm: int(oil.Mileage),
+			}
+		}
+
+	case MaintenanceTireRotation:
+		var rotation TireEvent
+		if err := db.Where("car_id = ? AND type = ?", car.ID, TireRotate).
+			Order("date DESC, id DESC").Limit(1).Find(&rotation).Error; err != nil {
+			return nil, err
+		}
+		if rotation.ID != 0 && (done == nil || rotation.OdometerKm > done.OdometerKm) {
+			done = &MaintenanceDone{Date: rotation.Date, OdometerKm: rotation.OdometerKm}
+		}
+
+	case MaintenanceTankCalibration:
+		// The calibration certificate states when it runs out
+		if expiry, err := time.Parse("2006-01-02", car.CalibrationExpirationDate); err == nil {
+			if done == nil {
+				done = &MaintenanceDone{}
+			}
+			done.DueDate = expiry
+		}
+	}
+	return done, nil
+}
+
+// MaintenanceStatuses returns where every car, or one car when carID is set, stands on each
+// task it has a plan for
+func MaintenanceStatuses(db *gorm.DB, carID uint, today time.Time) ([]MaintenanceDue, error) {
+	var plans []MaintenancePlan
+	if err := db.Find(&plans).Error; err != nil {
+		return nil, err
+	}
+
+	query := db.Model(&Car{})
+	if carID != 0 {
+		query = query.Where("id = ?", carID)
+	}
+	var cars []Car
+	if err := query.Order("car_no_plate ASC").Find(&cars).Error; err != nil {
+		return nil, err
+	}
+
+	var estimates []VehicleOdometer
+	if err := db.Find(&estimates).Error; err != nil {
+		return nil, err
+	}
+	estimateByCar := make(map[uint]*VehicleOdometer, len(estimates))
+	for i := range estimates {
+		estimateByCar[estimates[i].CarID] = &estimates[i]
+	}
+
+	var dues []MaintenanceDue
+	for _, car := range cars {
+		odometer, source := CarOdometer(car, estimateByCar[car.ID])
+		for _, task := range MaintenanceTasks {
+			plan := MaintenancePlanFor(plans, car.CarType, task)
+			if plan == nil {
+				continue
+			}
+			done, err := lastMaintenance(db, car, task)
+			if err != nil {
+				return nil, err
+			}
+
+			due := EvaluateMaintenance(*plan, done, odometer, today)
+			due.CarID, due.CarNoPlate, due.CarType, due.OdometerSource = car.ID, car.CarNoPlate, car.CarType, source
+			dues = append(dues, due)
+		}
+	}
+	return dues, nil
+}
+
+// PendingMaintenanceNotices returns the due and overdue tasks not reported yet
+func PendingMaintenanceNotices(db *gorm.DB, dues []MaintenanceDue) ([]MaintenanceDue, error) {
+	var pending []MaintenanceDue
+	for _, due := range dues {
+		if due.Status != MaintenanceDueSoon && due.Status != MaintenanceOverdue {
+			continue
+		}
+		var count int64
+		if err := db.Model(&MaintenanceNotice{}).
+			Where("car_id = ? AND task = ? AND status = ? AND cycle = ?", due.CarID, due.Task, due.Status, due.Cycle).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			pending = append(pending, due)
+		}
+	}
+	return pending, nil
+}
+
+// MarkMaintenanceNotified records the tasks as reported
+func MarkMaintenanceNotified(db *gorm.DB, dues []MaintenanceDue) error {
+	for _, due := range dues {
+		notice := MaintenanceNotice{CarID: due.CarID, Task: due.Task, Status: due.Status, Cycle: due.Cycle}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&notice).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultMaintenancePlans apply to every vehicle type until a type gets plans of its own
+var defaultMaintenancePlans = []MaintenancePlan{
+	{Task: MaintenanceOil, IntervalKm: 10000, IntervalDays: 180, WarnKm: 1000, WarnDays: 14},
+	{Task: MaintenanceFilters, IntervalKm: 20000, IntervalDays: 180, WarnKm: 1000, WarnDays: 14},
+	{Task: MaintenanceBrakes, IntervalKm: 40000, IntervalDays: 365, WarnKm: 2000, WarnDays: 30},
+	{Task: MaintenanceTankCalibration, IntervalDays: 365, WarnDays: 30},
+	{Task: MaintenanceTireRotation, IntervalKm: 15000, WarnKm: 1000},
+}
+
+// SeedMaintenancePlans creates the default plans when no plan exists
+func SeedMaintenancePlans(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&MaintenancePlan{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	plans := make([]MaintenancePlan, len(defaultMaintenancePlans))
+	copy(plans, defaultMaintenancePlans)
+	return db.Create(&plans).Error
+}
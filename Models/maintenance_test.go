@@ -0,0 +1,135 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenancePlanFor(t *testing.T) {
+	plans := []MaintenancePlan{
+		{CarType: "", Task: MaintenanceOil, IntervalKm: 10000},
+		{CarType: "Trailer", Task: MaintenanceOil, IntervalKm: 8000},
+		{CarType: "", Task: MaintenanceBrakes, IntervalKm: 40000},
+	}
+
+	if plan := MaintenancePlanFor(plans, "Trailer", MaintenanceOil); plan == nil || plan.IntervalKm != 8000 {
+		t.Errorf("Trailer oil plan = %+v, want the Trailer plan", plan)
+	}
+	if plan := MaintenancePlanFor(plans, "No Trailer", MaintenanceOil); plan == nil || plan.IntervalKm != 10000 {
+		t.Errorf("No Trailer oil plan = %+v, want the default plan", plan)
+	}
+	if plan := MaintenancePlanFor(plans, "Trailer", MaintenanceBrakes); plan == nil || plan.IntervalKm != 40000 {
+		t.Errorf("Trailer brakes plan = %+v, want the default plan", plan)
+	}
+	if plan := MaintenancePlanFor(plans, "Trailer", MaintenanceTireRotation); plan != nil {
+		t.Errorf("Trailer tire rotation plan = %+v, want none", plan)
+	}
+}
+
+func TestEvaluateMaintenance(t *testing.T) {
+	today := time.Date(2025, 6, 1, 14, 0, 0, 0, time.UTC)
+	plan := MaintenancePlan{Task: MaintenanceFilters, IntervalKm: 20000, IntervalDays: 180, WarnKm: 1000, WarnDays: 14}
+	done := func(date string, odometer int) *MaintenanceDone {
+		day, _ := time.Parse("2006-01-02", date)
+		return &MaintenanceDone{Date: day, OdometerKm: odometer}
+	}
+
+	tests := []struct {
+		name          string
+		done          *MaintenanceDone
+		odometer      int
+		status        string
+		remainingKm   *int
+		remainingDays *int
+	}{
+		{"never done", nil, 150000, MaintenanceUnknown, nil, nil},
+		{"within both intervals", done("2025-04-01", 140000), 150000, MaintenanceOK, intPtr(10000), intPtr(119)},
+		{"within the km warning", done("2025-04-01", 140000), 159500, MaintenanceDueSoon, intPtr(500), intPtr(119)},
+		{"past the km interval", done("2025-04-01", 140000), 160200, MaintenanceOverdue, intPtr(-200), intPtr(119)},
+		{"within the day warning", done("2024-12-10", 140000), 145000, MaintenanceDueSoon, intPtr(15000), intPtr(7)},
+		{"past the day interval", done("2024-11-01", 140000), 145000, MaintenanceOverdue, intPtr(15000), intPtr(-32)},
+		{"no odometer at the last service", done("2025-04-01", 0), 150000, MaintenanceOK, nil, intPtr(119)},
+	}
+
+	for _, tt := range tests {
+		due := EvaluateMaintenance(plan, tt.done, tt.odometer, today)
+		if due.Status != tt.status {
+			t.Errorf("%s: status = %s, want %s", tt.name, due.Status, tt.status)
+		}
+		if !equalIntPtr(due.RemainingKm, tt.remainingKm) {
+			t.Errorf("%s: remaining km = %v, want %v", tt.name, derefInt(due.RemainingKm), derefInt(tt.remainingKm))
+		}
+		if !equalIntPtr(due.RemainingDays, tt.remainingDays) {
+			t.Errorf("%s: remaining days = %v, want %v", tt.name, derefInt(due.RemainingDays), derefInt(tt.remainingDays))
+		}
+	}
+}
+
+func TestEvaluateMaintenanceOverrides(t *testing.T) {
+	today := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// The oil's own mileage replaces the plan interval
+	oil := MaintenancePlan{Task: MaintenanceOil, IntervalKm: 10000, WarnKm: 1000}
+	due := EvaluateMaintenance(oil, &MaintenanceDone{Date: today, OdometerKm: 100000, IntervalKm: 5000}, 104500, today)
+	if due.Status != MaintenanceDueSoon || *due.DueKm != 105000 {
+		t.Errorf("oil due = %s at %d km, want due_soon at 105000", due.Status, *due.DueKm)
+	}
+
+	// The calibration certificate's expiry replaces the computed date
+	calibration := MaintenancePlan{Task: MaintenanceTankCalibration, IntervalDays: 365, WarnDays: 30}
+	expiry := time.Date(2025, 5, 20, 0, 0, 0, 0, time.UTC)
+	due = EvaluateMaintenance(calibration, &MaintenanceDone{DueDate: expiry}, 0, today)
+	if due.Status != MaintenanceOverdue || *due.DueDate != "2025-05-20" || due.LastDate != nil {
+		t.Errorf("calibration due = %s on %s, want overdue on 2025-05-20", due.Status, *due.DueDate)
+	}
+}
+
+func TestCarOdometer(t *testing.T) {
+	car := Car{LastFuelOdometer: 120000}
+
+	if km, source := CarOdometer(car, nil); km != 120000 || source != OdometerSourceFuel {
+		t.Errorf("without estimate = %d from %s, want 120000 from fuel", km, source)
+	}
+	if km, source := CarOdometer(car, &VehicleOdometer{OdometerKm: 120450}); km != 120450 || source != OdometerSourceGPS {
+		t.Errorf("with estimate = %d from %s, want 120450 from gps", km, source)
+	}
+	// A fill after the last GPS read is more recent than the estimate
+	if km, source := CarOdometer(car, &VehicleOdometer{OdometerKm: 119000}); km != 120000 || source != OdometerSourceFuel {
+		t.Errorf("with stale estimate = %d from %s, want 120000 from fuel", km, source)
+	}
+}
+
+func TestMaintenanceRecordValidate(t *testing.T) {
+	valid := MaintenanceRecord{CarID: 1, Task: MaintenanceBrakes, Date: "2025-06-01", OdometerKm: 150000}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid record: %v", err)
+	}
+
+	invalid := []MaintenanceRecord{
+		{Task: MaintenanceBrakes, Date: "2025-06-01"},
+		{CarID: 1, Task: "wash", Date: "2025-06-01"},
+		{CarID: 1, Task: MaintenanceBrakes, Date: "01/06/2025"},
+		{CarID: 1, Task: MaintenanceBrakes, Date: "2025-06-01", OdometerKm: -1},
+	}
+	for _, record := range invalid {
+		if err := record.Validate(); err == nil {
+			t.Errorf("record %+v: expected an error", record)
+		}
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func derefInt(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -0,0 +1,190 @@
+package Models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Pricing rule types understood by the contract evaluator
+const (
+	RulePerKm               = "per_km"                 // Rate per km of fee-mapping distance
+	RulePerVolumeTier       = "per_volume_tier"        // Rate per VolumeUnit liters, selected by the mapping's fee tier
+	RulePerVolumeMappingFee = "per_volume_mapping_fee" // Mapping fee per VolumeUnit liters (Rate is the fallback fee)
+	RulePerVolume           = "per_volume"             // Flat Rate per VolumeUnit liters
+	RulePerCarDay           = "per_car_day"            // Rate per car per working day
+	RulePerCarMonth         = "per_car_month"          // Flat Rate per car, prorated below MinDays
+	RuleVAT                 = "vat"                    // Rate applied on revenue plus rentals (e.g. 0.14)
+)
+
+// Distance bases for per_km rules
+const (
+	DistanceBasisTrip          = "trip"           // Every trip is billed its own distance
+	DistanceBasisCapacityGroup = "capacity_group" // Consecutive trips filling one tank are billed the longest distance once
+)
+
+// Statistics groupings used for contract details and route breakdowns
+const (
+	GroupByDropOffPoint = "drop_off_point"
+	GroupByTerminal     = "terminal"
+	GroupByFeeTier      = "fee_tier"
+	GroupByRoute        = "route"
+)
+
+// PricingContract holds the tariff agreed with a customer company. A company
+// can have several versions, each in force between ValidFrom and ValidTo.
+type PricingContract struct {
+	gorm.Model
+	Company        string        `json:"company" gorm:"index"`
+	Description    string        `json:"description"`
+	ValidFrom      string        `json:"valid_from" gorm:"index"` // First day in force, empty since the beginning
+	ValidTo        string        `json:"valid_to"`                // Last day in force, empty while open-ended
+	DetailGrouping string        `json:"detail_grouping"`         // How company statistics details are grouped
+	RouteGrouping  string        `json:"route_grouping"`          // How route statistics are grouped
+	Rules          []PricingRule `json:"rules" gorm:"foreignKey:ContractID;constraint:OnDelete:CASCADE"`
+}
+
+// PricingRule is a single pricing component of a contract
+type PricingRule struct {
+	gorm.Model
+	ContractID     uint    `json:"contract_id" gorm:"index"`
+	RuleType       string  `json:"rule_type"`
+	Terminal       string  `json:"terminal"`       // Optional scope, empty matches every terminal
+	DropOffPoint   string  `json:"drop_off_point"` // Optional scope, empty matches every drop-off point
+	Tier           int     `json:"tier"`           // Fee tier for per_volume_tier rules
+	Rate           float64 `json:"rate"`
+	VolumeUnit     float64 `json:"volume_unit"`    // Liters per rate unit for volume rules, defaults to 1000
+	DistanceBasis  string  `json:"distance_basis"` // "trip" or "capacity_group" for per_km rules
+	MinDays        int     `json:"min_days"`       // Working days for a full per_car_month rent
+	DailyDeduction float64 `json:"daily_deduction"`
+}
+
+// VATRate returns the VAT rate of the contract, zero if it has no VAT rule
+func (c *PricingContract) VATRate() float64 {
+	for _, rule := range c.Rules {
+		if rule.RuleType == RuleVAT {
+			return rule.Rate
+		}
+	}
+	return 0
+}
+
+// RentalRule returns the car rental rule of the contract if any
+func (c *PricingContract) RentalRule() *PricingRule {
+	for i := range c.Rules {
+		if c.Rules[i].RuleType == RulePerCarDay || c.Rules[i].RuleType == RulePerCarMonth {
+			return &c.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the rule scope covers the given route
+func (r *PricingRule) Matches(terminal, dropOffPoint string) bool {
+	if r.Terminal != "" && r.Terminal != terminal {
+		return false
+	}
+	if r.DropOffPoint != "" && r.DropOffPoint != dropOffPoint {
+		return false
+	}
+	return true
+}
+
+// CarRent returns the rent owed for one car that worked the given number of days
+func (r *PricingRule) CarRent(workingDays int64) float64 {
+	switch r.RuleType {
+	case RulePerCarDay:
+		return float64(workingDays) * r.Rate
+	case RulePerCarMonth:
+		if r.MinDays <= 0 || workingDays >= int64(r.MinDays) {
+			return r.Rate
+		}
+		rent := r.Rate - float64(int64(r.MinDays)-workingDays)*r.DailyDeduction
+		if rent < 0 {
+			rent = 0
+		}
+		return rent
+	}
+	return 0
+}
+
+// DefaultPricingContract is used for companies that have no contract configured. Every liter
+// is billed the average fee of the company's fee mappings, or 50 without any.
+func DefaultPricingContract(company string, averageFee float64) PricingContract {
+	if averageFee == 0 {
+		averageFee = 50
+	}
+	return PricingContract{
+		Company:       company,
+		RouteGrouping: GroupByRoute,
+		Rules: []PricingRule{
+			{RuleType: RulePerVolume, Rate: averageFee, VolumeUnit: 1},
+		},
+	}
+}
+
+// SeedPricingContracts creates the contracts that used to be hardcoded in the trip statistics.
+// Companies that ever had a contract, even a deleted one, are left untouched.
+func SeedPricingContracts(db *gorm.DB) error {
+	contracts := []PricingContract{
+		{
+			Company:        "Petrol Arrows",
+			Description:    "Mapping fee per 1000 liters",
+			DetailGrouping: GroupByDropOffPoint,
+			RouteGrouping:  GroupByRoute,
+			Rules: []PricingRule{
+				{RuleType: RulePerVolumeMappingFee, VolumeUnit: 1000},
+			},
+		},
+		{
+			Company:        "TAQA",
+			Description:    "Per km from Alex and Suez plus monthly car rent",
+			DetailGrouping: GroupByTerminal,
+			RouteGrouping:  GroupByTerminal,
+			Rules: []PricingRule{
+				{RuleType: RulePerKm, Terminal: "Alex", Rate: 40.7, DistanceBasis: DistanceBasisTrip},
+				{RuleType: RulePerKm, Terminal: "Suez", Rate: 40.7, DistanceBasis: DistanceBasisTrip},
+				{RuleType: RulePerCarMonth, Rate: 43000, MinDays: 28, DailyDeduction: 1433},
+				{RuleType: RuleVAT, Rate: 0.14},
+			},
+		},
+		{
+			Company:        "Petromin",
+			Description:    "Per km on the longest drop-off of each tank load plus daily car rent",
+			DetailGrouping: GroupByTerminal,
+			RouteGrouping:  GroupByTerminal,
+			Rules: []PricingRule{
+				{RuleType: RulePerKm, Rate: 42.5, DistanceBasis: DistanceBasisCapacityGroup},
+				{RuleType: RulePerCarDay, Rate: 2000},
+				{RuleType: RuleVAT, Rate: 0.14},
+			},
+		},
+		{
+			Company:        "Watanya",
+			Description:    "Fee tier rate per 1000 liters",
+			DetailGrouping: GroupByFeeTier,
+			RouteGrouping:  GroupByFeeTier,
+			Rules: []PricingRule{
+				{RuleType: RulePerVolumeTier, Tier: 1, Rate: 82.5, VolumeUnit: 1000},
+				{RuleType: RulePerVolumeTier, Tier: 2, Rate: 104.5, VolumeUnit: 1000},
+				{RuleType: RulePerVolumeTier, Tier: 3, Rate: 126.5, VolumeUnit: 1000},
+				{RuleType: RulePerVolumeTier, Tier: 4, Rate: 148.5, VolumeUnit: 1000},
+				{RuleType: RulePerVolumeTier, Tier: 5, Rate: 170.5, VolumeUnit: 1000},
+				{RuleType: RuleVAT, Rate: 0.14},
+			},
+		},
+	}
+
+	for _, contract := range contracts {
+		var count int64
+		if err := db.Unscoped().Model(&PricingContract{}).Where("company = ?", contract.Company).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := db.Create(&contract).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package Models
+
+import "testing"
+
+type carRentTest struct {
+	rule        PricingRule
+	workingDays int64
+	expected    float64
+}
+
+var carRentTests = []carRentTest{
+	{PricingRule{RuleType: RulePerCarMonth, Rate: 43000, MinDays: 28, DailyDeduction: 1433}, 28, 43000},
+	{PricingRule{RuleType: RulePerCarMonth, Rate: 43000, MinDays: 28, DailyDeduction: 1433}, 31, 43000},
+	{PricingRule{RuleType: RulePerCarMonth, Rate: 43000, MinDays: 28, DailyDeduction: 1433}, 20, 31536},
+	{PricingRule{RuleType: RulePerCarMonth, Rate: 43000, MinDays: 28, DailyDeduction: 1433}, 0, 2876},
+	{PricingRule{RuleType: RulePerCarMonth, Rate: 1000, MinDays: 28, DailyDeduction: 100}, 1, 0},
+	{PricingRule{RuleType: RulePerCarDay, Rate: 2000}, 3, 6000},
+	{PricingRule{RuleType: RuleVAT, Rate: 0.14}, 3, 0},
+}
+
+func TestCarRent(t *testing.T) {
+	for _, test := range carRentTests {
+		got := test.rule.CarRent(test.workingDays)
+		if got != test.expected {
+			t.Errorf("%s with %d days: got %f, wanted %f", test.rule.RuleType, test.workingDays, got, test.expected)
+		}
+	}
+}
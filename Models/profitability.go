@@ -0,0 +1,202 @@
+package Models
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TripCostBasis is what the operating costs of a car are allocated to a trip by
+type TripCostBasis struct {
+	TripID   uint
+	CarID    uint
+	DriverID uint
+	Date     string
+	Distance float64
+}
+
+// CarCostPool is the fuel and maintenance cost of a car over a period, allocated to its trips
+// by distance
+type CarCostPool struct {
+	Fuel        float64 `json:"fuel"`
+	Maintenance float64 `json:"maintenance"`
+}
+
+// TripCost is the share of the operating costs of a trip
+type TripCost struct {
+	Fuel        float64 `json:"fuel"`
+	Salary      float64 `json:"salary"`
+	Maintenance float64 `json:"maintenance"`
+	Rent        float64 `json:"rent"`
+}
+
+// Total returns the operating cost of the trip
+func (c TripCost) Total() float64 {
+	return c.Fuel + c.Salary + c.Maintenance + c.Rent
+}
+
+// ProfitLine is the revenue, operating cost and margin of a trip or a group of trips
+type ProfitLine struct {
+	Key         string   `json:"key"`
+	Trips       int      `json:"trips"`
+	Distance    float64  `json:"distance"`
+	Revenue     float64  `json:"revenue"`
+	Fuel        float64  `json:"fuel"`
+	Salary      float64  `json:"salary"`
+	Maintenance float64  `json:"maintenance"`
+	Rent        float64  `json:"rent"`
+	Cost        float64  `json:"cost"`
+	Margin      float64  `json:"margin"`
+	MarginPct   *float64 `json:"margin_pct"` // Margin over revenue, nil without revenue
+}
+
+// Add adds a trip to the line
+func (l *ProfitLine) Add(distance, revenue float64, cost TripCost) {
+	l.Trips++
+	l.Distance += distance
+	l.Revenue += revenue
+	l.Fuel += cost.Fuel
+	l.Salary += cost.Salary
+	l.Maintenance += cost.Maintenance
+	l.Rent += cost.Rent
+	l.Cost += cost.Total()
+	l.Margin = l.Revenue - l.Cost
+	l.MarginPct = nil
+	if l.Revenue != 0 {
+		pct := math.Round(l.Margin/l.Revenue*10000) / 100
+		l.MarginPct = &pct
+	}
+}
+
+// SortProfitLines orders lines by margin, lowest first so the loss makers lead
+func SortProfitLines(lines []ProfitLine) {
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Margin != lines[j].Margin {
+			return lines[i].Margin < lines[j].Margin
+		}
+		return lines[i].Key < lines[j].Key
+	})
+}
+
+// daysInMonth returns the number of days in the month of a date
+func daysInMonth(day time.Time) int {
+	return time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// SalaryDailyCost returns what a driver costs on a day: the driver cost of each salary
+// period covering the day, spread over the days of the period. Periods not closed yet are
+// left out as their cost is not known.
+func SalaryDailyCost(salaries []Salary, date string) float64 {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0
+	}
+
+	var cost float64
+	for _, salary := range salaries {
+		start, startErr := time.Parse("2006-01-02", salary.StartDate)
+		end, endErr := time.Parse("2006-01-02", salary.CloseDate)
+		if startErr != nil || endErr != nil || end.Before(start) || day.Before(start) || day.After(end) {
+			continue
+		}
+		days := int(end.Sub(start).Hours()/24) + 1
+		cost += salary.DriverCost / float64(days)
+	}
+	return cost
+}
+
+// RentDailyCost returns the rent of a car on a day, its monthly rent spread over the days of
+// the month
+func RentDailyCost(monthlyRent float64, date string) float64 {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil || monthlyRent == 0 {
+		return 0
+	}
+	return monthlyRent / float64(daysInMonth(day))
+}
+
+// AllocateTripCosts shares the operating costs of the cars among their trips. Fuel and
+// maintenance follow the distance of each trip, evenly when the car's trips have none. The
+// driver salary and car rent of a day are split evenly among the trips of that day.
+func AllocateTripCosts(trips []TripCostBasis, pools map[uint]CarCostPool, salaries map[uint][]Salary, rents map[uint]float64) map[uint]TripCost {
+	carDistance := make(map[uint]float64)
+	carTrips := make(map[uint]int)
+	driverDayTrips := make(map[uint]map[string]int)
+	carDayTrips := make(map[uint]map[string]int)
+
+	for _, trip := range trips {
+		carDistance[trip.CarID] += trip.Distance
+		carTrips[trip.CarID]++
+		if driverDayTrips[trip.DriverID] == nil {
+			driverDayTrips[trip.DriverID] = make(map[string]int)
+		}
+		driverDayTrips[trip.DriverID][trip.Date]++
+		if carDayTrips[trip.CarID] == nil {
+			carDayTrips[trip.CarID] = make(map[string]int)
+		}
+		carDayTrips[trip.CarID][trip.Date]++
+	}
+
+	costs := make(map[uint]TripCost, len(trips))
+	for _, trip := range trips {
+		share := 1 / float64(carTrips[trip.CarID])
+		if carDistance[trip.CarID] > 0 {
+			share = trip.Distance / carDistance[trip.CarID]
+		}
+
+		pool := pools[trip.CarID]
+		cost := TripCost{
+			Fuel:        pool.Fuel * share,
+			Maintenance: pool.Maintenance * share,
+			Rent:        RentDailyCost(rents[trip.CarID], trip.Date) / float64(carDayTrips[trip.CarID][trip.Date]),
+		}
+		if trip.DriverID != 0 {
+			cost.Salary = SalaryDailyCost(salaries[trip.DriverID], trip.Date) / float64(driverDayTrips[trip.DriverID][trip.Date])
+		}
+		costs[trip.TripID] = cost
+	}
+	return costs
+}
+
+// UnallocatedCosts returns the operating costs between two times that no trip carries: the
+// fuel and maintenance of cars without trips, and the salary and rent of the days a driver or
+// car made no trip
+func UnallocatedCosts(trips []TripCostBasis, pools map[uint]CarCostPool, salaries map[uint][]Salary, rents map[uint]float64, from, to time.Time) TripCost {
+	carTrips := make(map[uint]bool)
+	carDays := make(map[uint]map[string]bool)
+	driverDays := make(map[uint]map[string]bool)
+	for _, trip := range trips {
+		carTrips[trip.CarID] = true
+		if carDays[trip.CarID] == nil {
+			carDays[trip.CarID] = make(map[string]bool)
+		}
+		carDays[trip.CarID][trip.Date] = true
+		if driverDays[trip.DriverID] == nil {
+			driverDays[trip.DriverID] = make(map[string]bool)
+		}
+		driverDays[trip.DriverID][trip.Date] = true
+	}
+
+	var cost TripCost
+	for carID, pool := range pools {
+		if !carTrips[carID] {
+			cost.Fuel += pool.Fuel
+			cost.Maintenance += pool.Maintenance
+		}
+	}
+
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		for carID, rent := range rents {
+			if !carDays[carID][date] {
+				cost.Rent += RentDailyCost(rent, date)
+			}
+		}
+		for driverID, driverSalaries := range salaries {
+			if !driverDays[driverID][date] {
+				cost.Salary += SalaryDailyCost(driverSalaries, date)
+			}
+		}
+	}
+	return cost
+}
@@ -0,0 +1,105 @@
+package Models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSalaryDailyCost(t *testing.T) {
+	salaries := []Salary{
+		{DriverCost: 3000, StartDate: "2025-03-01", CloseDate: "2025-03-30"},
+		{DriverCost: 500, StartDate: "2025-03-10", CloseDate: "2025-03-14"},
+		{DriverCost: 9999, StartDate: "2025-03-01"}, // Not closed yet
+	}
+
+	tests := []struct {
+		date string
+		want float64
+	}{
+		{"2025-03-01", 100},
+		{"2025-03-12", 200},
+		{"2025-03-30", 100},
+		{"2025-03-31", 0},
+		{"bad", 0},
+	}
+
+	for _, tt := range tests {
+		if got := SalaryDailyCost(salaries, tt.date); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("SalaryDailyCost(%q) = %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestRentDailyCost(t *testing.T) {
+	if got := RentDailyCost(2800, "2025-02-10"); got != 100 {
+		t.Errorf("February rent = %v, want 100", got)
+	}
+	if got := RentDailyCost(3100, "2025-03-10"); got != 100 {
+		t.Errorf("March rent = %v, want 100", got)
+	}
+	if got := RentDailyCost(0, "2025-03-10"); got != 0 {
+		t.Errorf("owned car rent = %v, want 0", got)
+	}
+}
+
+func TestAllocateTripCosts(t *testing.T) {
+	trips := []TripCostBasis{
+		{TripID: 1, CarID: 1, DriverID: 7, Date: "2025-02-10", Distance: 100},
+		{TripID: 2, CarID: 1, DriverID: 7, Date: "2025-02-10", Distance: 300},
+		{TripID: 3, CarID: 2, DriverID: 0, Date: "2025-02-11"},
+		{TripID: 4, CarID: 2, DriverID: 0, Date: "2025-02-12"},
+	}
+	pools := map[uint]CarCostPool{1: {Fuel: 800, Maintenance: 400}, 2: {Fuel: 100}}
+	salaries := map[uint][]Salary{7: {{DriverCost: 2800, StartDate: "2025-02-01", CloseDate: "2025-02-28"}}}
+	rents := map[uint]float64{1: 5600}
+
+	costs := AllocateTripCosts(trips, pools, salaries, rents)
+
+	want := map[uint]TripCost{
+		1: {Fuel: 200, Maintenance: 100, Salary: 50, Rent: 100},
+		2: {Fuel: 600, Maintenance: 300, Salary: 50, Rent: 100},
+		3: {Fuel: 50},
+		4: {Fuel: 50},
+	}
+	for tripID, cost := range want {
+		if costs[tripID] != cost {
+			t.Errorf("trip %d cost = %+v, want %+v", tripID, costs[tripID], cost)
+		}
+	}
+}
+
+func TestProfitLineAdd(t *testing.T) {
+	var line ProfitLine
+	line.Add(100, 1000, TripCost{Fuel: 300, Salary: 100})
+	line.Add(50, 0, TripCost{Rent: 200})
+
+	if line.Trips != 2 || line.Cost != 600 || line.Margin != 400 || line.MarginPct == nil || *line.MarginPct != 40 {
+		t.Errorf("line = %+v", line)
+	}
+
+	var loss ProfitLine
+	loss.Add(10, 0, TripCost{Fuel: 5})
+	if loss.MarginPct != nil || loss.Margin != -5 {
+		t.Errorf("loss = %+v", loss)
+	}
+}
+
+func TestUnallocatedCosts(t *testing.T) {
+	trips := []TripCostBasis{
+		{TripID: 1, CarID: 1, DriverID: 7, Date: "2025-02-10"},
+		{TripID: 2, CarID: 1, DriverID: 7, Date: "2025-02-11"},
+	}
+	pools := map[uint]CarCostPool{1: {Fuel: 800}, 2: {Fuel: 100, Maintenance: 50}}
+	salaries := map[uint][]Salary{7: {{DriverCost: 2800, StartDate: "2025-02-01", CloseDate: "2025-02-28"}}}
+	rents := map[uint]float64{1: 2800, 2: 5600}
+
+	from := time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)
+	cost := UnallocatedCosts(trips, pools, salaries, rents, from, from.AddDate(0, 0, 4))
+
+	// Car 2 never worked, car 1 and its driver were idle on the 12th and 13th
+	want := TripCost{Fuel: 100, Maintenance: 50, Salary: 200, Rent: 200 + 800}
+	if cost != want {
+		t.Errorf("UnallocatedCosts() = %+v, want %+v", cost, want)
+	}
+}
@@ -0,0 +1,227 @@
+package Models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FeeMappingRate is one effective-dated version of a fee mapping's fee and distance.
+// Dates are "2006-01-02" strings like TripStruct.Date; an empty ValidFrom means
+// "since the beginning" and an empty ValidTo means the version is still open.
+type FeeMappingRate struct {
+	gorm.Model
+	FeeMappingID uint    `json:"fee_mapping_id" gorm:"index"`
+	Fee          float64 `json:"fee"`
+	Distance     float64 `json:"distance"`
+	ValidFrom    string  `json:"valid_from" gorm:"index"`
+	ValidTo      string  `json:"valid_to"`
+}
+
+// InForce reports whether the rate applies on the given date
+func (r *FeeMappingRate) InForce(date string) bool {
+	return dateInRange(date, r.ValidFrom, r.ValidTo)
+}
+
+// InForce reports whether the contract applies on the given date
+func (c *PricingContract) InForce(date string) bool {
+	return dateInRange(date, c.ValidFrom, c.ValidTo)
+}
+
+func dateInRange(date, validFrom, validTo string) bool {
+	if validFrom != "" && date < validFrom {
+		return false
+	}
+	if validTo != "" && date > validTo {
+		return false
+	}
+	return true
+}
+
+// DayBefore returns the date preceding a "2006-01-02" date
+func DayBefore(date string) (string, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", err
+	}
+	return day.AddDate(0, 0, -1).Format("2006-01-02"), nil
+}
+
+// ScheduleFeeMappingRate makes fee and distance effective from validFrom until the
+// next scheduled version. The version in force the day before is closed, a version
+// starting on the same day is replaced, and the mapping's current fee and distance
+// are refreshed.
+func ScheduleFeeMappingRate(db *gorm.DB, mapping *FeeMapping, fee, distance float64, validFrom string) (*FeeMappingRate, error) {
+	dayBefore, err := DayBefore(validFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := FeeMappingRate{
+		FeeMappingID: mapping.ID,
+		Fee:          fee,
+		Distance:     distance,
+		ValidFrom:    validFrom,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&FeeMappingRate{}).Where("fee_mapping_id = ?", mapping.ID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		// Mappings created before rate history keep their values as the opening version
+		if count == 0 {
+			opening := FeeMappingRate{
+				FeeMappingID: mapping.ID,
+				Fee:          mapping.Fee,
+				Distance:     mapping.Distance,
+			}
+			if err := tx.Create(&opening).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("fee_mapping_id = ? AND valid_from = ?", mapping.ID, validFrom).
+			Delete(&FeeMappingRate{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&FeeMappingRate{}).
+			Where("fee_mapping_id = ? AND valid_from < ? AND (valid_to = '' OR valid_to >= ?)", mapping.ID, validFrom, validFrom).
+			Update("valid_to", dayBefore).Error; err != nil {
+			return err
+		}
+
+		var next FeeMappingRate
+		err := tx.Where("fee_mapping_id = ? AND valid_from > ?", mapping.ID, validFrom).Order("valid_from ASC").First(&next).Error
+		if err == nil {
+			if rate.ValidTo, err = DayBefore(next.ValidFrom); err != nil {
+				return err
+			}
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if err := tx.Create(&rate).Error; err != nil {
+			return err
+		}
+
+		return applyCurrentFeeMappingRate(tx, mapping)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// applyCurrentFeeMappingRate copies the version in force today onto the mapping row,
+// which is what dropdowns and reports that join fee_mappings read
+func applyCurrentFeeMappingRate(db *gorm.DB, mapping *FeeMapping) error {
+	today := time.Now().Format("2006-01-02")
+
+	var rate FeeMappingRate
+	err := db.Where("fee_mapping_id = ? AND (valid_from = '' OR valid_from <= ?) AND (valid_to = '' OR valid_to >= ?)",
+		mapping.ID, today, today).Order("valid_from DESC").First(&rate).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if mapping.Fee == rate.Fee && mapping.Distance == rate.Distance {
+		return nil
+	}
+
+	mapping.Fee = rate.Fee
+	mapping.Distance = rate.Distance
+	return db.Model(mapping).Updates(map[string]interface{}{
+		"fee":      rate.Fee,
+		"distance": rate.Distance,
+	}).Error
+}
+
+// ApplyDueFeeMappingRates refreshes every mapping whose scheduled rate has come into force
+func ApplyDueFeeMappingRates(db *gorm.DB) error {
+	var mappingIDs []uint
+	if err := db.Model(&FeeMappingRate{}).Distinct("fee_mapping_id").Pluck("fee_mapping_id", &mappingIDs).Error; err != nil {
+		return err
+	}
+
+	for _, id := range mappingIDs {
+		var mapping FeeMapping
+		if err := db.First(&mapping, id).Error; err != nil {
+			continue
+		}
+		if err := applyCurrentFeeMappingRate(db, &mapping); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ScheduleContractVersion puts a new version of a company's contract in force from
+// its ValidFrom until the next scheduled version, closing the version in force the
+// day before and replacing a version starting on the same day
+func ScheduleContractVersion(db *gorm.DB, contract *PricingContract) error {
+	dayBefore, err := DayBefore(contract.ValidFrom)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var sameDay []PricingContract
+		if err := tx.Where("company = ? AND valid_from = ?", contract.Company, contract.ValidFrom).
+			Find(&sameDay).Error; err != nil {
+			return err
+		}
+		for _, version := range sameDay {
+			if err := tx.Where("contract_id = ?", version.ID).Delete(&PricingRule{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&version).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&PricingContract{}).
+			Where("company = ? AND valid_from < ? AND (valid_to = '' OR valid_to >= ?)", contract.Company, contract.ValidFrom, contract.ValidFrom).
+			Update("valid_to", dayBefore).Error; err != nil {
+			return err
+		}
+
+		contract.ValidTo = ""
+		var next PricingContract
+		err := tx.Where("company = ? AND valid_from > ?", contract.Company, contract.ValidFrom).Order("valid_from ASC").First(&next).Error
+		if err == nil {
+			if contract.ValidTo, err = DayBefore(next.ValidFrom); err != nil {
+				return err
+			}
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		contract.ID = 0
+		for i := range contract.Rules {
+			contract.Rules[i].ID = 0
+			contract.Rules[i].ContractID = 0
+		}
+
+		return tx.Create(contract).Error
+	})
+}
+
+// RateOn returns the fee and distance in force on a date, falling back to the
+// mapping's current values when it has no rate history covering the date
+func (m *FeeMapping) RateOn(db *gorm.DB, date string) (float64, float64) {
+	var rate FeeMappingRate
+	err := db.Where("fee_mapping_id = ? AND (valid_from = '' OR valid_from <= ?) AND (valid_to = '' OR valid_to >= ?)",
+		m.ID, date, date).Order("valid_from DESC").First(&rate).Error
+	if err != nil {
+		return m.Fee, m.Distance
+	}
+	return rate.Fee, rate.Distance
+}
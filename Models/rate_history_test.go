@@ -0,0 +1,38 @@
+package Models
+
+import "testing"
+
+type inForceTest struct {
+	rate     FeeMappingRate
+	date     string
+	expected bool
+}
+
+var inForceTests = []inForceTest{
+	{FeeMappingRate{}, "2025-01-01", true},
+	{FeeMappingRate{ValidTo: "2024-12-31"}, "2024-12-31", true},
+	{FeeMappingRate{ValidTo: "2024-12-31"}, "2025-01-01", false},
+	{FeeMappingRate{ValidFrom: "2025-01-01"}, "2024-12-31", false},
+	{FeeMappingRate{ValidFrom: "2025-01-01"}, "2025-01-01", true},
+	{FeeMappingRate{ValidFrom: "2025-01-01", ValidTo: "2025-03-31"}, "2025-04-01", false},
+}
+
+func TestInForce(t *testing.T) {
+	for _, test := range inForceTests {
+		got := test.rate.InForce(test.date)
+		if got != test.expected {
+			t.Errorf("%q..%q on %s: got %t, wanted %t", test.rate.ValidFrom, test.rate.ValidTo, test.date, got, test.expected)
+		}
+	}
+}
+
+func TestDayBefore(t *testing.T) {
+	got, err := DayBefore("2025-03-01")
+	if err != nil || got != "2025-02-28" {
+		t.Errorf("got %q, %v, wanted 2025-02-28", got, err)
+	}
+
+	if _, err := DayBefore("01/03/2025"); err == nil {
+		t.Errorf("expected an error for a malformed date")
+	}
+}
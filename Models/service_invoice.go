@@ -24,10 +24,11 @@ type ServiceInvoice struct {
 // InspectionItem represents individual inspection line items
 type InspectionItem struct {
 	gorm.Model
-	ServiceInvoiceID uint   `json:"service_invoice_id" gorm:"not null;index"`
-	Service          string `json:"service" gorm:"size:500"`
-	Notes            string `json:"notes" gorm:"type:text"`
-	ItemOrder        int    `json:"item_order" gorm:"not null;default:0"`
+	ServiceInvoiceID uint    `json:"service_invoice_id" gorm:"not null;index"`
+	Service          string  `json:"service" gorm:"size:500"`
+	Notes            string  `json:"notes" gorm:"type:text"`
+	ItemOrder        int     `json:"item_order" gorm:"not null;default:0"`
+	Cost             float64 `json:"cost"`
 
 	// Relationship
 	ServiceInvoice ServiceInvoice `json:"service_invoice,omitempty" gorm:"foreignKey:ServiceInvoiceID"`
@@ -45,6 +46,7 @@ type ServiceInvoiceRequest struct {
 }
 
 type InspectionItemRequest struct {
-	Service string `json:"service"`
-	Notes   string `json:"notes"`
+	Service string  `json:"service"`
+	Notes   string  `json:"notes"`
+	Cost    float64 `json:"cost"`
 }
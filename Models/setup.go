@@ -52,16 +52,19 @@ func Connect() {
 	// 2. Then migrate models with simple foreign key relationships
 
 	DB.AutoMigrate(
-		&Truck{},        // Once tires are created
-		&TirePosition{}, // Depends on Truck and Tire
-		&Expense{},      // Depends on Driver
-		&Loan{},         // Depends on Driver
+		&Truck{},         // Once tires are created
+		&TirePosition{},  // Depends on Truck and Tire
+		&TruckCoupling{}, // Depends on Truck
+		&TireEvent{},     // Depends on Tire and TirePosition
+		&Expense{},       // Depends on Driver
+		&Loan{},          // Depends on Driver
 		&Salary{},
 	)
 
 	// 3. Finally, migrate models with complex relationships or that depend on multiple other models
 	DB.AutoMigrate(
 		&FeeMapping{}, // Required for trips but has no dependencies itself
+		&FeeMappingRate{},
 		&TripStruct{}, // Depends on Car, Driver, and relates to FeeMapping
 		&FuelEvent{},  // Depends on Car info
 		&Service{},    // Depends on Car info
@@ -74,6 +77,50 @@ func Connect() {
 	)
 
 	DB.AutoMigrate(&Vendor{}, &VendorTransaction{})
+	DB.AutoMigrate(&PricingContract{}, &PricingRule{})
+	DB.AutoMigrate(&Invoice{}, &InvoiceLine{}, &InvoiceTrip{}, &InvoiceSequence{})
+	DB.AutoMigrate(&ETATaxpayer{}, &ETASubmission{})
+	DB.AutoMigrate(&VehiclePosition{}, &TripSuggestion{}, &Geofence{}, &GeofenceEvent{})
+	DB.AutoMigrate(&SpeedRule{}, &SpeedViolationEvent{}, &DriverScore{}, &DriverAssignment{})
+	DB.AutoMigrate(&FuelAnomaly{}, &ExternalVehicleIdentity{})
+	DB.AutoMigrate(&SyncCursor{}, &SyncRun{}, &SyncRunError{})
+	DB.AutoMigrate(&VehicleCostEntry{})
+	DB.AutoMigrate(&MaintenancePlan{}, &MaintenanceRecord{}, &VehicleOdometer{}, &MaintenanceNotice{})
+	DB.AutoMigrate(&WorkOrder{}, &WorkOrderPart{})
+	DB.AutoMigrate(&Part{}, &StockLocation{}, &StockMovement{})
+	if err := SeedPricingContracts(DB); err != nil {
+		log.Println(err)
+	}
+	if err := ApplyDueFeeMappingRates(DB); err != nil {
+		log.Println(err)
+	}
+	if err := SeedGeofences(DB); err != nil {
+		log.Println(err)
+	}
+	if err := BackfillDriverAssignments(DB); err != nil {
+		log.Println(err)
+	}
+	if err := SeedExternalVehicleIdentities(DB); err != nil {
+		log.Println(err)
+	}
+	if err := LinkTrucksToCars(DB); err != nil {
+		log.Println(err)
+	}
+	if err := BackfillTireMounts(DB); err != nil {
+		log.Println(err)
+	}
+	if err := BackfillFuelEventCars(DB); err != nil {
+		log.Println(err)
+	}
+	if _, err := RefreshVehicleCosts(DB); err != nil {
+		log.Println(err)
+	}
+	if err := TrackVehicleCostWrites(DB); err != nil {
+		log.Println(err)
+	}
+	if err := SeedMaintenancePlans(DB); err != nil {
+		log.Println(err)
+	}
 
 	// 4. After migrations, set up any special indexes
 	// var admin User
@@ -103,57 +150,6 @@ func Connect() {
 	// SetupCars()
 }
 
-func CreateDefaultPositions(db *gorm.DB, truckID uint) error {
-	positions := []TirePosition{
-		// Steering positions (2)
-		{TruckID: truckID, PositionType: "steering", PositionIndex: 1, Side: "left"},
-		{TruckID: truckID, PositionType: "steering", PositionIndex: 2, Side: "right"},
-
-		// Head axle 1 positions (4) - Properly ordered
-		{TruckID: truckID, PositionType: "head_axle_1", PositionIndex: 1, Side: "left"},        // Outer Left
-		{TruckID: truckID, PositionType: "head_axle_1", PositionIndex: 2, Side: "inner_left"},  // Inner Left
-		{TruckID: truckID, PositionType: "head_axle_1", PositionIndex: 3, Side: "inner_right"}, // Inner Right
-		{TruckID: truckID, PositionType: "head_axle_1", PositionIndex: 4, Side: "right"},       // Outer Right
-
-		// Head axle 2 positions (4) - Properly ordered
-		{TruckID: truckID, PositionType: "head_axle_2", PositionIndex: 1, Side: "left"},        // Outer Left
-		{TruckID: truckID, PositionType: "head_axle_2", PositionIndex: 2, Side: "inner_left"},  // Inner Left
-		{TruckID: truckID, PositionType: "head_axle_2", PositionIndex: 3, Side: "inner_right"}, // Inner Right
-		{TruckID: truckID, PositionType: "head_axle_2", PositionIndex: 4, Side: "right"},       // Outer Right
-
-		// Trailer axle 1 positions - Properly ordered
-		{TruckID: truckID, PositionType: "trailer_axle_1", PositionIndex: 1, Side: "left"},        // Outer Left
-		{TruckID: truckID, PositionType: "trailer_axle_1", PositionIndex: 2, Side: "inner_left"},  // Inner Left
-		{TruckID: truckID, PositionType: "trailer_axle_1", PositionIndex: 3, Side: "inner_right"}, // Inner Right
-		{TruckID: truckID, PositionType: "trailer_axle_1", PositionIndex: 4, Side: "right"},       // Outer Right
-
-		// Trailer axle 2 positions - Properly ordered
-		{TruckID: truckID, PositionType: "trailer_axle_2", PositionIndex: 1, Side: "left"},        // Outer Left
-		{TruckID: truckID, PositionType: "trailer_axle_2", PositionIndex: 2, Side: "inner_left"},  // Inner Left
-		{TruckID: truckID, PositionType: "trailer_axle_2", PositionIndex: 3, Side: "inner_right"}, // Inner Right
-		{TruckID: truckID, PositionType: "trailer_axle_2", PositionIndex: 4, Side: "right"},       // Outer Right
-
-		// Trailer axle 3 positions - Properly ordered
-		{TruckID: truckID, PositionType: "trailer_axle_3", PositionIndex: 1, Side: "left"},        // Outer Left
-		{TruckID: truckID, PositionType: "trailer_axle_3", PositionIndex: 2, Side: "inner_left"},  // Inner Left
-		{TruckID: truckID, PositionType: "trailer_axle_3", PositionIndex: 3, Side: "inner_right"}, // Inner Right
-		{TruckID: truckID, PositionType: "trailer_axle_3", PositionIndex: 4, Side: "right"},       // Outer Right
-
-		// Trailer axle 4 positions - Properly ordered
-		{TruckID: truckID, PositionType: "trailer_axle_4", PositionIndex: 1, Side: "left"},        // Outer Left
-		{TruckID: truckID, PositionType: "trailer_axle_4", PositionIndex: 2, Side: "inner_left"},  // Inner Left
-		{TruckID: truckID, PositionType: "trailer_axle_4", PositionIndex: 3, Side: "inner_right"}, // Inner Right
-		{TruckID: truckID, PositionType: "trailer_axle_4", PositionIndex: 4, Side: "right"},       // Outer Right
-
-		// Spare positions (2)
-		{TruckID: truckID, PositionType: "spare", PositionIndex: 1, Side: "none"},
-		{TruckID: truckID, PositionType: "spare", PositionIndex: 2, Side: "none"},
-	}
-
-	result := db.Create(&positions)
-	return result.Error
-}
-
 func SetupCars() {
 	var OldCars []Car
 	if err := DB.Model(&Car{}).Find(&OldCars).Error; err != nil {
@@ -0,0 +1,247 @@
+package Models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Speed rule load conditions
+const (
+	SpeedRuleLoaded = "loaded"
+	SpeedRuleEmpty  = "empty"
+)
+
+// SpeedRule is a speed limit for the cars it applies to. Empty conditions match every car,
+// the most specific matching rule wins: a car, then a geofence zone, load, car type and company.
+type SpeedRule struct {
+	gorm.Model
+	Name               string `json:"name"`
+	Company            string `json:"company"`
+	CarType            string `json:"car_type"` // As on Car.CarType, e.g. "تريلا"
+	CarID              uint   `json:"car_id"`
+	Geofence           string `json:"geofence"` // Name of the geofence the limit applies inside
+	Load               string `json:"load"`     // SpeedRuleLoaded, SpeedRuleEmpty or empty for both
+	MaxSpeed           int    `json:"max_speed"`
+	MinDurationSeconds int    `json:"min_duration_seconds"` // How long the limit must be exceeded before a violation fires
+	Priority           int    `json:"priority"`             // Lowest first among equally specific rules
+	Enabled            bool   `json:"enabled"`              // CreateSpeedRule defaults it to true
+}
+
+// SpeedContext is what speed rules are matched against for one speed point
+type SpeedContext struct {
+	CarID   uint
+	Company string
+	CarType string
+	Loaded  bool
+	Zones   map[string]bool // Names of the geofences containing the point
+}
+
+// DefaultSpeedRule is applied when no configured rule matches
+func DefaultSpeedRule(maxSpeed int) SpeedRule {
+	return SpeedRule{Name: "default", MaxSpeed: maxSpeed, Enabled: true}
+}
+
+// Validate checks the settings of a speed rule
+func (r *SpeedRule) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.MaxSpeed <= 0 {
+		return errors.New("max_speed must be positive")
+	}
+	if r.MinDurationSeconds < 0 {
+		return errors.New("min_duration_seconds cannot be negative")
+	}
+	switch r.Load {
+	case "", SpeedRuleLoaded, SpeedRuleEmpty:
+	default:
+		return fmt.Errorf("unknown load %q", r.Load)
+	}
+	return nil
+}
+
+// Matches reports whether every condition of the rule holds for the context
+func (r *SpeedRule) Matches(ctx SpeedContext) bool {
+	switch {
+	case !r.Enabled:
+		return false
+	case r.CarID != 0 && r.CarID != ctx.CarID:
+		return false
+	case r.Company != "" && r.Company != ctx.Company:
+		return false
+	case r.CarType != "" && r.CarType != ctx.CarType:
+		return false
+	case r.Geofence != "" && !ctx.Zones[r.Geofence]:
+		return false
+	case r.Load == SpeedRuleLoaded && !ctx.Loaded, r.Load == SpeedRuleEmpty && ctx.Loaded:
+		return false
+	}
+	return true
+}
+
+func (r *SpeedRule) specificity() int {
+	score := 0
+	if r.CarID != 0 {
+		score += 16
+	}
+	if r.Geofence != "" {
+		score += 8
+	}
+	if r.Load != "" {
+		score += 4
+	}
+	if r.CarType != "" {
+		score += 2
+	}
+	if r.Company != "" {
+		score++
+	}
+	return score
+}
+
+// SelectSpeedRule returns the most specific rule matching the context, nil if none does
+func SelectSpeedRule(rules []SpeedRule, ctx SpeedContext) *SpeedRule {
+	var selected *SpeedRule
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(ctx) {
+			continue
+		}
+		if selected == nil || rule.specificity() > selected.specificity() ||
+			(rule.specificity() == selected.specificity() && rule.Priority < selected.Priority) {
+			selected = rule
+		}
+	}
+	return selected
+}
+
+// LoadSpeedRules returns the enabled speed rules
+func LoadSpeedRules(db *gorm.DB) ([]SpeedRule, error) {
+	var rules []SpeedRule
+	err := db.Where("enabled = ?", true).Order("priority ASC, id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// SpeedViolationEvent is a stretch of consecutive speed points above the limit of a rule.
+// Times are GPS fix times, stored without a zone like VehiclePosition.RecordedAt.
+type SpeedViolationEvent struct {
+	gorm.Model
+	VehicleID       string    `json:"vehicle_id" gorm:"uniqueIndex:idx_speed_violation_start"` // Telematics unit ID
+	CarID           uint      `json:"car_id" gorm:"index"`
+	CarNoPlate      string    `json:"car_no_plate"`
+	DriverID        uint      `json:"driver_id" gorm:"index"` // Driver assigned to the car when the violation started
+	DriverName      string    `json:"driver_name"`
+	Company         string    `json:"company" gorm:"index"`
+	RuleID          uint      `json:"rule_id"` // 0 for the default rule
+	RuleName        string    `json:"rule_name"`
+	SpeedLimit      int       `json:"speed_limit"`
+	StartedAt       time.Time `json:"started_at" gorm:"uniqueIndex:idx_speed_violation_start;index"`
+	EndedAt         time.Time `json:"ended_at"`
+	DurationSeconds int       `json:"duration_seconds"`
+	PeakSpeed       int       `json:"peak_speed"`
+	AverageSpeed    float64   `json:"average_speed"`
+	Points          int       `json:"points"`
+	PeakAt          time.Time `json:"peak_at"`
+	PeakLatitude    float64   `json:"peak_latitude"`
+	PeakLongitude   float64   `json:"peak_longitude"`
+	StartLatitude   float64   `json:"start_latitude"`
+	StartLongitude  float64   `json:"start_longitude"`
+}
+
+// SpeedSample is a speed point with the rule that applies to it
+type SpeedSample struct {
+	At        time.Time
+	Speed     int
+	Latitude  float64
+	Longitude float64
+	Rule      *SpeedRule
+}
+
+// MaxSpeedSampleGap is the longest gap between two points of the same violation
+const MaxSpeedSampleGap = 2 * time.Minute
+
+// DetectSpeedViolations merges consecutive samples above the limit of the same rule into
+// events, keeping those sustained for the rule's minimum duration
+func DetectSpeedViolations(samples []SpeedSample, maxGap time.Duration) []SpeedViolationEvent {
+	sorted := make([]SpeedSample, len(samples))
+	copy(sorted, samples)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].At.Before(sorted[j].At)
+	})
+
+	events := []SpeedViolationEvent{}
+	var run []SpeedSample
+
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		rule := run[0].Rule
+		first, last := run[0], run[len(run)-1]
+		duration := last.At.Sub(first.At)
+		if duration >= time.Duration(rule.MinDurationSeconds)*time.Second {
+			event := SpeedViolationEvent{
+				RuleID:          rule.ID,
+				RuleName:        rule.Name,
+				SpeedLimit:      rule.MaxSpeed,
+				StartedAt:       first.At,
+				EndedAt:         last.At,
+				DurationSeconds: int(duration.Seconds()),
+				Points:          len(run),
+				StartLatitude:   first.Latitude,
+				StartLongitude:  first.Longitude,
+			}
+			total := 0
+			for _, sample := range run {
+				total += sample.Speed
+				if sample.Speed > event.PeakSpeed {
+					event.PeakSpeed = sample.Speed
+					event.PeakAt = sample.At
+					event.PeakLatitude = sample.Latitude
+					event.PeakLongitude = sample.Longitude
+				}
+			}
+			event.AverageSpeed = math.Round(float64(total)/float64(len(run))*10) / 10
+			events = append(events, event)
+		}
+		run = nil
+	}
+
+	for _, sample := range sorted {
+		if sample.Rule == nil || sample.Speed <= sample.Rule.MaxSpeed {
+			flush()
+			continue
+		}
+		if len(run) > 0 {
+			previous := run[len(run)-1]
+			if previous.Rule != sample.Rule || sample.At.Sub(previous.At) > maxGap {
+				flush()
+			}
+		}
+		run = append(run, sample)
+	}
+	flush()
+
+	return events
+}
+
+// RecordSpeedViolations stores violation events. The speed check rescans the day, so an
+// event already stored with the same start is updated as it grows.
+func RecordSpeedViolations(db *gorm.DB, events []SpeedViolationEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "vehicle_id"}, {Name: "started_at"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "driver_id", "driver_name", "rule_id", "rule_name", "speed_limit", "ended_at", "duration_seconds",
+			"peak_speed", "average_speed", "points", "peak_at", "peak_latitude", "peak_longitude",
+		}),
+	}).Create(&events).Error
+}
@@ -0,0 +1,121 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectSpeedRule(t *testing.T) {
+	rule := func(id uint, name string, maxSpeed int) SpeedRule {
+		r := SpeedRule{Name: name, MaxSpeed: maxSpeed, Enabled: true}
+		r.ID = id
+		return r
+	}
+
+	company := rule(1, "TAQA", 80)
+	company.Company = "TAQA"
+	trailer := rule(2, "TAQA trailers", 70)
+	trailer.Company, trailer.CarType = "TAQA", "تريلا"
+	loaded := rule(3, "Loaded trailers", 60)
+	loaded.CarType, loaded.Load = "تريلا", SpeedRuleLoaded
+	zone := rule(4, "Ring road", 50)
+	zone.Geofence = "Ring road"
+	car := rule(5, "Car 7", 90)
+	car.CarID = 7
+	disabled := rule(6, "Disabled", 10)
+	disabled.Enabled = false
+
+	rules := []SpeedRule{company, trailer, loaded, zone, car, disabled}
+
+	tests := []struct {
+		name     string
+		ctx      SpeedContext
+		expected string
+	}{
+		{"company", SpeedContext{CarID: 1, Company: "TAQA"}, "TAQA"},
+		{"car type", SpeedContext{CarID: 1, Company: "TAQA", CarType: "تريلا"}, "TAQA trailers"},
+		{"loaded", SpeedContext{CarID: 1, Company: "TAQA", CarType: "تريلا", Loaded: true}, "Loaded trailers"},
+		{"zone", SpeedContext{CarID: 1, Company: "TAQA", CarType: "تريلا", Loaded: true, Zones: map[string]bool{"Ring road": true}}, "Ring road"},
+		{"car", SpeedContext{CarID: 7, Company: "TAQA", Zones: map[string]bool{"Ring road": true}}, "Car 7"},
+		{"none", SpeedContext{CarID: 1, Company: "Watanya"}, ""},
+	}
+
+	for _, test := range tests {
+		got := SelectSpeedRule(rules, test.ctx)
+		name := ""
+		if got != nil {
+			name = got.Name
+		}
+		if name != test.expected {
+			t.Errorf("%s: got rule %q, wanted %q", test.name, name, test.expected)
+		}
+	}
+}
+
+func TestDetectSpeedViolations(t *testing.T) {
+	start, _ := time.Parse(PositionTimeLayout, "2025-03-01 10:00:00")
+	highway := &SpeedRule{Name: "highway", MaxSpeed: 80, MinDurationSeconds: 60}
+	zone := &SpeedRule{Name: "zone", MaxSpeed: 50}
+
+	sample := func(seconds, speed int, rule *SpeedRule) SpeedSample {
+		return SpeedSample{At: start.Add(time.Duration(seconds) * time.Second), Speed: speed, Rule: rule}
+	}
+
+	samples := []SpeedSample{
+		sample(0, 85, highway), // Sustained for 90 seconds
+		sample(30, 95, highway),
+		sample(60, 90, highway),
+		sample(90, 82, highway),
+		sample(120, 70, highway),
+		sample(300, 99, highway), // Single point, too short for the highway rule
+		sample(330, 60, highway),
+		sample(600, 55, zone), // The zone rule fires on a single point
+		sample(900, 85, highway),
+		sample(1200, 86, highway), // Gap too long to merge with the point before
+	}
+
+	events := DetectSpeedViolations(samples, MaxSpeedSampleGap)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, wanted 2: %+v", len(events), events)
+	}
+
+	first := events[0]
+	if first.RuleName != "highway" || first.Points != 4 || first.DurationSeconds != 90 ||
+		first.PeakSpeed != 95 || first.AverageSpeed != 88 || !first.PeakAt.Equal(start.Add(30*time.Second)) {
+		t.Errorf("got %+v for the sustained violation", first)
+	}
+	if events[1].RuleName != "zone" || events[1].SpeedLimit != 50 || events[1].Points != 1 {
+		t.Errorf("got %+v for the zone violation", events[1])
+	}
+}
+
+func TestSpeedRuleDisabledSaved(t *testing.T) {
+	db := openTestDB(t, &SpeedRule{})
+
+	rule := SpeedRule{Name: "Cairo ring road", MaxSpeed: 70}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+	var saved SpeedRule
+	if err := db.First(&saved, rule.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Enabled {
+		t.Error("a speed rule created disabled was saved enabled")
+	}
+}
+
+func TestSpeedViolationHash(t *testing.T) {
+	start := time.Date(2025, 7, 10, 8, 0, 0, 0, time.UTC)
+	event := SpeedViolationEvent{VehicleID: "v1", CarID: 3, RuleID: 2, StartedAt: start, PeakSpeed: 95}
+	grown := event
+	grown.PeakSpeed, grown.EndedAt = 112, start.Add(4*time.Minute)
+	if SpeedViolationHash(event) != SpeedViolationHash(grown) {
+		t.Error("a growing violation changed its alert hash")
+	}
+	later := event
+	later.StartedAt = start.Add(time.Hour)
+	if SpeedViolationHash(event) == SpeedViolationHash(later) {
+		t.Error("violations with different starts share an alert hash")
+	}
+}
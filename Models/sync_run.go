@@ -0,0 +1,216 @@
+package Models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sync run kinds
+const (
+	SyncRunScheduled = "scheduled" // Resumes from the source's cursor
+	SyncRunBackfill  = "backfill"  // Fetches a date range given by hand
+)
+
+// Sync run statuses
+const (
+	SyncRunRunning   = "running"
+	SyncRunSucceeded = "succeeded"
+	SyncRunFailed    = "failed"
+)
+
+// Sync run error stages
+const (
+	SyncStageFetch    = "fetch"
+	SyncStageValidate = "validate"
+	SyncStageStore    = "store"
+	SyncStageSync     = "sync"
+)
+
+// SyncCursorOverlap is how far back a scheduled run fetches before the cursor, as records
+// can be posted late
+const SyncCursorOverlap = 24 * time.Hour
+
+// SyncRunRetention is how long finished runs and their errors are kept
+const SyncRunRetention = 90 * 24 * time.Hour
+
+// SyncCursor is the time up to which the records of an external source were fetched
+type SyncCursor struct {
+	gorm.Model
+	Source       string    `json:"source" gorm:"uniqueIndex"`
+	FetchedUntil time.Time `json:"fetched_until"`
+	LastRunID    uint      `json:"last_run_id"`
+}
+
+// SyncRun is one fetch of the records of an external source and their conversion
+type SyncRun struct {
+	gorm.Model
+	Source     string         `json:"source" gorm:"index"`
+	Kind       string         `json:"kind"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Status     string         `json:"status" gorm:"index"`
+	Pages      int            `json:"pages"`
+	Fetched    int            `json:"fetched"`
+	Stored     int            `json:"stored"`
+	Skipped    int            `json:"skipped"` // Already stored or invalid
+	Synced     int            `json:"synced"`
+	Failed     int            `json:"failed"`
+	Error      string         `json:"error"`
+	FinishedAt *time.Time     `json:"finished_at"`
+	Errors     []SyncRunError `json:"errors,omitempty" gorm:"foreignKey:SyncRunID;constraint:OnDelete:CASCADE"`
+}
+
+// SyncRunError is a failure of a sync run, on one record when RecordID is set
+type SyncRunError struct {
+	gorm.Model
+	SyncRunID uint   `json:"sync_run_id" gorm:"index"`
+	RecordID  uint   `json:"record_id"`
+	Stage     string `json:"stage"`
+	Message   string `json:"message"`
+}
+
+// AddError records a failure on the run, stored when the run finishes
+func (r *SyncRun) AddError(recordID uint, stage string, err error) {
+	r.Errors = append(r.Errors, SyncRunError{RecordID: recordID, Stage: stage, Message: err.Error()})
+}
+
+// Eventful reports whether the run stored, synced or failed anything worth recording
+func (r *SyncRun) Eventful() bool {
+	return r.Status == SyncRunFailed || r.Stored > 0 || r.Synced > 0 || len(r.Errors) > 0
+}
+
+// NewSyncRunErrors drops the record errors already recorded by an earlier run, as records
+// failing for good fail again on every run
+func NewSyncRunErrors(errs, recorded []SyncRunError) []SyncRunError {
+	type errorKey struct {
+		RecordID uint
+		Stage    string
+		Message  string
+	}
+	known := make(map[errorKey]bool, len(recorded))
+	for _, e := range recorded {
+		known[errorKey{e.RecordID, e.Stage, e.Message}] = true
+	}
+
+	var fresh []SyncRunError
+	for _, e := range errs {
+		if e.RecordID != 0 && known[errorKey{e.RecordID, e.Stage, e.Message}] {
+			continue
+		}
+		fresh = append(fresh, e)
+	}
+	return fresh
+}
+
+// SyncFetchRange returns the range a scheduled run fetches: from the cursor, less the overlap,
+// or the day before now without a cursor, until now
+func SyncFetchRange(cursor *SyncCursor, now time.Time) (time.Time, time.Time) {
+	from := now.AddDate(0, 0, -1)
+	if cursor != nil && !cursor.FetchedUntil.IsZero() {
+		from = cursor.FetchedUntil.Add(-SyncCursorOverlap)
+	}
+	if from.After(now) {
+		from = now
+	}
+	return from, now
+}
+
+// LoadSyncCursor returns the cursor of a source, nil if it was never fetched
+func LoadSyncCursor(db *gorm.DB, source string) (*SyncCursor, error) {
+	var cursor SyncCursor
+	if err := db.Where("source = ?", source).Limit(1).Find(&cursor).Error; err != nil || cursor.ID == 0 {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// AdvanceSyncCursor moves the cursor of a source to until, never backwards. The last run is
+// kept when the run was not recorded.
+func AdvanceSyncCursor(db *gorm.DB, source string, until time.Time, runID uint) error {
+	cursor, err := LoadSyncCursor(db, source)
+	if err != nil {
+		return err
+	}
+	if cursor == nil {
+		cursor = &SyncCursor{Source: source}
+	}
+	if until.After(cursor.FetchedUntil) {
+		cursor.FetchedUntil = until
+	}
+	if runID != 0 {
+		cursor.LastRunID = runID
+	}
+	return db.Save(cursor).Error
+}
+
+// NewSyncRun returns a running run, stored once it finishes if it was eventful
+func NewSyncRun(source, kind string, from, to time.Time) *SyncRun {
+	return &SyncRun{Source: source, Kind: kind, From: from, To: to, Status: SyncRunRunning}
+}
+
+// StartSyncRun records the start of a run, which is then always recorded
+func StartSyncRun(db *gorm.DB, source, kind string, from, to time.Time) (*SyncRun, error) {
+	run := NewSyncRun(source, kind, from, to)
+	return run, db.Create(run).Error
+}
+
+// FinishSyncRun stores the counts and errors of a run, failed when err is set. Record errors
+// already recorded are left out, and a run not stored at its start is only stored if it was
+// eventful.
+func FinishSyncRun(db *gorm.DB, run *SyncRun, err error) error {
+	now := time.Now()
+	run.FinishedAt = &now
+	run.Status = SyncRunSucceeded
+	if err != nil {
+		run.Status = SyncRunFailed
+		run.Error = err.Error()
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var recordIDs []uint
+		for _, e := range run.Errors {
+			if e.RecordID != 0 {
+				recordIDs = append(recordIDs, e.RecordID)
+			}
+		}
+		if len(recordIDs) > 0 {
+			var recorded []SyncRunError
+			if err := tx.Joins("JOIN sync_runs ON sync_runs.id = sync_run_errors.sync_run_id AND sync_runs.source = ?", run.Source).
+				Where("sync_run_errors.record_id IN ?", recordIDs).Find(&recorded).Error; err != nil {
+				return err
+			}
+			run.Errors = NewSyncRunErrors(run.Errors, recorded)
+		}
+
+		if run.ID == 0 && !run.Eventful() {
+			return nil
+		}
+		if err := tx.Omit("Errors").Save(run).Error; err != nil {
+			return err
+		}
+		for i := range run.Errors {
+			run.Errors[i].SyncRunID = run.ID
+		}
+		if len(run.Errors) > 0 {
+			return tx.Create(&run.Errors).Error
+		}
+		return nil
+	})
+}
+
+// PruneSyncRuns deletes the runs finished longer than SyncRunRetention ago with their errors,
+// returning the number of runs removed
+func PruneSyncRuns(db *gorm.DB, now time.Time) (int64, error) {
+	var removed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&SyncRun{}).Unscoped().Select("id").Where("finished_at < ?", now.Add(-SyncRunRetention))
+		if err := tx.Unscoped().Where("sync_run_id IN (?)", expired).Delete(&SyncRunError{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("finished_at < ?", now.Add(-SyncRunRetention)).Delete(&SyncRun{})
+		removed = result.RowsAffected
+		return result.Error
+	})
+	return removed, err
+}
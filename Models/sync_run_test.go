@@ -0,0 +1,90 @@
+package Models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSyncFetchRange(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cursor   *SyncCursor
+		wantFrom time.Time
+	}{
+		{"no cursor", nil, now.AddDate(0, 0, -1)},
+		{"empty cursor", &SyncCursor{}, now.AddDate(0, 0, -1)},
+		{"recent cursor", &SyncCursor{FetchedUntil: now.Add(-time.Hour)}, now.Add(-time.Hour - SyncCursorOverlap)},
+		{"cursor days behind", &SyncCursor{FetchedUntil: now.AddDate(0, 0, -5)}, now.AddDate(0, 0, -5).Add(-SyncCursorOverlap)},
+		{"cursor ahead", &SyncCursor{FetchedUntil: now.AddDate(0, 0, 3)}, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := SyncFetchRange(tt.cursor, now)
+			if !from.Equal(tt.wantFrom) {
+				t.Errorf("from = %v, want %v", from, tt.wantFrom)
+			}
+			if !to.Equal(now) {
+				t.Errorf("to = %v, want %v", to, now)
+			}
+		})
+	}
+}
+
+func TestSyncRunAddError(t *testing.T) {
+	var run SyncRun
+	run.AddError(42, SyncStageSync, errors.New("car not found"))
+
+	if len(run.Errors) != 1 {
+		t.Fatalf("got %d errors, want 1", len(run.Errors))
+	}
+	if got := run.Errors[0]; got.RecordID != 42 || got.Stage != SyncStageSync || got.Message != "car not found" {
+		t.Errorf("error = %+v", got)
+	}
+}
+
+func TestFinishSyncRun(t *testing.T) {
+	db := openTestDB(t, &SyncRun{}, &SyncRunError{})
+	now := time.Now()
+
+	// A scheduled run that found nothing is not recorded
+	quiet := NewSyncRun(ExternalSourcePetroApp, SyncRunScheduled, now.Add(-time.Hour), now)
+	if err := FinishSyncRun(db, quiet, nil); err != nil {
+		t.Fatal(err)
+	}
+	if quiet.ID != 0 {
+		t.Errorf("quiet run recorded as %d", quiet.ID)
+	}
+
+	// A record failing for good is recorded by its first run only
+	for i := 0; i < 2; i++ {
+		run := NewSyncRun(ExternalSourcePetroApp, SyncRunScheduled, now.Add(-time.Hour), now)
+		run.Failed++
+		run.AddError(42, SyncStageSync, errors.New("car not found"))
+		if err := FinishSyncRun(db, run, nil); err != nil {
+			t.Fatal(err)
+		}
+		if recorded := run.ID != 0; recorded != (i == 0) {
+			t.Errorf("run %d recorded = %v", i, recorded)
+		}
+	}
+	var runs, errs int64
+	db.Model(&SyncRun{}).Count(&runs)
+	db.Model(&SyncRunError{}).Count(&errs)
+	if runs != 1 || errs != 1 {
+		t.Errorf("got %d runs and %d errors, want 1 of each", runs, errs)
+	}
+
+	// Runs past the retention are pruned with their errors
+	removed, err := PruneSyncRuns(db, now.Add(SyncRunRetention+time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&SyncRunError{}).Count(&errs)
+	if removed != 1 || errs != 0 {
+		t.Errorf("pruned %d runs leaving %d errors, want 1 run and no errors", removed, errs)
+	}
+}
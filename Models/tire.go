@@ -1 +1,555 @@
 package Models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tire statuses
+const (
+	TireInUse   = "in-use"
+	TireSpare   = "spare"
+	TireRetired = "retired"
+)
+
+// Tire event types
+const (
+	TireMount    = "mount"
+	TireDismount = "dismount"
+	TireRotate   = "rotate" // Moved to another position of the same truck
+	TireRetread  = "retread"
+	TireInspect  = "inspect"
+	TireRetire   = "retire"
+	TireCouple   = "couple"   // The trailer the tire is on was coupled to a head
+	TireDecouple = "decouple" // The trailer the tire is on was taken off its head
+)
+
+// TireEvent is a step in the life of a tire, with the odometer of the truck it was on
+type TireEvent struct {
+	gorm.Model
+	TireID         uint      `json:"tire_id" gorm:"index"`
+	Type           string    `json:"type" gorm:"index"`
+	Date           time.Time `json:"date" gorm:"index"`
+	TruckID        *uint     `json:"truck_id" gorm:"index"`
+	CarID          *uint     `json:"car_id" gorm:"index"` // The car of the truck, whose odometer is read
+	PositionID     *uint     `json:"position_id"`         // Position mounted on, rotated to or left
+	FromPositionID *uint     `json:"from_position_id"`    // Position a rotated tire left
+	OdometerKm     int       `json:"odometer_km"`         // 0 when the tire is off a truck
+	TreadDepthMm   float64   `json:"tread_depth_mm"`
+	PressurePsi    float64   `json:"pressure_psi"`
+	Cost           float64   `json:"cost"`   // Retread cost
+	Reason         string    `json:"reason"` // Why the tire was retired
+	Notes          string    `json:"notes"`
+	RecordedBy     string    `json:"recorded_by"`
+}
+
+// TireUsage is the distance run and cost of a tire over its life
+type TireUsage struct {
+	TireID       uint     `json:"tire_id"`
+	Serial       string   `json:"serial"`
+	Brand        string   `json:"brand"`
+	Size         string   `json:"size"`
+	Status       string   `json:"status"`
+	Kilometers   int      `json:"kilometers"`
+	Cost         float64  `json:"cost"` // Purchase plus retreads
+	Retreads     int      `json:"retreads"`
+	CostPerKm    *float64 `json:"cost_per_km"`
+	TreadDepthMm *float64 `json:"tread_depth_mm"` // Last inspection
+	TruckID      *uint    `json:"truck_id"`       // Set while mounted
+	RetireReason string   `json:"retire_reason,omitempty"`
+}
+
+// TireBrandUsage compares the tires of a brand
+type TireBrandUsage struct {
+	Brand         string   `json:"brand"`
+	Tires         int      `json:"tires"`
+	Retired       int      `json:"retired"`
+	Kilometers    int      `json:"kilometers"`
+	Cost          float64  `json:"cost"`
+	CostPerKm     *float64 `json:"cost_per_km"`
+	AverageLifeKm *int     `json:"average_life_km"` // Distance run by the retired tires, on average
+}
+
+// Validate checks the tire has a serial and a known status
+func (t *Tire) Validate() error {
+	t.Serial = strings.TrimSpace(t.Serial)
+	if t.Serial == "" {
+		return errors.New("serial is required")
+	}
+	if t.Cost < 0 {
+		return errors.New("cost must not be negative")
+	}
+	switch t.Status {
+	case "":
+		t.Status = TireSpare
+	case TireInUse, TireSpare, TireRetired:
+	default:
+		return fmt.Errorf("unknown tire status %q", t.Status)
+	}
+	return nil
+}
+
+// CheckTireEvent tells whether a tire with a status, mounted or not, can go through an event
+func CheckTireEvent(eventType, status string, mounted bool) error {
+	if status == TireRetired {
+		return errors.New("the tire is retired")
+	}
+	switch eventType {
+	case TireMount, TireInspect, TireRetire:
+		return nil
+	case TireDismount, TireRotate:
+		if !mounted {
+			return errors.New("the tire is not mounted")
+		}
+	case TireRetread:
+		if mounted {
+			return errors.New("dismount the tire before retreading it")
+		}
+	default:
+		return fmt.Errorf("unknown tire event %q", eventType)
+	}
+	return nil
+}
+
+// sortTireEvents orders events as they happened
+func sortTireEvents(events []TireEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Date.Equal(events[j].Date) {
+			return events[i].Date.Before(events[j].Date)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
+
+// TireKilometers returns the distance a tire ran from the odometer readings of its events,
+// counting a tire still mounted up to the odometer of its car. Distance is only read between
+// readings of the same car, and readings going backwards, such as an odometer reset, are not
+// counted.
+func TireKilometers(events []TireEvent, odometers map[uint]int) int {
+	sortTireEvents(events)
+
+	km := 0
+	var mount *TireEvent
+	run := func(end *TireEvent) {
+		if mount == nil || mount.CarID == nil || end.CarID == nil || *mount.CarID != *end.CarID {
+			return
+		}
+		if end.OdometerKm > mount.OdometerKm {
+			km += end.OdometerKm - mount.OdometerKm
+		}
+	}
+	for i := range events {
+		event := &events[i]
+		switch event.Type {
+		case TireMount, TireCouple:
+			mount = event
+		case TireRotate:
+			run(event)
+			mount = event
+		case TireDismount, TireRetire, TireDecouple:
+			run(event)
+			mount = nil
+		}
+	}
+	if mount != nil && mount.CarID != nil {
+		if current := odometers[*mount.CarID]; current > mount.OdometerKm {
+			km += current - mount.OdometerKm
+		}
+	}
+	return km
+}
+
+// SummarizeTire returns the usage of a tire from its events
+func SummarizeTire(tire Tire, events []TireEvent, odometers map[uint]int) TireUsage {
+	usage := TireUsage{
+		TireID:     tire.ID,
+		Serial:     tire.Serial,
+		Brand:      tire.Brand,
+		Size:       tire.Size,
+		Status:     tire.Status,
+		Kilometers: TireKilometers(events, odometers),
+		Cost:       tire.Cost,
+	}
+	sortTireEvents(events)
+	mounted := false
+	for _, event := range events {
+		switch event.Type {
+		case TireRetread:
+			usage.Retreads++
+			usage.Cost += event.Cost
+		case TireInspect:
+			if event.TreadDepthMm > 0 {
+				depth := event.TreadDepthMm
+				usage.TreadDepthMm = &depth
+			}
+		case TireRetire:
+			usage.RetireReason = event.Reason
+		}
+		switch event.Type {
+		case TireMount, TireRotate:
+			mounted = true
+			usage.TruckID = event.TruckID
+		case TireDismount, TireRetire:
+			mounted = false
+		}
+	}
+	if !mounted {
+		usage.TruckID = nil
+	}
+	usage.Cost = roundCost(usage.Cost)
+	if usage.Kilometers > 0 {
+		perKm := math.Round(usage.Cost/float64(usage.Kilometers)*10000) / 10000
+		usage.CostPerKm = &perKm
+	}
+	return usage
+}
+
+// SummarizeTireBrands totals the usage of the tires per brand, cheapest per km first
+func SummarizeTireBrands(usages []TireUsage) []TireBrandUsage {
+	byBrand := make(map[string]*TireBrandUsage)
+	retiredKm := make(map[string]int)
+	var brands []string
+	for _, usage := range usages {
+		brand := strings.TrimSpace(usage.Brand)
+		key := strings.ToLower(brand)
+		summary, ok := byBrand[key]
+		if !ok {
+			summary = &TireBrandUsage{Brand: brand}
+			byBrand[key] = summary
+			brands = append(brands, key)
+		}
+		summary.Tires++
+		summary.Kilometers += usage.Kilometers
+		summary.Cost += usage.Cost
+		if usage.Status == TireRetired {
+			summary.Retired++
+			retiredKm[key] += usage.Kilometers
+		}
+	}
+
+	summaries := make([]TireBrandUsage, 0, len(brands))
+	for _, brand := range brands {
+		summary := byBrand[brand]
+		summary.Cost = roundCost(summary.Cost)
+		if summary.Kilometers > 0 {
+			perKm := math.Round(summary.Cost/float64(summary.Kilometers)*10000) / 10000
+			summary.CostPerKm = &perKm
+		}
+		if summary.Retired > 0 {
+			life := retiredKm[brand] / summary.Retired
+			summary.AverageLifeKm = &life
+		}
+		summaries = append(summaries, *summary)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i].CostPerKm, summaries[j].CostPerKm
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if a != nil && *a != *b {
+			return *a < *b
+		}
+		return summaries[i].Brand < summaries[j].Brand
+	})
+	return summaries
+}
+
+// TruckOdometer returns the car a truck runs on and its odometer, nil for a trailer standing
+// on its own
+func TruckOdometer(db *gorm.DB, truck Truck) (*uint, int, error) {
+	carID, err := TruckCarID(db, truck)
+	if err != nil || carID == nil {
+		return nil, 0, err
+	}
+	odometer, err := currentOdometer(db, *carID)
+	return carID, odometer, err
+}
+
+// currentOdometer returns the odometer of a car, its GPS estimate when ahead of the last fill
+func currentOdometer(db *gorm.DB, carID uint) (int, error) {
+	var car Car
+	if err := db.Select("id", "last_fuel_odometer").First(&car, carID).Error; err != nil {
+		return 0, err
+	}
+	var estimate VehicleOdometer
+	if err := db.Where("car_id = ?", car.ID).Limit(1).Find(&estimate).Error; err != nil {
+		return 0, err
+	}
+	odometer, _ := CarOdometer(car, &estimate)
+	return odometer, nil
+}
+
+// newTireEvent returns an event on a position, read at the odometer of its truck unless one
+// was given
+func newTireEvent(tx *gorm.DB, template TireEvent, eventType string, tireID uint, position TirePosition) (TireEvent, error) {
+	event := template
+	event.ID = 0
+	event.Type = eventType
+	event.TireID = tireID
+	event.PositionID = &position.ID
+	event.TruckID = &position.TruckID
+	if event.Date.IsZero() {
+		event.Date = WallClockNow()
+	}
+
+	var truck Truck
+	if err := tx.First(&truck, position.TruckID).Error; err != nil {
+		return event, fmt.Errorf("truck %d not found", position.TruckID)
+	}
+	carID, odometer, err := TruckOdometer(tx, truck)
+	if err != nil {
+		return event, err
+	}
+	event.CarID = carID
+	if event.OdometerKm == 0 {
+		event.OdometerKm = odometer
+	}
+	return event, nil
+}
+
+// MountTire puts a tire on a position. The tire already there is dismounted and a tire moved
+// from another position of the same truck is rotated, from another truck dismounted first.
+// The template carries the date, odometer and notes of the events recorded, its odometer
+// applying to the position's truck only.
+func MountTire(db *gorm.DB, tireID, positionID uint, template TireEvent) ([]TireEvent, error) {
+	var events []TireEvent
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var tire Tire
+		if err := tx.First(&tire, tireID).Error; err != nil {
+			return fmt.Errorf("tire %d not found", tireID)
+		}
+		var position TirePosition
+		if err := tx.First(&position, positionID).Error; err != nil {
+			return fmt.Errorf("position %d not found", positionID)
+		}
+		if position.TireID != nil && *position.TireID == tireID {
+			return errors.New("the tire is already on this position")
+		}
+		var current TirePosition
+		if err := tx.Where("tire_id = ?", tireID).Limit(1).Find(&current).Error; err != nil {
+			return err
+		}
+		if err := CheckTireEvent(TireMount, tire.Status, current.ID != 0); err != nil {
+			return err
+		}
+
+		// The tire on the position comes off first
+		if position.TireID != nil {
+			event, err := newTireEvent(tx, template, TireDismount, *position.TireID, position)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+			if err := tx.Model(&Tire{}).Where("id = ?", *position.TireID).Update("status", TireSpare).Error; err != nil {
+				return err
+			}
+		}
+
+		mountType := TireMount
+		if current.ID != 0 {
+			if current.TruckID == position.TruckID {
+				mountType = TireRotate
+			} else {
+				// The caller's odometer is read on the new truck, the old one gives its own
+				dismount := template
+				dismount.OdometerKm = 0
+				event, err := newTireEvent(tx, dismount, TireDismount, tireID, current)
+				if err != nil {
+					return err
+				}
+				events = append(events, event)
+			}
+			if err := tx.Model(&current).Update("tire_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		event, err := newTireEvent(tx, template, mountType, tireID, position)
+		if err != nil {
+			return err
+		}
+		if mountType == TireRotate {
+			event.FromPositionID = &current.ID
+		}
+		events = append(events, event)
+
+		if err := tx.Model(&position).Update("tire_id", tireID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&tire).Update("status", TireInUse).Error; err != nil {
+			return err
+		}
+		return tx.Create(&events).Error
+	})
+	return events, err
+}
+
+// DismountTire takes the tire off a position and keeps it as a spare
+func DismountTire(db *gorm.DB, positionID uint, template TireEvent) (*TireEvent, error) {
+	var event TireEvent
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var position TirePosition
+		if err := tx.First(&position, positionID).Error; err != nil {
+			return fmt.Errorf("position %d not found", positionID)
+		}
+		if position.TireID == nil {
+			return errors.New("no tire is mounted on this position")
+		}
+
+		var err error
+		if event, err = newTireEvent(tx, template, TireDismount, *position.TireID, position); err != nil {
+			return err
+		}
+		if err := tx.Model(&position).Update("tire_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Tire{}).Where("id = ?", event.TireID).Update("status", TireSpare).Error; err != nil {
+			return err
+		}
+		return tx.Create(&event).Error
+	})
+	return &event, err
+}
+
+// RecordTireEvent records an inspection, retread or retirement. A mounted tire is read at
+// the odometer of its truck and comes off it when retired.
+func RecordTireEvent(db *gorm.DB, event *TireEvent) error {
+	switch event.Type {
+	case TireInspect:
+		if event.TreadDepthMm <= 0 && event.PressurePsi <= 0 {
+			return errors.New("an inspection needs the tread depth or the pressure")
+		}
+	case TireRetread:
+		if event.Cost < 0 {
+			return errors.New("cost must not be negative")
+		}
+	case TireRetire:
+		event.Reason = strings.TrimSpace(event.Reason)
+		if event.Reason == "" {
+			return errors.New("a reason is required to retire a tire")
+		}
+	default:
+		return fmt.Errorf("%s events are recorded by mounting and dismounting the tire", event.Type)
+	}
+	if event.TreadDepthMm < 0 || event.PressurePsi < 0 {
+		return errors.New("tread depth and pressure must not be negative")
+	}
+	if event.Date.IsZero() {
+		event.Date = WallClockNow()
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var tire Tire
+		if err := tx.First(&tire, event.TireID).Error; err != nil {
+			return fmt.Errorf("tire %d not found", event.TireID)
+		}
+		var position TirePosition
+		if err := tx.Where("tire_id = ?", tire.ID).Limit(1).Find(&position).Error; err != nil {
+			return err
+		}
+		if err := CheckTireEvent(event.Type, tire.Status, position.ID != 0); err != nil {
+			return err
+		}
+
+		if position.ID != 0 {
+			mounted, err := newTireEvent(tx, *event, event.Type, tire.ID, position)
+			if err != nil {
+				return err
+			}
+			*event = mounted
+		} else if event.Type == TireRetread {
+			// Retreads are charged to the car the tire last came off
+			var last TireEvent
+			if err := tx.Where("tire_id = ? AND car_id IS NOT NULL", tire.ID).
+				Order("date DESC, id DESC").Limit(1).Find(&last).Error; err != nil {
+				return err
+			}
+			event.CarID = last.CarID
+		}
+		if event.Type == TireRetire {
+			if position.ID != 0 {
+				if err := tx.Model(&position).Update("tire_id", nil).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&tire).Update("status", TireRetired).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(event).Error
+	})
+}
+
+// TireUsages returns the usage of every tire
+func TireUsages(db *gorm.DB) ([]TireUsage, error) {
+	var tires []Tire
+	if err := db.Order("id ASC").Find(&tires).Error; err != nil {
+		return nil, err
+	}
+	var events []TireEvent
+	if err := db.Order("date ASC, id ASC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	eventsByTire := make(map[uint][]TireEvent)
+	for _, event := range events {
+		eventsByTire[event.TireID] = append(eventsByTire[event.TireID], event)
+	}
+
+	var cars []Car
+	if err := db.Select("id", "last_fuel_odometer").Find(&cars).Error; err != nil {
+		return nil, err
+	}
+	var estimates []VehicleOdometer
+	if err := db.Find(&estimates).Error; err != nil {
+		return nil, err
+	}
+	estimatesByCar := make(map[uint]*VehicleOdometer, len(estimates))
+	for i := range estimates {
+		estimatesByCar[estimates[i].CarID] = &estimates[i]
+	}
+	odometers := make(map[uint]int, len(cars))
+	for _, car := range cars {
+		odometers[car.ID], _ = CarOdometer(car, estimatesByCar[car.ID])
+	}
+
+	usages := make([]TireUsage, 0, len(tires))
+	for _, tire := range tires {
+		usages = append(usages, SummarizeTire(tire, eventsByTire[tire.ID], odometers))
+	}
+	return usages, nil
+}
+
+// BackfillTireMounts records a mount at the current odometer for the tires found on a position
+// without any history, mounted before tire events were recorded, so that the distance they
+// run from now on is counted
+func BackfillTireMounts(db *gorm.DB) error {
+	var recorded []uint
+	if err := db.Model(&TireEvent{}).Distinct("tire_id").Pluck("tire_id", &recorded).Error; err != nil {
+		return err
+	}
+
+	query := db.Where("tire_id IS NOT NULL")
+	if len(recorded) > 0 {
+		query = query.Where("tire_id NOT IN ?", recorded)
+	}
+	var positions []TirePosition
+	if err := query.Find(&positions).Error; err != nil {
+		return err
+	}
+
+	for _, position := range positions {
+		event, err := newTireEvent(db, TireEvent{Notes: "Mounted before tire history was recorded"}, TireMount, *position.TireID, position)
+		if err != nil {
+			return err
+		}
+		if err := db.Create(&event).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
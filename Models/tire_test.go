@@ -0,0 +1,197 @@
+package Models
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestTireKilometers(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 9, 0, 0, 0, time.UTC) }
+	carA, carB := uint(1), uint(2)
+
+	events := []TireEvent{
+		{Type: TireDismount, Date: day(10), CarID: &carA, OdometerKm: 108000},
+		{Type: TireMount, Date: day(1), CarID: &carA, OdometerKm: 100000},
+		{Type: TireRotate, Date: day(5), CarID: &carA, OdometerKm: 104000},
+		{Type: TireRetread, Date: day(12), Cost: 900},
+		{Type: TireMount, Date: day(15), CarID: &carB, OdometerKm: 50000},
+		{Type: TireInspect, Date: day(20), CarID: &carB, OdometerKm: 53000, TreadDepthMm: 9},
+	}
+
+	// 8000 km on the first truck and 6000 on the second, still mounted
+	if km := TireKilometers(events, map[uint]int{carB: 56000}); km != 14000 {
+		t.Errorf("TireKilometers() = %d, want 14000", km)
+	}
+	// An odometer behind the mount reading adds nothing
+	if km := TireKilometers(events, map[uint]int{carB: 40000}); km != 8000 {
+		t.Errorf("TireKilometers() with a reset odometer = %d, want 8000", km)
+	}
+
+	tire := Tire{Model: gorm.Model{ID: 7}, Serial: "X1", Brand: "Michelin", Cost: 5100, Status: TireInUse}
+	usage := SummarizeTire(tire, events, map[uint]int{carB: 56000})
+	if usage.Kilometers != 14000 || usage.Cost != 6000 || usage.Retreads != 1 {
+		t.Errorf("usage = %+v, want 14000 km, 6000 cost and one retread", usage)
+	}
+	if usage.CostPerKm == nil || *usage.CostPerKm != 0.4286 {
+		t.Errorf("cost per km = %v, want 0.4286", usage.CostPerKm)
+	}
+	if usage.TreadDepthMm == nil || *usage.TreadDepthMm != 9 {
+		t.Errorf("tread depth = %v, want 9", usage.TreadDepthMm)
+	}
+}
+
+func TestTireKilometersCoupling(t *testing.T) {
+	headA, headB := uint(1), uint(2)
+	// A tire on a trailer pulled by one head, parked, then pulled by another
+	events := []TireEvent{
+		{Model: gorm.Model{ID: 1}, Type: TireMount, CarID: &headA, OdometerKm: 10000},
+		{Model: gorm.Model{ID: 2}, Type: TireDecouple, CarID: &headA, OdometerKm: 14000},
+		{Model: gorm.Model{ID: 3}, Type: TireCouple, CarID: &headB, OdometerKm: 300000},
+		{Model: gorm.Model{ID: 4}, Type: TireInspect, CarID: &headB, OdometerKm: 301000, TreadDepthMm: 11},
+	}
+	if km := TireKilometers(events, map[uint]int{headA: 90000, headB: 302500}); km != 6500 {
+		t.Errorf("TireKilometers() = %d, want 4000 on the first head and 2500 on the second", km)
+	}
+
+	// A mount read on one car and a dismount on another cannot be compared
+	events = []TireEvent{
+		{Model: gorm.Model{ID: 1}, Type: TireMount, CarID: &headA, OdometerKm: 10000},
+		{Model: gorm.Model{ID: 2}, Type: TireDismount, CarID: &headB, OdometerKm: 300000},
+	}
+	if km := TireKilometers(events, nil); km != 0 {
+		t.Errorf("TireKilometers() across cars = %d, want 0", km)
+	}
+}
+
+func TestSummarizeTireRetired(t *testing.T) {
+	truck, car := uint(3), uint(1)
+	events := []TireEvent{
+		{Model: gorm.Model{ID: 1}, Type: TireMount, TruckID: &truck, CarID: &car, OdometerKm: 20000},
+		{Model: gorm.Model{ID: 2}, Type: TireRetire, TruckID: &truck, CarID: &car, OdometerKm: 80000, Reason: "sidewall cut"},
+	}
+	usage := SummarizeTire(Tire{Cost: 6000, Status: TireRetired}, events, map[uint]int{car: 95000})
+	if usage.Kilometers != 60000 || usage.TruckID != nil || usage.RetireReason != "sidewall cut" {
+		t.Errorf("usage = %+v, want 60000 km off the truck", usage)
+	}
+}
+
+func TestSummarizeTireBrands(t *testing.T) {
+	perKm := func(v float64) *float64 { return &v }
+	usages := []TireUsage{
+		{Brand: "Michelin", Status: TireRetired, Kilometers: 100000, Cost: 7000, CostPerKm: perKm(0.07)},
+		{Brand: "michelin ", Status: TireInUse, Kilometers: 20000, Cost: 7000, CostPerKm: perKm(0.35)},
+		{Brand: "Bridgestone", Status: TireRetired, Kilometers: 60000, Cost: 5400, CostPerKm: perKm(0.09)},
+		{Brand: "Hankook", Status: TireSpare, Cost: 4000},
+	}
+
+	brands := SummarizeTireBrands(usages)
+	if len(brands) != 3 {
+		t.Fatalf("got %d brands, want 3", len(brands))
+	}
+	michelin := brands[1]
+	if michelin.Brand != "Michelin" || michelin.Tires != 2 || michelin.Kilometers != 120000 ||
+		michelin.CostPerKm == nil || *michelin.CostPerKm != 0.1167 {
+		t.Errorf("second brand = %+v, want Michelin at 0.1167 per km", michelin)
+	}
+	if michelin.AverageLifeKm == nil || *michelin.AverageLifeKm != 100000 {
+		t.Errorf("Michelin average life = %v, want 100000", michelin.AverageLifeKm)
+	}
+	if brands[0].Brand != "Bridgestone" || brands[2].Brand != "Hankook" || brands[2].CostPerKm != nil {
+		t.Errorf("brands out of order: %+v", brands)
+	}
+}
+
+func TestCheckTireEvent(t *testing.T) {
+	tests := []struct {
+		eventType string
+		status    string
+		mounted   bool
+		ok        bool
+	}{
+		{TireMount, TireSpare, false, true},
+		{TireDismount, TireSpare, false, false},
+		{TireRotate, TireInUse, true, true},
+		{TireRetread, TireInUse, true, false},
+		{TireRetread, TireSpare, false, true},
+		{TireInspect, TireRetired, false, false},
+		{TireRetire, TireInUse, true, true},
+		{"repair", TireSpare, false, false},
+	}
+	for _, tt := range tests {
+		err := CheckTireEvent(tt.eventType, tt.status, tt.mounted)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckTireEvent(%s, %s, %v) = %v, want ok %v", tt.eventType, tt.status, tt.mounted, err, tt.ok)
+		}
+	}
+}
+
+func TestBackfillTireMounts(t *testing.T) {
+	db := openTestDB(t, &Car{}, &Driver{}, &Truck{}, &TirePosition{}, &Tire{}, &TireEvent{}, &VehicleOdometer{})
+
+	car := Car{CarNoPlate: "ف ع ص 4381", LastFuelOdometer: 100000}
+	db.Create(&car)
+	truck := Truck{TruckNo: car.CarNoPlate, UnitType: TruckUnitCombined, CarID: &car.ID}
+	db.Create(&truck)
+	mounted, tracked := Tire{Serial: "A1", Status: TireInUse}, Tire{Serial: "B2", Status: TireInUse}
+	db.Create(&mounted)
+	db.Create(&tracked)
+	db.Create(&TirePosition{TruckID: truck.ID, PositionType: "steering", Side: "left", TireID: &mounted.ID})
+	db.Create(&TirePosition{TruckID: truck.ID, PositionType: "steering", Side: "right", TireID: &tracked.ID})
+	db.Create(&TireEvent{TireID: tracked.ID, Type: TireMount, Date: WallClockNow(), CarID: &car.ID, OdometerKm: 90000})
+
+	// Run twice as on every startup
+	for i := 0; i < 2; i++ {
+		if err := BackfillTireMounts(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db.Model(&car).Update("last_fuel_odometer", 105000)
+	usages, err := TireUsages(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	km := map[string]int{}
+	for _, usage := range usages {
+		km[usage.Serial] = usage.Kilometers
+	}
+	if km["A1"] != 5000 || km["B2"] != 15000 {
+		t.Errorf("kilometers = %v, want 5000 on the backfilled tire and 15000 on the tracked one", km)
+	}
+}
+
+func TestMountTireFromAnotherTruck(t *testing.T) {
+	db := openTestDB(t, &Car{}, &Driver{}, &Truck{}, &TirePosition{}, &Tire{}, &TireEvent{}, &VehicleOdometer{})
+
+	oldCar := Car{CarNoPlate: "ف ع ص 4381", LastFuelOdometer: 120000}
+	newCar := Car{CarNoPlate: "ق ن ر 5921", LastFuelOdometer: 60000}
+	db.Create(&oldCar)
+	db.Create(&newCar)
+	oldTruck := Truck{TruckNo: oldCar.CarNoPlate, UnitType: TruckUnitCombined, CarID: &oldCar.ID}
+	newTruck := Truck{TruckNo: newCar.CarNoPlate, UnitType: TruckUnitCombined, CarID: &newCar.ID}
+	db.Create(&oldTruck)
+	db.Create(&newTruck)
+	tire := Tire{Serial: "A1", Status: TireSpare}
+	db.Create(&tire)
+	from := TirePosition{TruckID: oldTruck.ID, PositionType: "steering", Side: "left"}
+	to := TirePosition{TruckID: newTruck.ID, PositionType: "steering", Side: "left"}
+	db.Create(&from)
+	db.Create(&to)
+
+	if _, err := MountTire(db, tire.ID, from.ID, TireEvent{OdometerKm: 110000}); err != nil {
+		t.Fatal(err)
+	}
+	events, err := MountTire(db, tire.ID, to.ID, TireEvent{OdometerKm: 61000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != TireDismount || events[1].Type != TireMount {
+		t.Fatalf("events = %+v, want a dismount then a mount", events)
+	}
+	if events[0].OdometerKm != 120000 || events[1].OdometerKm != 61000 {
+		t.Errorf("odometers = %d and %d, want the old truck's 120000 and the given 61000",
+			events[0].OdometerKm, events[1].OdometerKm)
+	}
+}
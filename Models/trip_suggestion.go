@@ -0,0 +1,333 @@
+package Models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Trip suggestion statuses
+const (
+	TripSuggestionDetecting = "detecting" // Sequence in progress, the car has not left the drop-off yet
+	TripSuggestionPending   = "pending"   // Complete, waiting for a dispatcher
+	TripSuggestionMatched   = "matched"   // A registered trip already covers it
+	TripSuggestionConfirmed = "confirmed" // Registered as a trip by a dispatcher
+	TripSuggestionDismissed = "dismissed"
+	TripSuggestionExpired   = "expired" // Left pending past TripSuggestionExpiry
+)
+
+// TripSuggestionExpiry is how long after leaving the terminal a suggestion is offered to
+// dispatchers, and TripDetectionTimeout how long after reaching the terminal a detection may
+// stay open before it is dropped as abandoned
+const (
+	TripSuggestionExpiry = 14 * 24 * time.Hour
+	TripDetectionTimeout = 3 * 24 * time.Hour
+)
+
+// Trip detection stages of a detecting suggestion
+const (
+	TripStageAtTerminal = "at_terminal"
+	TripStageToDropOff  = "to_drop_off"
+	TripStageAtDropOff  = "at_drop_off"
+)
+
+// GeofenceTransition is a car entering or leaving a geofence
+type GeofenceTransition struct {
+	Entered   bool
+	Type      string
+	Name      string
+	Latitude  float64 // Car position when the transition was seen
+	Longitude float64
+	At        time.Time
+}
+
+// TripSuggestion is a trip inferred from a terminal, drop-off, exit geofence sequence,
+// offered to dispatchers as a draft TripStruct
+type TripSuggestion struct {
+	gorm.Model
+	Status       string `json:"status" gorm:"index"`
+	Stage        string `json:"stage"`
+	CarID        uint   `json:"car_id" gorm:"index"`
+	CarNoPlate   string `json:"car_no_plate"`
+	DriverID     uint   `json:"driver_id"`
+	DriverName   string `json:"driver_name"`
+	Transporter  string `json:"transporter"`
+	TankCapacity int    `json:"tank_capacity"`
+	Company      string `json:"company"`
+
+	TerminalGeofence  string     `json:"terminal_geofence"`
+	TerminalLatitude  float64    `json:"terminal_lat"`
+	TerminalLongitude float64    `json:"terminal_long"`
+	Terminal          string     `json:"terminal"` // Terminal name as used on trips
+	DropOffPoint      string     `json:"drop_off_point"`
+	TerminalArrival   *time.Time `json:"terminal_arrival"`
+	TerminalDeparture *time.Time `json:"terminal_departure"`
+	DropOffArrival    *time.Time `json:"drop_off_arrival"`
+	DropOffDeparture  *time.Time `json:"drop_off_departure"`
+
+	Date    string  `json:"date" gorm:"index"` // Terminal departure date, "2006-01-02"
+	Mileage float64 `json:"mileage"`           // GPS kilometers from terminal departure to drop-off arrival
+	TripID  *uint   `json:"trip_id" gorm:"index"`
+}
+
+// AdvanceTripDetection applies a geofence transition to the open detection of a car. It returns
+// the detection still open, nil if there is none, and the suggestion completed by the transition.
+// Entering a terminal starts a detection, reaching a drop-off after it records the delivery and
+// leaving the drop-off, or entering any other geofence, completes it. A car going back to the
+// garage before reaching a drop-off abandons the detection.
+func AdvanceTripDetection(open *TripSuggestion, t GeofenceTransition) (*TripSuggestion, *TripSuggestion) {
+	at := t.At
+	var completed *TripSuggestion
+
+	complete := func() {
+		if open.DropOffDeparture == nil {
+			open.DropOffDeparture = &at
+		}
+		open.Stage = ""
+		open.Status = TripSuggestionPending
+		completed = open
+		open = nil
+	}
+
+	if !t.Entered {
+		switch {
+		case open == nil:
+		case t.Type == GeofenceTerminal && open.Stage == TripStageAtTerminal:
+			open.TerminalDeparture = &at
+			open.Stage = TripStageToDropOff
+		case t.Type == GeofenceDropOff && open.Stage == TripStageAtDropOff && t.Name == open.DropOffPoint:
+			complete()
+		}
+		return open, completed
+	}
+
+	switch t.Type {
+	case GeofenceTerminal:
+		if open != nil && open.Stage == TripStageAtDropOff {
+			complete()
+		}
+		open = &TripSuggestion{
+			Status:            TripSuggestionDetecting,
+			Stage:             TripStageAtTerminal,
+			TerminalGeofence:  t.Name,
+			TerminalLatitude:  t.Latitude,
+			TerminalLongitude: t.Longitude,
+			TerminalArrival:   &at,
+		}
+
+	case GeofenceGarage:
+		if open != nil && open.Stage == TripStageAtDropOff {
+			complete()
+		}
+		open = nil
+
+	case GeofenceDropOff:
+		if open == nil || (open.Stage == TripStageAtDropOff && open.DropOffPoint == t.Name) {
+			break
+		}
+		if open.Stage == TripStageAtDropOff {
+			// Multi drop-off trip, each delivery is suggested separately
+			previous := *open
+			complete()
+			open = &TripSuggestion{
+				Status:            TripSuggestionDetecting,
+				TerminalGeofence:  previous.TerminalGeofence,
+				TerminalLatitude:  previous.TerminalLatitude,
+				TerminalLongitude: previous.TerminalLongitude,
+				TerminalArrival:   previous.TerminalArrival,
+				TerminalDeparture: previous.TerminalDeparture,
+			}
+		}
+		if open.TerminalDeparture == nil {
+			open.TerminalDeparture = &at
+		}
+		open.Stage = TripStageAtDropOff
+		open.DropOffPoint = t.Name
+		open.DropOffArrival = &at
+	}
+
+	return open, completed
+}
+
+// DetectTrips feeds the geofence transitions of a car to its open detection, stores the
+// suggestions they complete and checks those against the registered trips
+func DetectTrips(db *gorm.DB, car *Car, transitions ...GeofenceTransition) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var open *TripSuggestion
+		var existing TripSuggestion
+		if err := tx.Where("car_id = ? AND status = ?", car.ID, TripSuggestionDetecting).
+			Order("id DESC").Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID != 0 {
+			open = &existing
+		}
+		openID := existing.ID
+
+		for _, transition := range transitions {
+			var completed *TripSuggestion
+			open, completed = AdvanceTripDetection(open, transition)
+			if completed == nil {
+				continue
+			}
+
+			if err := completeTripSuggestion(tx, car, completed); err != nil {
+				return err
+			}
+			if err := tx.Save(completed).Error; err != nil {
+				return err
+			}
+		}
+
+		// The stored detection was abandoned: it was neither completed nor kept open
+		if openID != 0 && existing.Status == TripSuggestionDetecting && (open == nil || open.ID != openID) {
+			if err := tx.Delete(&TripSuggestion{}, openID).Error; err != nil {
+				return err
+			}
+		}
+
+		if open != nil {
+			open.CarID = car.ID
+			open.CarNoPlate = car.CarNoPlate
+			open.Company = car.OperatingCompany
+			return tx.Save(open).Error
+		}
+		return nil
+	})
+}
+
+// ExpireTripSuggestions marks the suggestions left pending past TripSuggestionExpiry as
+// expired and drops the detections open past TripDetectionTimeout, such as a car whose GPS
+// stopped reporting on the way. Times are wall clock, as the transitions. It returns the
+// number of suggestions expired or dropped.
+func ExpireTripSuggestions(db *gorm.DB, now time.Time) (int64, error) {
+	expired := db.Model(&TripSuggestion{}).
+		Where("status = ? AND terminal_departure < ?", TripSuggestionPending, now.Add(-TripSuggestionExpiry)).
+		Update("status", TripSuggestionExpired)
+	if expired.Error != nil {
+		return 0, expired.Error
+	}
+
+	abandoned := db.Where("status = ? AND terminal_arrival < ?", TripSuggestionDetecting, now.Add(-TripDetectionTimeout)).
+		Delete(&TripSuggestion{})
+	return expired.RowsAffected + abandoned.RowsAffected, abandoned.Error
+}
+
+// completeTripSuggestion fills the draft trip fields of a completed suggestion
+func completeTripSuggestion(db *gorm.DB, car *Car, suggestion *TripSuggestion) error {
+	suggestion.CarID = car.ID
+	suggestion.CarNoPlate = car.CarNoPlate
+	suggestion.DriverID = car.DriverID
+	suggestion.DriverName = car.Driver.Name
+	if driver, err := ResolveDriver(db, car.ID, *suggestion.TerminalDeparture); err != nil {
+		return err
+	} else if driver.ID != 0 {
+		suggestion.DriverID = driver.ID
+		suggestion.DriverName = driver.Name
+	}
+	suggestion.Transporter = car.Transporter
+	suggestion.TankCapacity = car.TankCapacity
+	suggestion.Company = car.OperatingCompany
+	suggestion.Date = suggestion.TerminalDeparture.Format("2006-01-02")
+	suggestion.Terminal = resolveTerminalName(db, suggestion)
+
+	track, err := VehicleTrack(db, car.ID, *suggestion.TerminalDeparture, *suggestion.DropOffArrival)
+	if err != nil {
+		return err
+	}
+	suggestion.Mileage = math.Round(TrackDistance(track)*10) / 10
+
+	return MatchTripSuggestion(db, suggestion)
+}
+
+// terminalMatchRadius is how far the car may be from a terminal's coordinates
+const terminalMatchRadius = 2.0 // kilometers
+
+// resolveTerminalName maps the terminal geofence of a suggestion to the nearest registered
+// terminal, falling back to the geofence name
+func resolveTerminalName(db *gorm.DB, suggestion *TripSuggestion) string {
+	var terminals []Terminal
+	if err := db.Where("latitude <> 0 OR longitude <> 0").Find(&terminals).Error; err != nil {
+		return suggestion.TerminalGeofence
+	}
+
+	name, nearest := suggestion.TerminalGeofence, terminalMatchRadius
+	for _, terminal := range terminals {
+		distance := DistanceKm(suggestion.TerminalLatitude, suggestion.TerminalLongitude, terminal.Latitude, terminal.Longitude)
+		if distance <= nearest {
+			name, nearest = terminal.Name, distance
+		}
+	}
+	return name
+}
+
+// MatchTripSuggestion links a pending suggestion to a registered trip of the same car and
+// drop-off within a day of it, marking it matched
+func MatchTripSuggestion(db *gorm.DB, suggestion *TripSuggestion) error {
+	day, err := time.Parse("2006-01-02", suggestion.Date)
+	if err != nil {
+		return err
+	}
+
+	// Trips already claimed by another suggestion are not matched twice
+	claimed := db.Model(&TripSuggestion{}).Select("trip_id").
+		Where("trip_id IS NOT NULL AND id <> ?", suggestion.ID)
+
+	var trip TripStruct
+	err = db.Where("car_id = ? AND drop_off_point = ? AND date BETWEEN ? AND ?",
+		suggestion.CarID, suggestion.DropOffPoint,
+		day.AddDate(0, 0, -1).Format("2006-01-02"), day.AddDate(0, 0, 1).Format("2006-01-02")).
+		Where("id NOT IN (?)", claimed).
+		Order("date ASC").
+		First(&trip).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	suggestion.Status = TripSuggestionMatched
+	suggestion.TripID = &trip.ID
+	return nil
+}
+
+// TripWindow is when a car drove loaded, from leaving the terminal to reaching the drop-off
+type TripWindow struct {
+	From time.Time
+	To   *time.Time // Nil while the car is still on its way to the drop-off
+}
+
+// TripWindows returns the loaded windows of trip suggestions, skipping dismissed ones and
+// those that have not left the terminal yet
+func TripWindows(suggestions []TripSuggestion) []TripWindow {
+	var windows []TripWindow
+	for _, suggestion := range suggestions {
+		if suggestion.Status == TripSuggestionDismissed || suggestion.TerminalDeparture == nil {
+			continue
+		}
+		windows = append(windows, TripWindow{From: *suggestion.TerminalDeparture, To: suggestion.DropOffArrival})
+	}
+	return windows
+}
+
+// LoadedAt reports whether a time falls within one of the trip windows
+func LoadedAt(windows []TripWindow, at time.Time) bool {
+	for _, window := range windows {
+		if !at.Before(window.From) && (window.To == nil || !at.After(*window.To)) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadTripWindows loads the loaded windows of a car overlapping a period
+func LoadTripWindows(db *gorm.DB, carID uint, from, to time.Time) ([]TripWindow, error) {
+	var suggestions []TripSuggestion
+	err := db.Where("car_id = ? AND status <> ? AND terminal_departure IS NOT NULL AND terminal_departure <= ?",
+		carID, TripSuggestionDismissed, to).
+		Where("drop_off_arrival IS NULL OR drop_off_arrival >= ?", from).
+		Find(&suggestions).Error
+	return TripWindows(suggestions), err
+}
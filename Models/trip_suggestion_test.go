@@ -0,0 +1,162 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func transitionAt(entered bool, geofenceType, name, clock string) GeofenceTransition {
+	at, _ := time.Parse(PositionTimeLayout, "2025-07-10 "+clock)
+	return GeofenceTransition{Entered: entered, Type: geofenceType, Name: name, At: at}
+}
+
+func runDetection(transitions ...GeofenceTransition) (*TripSuggestion, []*TripSuggestion) {
+	var open *TripSuggestion
+	var completed []*TripSuggestion
+	for _, transition := range transitions {
+		var done *TripSuggestion
+		open, done = AdvanceTripDetection(open, transition)
+		if done != nil {
+			completed = append(completed, done)
+		}
+	}
+	return open, completed
+}
+
+func TestAdvanceTripDetection(t *testing.T) {
+	open, completed := runDetection(
+		transitionAt(false, GeofenceGarage, "garage", "05:00:00"),
+		transitionAt(true, GeofenceTerminal, "Badr Terminal", "06:00:00"),
+		transitionAt(false, GeofenceTerminal, "Badr Terminal", "07:30:00"),
+		transitionAt(true, GeofenceDropOff, "Station A", "09:00:00"),
+		transitionAt(false, GeofenceDropOff, "Station A", "10:00:00"),
+	)
+	if open != nil || len(completed) != 1 {
+		t.Fatalf("got open %+v and %d completed, wanted a single completed trip", open, len(completed))
+	}
+
+	trip := completed[0]
+	if trip.Status != TripSuggestionPending || trip.TerminalGeofence != "Badr Terminal" || trip.DropOffPoint != "Station A" {
+		t.Errorf("unexpected trip %+v", trip)
+	}
+	if trip.TerminalDeparture.Format("15:04") != "07:30" || trip.DropOffArrival.Format("15:04") != "09:00" ||
+		trip.DropOffDeparture.Format("15:04") != "10:00" {
+		t.Errorf("unexpected timestamps %s %s %s", trip.TerminalDeparture, trip.DropOffArrival, trip.DropOffDeparture)
+	}
+}
+
+func TestAdvanceTripDetectionMultiDropOff(t *testing.T) {
+	// Geofence to geofence moves between polls have no separate exit
+	open, completed := runDetection(
+		transitionAt(true, GeofenceTerminal, "Badr Terminal", "06:00:00"),
+		transitionAt(true, GeofenceDropOff, "Station A", "08:00:00"),
+		transitionAt(true, GeofenceDropOff, "Station B", "09:00:00"),
+		transitionAt(true, GeofenceTerminal, "Badr Terminal", "11:00:00"),
+	)
+	if len(completed) != 2 {
+		t.Fatalf("got %d completed trips, wanted 2", len(completed))
+	}
+	if completed[0].DropOffPoint != "Station A" || completed[1].DropOffPoint != "Station B" {
+		t.Errorf("unexpected drop-offs %s, %s", completed[0].DropOffPoint, completed[1].DropOffPoint)
+	}
+	if completed[1].TerminalDeparture.Format("15:04") != "08:00" || completed[1].DropOffDeparture.Format("15:04") != "11:00" {
+		t.Errorf("unexpected second trip timestamps %s %s", completed[1].TerminalDeparture, completed[1].DropOffDeparture)
+	}
+	if open == nil || open.Stage != TripStageAtTerminal {
+		t.Errorf("expected a new detection at the terminal, got %+v", open)
+	}
+}
+
+func TestAdvanceTripDetectionAbandoned(t *testing.T) {
+	open, completed := runDetection(
+		transitionAt(true, GeofenceTerminal, "Badr Terminal", "06:00:00"),
+		transitionAt(false, GeofenceTerminal, "Badr Terminal", "07:00:00"),
+		transitionAt(true, GeofenceGarage, "garage", "08:00:00"),
+	)
+	if open != nil || len(completed) != 0 {
+		t.Errorf("got open %+v and %d completed, wanted the detection abandoned", open, len(completed))
+	}
+
+	// A drop-off without a terminal before it is not a trip
+	open, completed = runDetection(
+		transitionAt(true, GeofenceDropOff, "Station A", "08:00:00"),
+		transitionAt(false, GeofenceDropOff, "Station A", "09:00:00"),
+	)
+	if open != nil || len(completed) != 0 {
+		t.Errorf("got open %+v and %d completed for a lone drop-off", open, len(completed))
+	}
+}
+
+func TestLoadedAt(t *testing.T) {
+	at := func(clock string) *time.Time {
+		parsed, _ := time.Parse(PositionTimeLayout, "2025-07-10 "+clock)
+		return &parsed
+	}
+	windows := TripWindows([]TripSuggestion{
+		{Status: TripSuggestionPending, TerminalDeparture: at("06:00:00"), DropOffArrival: at("09:00:00")},
+		{Status: TripSuggestionDismissed, TerminalDeparture: at("10:00:00"), DropOffArrival: at("11:00:00")},
+		{Status: TripSuggestionDetecting, Stage: TripStageAtTerminal, TerminalArrival: at("12:00:00")},
+		{Status: TripSuggestionDetecting, Stage: TripStageToDropOff, TerminalDeparture: at("14:00:00")},
+	})
+
+	tests := []struct {
+		clock  string
+		loaded bool
+	}{
+		{"05:59:00", false},
+		{"06:00:00", true},
+		{"09:00:00", true},
+		{"09:30:00", false},
+		{"10:30:00", false}, // Dismissed
+		{"12:30:00", false}, // Still loading at the terminal
+		{"18:00:00", true},  // On the way to the drop-off
+	}
+	for _, tt := range tests {
+		if got := LoadedAt(windows, *at(tt.clock)); got != tt.loaded {
+			t.Errorf("LoadedAt(%s) = %v, want %v", tt.clock, got, tt.loaded)
+		}
+	}
+}
+
+func TestExpireTripSuggestions(t *testing.T) {
+	db := openTestDB(t, &TripSuggestion{})
+	now := time.Date(2025, 7, 30, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+
+	suggestions := []TripSuggestion{
+		{Status: TripSuggestionPending, TerminalArrival: daysAgo(21), TerminalDeparture: daysAgo(20)},
+		{Status: TripSuggestionPending, TerminalArrival: daysAgo(3), TerminalDeparture: daysAgo(2)},
+		{Status: TripSuggestionConfirmed, TerminalArrival: daysAgo(31), TerminalDeparture: daysAgo(30)},
+		{Status: TripSuggestionDetecting, Stage: TripStageToDropOff, TerminalArrival: daysAgo(5), TerminalDeparture: daysAgo(5)},
+		{Status: TripSuggestionDetecting, Stage: TripStageAtTerminal, TerminalArrival: daysAgo(0)},
+	}
+	db.Create(&suggestions)
+
+	count, err := ExpireTripSuggestions(db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("ExpireTripSuggestions() = %d, want 2", count)
+	}
+
+	var stored []TripSuggestion
+	db.Order("id").Find(&stored)
+	var statuses []string
+	for _, suggestion := range stored {
+		statuses = append(statuses, suggestion.Status)
+	}
+	want := []string{TripSuggestionExpired, TripSuggestionPending, TripSuggestionConfirmed, TripSuggestionDetecting}
+	if len(statuses) != len(want) {
+		t.Fatalf("statuses = %v, want %v", statuses, want)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("statuses = %v, want %v", statuses, want)
+			break
+		}
+	}
+}
@@ -1,27 +1,55 @@
 package Models
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"gorm.io/gorm"
 )
 
-// Truck represents a complete truck with head and trailer
+// Truck unit types
+const (
+	TruckUnitHead     = "head"
+	TruckUnitTrailer  = "trailer"
+	TruckUnitCombined = "combined" // Head and trailer registered as one unit
+)
+
+// Truck represents a unit of the fleet, a head, a trailer or both, and its tire positions
 type Truck struct {
 	gorm.Model
-	TruckNo   string         `json:"truck_no" gorm:"type:varchar(50);uniqueIndex"`
-	Make      string         `json:"make"`
-	Year      int            `json:"year"`
-	Positions []TirePosition `json:"positions"`
+	TruckNo    string         `json:"truck_no" gorm:"type:varchar(50);uniqueIndex"`
+	Make       string         `json:"make"`
+	Year       int            `json:"year"`
+	UnitType   string         `json:"unit_type"`
+	AxleLayout string         `json:"axle_layout"`               // Template the positions were generated from
+	CarID      *uint          `json:"car_id" gorm:"uniqueIndex"` // The fleet car, required for heads
+	Car        *Car           `json:"car,omitempty" gorm:"foreignKey:CarID"`
+	HeadID     *uint          `json:"head_id" gorm:"index"` // Head a trailer is coupled to
+	Positions  []TirePosition `json:"positions"`
+}
+
+// TruckCoupling is a trailer pulled by a head, from coupling until decoupling
+type TruckCoupling struct {
+	gorm.Model
+	HeadID      uint       `json:"head_id" gorm:"index"`
+	TrailerID   uint       `json:"trailer_id" gorm:"index"`
+	CoupledAt   time.Time  `json:"coupled_at"`
+	DecoupledAt *time.Time `json:"decoupled_at"`
+	CoupledBy   string     `json:"coupled_by"`
+	DecoupledBy string     `json:"decoupled_by"`
 }
 
 // Tire represents a single tire in the system
 type Tire struct {
 	gorm.Model
-	Serial          string `json:"serial" gorm:"type:varchar(100);"`
-	Brand           string `json:"brand"`
-	Size            string `json:"size"`
-	ManufactureDate string `json:"manufacture_date"`
-	PurchaseDate    string `json:"purchase_date"`
-	Status          string `json:"status"` // "in-use", "spare", "retired"
+	Serial          string  `json:"serial" gorm:"type:varchar(100);"`
+	Brand           string  `json:"brand"`
+	Size            string  `json:"size"`
+	ManufactureDate string  `json:"manufacture_date"`
+	PurchaseDate    string  `json:"purchase_date"`
+	Cost            float64 `json:"cost"`
+	Status          string  `json:"status"` // "in-use", "spare", "retired"
 }
 
 // TirePosition represents a specific position on a truck where a tire can be mounted
@@ -35,3 +63,300 @@ type TirePosition struct {
 	TireID        *uint  `json:"tire_id"`        // The currently mounted tire (null if empty)
 	Tire          *Tire  `json:"tire,omitempty" gorm:"foreignKey:TireID"`
 }
+
+// AxleLayout is an axle of a template and how many tires it carries, 2 single or 4 dual
+type AxleLayout struct {
+	PositionType string `json:"position_type"`
+	Tires        int    `json:"tires"`
+}
+
+// AxleTemplate is an axle configuration the positions of a unit are generated from
+type AxleTemplate struct {
+	Name     string       `json:"name"`
+	UnitType string       `json:"unit_type"`
+	Axles    []AxleLayout `json:"axles"`
+	Spares   int          `json:"spares"`
+}
+
+// AxleTemplates are the axle configurations of the fleet
+var AxleTemplates = []AxleTemplate{
+	{Name: "4x2_head", UnitType: TruckUnitHead, Spares: 1, Axles: []AxleLayout{
+		{"steering", 2}, {"head_axle_1", 4},
+	}},
+	{Name: "6x2_head", UnitType: TruckUnitHead, Spares: 1, Axles: []AxleLayout{
+		{"steering", 2}, {"head_axle_1", 4}, {"head_axle_2", 2},
+	}},
+	{Name: "6x4_head", UnitType: TruckUnitHead, Spares: 1, Axles: []AxleLayout{
+		{"steering", 2}, {"head_axle_1", 4}, {"head_axle_2", 4},
+	}},
+	{Name: "2_axle_trailer", UnitType: TruckUnitTrailer, Spares: 1, Axles: []AxleLayout{
+		{"trailer_axle_1", 4}, {"trailer_axle_2", 4},
+	}},
+	{Name: "3_axle_trailer", UnitType: TruckUnitTrailer, Spares: 1, Axles: []AxleLayout{
+		{"trailer_axle_1", 4}, {"trailer_axle_2", 4}, {"trailer_axle_3", 4},
+	}},
+	{Name: "4_axle_trailer", UnitType: TruckUnitTrailer, Spares: 1, Axles: []AxleLayout{
+		{"trailer_axle_1", 4}, {"trailer_axle_2", 4}, {"trailer_axle_3", 4}, {"trailer_axle_4", 4},
+	}},
+	{Name: "6x4_tanker", UnitType: TruckUnitCombined, Spares: 2, Axles: []AxleLayout{
+		{"steering", 2}, {"head_axle_1", 4}, {"head_axle_2", 4},
+		{"trailer_axle_1", 4}, {"trailer_axle_2", 4}, {"trailer_axle_3", 4}, {"trailer_axle_4", 4},
+	}},
+}
+
+// DefaultAxleLayouts is the template of a unit registered without one
+var DefaultAxleLayouts = map[string]string{
+	TruckUnitHead:     "6x4_head",
+	TruckUnitTrailer:  "3_axle_trailer",
+	TruckUnitCombined: "6x4_tanker",
+}
+
+// axleSides are the sides of the tires of a single and a dual axle, outer left to outer right
+var axleSides = map[int][]string{
+	2: {"left", "right"},
+	4: {"left", "inner_left", "inner_right", "right"},
+}
+
+// FindAxleTemplate returns the template with a name
+func FindAxleTemplate(name string) (AxleTemplate, bool) {
+	for _, template := range AxleTemplates {
+		if template.Name == name {
+			return template, true
+		}
+	}
+	return AxleTemplate{}, false
+}
+
+// Validate checks the unit type and axle layout of the truck, defaulting both, and that a
+// head is linked to its car
+func (t *Truck) Validate() error {
+	if t.TruckNo == "" {
+		return errors.New("truck_no is required")
+	}
+	if t.UnitType == "" {
+		t.UnitType = TruckUnitCombined
+	}
+	if _, ok := DefaultAxleLayouts[t.UnitType]; !ok {
+		return fmt.Errorf("unknown unit type %q", t.UnitType)
+	}
+	if t.AxleLayout == "" {
+		t.AxleLayout = DefaultAxleLayouts[t.UnitType]
+	}
+	template, ok := FindAxleTemplate(t.AxleLayout)
+	if !ok {
+		return fmt.Errorf("unknown axle layout %q", t.AxleLayout)
+	}
+	if template.UnitType != t.UnitType {
+		return fmt.Errorf("the %s layout is for a %s, not a %s", template.Name, template.UnitType, t.UnitType)
+	}
+	if t.UnitType == TruckUnitHead && t.CarID == nil {
+		return errors.New("a head is registered for a car, car_id is required")
+	}
+	return nil
+}
+
+// RunningCar returns the car whose odometer the unit runs on: its own car, or for a trailer
+// the car of the head it is coupled to
+func (t *Truck) RunningCar(head *Truck) *uint {
+	if t.UnitType != TruckUnitTrailer {
+		return t.CarID
+	}
+	if t.HeadID == nil || head == nil || head.ID != *t.HeadID {
+		return nil
+	}
+	return head.CarID
+}
+
+// TemplatePositions returns the tire positions of a template for a truck
+func TemplatePositions(truckID uint, template AxleTemplate) []TirePosition {
+	var positions []TirePosition
+	for _, axle := range template.Axles {
+		for i, side := range axleSides[axle.Tires] {
+			positions = append(positions, TirePosition{
+				TruckID: truckID, PositionType: axle.PositionType, PositionIndex: i + 1, Side: side,
+			})
+		}
+	}
+	for i := 1; i <= template.Spares; i++ {
+		positions = append(positions, TirePosition{TruckID: truckID, PositionType: "spare", PositionIndex: i, Side: "none"})
+	}
+	return positions
+}
+
+// CreateTemplatePositions creates the tire positions of a truck from its axle layout
+func CreateTemplatePositions(db *gorm.DB, truck Truck) error {
+	template, ok := FindAxleTemplate(truck.AxleLayout)
+	if !ok {
+		return fmt.Errorf("unknown axle layout %q", truck.AxleLayout)
+	}
+	positions := TemplatePositions(truck.ID, template)
+	return db.Create(&positions).Error
+}
+
+// TruckCarID returns the car whose odometer a truck runs on
+func TruckCarID(db *gorm.DB, truck Truck) (*uint, error) {
+	var head *Truck
+	if truck.UnitType == TruckUnitTrailer && truck.HeadID != nil {
+		head = &Truck{}
+		if err := db.First(head, *truck.HeadID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return truck.RunningCar(head), nil
+}
+
+// LinkTrucksToCars links the units registered before trucks referenced cars to the car with
+// the same plate, as combined units with the layout they were created with
+func LinkTrucksToCars(db *gorm.DB) error {
+	if err := db.Model(&Truck{}).Where("unit_type = '' OR unit_type IS NULL").Updates(map[string]interface{}{
+		"unit_type":   TruckUnitCombined,
+		"axle_layout": DefaultAxleLayouts[TruckUnitCombined],
+	}).Error; err != nil {
+		return err
+	}
+
+	var trucks []Truck
+	if err := db.Where("car_id IS NULL AND unit_type <> ?", TruckUnitTrailer).Find(&trucks).Error; err != nil {
+		return err
+	}
+	if len(trucks) == 0 {
+		return nil
+	}
+	var cars []Car
+	if err := db.Select("id", "car_no_plate").Find(&cars).Error; err != nil {
+		return err
+	}
+	carsByPlate := make(map[string]uint, len(cars))
+	for _, car := range cars {
+		carsByPlate[PlateKey(car.CarNoPlate)] = car.ID
+	}
+	for _, truck := range trucks {
+		carID, ok := carsByPlate[PlateKey(truck.TruckNo)]
+		if !ok {
+			continue
+		}
+		// A car is linked to one unit
+		var taken int64
+		if err := db.Model(&Truck{}).Where("car_id = ?", carID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			continue
+		}
+		if err := db.Model(&truck).Update("car_id", carID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordCouplingEvents records the coupling or decoupling of a trailer on each of its mounted
+// tires, at the odometer of the head, so the distance they run is read from the right car
+func recordCouplingEvents(tx *gorm.DB, trailer Truck, eventType string, carID *uint, at time.Time, by string) error {
+	var positions []TirePosition
+	if err := tx.Where("truck_id = ? AND tire_id IS NOT NULL", trailer.ID).Find(&positions).Error; err != nil {
+		return err
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+
+	odometer := 0
+	if carID != nil {
+		var err error
+		if odometer, err = currentOdometer(tx, *carID); err != nil {
+			return err
+		}
+	}
+
+	events := make([]TireEvent, 0, len(positions))
+	for _, position := range positions {
+		positionID := position.ID
+		events = append(events, TireEvent{
+			TireID:     *position.TireID,
+			Type:       eventType,
+			Date:       at,
+			TruckID:    &trailer.ID,
+			CarID:      carID,
+			PositionID: &positionID,
+			OdometerKm: odometer,
+			RecordedBy: by,
+		})
+	}
+	return tx.Create(&events).Error
+}
+
+// CoupleTrailer couples a trailer to a head. Both must be free.
+func CoupleTrailer(db *gorm.DB, trailerID, headID uint, at time.Time, by string) (*TruckCoupling, error) {
+	var coupling TruckCoupling
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var trailer, head Truck
+		if err := tx.First(&trailer, trailerID).Error; err != nil {
+			return fmt.Errorf("trailer %d not found", trailerID)
+		}
+		if err := tx.First(&head, headID).Error; err != nil {
+			return fmt.Errorf("head %d not found", headID)
+		}
+		if trailer.UnitType != TruckUnitTrailer || head.UnitType != TruckUnitHead {
+			return errors.New("a trailer is coupled to a head")
+		}
+		if trailer.HeadID != nil {
+			return fmt.Errorf("%s is already coupled, decouple it first", trailer.TruckNo)
+		}
+		var pulling int64
+		if err := tx.Model(&Truck{}).Where("head_id = ?", head.ID).Count(&pulling).Error; err != nil {
+			return err
+		}
+		if pulling > 0 {
+			return fmt.Errorf("%s is already pulling a trailer", head.TruckNo)
+		}
+
+		if err := tx.Model(&trailer).Update("head_id", head.ID).Error; err != nil {
+			return err
+		}
+		coupling = TruckCoupling{HeadID: head.ID, TrailerID: trailer.ID, CoupledAt: at, CoupledBy: by}
+		if err := tx.Create(&coupling).Error; err != nil {
+			return err
+		}
+		return recordCouplingEvents(tx, trailer, TireCouple, head.CarID, at, by)
+	})
+	return &coupling, err
+}
+
+// DecoupleTrailer takes a trailer off its head
+func DecoupleTrailer(db *gorm.DB, trailerID uint, at time.Time, by string) (*TruckCoupling, error) {
+	var coupling TruckCoupling
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var trailer Truck
+		if err := tx.First(&trailer, trailerID).Error; err != nil {
+			return fmt.Errorf("trailer %d not found", trailerID)
+		}
+		if trailer.HeadID == nil {
+			return fmt.Errorf("%s is not coupled", trailer.TruckNo)
+		}
+		carID, err := TruckCarID(tx, trailer)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("trailer_id = ? AND decoupled_at IS NULL", trailer.ID).
+			Order("id DESC").Limit(1).Find(&coupling).Error; err != nil {
+			return err
+		}
+		if coupling.ID != 0 {
+			if at.Before(coupling.CoupledAt) {
+				return errors.New("a trailer cannot be decoupled before it was coupled")
+			}
+			coupling.DecoupledAt = &at
+			coupling.DecoupledBy = by
+			if err := tx.Save(&coupling).Error; err != nil {
+				return err
+			}
+		}
+		if err := recordCouplingEvents(tx, trailer, TireDecouple, carID, at, by); err != nil {
+			return err
+		}
+		return tx.Model(&trailer).Update("head_id", nil).Error
+	})
+	return &coupling, err
+}
@@ -0,0 +1,79 @@
+package Models
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestTemplatePositions(t *testing.T) {
+	tanker, _ := FindAxleTemplate("6x4_tanker")
+	positions := TemplatePositions(5, tanker)
+	if len(positions) != 28 {
+		t.Fatalf("6x4 tanker has %d positions, want 28", len(positions))
+	}
+	first, last := positions[2], positions[len(positions)-1]
+	if first.TruckID != 5 || first.PositionType != "head_axle_1" || first.PositionIndex != 1 || first.Side != "left" {
+		t.Errorf("third position = %+v, want the outer left of the first drive axle", first)
+	}
+	if last.PositionType != "spare" || last.PositionIndex != 2 || last.Side != "none" {
+		t.Errorf("last position = %+v, want the second spare", last)
+	}
+
+	head, _ := FindAxleTemplate("6x2_head")
+	positions = TemplatePositions(1, head)
+	if len(positions) != 9 || positions[7].PositionType != "head_axle_2" || positions[7].Side != "right" {
+		t.Errorf("6x2 head positions = %+v, want a single tag axle and a spare", positions)
+	}
+
+	for _, name := range []string{"4x2_head", "3_axle_trailer"} {
+		if _, ok := FindAxleTemplate(name); !ok {
+			t.Errorf("template %s not found", name)
+		}
+	}
+}
+
+func TestTruckValidate(t *testing.T) {
+	carID := uint(4)
+	tests := []struct {
+		name   string
+		truck  Truck
+		layout string
+		ok     bool
+	}{
+		{"legacy unit", Truck{TruckNo: "ABC123"}, "6x4_tanker", true},
+		{"head", Truck{TruckNo: "ABC123", UnitType: TruckUnitHead, CarID: &carID}, "6x4_head", true},
+		{"head without a car", Truck{TruckNo: "ABC123", UnitType: TruckUnitHead}, "", false},
+		{"trailer", Truck{TruckNo: "T-9", UnitType: TruckUnitTrailer, AxleLayout: "2_axle_trailer"}, "2_axle_trailer", true},
+		{"trailer on a head layout", Truck{TruckNo: "T-9", UnitType: TruckUnitTrailer, AxleLayout: "6x4_head"}, "", false},
+		{"unknown type", Truck{TruckNo: "T-9", UnitType: "dolly"}, "", false},
+		{"no number", Truck{UnitType: TruckUnitTrailer}, "", false},
+	}
+	for _, tt := range tests {
+		err := tt.truck.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if tt.ok && tt.truck.AxleLayout != tt.layout {
+			t.Errorf("%s: layout = %s, want %s", tt.name, tt.truck.AxleLayout, tt.layout)
+		}
+	}
+}
+
+func TestRunningCar(t *testing.T) {
+	headCar := uint(7)
+	headID := uint(2)
+	head := Truck{Model: gorm.Model{ID: headID}, UnitType: TruckUnitHead, CarID: &headCar}
+	trailer := Truck{Model: gorm.Model{ID: 3}, UnitType: TruckUnitTrailer, HeadID: &headID}
+
+	if car := head.RunningCar(nil); car == nil || *car != headCar {
+		t.Errorf("head runs on %v, want its own car", car)
+	}
+	if car := trailer.RunningCar(&head); car == nil || *car != headCar {
+		t.Errorf("coupled trailer runs on %v, want the head's car", car)
+	}
+	trailer.HeadID = nil
+	if car := trailer.RunningCar(&head); car != nil {
+		t.Errorf("decoupled trailer runs on %v, want none", *car)
+	}
+}
@@ -0,0 +1,519 @@
+package Models
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Vehicle cost sources, the tables feeding the ledger
+const (
+	CostSourceFuel              = "fuel"
+	CostSourceOilChange         = "oil_change"
+	CostSourceService           = "service"
+	CostSourceServiceInvoice    = "service_invoice"
+	CostSourceTire              = "tire"
+	CostSourceTireRetread       = "tire_retread"
+	CostSourceVendorTransaction = "vendor_transaction"
+	CostSourceWorkOrder         = "work_order"
+	CostSourceStockIssue        = "stock_issue"
+)
+
+// VehicleCostEntry is one cost of a car, rebuilt from the fuel, oil change, service, service
+// invoice, tire, tire event, vendor transaction and work order tables and the stock issued to cars
+type VehicleCostEntry struct {
+	gorm.Model
+	CarID       uint      `json:"car_id" gorm:"index"`
+	CarNoPlate  string    `json:"car_no_plate"`
+	Source      string    `json:"source" gorm:"uniqueIndex:idx_vehicle_cost_source"`
+	SourceID    uint      `json:"source_id" gorm:"uniqueIndex:idx_vehicle_cost_source"`
+	Date        time.Time `json:"date" gorm:"index"`
+	Amount      float64   `json:"amount"`
+	OdometerKm  int       `json:"odometer_km"` // Odometer when the cost was made, 0 when unknown
+	Description string    `json:"description"`
+}
+
+// VehicleCostSummary is the cost of ownership of a car over a period
+type VehicleCostSummary struct {
+	CarID           uint               `json:"car_id"`
+	CarNoPlate      string             `json:"car_no_plate"`
+	Total           float64            `json:"total"`
+	BySource        map[string]float64 `json:"by_source"`
+	Entries         int                `json:"entries"`
+	Kilometers      int                `json:"kilometers"` // Between the lowest and highest odometer readings
+	CostPerKm       *float64           `json:"cost_per_km"`
+	DeliveredLiters int                `json:"delivered_liters"` // Capacity of the car's trips
+	CostPerLiter    *float64           `json:"cost_per_liter"`
+}
+
+// costDateLayouts are the layouts the date strings of the cost tables are written in
+var costDateLayouts = []string{"2006-01-02", "2006-01-02 15:04:05", "2006/01/02", "02-01-2006", "02/01/2006", time.RFC3339}
+
+// ParseCostDate parses the date of a cost record, falling back to when the record was created
+func ParseCostDate(date string, createdAt time.Time) time.Time {
+	date = strings.TrimSpace(date)
+	for _, layout := range costDateLayouts {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			return parsed
+		}
+	}
+	return createdAt
+}
+
+// FuelCostEntry returns the ledger entry of a fuel event
+func FuelCostEntry(event FuelEvent) VehicleCostEntry {
+	return VehicleCostEntry{
+		CarID:       event.CarID,
+		CarNoPlate:  event.CarNoPlate,
+		Source:      CostSourceFuel,
+		SourceID:    event.ID,
+		Date:        ParseCostDate(event.Date, event.CreatedAt),
+		Amount:      event.Price,
+		OdometerKm:  event.OdometerAfter,
+		Description: fmt.Sprintf("%v liters at %v", event.Liters, event.PricePerLiter),
+	}
+}
+
+// OilChangeCostEntry returns the ledger entry of an oil change
+func OilChangeCostEntry(oil OilChange) VehicleCostEntry {
+	return VehicleCostEntry{
+		CarID:       oil.CarID,
+		CarNoPlate:  oil.CarNoPlate,
+		Source:      CostSourceOilChange,
+		SourceID:    oil.ID,
+		Date:        ParseCostDate(oil.Date, oil.CreatedAt),
+		Amount:      oil.Cost,
+		OdometerKm:  int(oil.OdometerAtChange),
+		Description: fmt.Sprintf("Oil change, %v km oil", oil.Mileage),
+	}
+}
+
+// ServiceCostEntry returns the ledger entry of a service
+func ServiceCostEntry(service Service) VehicleCostEntry {
+	return VehicleCostEntry{
+		CarID:       service.CarID,
+		CarNoPlate:  service.CarNoPlate,
+		Source:      CostSourceService,
+		SourceID:    service.ID,
+		Date:        ParseCostDate(service.DateOfService, service.CreatedAt),
+		Amount:      service.Cost,
+		OdometerKm:  service.OdometerReading,
+		Description: service.ServiceType,
+	}
+}
+
+// ServiceInvoiceCostEntry returns the ledger entry of a service invoice, the sum of its
+// inspection items
+func ServiceInvoiceCostEntry(invoice ServiceInvoice) VehicleCostEntry {
+	entry := VehicleCostEntry{
+		CarID:      invoice.CarID,
+		CarNoPlate: invoice.PlateNumber,
+		Source:     CostSourceServiceInvoice,
+		SourceID:   invoice.ID,
+		Date:       invoice.Date,
+		OdometerKm: int(invoice.MeterReading),
+	}
+	services := make([]string, 0, len(invoice.InspectionItems))
+	for _, item := range invoice.InspectionItems {
+		entry.Amount += item.Cost
+		if item.Service != "" {
+			services = append(services, item.Service)
+		}
+	}
+	entry.Description = strings.Join(services, ", ")
+	return entry
+}
+
+// TireCostEntry returns the ledger entry of a tire bought for a car
+func TireCostEntry(tire Tire, car Car) VehicleCostEntry {
+	return VehicleCostEntry{
+		CarID:       car.ID,
+		CarNoPlate:  car.CarNoPlate,
+		Source:      CostSourceTire,
+		SourceID:    tire.ID,
+		Date:        ParseCostDate(tire.PurchaseDate, tire.CreatedAt),
+		Amount:      tire.Cost,
+		Description: strings.TrimSpace(tire.Brand + " " + tire.Size + " " + tire.Serial),
+	}
+}
+
+// TireRetreadCostEntry returns the ledger entry of a tire retreaded for a car
+func TireRetreadCostEntry(event TireEvent, tire Tire, car Car) VehicleCostEntry {
+	return VehicleCostEntry{
+		CarID:       car.ID,
+		CarNoPlate:  car.CarNoPlate,
+		Source:      CostSourceTireRetread,
+		SourceID:    event.ID,
+		Date:        event.Date,
+		Amount:      event.Cost,
+		OdometerKm:  event.OdometerKm,
+		Description: strings.TrimSpace("Retread " + tire.Brand + " " + tire.Size + " " + tire.Serial),
+	}
+}
+
+// VendorCostEntry returns the ledger entry of a purchase from a vendor for a car
+func VendorCostEntry(transaction VendorTransaction, car Car) VehicleCostEntry {
+	return VehicleCostEntry{
+		CarID:       car.ID,
+		CarNoPlate:  car.CarNoPlate,
+		Source:      CostSourceVendorTransaction,
+		SourceID:    transaction.ID,
+		Date:        transaction.Date,
+		Amount:      transaction.Amount,
+		Description: transaction.Description,
+	}
+}
+
+// collectVehicleCosts reads the ledger entries from every cost table
+func collectVehicleCosts(db *gorm.DB) ([]VehicleCostEntry, error) {
+	var cars []Car
+	if err := db.Select("id", "car_no_plate").Find(&cars).Error; err != nil {
+		return nil, err
+	}
+	carsByID := make(map[uint]Car, len(cars))
+	carsByPlate := make(map[string]Car, len(cars))
+	for _, car := range cars {
+		carsByID[car.ID] = car
+		carsByPlate[PlateKey(car.CarNoPlate)] = car
+	}
+
+	var entries []VehicleCostEntry
+
+	// Fuel synced from PetroApp before cars were matched has no car and is matched by plate
+	var fuelEvents []FuelEvent
+	if err := db.Where("price > 0").Find(&fuelEvents).Error; err != nil {
+		return nil, err
+	}
+	for _, event := range fuelEvents {
+		if event.CarID == 0 {
+			car, ok := carsByPlate[PlateKey(event.CarNoPlate)]
+			if !ok {
+				continue
+			}
+			event.CarID = car.ID
+		}
+		entries = append(entries, FuelCostEntry(event))
+	}
+
+	var oilChanges []OilChange
+	if err := db.Where("car_id <> 0 AND cost > 0").Find(&oilChanges).Error; err != nil {
+		return nil, err
+	}
+	for _, oil := range oilChanges {
+		entries = append(entries, OilChangeCostEntry(oil))
+	}
+
+	var services []Service
+	if err := db.Where("car_id <> 0 AND cost > 0").Find(&services).Error; err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		entries = append(entries, ServiceCostEntry(service))
+	}
+
+	var invoices []ServiceInvoice
+	if err := db.Preload("InspectionItems").Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	for _, invoice := range invoices {
+		if entry := ServiceInvoiceCostEntry(invoice); entry.Amount > 0 {
+			entries = append(entries, entry)
+		}
+	}
+
+	// Tires are charged to the first car they ran on, mounted on it or on a trailer it pulled, and
+	// tires without a mount history to the car the unit they are on runs on
+	var tires []Tire
+	if err := db.Find(&tires).Error; err != nil {
+		return nil, err
+	}
+	tiresByID := make(map[uint]Tire, len(tires))
+	for _, tire := range tires {
+		tiresByID[tire.ID] = tire
+	}
+	var tireEvents []TireEvent
+	if err := db.Where("car_id IS NOT NULL AND type IN ?", []string{TireMount, TireCouple, TireRetread}).
+		Order("date ASC, id ASC").Find(&tireEvents).Error; err != nil {
+		return nil, err
+	}
+	charged := make(map[uint]bool)
+	for _, event := range tireEvents {
+		tire, ok := tiresByID[event.TireID]
+		car, found := carsByID[*event.CarID]
+		if !ok || !found {
+			continue
+		}
+		if event.Type == TireRetread && event.Cost > 0 {
+			entries = append(entries, TireRetreadCostEntry(event, tire, car))
+		}
+		if event.Type != TireRetread && !charged[tire.ID] {
+			charged[tire.ID] = true
+			if tire.Cost > 0 {
+				entries = append(entries, TireCostEntry(tire, car))
+			}
+		}
+	}
+	var trucks []Truck
+	if err := db.Find(&trucks).Error; err != nil {
+		return nil, err
+	}
+	trucksByID := make(map[uint]*Truck, len(trucks))
+	for i := range trucks {
+		trucksByID[trucks[i].ID] = &trucks[i]
+	}
+	var positions []TirePosition
+	if err := db.Preload("Tire").Where("tire_id IS NOT NULL").Find(&positions).Error; err != nil {
+		return nil, err
+	}
+	for _, position := range positions {
+		truck, ok := trucksByID[position.TruckID]
+		if position.Tire == nil || !ok || position.Tire.Cost <= 0 || charged[position.Tire.ID] {
+			continue
+		}
+		var head *Truck
+		if truck.HeadID != nil {
+			head = trucksByID[*truck.HeadID]
+		}
+		if carID := truck.RunningCar(head); carID != nil {
+			if car, ok := carsByID[*carID]; ok {
+				entries = append(entries, TireCostEntry(*position.Tire, car))
+			}
+		}
+	}
+
+	// Credits are purchases from the vendor, debits are payments of them
+	var transactions []VendorTransaction
+	if err := db.Where("car_id IS NOT NULL AND amount > 0").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	for _, transaction := range transactions {
+		if car, ok := carsByID[*transaction.CarID]; ok {
+			entries = append(entries, VendorCostEntry(transaction, car))
+		}
+	}
+
+	// Work orders are charged once closed, when their parts and labour are final
+	var orders []WorkOrder
+	if err := db.Where("status = ?", WorkOrderClosed).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if order.TotalCost() > 0 {
+			entries = append(entries, WorkOrderCostEntry(order))
+		}
+	}
+
+	// Stock used on a tire position is charged to its car, stock used on a work order by the order
+	var issues []StockMovement
+	if err := db.Preload("Part").Where("type = ? AND car_id IS NOT NULL AND work_order_id IS NULL", StockIssue).
+		Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		if car, ok := carsByID[*issue.CarID]; ok && issue.Value < 0 {
+			entries = append(entries, StockIssueCostEntry(issue, car))
+		}
+	}
+
+	return entries, nil
+}
+
+// vehicleCostModels are the models whose writes change the ledger
+var vehicleCostModels = []interface{}{
+	&FuelEvent{}, &OilChange{}, &Service{}, &ServiceInvoice{}, &InspectionItem{}, &Tire{}, &TireEvent{},
+	&TirePosition{}, &Truck{}, &VendorTransaction{}, &WorkOrder{}, &WorkOrderPart{}, &StockMovement{},
+}
+
+var (
+	vehicleCostsStale   atomic.Bool // Set by writes to the cost tables since the last rebuild
+	vehicleCostsRebuild sync.Mutex
+)
+
+// TrackVehicleCostWrites registers callbacks marking the ledger stale whenever a cost table is
+// written, so that the next read of the ledger rebuilds it
+func TrackVehicleCostWrites(db *gorm.DB) error {
+	tables := make(map[string]bool, len(vehicleCostModels))
+	for _, model := range vehicleCostModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		tables[stmt.Schema.Table] = true
+	}
+
+	markStale := func(tx *gorm.DB) {
+		if tx.Error == nil && tables[tx.Statement.Table] {
+			vehicleCostsStale.Store(true)
+		}
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("vehicle_costs:create", markStale); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("vehicle_costs:update", markStale); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("vehicle_costs:delete", markStale)
+}
+
+// refreshStaleVehicleCosts rebuilds the ledger if a cost table was written since its last rebuild
+func refreshStaleVehicleCosts(db *gorm.DB) error {
+	if !vehicleCostsStale.Load() {
+		return nil
+	}
+	_, err := RefreshVehicleCosts(db)
+	return err
+}
+
+// RefreshVehicleCosts rebuilds the ledger from the cost tables and returns its size
+func RefreshVehicleCosts(db *gorm.DB) (int, error) {
+	vehicleCostsRebuild.Lock()
+	defer vehicleCostsRebuild.Unlock()
+
+	// Writes made while the ledger is rebuilt mark it stale again
+	stale := vehicleCostsStale.Swap(false)
+	entries, err := collectVehicleCosts(db)
+	if err != nil {
+		if stale {
+			vehicleCostsStale.Store(true)
+		}
+		return 0, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("1 = 1").Delete(&VehicleCostEntry{}).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(entries, 500).Error
+	})
+	if err != nil && stale {
+		vehicleCostsStale.Store(true)
+	}
+	return len(entries), err
+}
+
+// SummarizeVehicleCosts totals the ledger entries per car. Cost per km uses the odometer
+// readings of the entries and cost per liter the capacity delivered by the car's trips.
+func SummarizeVehicleCosts(entries []VehicleCostEntry, delivered map[uint]int) []VehicleCostSummary {
+	summaries := make(map[uint]*VehicleCostSummary)
+	minOdometer := make(map[uint]int)
+	maxOdometer := make(map[uint]int)
+
+	for _, entry := range entries {
+		summary, ok := summaries[entry.CarID]
+		if !ok {
+			summary = &VehicleCostSummary{CarID: entry.CarID, CarNoPlate: entry.CarNoPlate, BySource: map[string]float64{}}
+			summaries[entry.CarID] = summary
+		}
+		if summary.CarNoPlate == "" {
+			summary.CarNoPlate = entry.CarNoPlate
+		}
+		summary.Total += entry.Amount
+		summary.BySource[entry.Source] += entry.Amount
+		summary.Entries++
+
+		if entry.OdometerKm > 0 {
+			if low, ok := minOdometer[entry.CarID]; !ok || entry.OdometerKm < low {
+				minOdometer[entry.CarID] = entry.OdometerKm
+			}
+			if entry.OdometerKm > maxOdometer[entry.CarID] {
+				maxOdometer[entry.CarID] = entry.OdometerKm
+			}
+		}
+	}
+
+	result := make([]VehicleCostSummary, 0, len(summaries))
+	for carID, summary := range summaries {
+		summary.Kilometers = maxOdometer[carID] - minOdometer[carID]
+		if summary.Kilometers > 0 {
+			perKm := summary.Total / float64(summary.Kilometers)
+			summary.CostPerKm = &perKm
+		}
+		summary.DeliveredLiters = delivered[carID]
+		if summary.DeliveredLiters > 0 {
+			perLiter := summary.Total / float64(summary.DeliveredLiters)
+			summary.CostPerLiter = &perLiter
+		}
+		result = append(result, *summary)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].CarID < result[j].CarID
+	})
+	return result
+}
+
+// DeliveredLiters returns the capacity each car delivered on its trips between two days, the
+// range is unbounded where a time is zero
+func DeliveredLiters(db *gorm.DB, carID uint, from, to time.Time) (map[uint]int, error) {
+	query := db.Model(&TripStruct{}).Select("car_id, SUM(capacity) AS liters").Group("car_id")
+	if carID != 0 {
+		query = query.Where("car_id = ?", carID)
+	}
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from.Format("2006-01-02"))
+	}
+	if !to.IsZero() {
+		query = query.Where("date < ?", to.Format("2006-01-02"))
+	}
+
+	var rows []struct {
+		CarID  uint
+		Liters int
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	delivered := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		delivered[row.CarID] = row.Liters
+	}
+	return delivered, nil
+}
+
+// VehicleCosts returns the ledger entries of a car, or of every car when carID is 0, between
+// two times, rebuilding the ledger first if costs were written since. The range is unbounded
+// where a time is zero.
+func VehicleCosts(db *gorm.DB, carID uint, from, to time.Time) *gorm.DB {
+	if err := refreshStaleVehicleCosts(db); err != nil {
+		log.Printf("Error refreshing vehicle costs: %v", err)
+	}
+
+	query := db.Model(&VehicleCostEntry{})
+	if carID != 0 {
+		query = query.Where("car_id = ?", carID)
+	}
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("date < ?", to)
+	}
+	return query
+}
+
+// VehicleCostSummaries returns the cost of ownership of a car, or of every car when carID is
+// 0, between two times
+func VehicleCostSummaries(db *gorm.DB, carID uint, from, to time.Time) ([]VehicleCostSummary, error) {
+	var entries []VehicleCostEntry
+	if err := VehicleCosts(db, carID, from, to).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	delivered, err := DeliveredLiters(db, carID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return SummarizeVehicleCosts(entries, delivered), nil
+}
@@ -0,0 +1,106 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCostDate(t *testing.T) {
+	createdAt := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		date string
+		want time.Time
+	}{
+		{"2025-03-22", time.Date(2025, 3, 22, 0, 0, 0, 0, time.UTC)},
+		{"22-03-2025", time.Date(2025, 3, 22, 0, 0, 0, 0, time.UTC)},
+		{"2025/03/22", time.Date(2025, 3, 22, 0, 0, 0, 0, time.UTC)},
+		{"", createdAt},
+		{"yesterday", createdAt},
+	}
+
+	for _, tt := range tests {
+		if got := ParseCostDate(tt.date, createdAt); !got.Equal(tt.want) {
+			t.Errorf("ParseCostDate(%q) = %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestServiceInvoiceCostEntry(t *testing.T) {
+	invoice := ServiceInvoice{
+		CarID:        3,
+		PlateNumber:  "ABC 123",
+		MeterReading: 120500,
+		InspectionItems: []InspectionItem{
+			{Service: "Brakes", Cost: 1500},
+			{Notes: "Checked", Cost: 0},
+			{Service: "Filters", Cost: 350},
+		},
+	}
+
+	entry := ServiceInvoiceCostEntry(invoice)
+	if entry.Amount != 1850 || entry.OdometerKm != 120500 || entry.Description != "Brakes, Filters" {
+		t.Errorf("entry = %+v", entry)
+	}
+}
+
+func TestSummarizeVehicleCosts(t *testing.T) {
+	entries := []VehicleCostEntry{
+		{CarID: 1, Source: CostSourceFuel, Amount: 2000, OdometerKm: 10000},
+		{CarID: 1, Source: CostSourceFuel, Amount: 2000, OdometerKm: 11000},
+		{CarID: 1, Source: CostSourceService, Amount: 1000, OdometerKm: 10500},
+		{CarID: 1, Source: CostSourceTire, Amount: 3000},
+		{CarID: 2, Source: CostSourceOilChange, Amount: 500, OdometerKm: 40000},
+	}
+
+	summaries := SummarizeVehicleCosts(entries, map[uint]int{1: 80000})
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+
+	first := summaries[0]
+	if first.CarID != 1 || first.Total != 8000 || first.Entries != 4 || first.BySource[CostSourceFuel] != 4000 {
+		t.Errorf("first = %+v", first)
+	}
+	if first.Kilometers != 1000 || first.CostPerKm == nil || *first.CostPerKm != 8 {
+		t.Errorf("kilometers = %d, cost per km = %v, want 1000 and 8", first.Kilometers, first.CostPerKm)
+	}
+	if first.CostPerLiter == nil || *first.CostPerLiter != 0.1 {
+		t.Errorf("cost per liter = %v, want 0.1", first.CostPerLiter)
+	}
+
+	// A single odometer reading and no trips give no rates
+	second := summaries[1]
+	if second.Kilometers != 0 || second.CostPerKm != nil || second.CostPerLiter != nil {
+		t.Errorf("second = %+v", second)
+	}
+}
+
+func TestVehicleCostLedger(t *testing.T) {
+	db := openTestDB(t, &Car{}, &Driver{}, &VehicleCostEntry{}, &Part{}, &StockLocation{})
+	if err := db.AutoMigrate(vehicleCostModels...); err != nil {
+		t.Fatal(err)
+	}
+	if err := TrackVehicleCostWrites(db); err != nil {
+		t.Fatal(err)
+	}
+
+	car := Car{CarNoPlate: "ف ع ص 4381"}
+	db.Create(&car)
+	// Synced before the car was matched
+	db.Create(&FuelEvent{CarNoPlate: "فعص4381", Date: "2025-03-01", Liters: 100, Price: 1500})
+	if _, err := RefreshVehicleCosts(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Written after the rebuild, read without waiting for the next one
+	db.Create(&FuelEvent{CarID: car.ID, CarNoPlate: car.CarNoPlate, Date: "2025-03-02", Liters: 50, Price: 750})
+
+	var entries []VehicleCostEntry
+	if err := VehicleCosts(db, car.ID, time.Time{}, time.Time{}).Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d ledger entries of the car, want 2", len(entries))
+	}
+}
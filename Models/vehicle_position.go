@@ -0,0 +1,130 @@
+package Models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VehiclePosition is one GPS fix of a car as reported by the telematics poll.
+// RecordedAt is the provider's fix time, stored without a zone like Car.LocationTimeStamp.
+type VehiclePosition struct {
+	gorm.Model
+	CarID        uint      `json:"car_id" gorm:"uniqueIndex:idx_vehicle_position_fix"`
+	CarNoPlate   string    `json:"car_no_plate"`
+	VehicleID    string    `json:"vehicle_id"` // Telematics unit ID
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	Speed        int       `json:"speed"`
+	EngineStatus string    `json:"engine_status"`
+	Location     string    `json:"location"`
+	RecordedAt   time.Time `json:"recorded_at" gorm:"uniqueIndex:idx_vehicle_position_fix;index"`
+	Downsampled  bool      `json:"downsampled" gorm:"index"` // Kept as the representative of its interval
+}
+
+// Position retention: every fix is kept for PositionRawRetention, then one fix per car and
+// PositionDownsampleInterval is kept until PositionRetention
+const (
+	PositionRawRetention       = 7 * 24 * time.Hour
+	PositionDownsampleInterval = 15 * time.Minute
+	PositionRetention          = 180 * 24 * time.Hour
+)
+
+// PositionTimeLayout is the layout of position times in requests and on Car.LocationTimeStamp
+const PositionTimeLayout = "2006-01-02 15:04:05"
+
+// RecordVehiclePositions stores the fixes of a poll, skipping those already recorded
+func RecordVehiclePositions(db *gorm.DB, positions []VehiclePosition) error {
+	if len(positions) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&positions).Error
+}
+
+// VehicleTrack returns the fixes of a car between two times, oldest first
+func VehicleTrack(db *gorm.DB, carID uint, from, to time.Time) ([]VehiclePosition, error) {
+	var positions []VehiclePosition
+	err := db.Where("car_id = ? AND recorded_at BETWEEN ? AND ?", carID, from, to).
+		Order("recorded_at ASC").
+		Find(&positions).Error
+	return positions, err
+}
+
+// FleetSnapshot returns the last fix of every car at the given instant. Cars whose last
+// fix is older than maxAge are left out.
+func FleetSnapshot(db *gorm.DB, at time.Time, maxAge time.Duration) ([]VehiclePosition, error) {
+	latest := db.Model(&VehiclePosition{}).
+		Select("car_id, MAX(recorded_at) AS recorded_at").
+		Where("recorded_at BETWEEN ? AND ?", at.Add(-maxAge), at).
+		Group("car_id")
+
+	var positions []VehiclePosition
+	err := db.Joins("JOIN (?) AS latest ON latest.car_id = vehicle_positions.car_id AND latest.recorded_at = vehicle_positions.recorded_at", latest).
+		Order("vehicle_positions.car_no_plate ASC").
+		Find(&positions).Error
+	return positions, err
+}
+
+// PruneVehiclePositions applies the retention policy: fixes older than PositionRetention are
+// deleted and those older than PositionRawRetention are downsampled. It returns the number
+// of fixes removed.
+func PruneVehiclePositions(db *gorm.DB, now time.Time) (int64, error) {
+	expired := db.Unscoped().Where("recorded_at < ?", now.Add(-PositionRetention)).Delete(&VehiclePosition{})
+	if expired.Error != nil {
+		return 0, expired.Error
+	}
+	removed := expired.RowsAffected
+
+	// Whole intervals only, so an interval is never downsampled twice
+	cutoff := now.Add(-PositionRawRetention).Truncate(PositionDownsampleInterval)
+
+	var carIDs []uint
+	if err := db.Model(&VehiclePosition{}).
+		Where("recorded_at < ? AND downsampled = ?", cutoff, false).
+		Distinct().Pluck("car_id", &carIDs).Error; err != nil {
+		return removed, err
+	}
+
+	for _, carID := range carIDs {
+		var positions []VehiclePosition
+		if err := db.Where("car_id = ? AND recorded_at < ? AND downsampled = ?", carID, cutoff, false).
+			Order("recorded_at ASC").
+			Find(&positions).Error; err != nil {
+			return removed, err
+		}
+
+		keep, drop := downsamplePositions(positions, PositionDownsampleInterval)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&VehiclePosition{}).Where("id IN ?", keep).Update("downsampled", true).Error; err != nil {
+				return err
+			}
+			if len(drop) > 0 {
+				return tx.Unscoped().Where("id IN ?", drop).Delete(&VehiclePosition{}).Error
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+		removed += int64(len(drop))
+	}
+
+	return removed, nil
+}
+
+// downsamplePositions keeps the first fix of every interval. Positions must be of a single
+// car and ordered by time.
+func downsamplePositions(positions []VehiclePosition, interval time.Duration) (keep, drop []uint) {
+	var bucket time.Time
+	for i, position := range positions {
+		start := position.RecordedAt.Truncate(interval)
+		if i == 0 || !start.Equal(bucket) {
+			bucket = start
+			keep = append(keep, position.ID)
+		} else {
+			drop = append(drop, position.ID)
+		}
+	}
+	return keep, drop
+}
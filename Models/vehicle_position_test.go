@@ -0,0 +1,37 @@
+package Models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestDownsamplePositions(t *testing.T) {
+	at := func(id uint, clock string) VehiclePosition {
+		recorded, _ := time.Parse(PositionTimeLayout, "2025-07-10 "+clock)
+		return VehiclePosition{Model: gorm.Model{ID: id}, RecordedAt: recorded}
+	}
+
+	positions := []VehiclePosition{
+		at(1, "08:00:00"),
+		at(2, "08:05:00"),
+		at(3, "08:14:59"),
+		at(4, "08:15:00"),
+		at(5, "08:40:00"),
+		at(6, "08:44:00"),
+	}
+
+	keep, drop := downsamplePositions(positions, 15*time.Minute)
+	if !reflect.DeepEqual(keep, []uint{1, 4, 5}) {
+		t.Errorf("kept %v, wanted [1 4 5]", keep)
+	}
+	if !reflect.DeepEqual(drop, []uint{2, 3, 6}) {
+		t.Errorf("dropped %v, wanted [2 3 6]", drop)
+	}
+
+	if keep, drop := downsamplePositions(nil, 15*time.Minute); keep != nil || drop != nil {
+		t.Errorf("got %v %v for no positions", keep, drop)
+	}
+}
@@ -10,6 +10,7 @@ type Vendor struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	Name      string         `json:"name" gorm:"not null;uniqueIndex"` // Added uniqueIndex constraint
 	Contact   string         `json:"contact"`
+	TaxID     string         `json:"tax_id" gorm:"index"` // ETA registration number, used to reconcile received e-invoices
 	Notes     string         `json:"notes"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -23,6 +24,7 @@ type Vendor struct {
 type VendorTransaction struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	VendorID    uint           `json:"vendor_id" gorm:"not null;index"`
+	CarID       *uint          `json:"car_id" gorm:"index"`        // Car a purchase was for, nil for general expenses
 	Date        time.Time      `json:"date" gorm:"not null;index"` // Added index for date-based queries
 	Description string         `json:"description" gorm:"not null"`
 	Amount      float64        `json:"amount" gorm:"not null"` // Positive for credit (vendor provided), negative for debit (payment to vendor)
@@ -0,0 +1,289 @@
+package Models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Work order statuses
+const (
+	WorkOrderOpen       = "open"
+	WorkOrderInProgress = "in_progress"
+	WorkOrderClosed     = "closed"
+)
+
+// MaintenanceSlackStatus is the fleet status of a car with an open work order
+const MaintenanceSlackStatus = "Stopped for Maintenance"
+
+// WorkOrder is a car taken off the road for repair, from opening until it is back in service.
+// Its parts and labour are the repair cost, its service invoice the checklist of the visit.
+type WorkOrder struct {
+	gorm.Model
+	CarID               uint            `json:"car_id" gorm:"index"`
+	CarNoPlate          string          `json:"car_no_plate"`
+	ServiceInvoiceID    *uint           `json:"service_invoice_id" gorm:"index"`
+	Status              string          `json:"status" gorm:"index"`
+	Description         string          `json:"description"`
+	OdometerKm          int             `json:"odometer_km"`
+	OpenedAt            time.Time       `json:"opened_at"`
+	StartedAt           *time.Time      `json:"started_at"`
+	ClosedAt            *time.Time      `json:"closed_at"`
+	LabourHours         float64         `json:"labour_hours"`
+	LabourCost          float64         `json:"labour_cost"`
+	PartsCost           float64         `json:"parts_cost"` // Sum of the parts, kept by SetParts
+	OpenedBy            string          `json:"opened_by"`
+	ClosedBy            string          `json:"closed_by"`
+	PreviousSlackStatus string          `json:"previous_slack_status"` // Restored when the order closes
+	Parts               []WorkOrderPart `json:"parts,omitempty" gorm:"foreignKey:WorkOrderID;constraint:OnDelete:CASCADE"`
+
+	ServiceInvoice *ServiceInvoice `json:"service_invoice,omitempty" gorm:"foreignKey:ServiceInvoiceID"`
+}
+
+// WorkOrderPart is a part used on a work order
+type WorkOrderPart struct {
+	gorm.Model
+	WorkOrderID uint    `json:"work_order_id" gorm:"index"`
+	Name        string  `json:"name"`
+	PartNumber  string  `json:"part_number"`
+	VendorID    *uint   `json:"vendor_id" gorm:"index"`
+	VendorName  string  `json:"vendor_name"`
+	Quantity    float64 `json:"quantity"`
+	UnitCost    float64 `json:"unit_cost"`
+	Cost        float64 `json:"cost"` // Quantity times unit cost
+
+	StockMovementID *uint `json:"stock_movement_id"` // Set when the part was issued from stock
+}
+
+// WorkOrderDowntime is how long a car was off the road for repair over a period
+type WorkOrderDowntime struct {
+	CarID         uint    `json:"car_id"`
+	CarNoPlate    string  `json:"car_no_plate"`
+	WorkOrders    int     `json:"work_orders"`
+	DowntimeHours float64 `json:"downtime_hours"`
+	RepairCost    float64 `json:"repair_cost"`
+}
+
+// TotalCost returns the parts and labour cost of the order
+func (w *WorkOrder) TotalCost() float64 {
+	return w.PartsCost + w.LabourCost
+}
+
+// IsActive tells whether the car is still off the road
+func (w *WorkOrder) IsActive() bool {
+	return w.Status == WorkOrderOpen || w.Status == WorkOrderInProgress
+}
+
+// SetParts replaces the parts bought for the order, pricing each and totalling the parts cost.
+// Parts issued from stock stay on the order, as the stock they took is gone.
+func (w *WorkOrder) SetParts(parts []WorkOrderPart) error {
+	var kept []WorkOrderPart
+	w.PartsCost = 0
+	for _, part := range w.Parts {
+		if part.StockMovementID != nil {
+			kept = append(kept, part)
+			w.PartsCost += part.Cost
+		}
+	}
+
+	bought := make([]WorkOrderPart, 0, len(parts))
+	for _, part := range parts {
+		if part.StockMovementID == nil {
+			bought = append(bought, part)
+		}
+	}
+	parts = bought
+	for i := range parts {
+		part := &parts[i]
+		part.Name = strings.TrimSpace(part.Name)
+		if part.Name == "" {
+			return fmt.Errorf("part %d: name is required", i+1)
+		}
+		if part.Quantity == 0 {
+			part.Quantity = 1
+		}
+		if part.Quantity < 0 || part.UnitCost < 0 {
+			return fmt.Errorf("part %d: quantity and unit cost must not be negative", i+1)
+		}
+		part.Cost = math.Round(part.Quantity*part.UnitCost*100) / 100
+		w.PartsCost += part.Cost
+	}
+	w.Parts = append(kept, parts...)
+	return nil
+}
+
+// Transition moves the order to a status. Orders go from open to in progress to closed, or
+// straight from open to closed, and a closed order stays closed.
+func (w *WorkOrder) Transition(status string, at time.Time) error {
+	switch {
+	case status == WorkOrderInProgress && w.Status == WorkOrderOpen:
+		w.StartedAt = &at
+	case status == WorkOrderClosed && w.IsActive():
+		if at.Before(w.OpenedAt) {
+			return errors.New("a work order cannot close before it opened")
+		}
+		if w.StartedAt == nil {
+			w.StartedAt = &w.OpenedAt
+		}
+		w.ClosedAt = &at
+	default:
+		return fmt.Errorf("cannot move a work order from %s to %s", w.Status, status)
+	}
+	w.Status = status
+	return nil
+}
+
+// DowntimeHours returns the hours the order kept the car off the road within [from, to),
+// counting an order still open until now. A zero bound leaves that side open.
+func (w *WorkOrder) DowntimeHours(from, to, now time.Time) float64 {
+	start, end := w.OpenedAt, now
+	if w.ClosedAt != nil {
+		end = *w.ClosedAt
+	}
+	if !from.IsZero() && start.Before(from) {
+		start = from
+	}
+	if !to.IsZero() && end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Hours()
+}
+
+// SummarizeDowntime totals the downtime and repair cost of the orders per car, longest
+// downtime first
+func SummarizeDowntime(orders []WorkOrder, from, to, now time.Time) []WorkOrderDowntime {
+	byCar := make(map[uint]*WorkOrderDowntime)
+	for i := range orders {
+		order := &orders[i]
+		hours := order.DowntimeHours(from, to, now)
+		if hours == 0 {
+			continue
+		}
+		downtime, ok := byCar[order.CarID]
+		if !ok {
+			downtime = &WorkOrderDowntime{CarID: order.CarID, CarNoPlate: order.CarNoPlate}
+			byCar[order.CarID] = downtime
+		}
+		downtime.WorkOrders++
+		downtime.DowntimeHours += hours
+		downtime.RepairCost += order.TotalCost()
+	}
+
+	summaries := make([]WorkOrderDowntime, 0, len(byCar))
+	for _, downtime := range byCar {
+		downtime.DowntimeHours = math.Round(downtime.DowntimeHours*100) / 100
+		summaries = append(summaries, *downtime)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].DowntimeHours != summaries[j].DowntimeHours {
+			return summaries[i].DowntimeHours > summaries[j].DowntimeHours
+		}
+		return summaries[i].CarNoPlate < summaries[j].CarNoPlate
+	})
+	return summaries
+}
+
+// WorkOrderCostEntry returns the ledger entry of a closed work order, its parts and labour.
+// The inspection items of its service invoice are charged by the invoice's own entry.
+func WorkOrderCostEntry(order WorkOrder) VehicleCostEntry {
+	date := order.OpenedAt
+	if order.ClosedAt != nil {
+		date = *order.ClosedAt
+	}
+	return VehicleCostEntry{
+		CarID:       order.CarID,
+		CarNoPlate:  order.CarNoPlate,
+		Source:      CostSourceWorkOrder,
+		SourceID:    order.ID,
+		Date:        date,
+		Amount:      order.TotalCost(),
+		OdometerKm:  order.OdometerKm,
+		Description: order.Description,
+	}
+}
+
+// OpenWorkOrder creates a work order and stops the car for maintenance. A car has at most one
+// active order.
+func OpenWorkOrder(db *gorm.DB, order *WorkOrder) (*Car, error) {
+	var car Car
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&car, order.CarID).Error; err != nil {
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&WorkOrder{}).Where("car_id = ? AND status IN ?", car.ID,
+			[]string{WorkOrderOpen, WorkOrderInProgress}).Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return fmt.Errorf("%s already has an active work order", car.CarNoPlate)
+		}
+
+		order.CarNoPlate = car.CarNoPlate
+		order.Status = WorkOrderOpen
+		order.PreviousSlackStatus = car.SlackStatus
+		if order.OpenedAt.IsZero() {
+			order.OpenedAt = time.Now()
+		}
+		if order.OdometerKm == 0 {
+			order.OdometerKm = car.LastFuelOdometer
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&car).Updates(map[string]interface{}{
+			"slack_status":              MaintenanceSlackStatus,
+			"last_updated_slack_status": order.OpenedAt,
+		}).Error
+	})
+	return &car, err
+}
+
+// CloseWorkOrder closes a work order and puts the car back to the status it had before
+func CloseWorkOrder(db *gorm.DB, order *WorkOrder, at time.Time, closedBy string) (*Car, error) {
+	var car Car
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := order.Transition(WorkOrderClosed, at); err != nil {
+			return err
+		}
+		order.ClosedBy = closedBy
+		if err := tx.Omit("Parts", "ServiceInvoice").Save(order).Error; err != nil {
+			return err
+		}
+
+		if err := tx.First(&car, order.CarID).Error; err != nil {
+			return err
+		}
+		// A status set by hand or geofence since is kept
+		if car.SlackStatus != MaintenanceSlackStatus {
+			return nil
+		}
+		status := order.PreviousSlackStatus
+		if status == MaintenanceSlackStatus {
+			status = ""
+		}
+		return tx.Model(&car).Updates(map[string]interface{}{
+			"slack_status":              status,
+			"last_updated_slack_status": at,
+		}).Error
+	})
+	return &car, err
+}
+
+// HasActiveWorkOrder tells whether a car is off the road on a work order
+func HasActiveWorkOrder(db *gorm.DB, carID uint) bool {
+	var active int64
+	db.Model(&WorkOrder{}).Where("car_id = ? AND status IN ?", carID,
+		[]string{WorkOrderOpen, WorkOrderInProgress}).Count(&active)
+	return active > 0
+}
@@ -0,0 +1,134 @@
+package Models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorkOrderSetParts(t *testing.T) {
+	order := WorkOrder{LabourCost: 300}
+	err := order.SetParts([]WorkOrderPart{
+		{Name: " Brake pads ", Quantity: 2, UnitCost: 450.5},
+		{Name: "Oil filter", UnitCost: 120},
+	})
+	if err != nil {
+		t.Fatalf("SetParts: %v", err)
+	}
+	if order.Parts[0].Name != "Brake pads" || order.Parts[0].Cost != 901 {
+		t.Errorf("first part = %q at %v, want Brake pads at 901", order.Parts[0].Name, order.Parts[0].Cost)
+	}
+	if order.Parts[1].Quantity != 1 || order.Parts[1].Cost != 120 {
+		t.Errorf("second part = %v x at %v, want 1 at 120", order.Parts[1].Quantity, order.Parts[1].Cost)
+	}
+	if order.PartsCost != 1021 || order.TotalCost() != 1321 {
+		t.Errorf("parts cost = %v, total = %v, want 1021 and 1321", order.PartsCost, order.TotalCost())
+	}
+
+	if err := order.SetParts([]WorkOrderPart{{Name: ""}}); err == nil {
+		t.Error("expected an error for a part without a name")
+	}
+	if err := order.SetParts([]WorkOrderPart{{Name: "Bolt", Quantity: -1}}); err == nil {
+		t.Error("expected an error for a negative quantity")
+	}
+}
+
+func TestWorkOrderTransition(t *testing.T) {
+	opened := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	order := WorkOrder{Status: WorkOrderOpen, OpenedAt: opened}
+	if err := order.Transition(WorkOrderInProgress, opened.Add(2*time.Hour)); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := order.Transition(WorkOrderInProgress, opened.Add(3*time.Hour)); err == nil {
+		t.Error("expected an error starting an order in progress")
+	}
+	if err := order.Transition(WorkOrderClosed, opened.Add(-time.Hour)); err == nil {
+		t.Error("expected an error closing before opening")
+	}
+	if err := order.Transition(WorkOrderClosed, opened.Add(26*time.Hour)); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if order.Status != WorkOrderClosed || !order.StartedAt.Equal(opened.Add(2*time.Hour)) {
+		t.Errorf("closed order = %s started %v", order.Status, order.StartedAt)
+	}
+	if err := order.Transition(WorkOrderOpen, opened); err == nil {
+		t.Error("expected an error reopening a closed order")
+	}
+
+	// Closed straight from open, the repair starts when the order opened
+	direct := WorkOrder{Status: WorkOrderOpen, OpenedAt: opened}
+	if err := direct.Transition(WorkOrderClosed, opened.Add(time.Hour)); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !direct.StartedAt.Equal(opened) {
+		t.Errorf("started at = %v, want %v", direct.StartedAt, opened)
+	}
+}
+
+func TestWorkOrderDowntimeHours(t *testing.T) {
+	opened := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+	closed := time.Date(2025, 5, 3, 20, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 5, 10, 8, 0, 0, 0, time.UTC)
+	may2 := time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC)
+	may3 := time.Date(2025, 5, 3, 0, 0, 0, 0, time.UTC)
+
+	closedOrder := WorkOrder{OpenedAt: opened, ClosedAt: &closed}
+	openOrder := WorkOrder{OpenedAt: opened}
+
+	tests := []struct {
+		name     string
+		order    WorkOrder
+		from, to time.Time
+		want     float64
+	}{
+		{"closed, open range", closedOrder, time.Time{}, time.Time{}, 60},
+		{"closed, one day of it", closedOrder, may2, may3, 24},
+		{"closed, range after it", closedOrder, now, time.Time{}, 0},
+		{"still open", openOrder, time.Time{}, time.Time{}, 216},
+		{"still open, from a day", openOrder, may3, time.Time{}, 176},
+	}
+
+	for _, tt := range tests {
+		if got := tt.order.DowntimeHours(tt.from, tt.to, now); got != tt.want {
+			t.Errorf("%s: downtime = %v hours, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeDowntime(t *testing.T) {
+	opened := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+	closedA := opened.Add(10 * time.Hour)
+	closedB := opened.Add(55 * time.Hour)
+	now := opened.Add(100 * time.Hour)
+
+	orders := []WorkOrder{
+		{CarID: 1, CarNoPlate: "AAA", OpenedAt: opened, ClosedAt: &closedA, PartsCost: 500, LabourCost: 100},
+		{CarID: 1, CarNoPlate: "AAA", OpenedAt: opened.Add(50 * time.Hour), ClosedAt: &closedB, PartsCost: 50},
+		{CarID: 2, CarNoPlate: "BBB", OpenedAt: opened.Add(80 * time.Hour), LabourCost: 200},
+	}
+	summaries := SummarizeDowntime(orders, time.Time{}, time.Time{}, now)
+
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+	if summaries[0].CarID != 2 || summaries[0].DowntimeHours != 20 || summaries[0].RepairCost != 200 {
+		t.Errorf("first = %+v, want car 2 with 20 hours and 200", summaries[0])
+	}
+	if summaries[1].CarID != 1 || summaries[1].WorkOrders != 2 || summaries[1].DowntimeHours != 15 || summaries[1].RepairCost != 650 {
+		t.Errorf("second = %+v, want car 1 with two orders of 15 hours and 650", summaries[1])
+	}
+}
+
+func TestWorkOrderCostEntry(t *testing.T) {
+	opened := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+	closed := opened.Add(48 * time.Hour)
+	order := WorkOrder{CarID: 4, CarNoPlate: "ABC", OpenedAt: opened, ClosedAt: &closed,
+		PartsCost: 800, LabourCost: 250, OdometerKm: 152000, Description: "Clutch"}
+	order.ID = 9
+
+	entry := WorkOrderCostEntry(order)
+	if entry.Source != CostSourceWorkOrder || entry.SourceID != 9 || entry.Amount != 1050 ||
+		!entry.Date.Equal(closed) || entry.OdometerKm != 152000 {
+		t.Errorf("entry = %+v", entry)
+	}
+}
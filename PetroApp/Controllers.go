@@ -50,7 +50,7 @@ func GetClosestStations(c *fiber.Ctx) error {
 	// We get more than 10 because road distances might change the ranking
 	output := make([]StationWithDistance, 0, len(stations))
 	for _, station := range stations {
-		distance := CalculateDistance(input.Lat, input.Lng, station.Lat, station.Lng)
+		distance := Models.DistanceKm(input.Lat, input.Lng, station.Lat, station.Lng)
 		stationWithDistance := StationWithDistance{
 			Station:  station,
 			Distance: distance,
@@ -145,3 +145,35 @@ func getOSRMRouteData(originLat, originLng, destLat, destLng float64) (float64,
 	route := osrmResp.Routes[0]
 	return route.Distance / 1000, route.Duration / 60, nil
 }
+
+// StartBackfill fetches and syncs the PetroApp records between two days in the background
+func StartBackfill(c *fiber.Ctx) error {
+	var input struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	from, to, err := parseBackfillRange(input.StartDate, input.EndDate)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid date range",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := StartBackfillAsync(from, to); err != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"message": fmt.Sprintf("Backfill from %s to %s started", input.StartDate, input.EndDate),
+	})
+}
@@ -17,51 +17,6 @@ import (
 	"gorm.io/gorm"
 )
 
-// Plate number conversion map - maps PetroApp format to Arabic format
-type PlateNumberObj struct {
-	PlateNumberDB     string `json:"plate_number_db"`
-	PetroAppVehicleID uint   `json:"petro_app_vehicle_id"`
-}
-
-var PlateNumberMap = map[string]PlateNumberObj{
-	"C E F-4 3 8 1": PlateNumberObj{
-		PlateNumberDB:     "ف ع ص 4381",
-		PetroAppVehicleID: 53008,
-	},
-	"C Q F-4 2 5 3": PlateNumberObj{
-		PlateNumberDB:     "ف ق ص 4253",
-		PetroAppVehicleID: 53005,
-	},
-	"R Y F-9 1 5 6": PlateNumberObj{
-		PlateNumberDB:     "ف ى ر 9156",
-		PetroAppVehicleID: 53004,
-	},
-	"N A F-5 1 3 9": PlateNumberObj{
-		PlateNumberDB:     "ف أ ن 5139",
-		PetroAppVehicleID: 53007,
-	},
-	"S M F-9 2 4 7": PlateNumberObj{
-		PlateNumberDB:     "ف م س 9247",
-		PetroAppVehicleID: 53010,
-	},
-	"S R F-4 5 9 3": PlateNumberObj{
-		PlateNumberDB:     "ف ر س 4593",
-		PetroAppVehicleID: 53012,
-	},
-	"Y D F-6 5 8 4": PlateNumberObj{
-		PlateNumberDB:     "ف د ى 6584",
-		PetroAppVehicleID: 53006,
-	},
-	"Y D F-6 8 3 4": PlateNumberObj{
-		PlateNumberDB:     "ف د ى 6834",
-		PetroAppVehicleID: 53011,
-	},
-	"N A F-7 4 2 1": PlateNumberObj{
-		PlateNumberDB:     "ف ا ن 7421",
-		PetroAppVehicleID: 53009,
-	},
-}
-
 // API response structure
 type PetroAppAPIResponse struct {
 	Data   []Models.PetroAppRecord `json:"data"`
@@ -73,117 +28,6 @@ type PetroAppAPIResponse struct {
 	} `json:"meta"`
 }
 
-// FetchPetroAppRecords fetches records from PetroApp API for the last day
-func FetchPetroAppRecords() ([]Models.PetroAppRecord, error) {
-	log.Println("Starting PetroApp records fetch operation")
-
-	// Get date range: last day to current day
-	now := time.Now()
-	startDate := now.AddDate(0, 0, -1).Format("2006/01/02")
-	endDate := now.Format("2006/01/02")
-
-	params := fmt.Sprintf("?dates=%s-%s&limit=1000&page=1", startDate, endDate)
-	url := baseUrl + "/bills" + params
-
-	log.Printf("Fetching records from %s to %s", startDate, endDate)
-	log.Printf("Request URL: %s", url)
-
-	// Create HTTP request with shorter timeout to prevent hangs
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Printf("Error creating HTTP request: %v", err)
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set required headers - validate they're not empty
-	if token == "" {
-		return nil, fmt.Errorf("authorization token is not set")
-	}
-	if cookie == "" {
-		log.Println("Warning: cookie is empty, this might cause authentication issues")
-	}
-
-	req.Header.Set("Authorization", token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Cookie", cookie)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "Falcon-PetroApp-Sync/1.0")
-
-	// Reduced timeout to prevent hanging - fail fast
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error executing HTTP request: %v", err)
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			log.Printf("Error closing response body: %v", closeErr)
-		}
-	}()
-
-	log.Printf("PetroApp API response status: %d %s", resp.StatusCode, resp.Status)
-
-	// Handle different HTTP status codes
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// Continue processing
-	case http.StatusUnauthorized:
-		return nil, fmt.Errorf("authentication failed - check token and cookie")
-	case http.StatusForbidden:
-		return nil, fmt.Errorf("access forbidden - insufficient permissions")
-	case http.StatusTooManyRequests:
-		return nil, fmt.Errorf("rate limit exceeded - try again later")
-	default:
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, resp.Status)
-	}
-
-	// Decode response
-	decoder := json.NewDecoder(resp.Body)
-	var result PetroAppAPIResponse
-
-	if err := decoder.Decode(&result); err != nil {
-		log.Printf("Error decoding API response: %v", err)
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	// Validate response structure
-	if !result.Status {
-		log.Println("API returned status: false")
-		return nil, fmt.Errorf("API returned unsuccessful status")
-	}
-
-	if result.Data == nil {
-		log.Println("API returned nil data array")
-		return []Models.PetroAppRecord{}, nil
-	}
-
-	log.Printf("Successfully fetched %d records from PetroApp API", len(result.Data))
-
-	// Validate each record before storing
-	validRecords := make([]Models.PetroAppRecord, 0, len(result.Data))
-	for _, record := range result.Data {
-		if err := validatePetroAppRecord(record); err != nil {
-			log.Printf("Skipping invalid record ID %d: %v", record.ID, err)
-			continue
-		}
-		validRecords = append(validRecords, record)
-	}
-
-	log.Printf("Validated %d out of %d records", len(validRecords), len(result.Data))
-
-	// Store the validated records
-	if err := StoreUniquePetroAppRecords(validRecords); err != nil {
-		log.Printf("Error storing records: %v", err)
-		return nil, fmt.Errorf("failed to store records: %w", err)
-	}
-
-	return validRecords, nil
-}
-
 // validatePetroAppRecord validates a PetroApp record before processing
 func validatePetroAppRecord(record Models.PetroAppRecord) error {
 	if record.ID <= 0 {
@@ -227,11 +71,12 @@ func validatePetroAppRecord(record Models.PetroAppRecord) error {
 	return nil
 }
 
-// StoreUniquePetroAppRecords stores PetroApp records, avoiding duplicates
-func StoreUniquePetroAppRecords(records []Models.PetroAppRecord) error {
+// StoreUniquePetroAppRecords stores PetroApp records, avoiding duplicates. It returns the
+// number of records stored and skipped as already stored.
+func StoreUniquePetroAppRecords(records []Models.PetroAppRecord) (int, int, error) {
 	if len(records) == 0 {
 		log.Println("No records to store")
-		return nil
+		return 0, 0, nil
 	}
 
 	log.Printf("Processing %d PetroApp records for storage", len(records))
@@ -246,7 +91,7 @@ func StoreUniquePetroAppRecords(records []Models.PetroAppRecord) error {
 	// Use context-aware transaction
 	tx := Models.DB.WithContext(ctx).Begin()
 	if tx.Error != nil {
-		return fmt.Errorf("failed to begin transaction: %w", tx.Error)
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", tx.Error)
 	}
 
 	defer func() {
@@ -267,7 +112,7 @@ func StoreUniquePetroAppRecords(records []Models.PetroAppRecord) error {
 		Where("id IN ?", recordIDs).
 		Pluck("id", &existingIDs).Error; err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to check existing records: %w", err)
+		return 0, 0, fmt.Errorf("failed to check existing records: %w", err)
 	}
 
 	// Create map for faster lookup
@@ -292,7 +137,7 @@ func StoreUniquePetroAppRecords(records []Models.PetroAppRecord) error {
 		if err := tx.CreateInBatches(newRecords, 100).Error; err != nil {
 			tx.Rollback()
 			log.Printf("Error batch creating PetroApp records: %v", err)
-			return fmt.Errorf("failed to batch create records: %w", err)
+			return 0, 0, fmt.Errorf("failed to batch create records: %w", err)
 		}
 		stored = len(newRecords)
 		log.Printf("Batch created %d new PetroApp records", stored)
@@ -301,67 +146,144 @@ func StoreUniquePetroAppRecords(records []Models.PetroAppRecord) error {
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
 		log.Printf("Error committing transaction: %v", err)
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	log.Printf("Storage complete: %d new records stored, %d existing records skipped", stored, skipped)
-	return nil
+	return stored, skipped, nil
 }
 
-// SyncPetroAppRecordsToFuelEvents syncs unsynced PetroApp records to FuelEvents
-func SyncPetroAppRecordsToFuelEvents() error {
+// SyncPetroAppRecordsToFuelEvents syncs unsynced PetroApp records to FuelEvents, in batches
+// until none is left. Counts and record errors are added to the run.
+func SyncPetroAppRecordsToFuelEvents(run *Models.SyncRun) error {
 	log.Println("Starting PetroApp to FuelEvent synchronization")
 
 	// Use context with timeout to prevent hanging
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	var unsyncedRecords []Models.PetroAppRecord
-
-	// Get all unsynced records with timeout and reasonable limit
-	if err := Models.DB.WithContext(ctx).Where("is_synced = ?", false).
-		Order("date ASC").
-		Limit(100).
-		Find(&unsyncedRecords).Error; err != nil {
-		log.Printf("Error fetching unsynced records: %v", err)
-		return fmt.Errorf("failed to fetch unsynced records: %w", err)
+	// Records of vehicles not linked to a car wait on the unmatched list, so they do not
+	// become orphan fuel events or hold back the others
+	proposeUnmatchedVehicles(ctx)
+	linkedIDs, err := linkedPetroAppVehicleIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch linked PetroApp vehicles: %w", err)
 	}
 
-	if len(unsyncedRecords) == 0 {
-		log.Println("No unsynced PetroApp records found")
-		return nil
-	}
+	// Records failing in this run are left for the next one
+	failedIDs := []uint{0}
 
-	log.Printf("Found %d unsynced PetroApp records to process", len(unsyncedRecords))
+	for {
+		var unsyncedRecords []Models.PetroAppRecord
 
-	synced := 0
-	errors := 0
+		// Get unsynced records with timeout and reasonable limit
+		if err := Models.DB.WithContext(ctx).Where("is_synced = ? AND vehicle_id IN ? AND id NOT IN ?", false, linkedIDs, failedIDs).
+			Order("date ASC").
+			Limit(100).
+			Find(&unsyncedRecords).Error; err != nil {
+			log.Printf("Error fetching unsynced records: %v", err)
+			return fmt.Errorf("failed to fetch unsynced records: %w", err)
+		}
 
-	// Process each record individually to prevent long-running transactions
-	for _, record := range unsyncedRecords {
-		if err := syncSingleRecord(record); err != nil {
-			log.Printf("Error syncing record ID %d: %v", record.ID, err)
-			errors++
-			continue
+		if len(unsyncedRecords) == 0 {
+			break
+		}
+
+		log.Printf("Found %d unsynced PetroApp records to process", len(unsyncedRecords))
+
+		// Process each record individually to prevent long-running transactions
+		for _, record := range unsyncedRecords {
+			if err := syncSingleRecord(record); err != nil {
+				log.Printf("Error syncing record ID %d: %v", record.ID, err)
+				run.Failed++
+				run.AddError(record.ID, Models.SyncStageSync, err)
+				failedIDs = append(failedIDs, record.ID)
+				continue
+			}
+			run.Synced++
 		}
-		synced++
 	}
 
-	log.Printf("Synchronization complete: %d synced, %d errors", synced, errors)
+	log.Printf("Synchronization complete: %d synced, %d errors", run.Synced, run.Failed)
 
-	if errors > 0 {
-		log.Printf("Warning: synchronization completed with %d errors", errors)
+	if run.Failed > 0 {
+		log.Printf("Warning: synchronization completed with %d errors", run.Failed)
 	}
 
 	return nil
 }
 
+// linkedPetroAppVehicleIDs returns the PetroApp vehicle IDs confirmed for a car
+func linkedPetroAppVehicleIDs(ctx context.Context) ([]int, error) {
+	externalIDs, err := Models.ConfirmedExternalIDs(Models.DB.WithContext(ctx), Models.ExternalSourcePetroApp)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for _, externalID := range externalIDs {
+		if id, err := strconv.Atoi(externalID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// proposeUnmatchedVehicles runs the plate matcher over the PetroApp vehicles with unsynced
+// records and no car, proposing links for review
+func proposeUnmatchedVehicles(ctx context.Context) {
+	var vehicles []Models.PetroAppRecord
+	if err := Models.DB.WithContext(ctx).Model(&Models.PetroAppRecord{}).
+		Select("vehicle_id, MAX(vehicle) AS vehicle").
+		Where("is_synced = ? AND CAST(vehicle_id AS TEXT) NOT IN (?)", false,
+			Models.DB.Model(&Models.ExternalVehicleIdentity{}).Select("external_id").Where("source = ?", Models.ExternalSourcePetroApp)).
+		Group("vehicle_id").
+		Find(&vehicles).Error; err != nil {
+		log.Printf("Error fetching unmatched PetroApp vehicles: %v", err)
+		return
+	}
+
+	for _, vehicle := range vehicles {
+		identity, err := Models.ProposeExternalVehicle(Models.DB.WithContext(ctx), Models.ExternalSourcePetroApp,
+			strconv.Itoa(vehicle.VehicleID), strings.TrimSpace(vehicle.Vehicle))
+		if err != nil {
+			log.Printf("Error matching PetroApp vehicle %d: %v", vehicle.VehicleID, err)
+			continue
+		}
+		if identity != nil {
+			log.Printf("Proposed car %s for PetroApp vehicle %d (%s)", identity.CarNoPlate, vehicle.VehicleID, vehicle.Vehicle)
+		} else {
+			log.Printf("Warning: No car matches PetroApp vehicle %d (%s), its records wait on the unmatched list",
+				vehicle.VehicleID, vehicle.Vehicle)
+		}
+	}
+}
+
 // syncSingleRecord syncs a single PetroApp record to avoid transaction hangs
 func syncSingleRecord(record Models.PetroAppRecord) error {
 	// Use short timeout for each individual record
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Convert PetroApp record to FuelEvent
+	fuelEvent, err := convertPetroAppToFuelEvent(record)
+	if err != nil {
+		return fmt.Errorf("error converting record: %w", err)
+	}
+
+	// Skip duplicates before checking the location, which may fetch the track from the
+	// telematics provider
+	if fuelEventExistsInTx(Models.DB.WithContext(ctx), *fuelEvent) {
+		log.Printf("FuelEvent already exists for PetroApp record ID %d, marking as synced", record.ID)
+		if err := Models.DB.WithContext(ctx).Model(&record).Update("is_synced", true).Error; err != nil {
+			return fmt.Errorf("error updating sync status for duplicate: %w", err)
+		}
+		return nil
+	}
+
+	// Check the truck was at the station, before the transaction as the track may be fetched
+	// from the telematics provider
+	verifyFillLocation(fuelEvent, record)
+
 	tx := Models.DB.WithContext(ctx).Begin()
 	if tx.Error != nil {
 		return fmt.Errorf("failed to begin transaction: %w", tx.Error)
@@ -374,14 +296,7 @@ func syncSingleRecord(record Models.PetroAppRecord) error {
 		}
 	}()
 
-	// Convert PetroApp record to FuelEvent
-	fuelEvent, err := convertPetroAppToFuelEvent(record)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("error converting record: %w", err)
-	}
-
-	// Check if this FuelEvent already exists
+	// Check again in the transaction, in case it was stored while the location was checked
 	if fuelEventExistsInTx(tx, *fuelEvent) {
 		log.Printf("FuelEvent already exists for PetroApp record ID %d, marking as synced", record.ID)
 
@@ -421,6 +336,11 @@ func syncSingleRecord(record Models.PetroAppRecord) error {
 		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
+	// Queue the fill-up for review when it looks suspicious
+	if _, err := Models.CheckFuelEvent(Models.DB, *fuelEvent); err != nil {
+		log.Printf("Warning: Error checking FuelEvent ID %d for anomalies: %v", fuelEvent.ID, err)
+	}
+
 	// Send notifications AFTER database commit to ensure consistency
 	sendNotificationsAsync(*fuelEvent, record.ID)
 
@@ -461,6 +381,11 @@ func createWhatsAppMessage(fuelEvent Models.FuelEvent) string {
 	messageBuilder.WriteString(fmt.Sprintf("- Efficiency: %.2f km/L\n\n", fuelEvent.FuelRate))
 	messageBuilder.WriteString("🏪 *Station:* " + fuelEvent.Transporter + "\n\n")
 
+	// Add fill location check
+	if fuelEvent.LocationStatus == Models.FillLocationMismatched && fuelEvent.LocationDistanceKm != nil {
+		messageBuilder.WriteString(fmt.Sprintf("📍 *LOCATION MISMATCH* - Truck was %.1f km from the station\n\n", *fuelEvent.LocationDistanceKm))
+	}
+
 	// Add efficiency status
 	if fuelEvent.FuelRate > 2.8 {
 		messageBuilder.WriteString("⚠️ *HIGH EFFICIENCY ALERT* - Above normal range")
@@ -535,11 +460,12 @@ func convertPetroAppToFuelEvent(record Models.PetroAppRecord) (*Models.FuelEvent
 	// Calculate price per liter
 	pricePerLiter := cost / liters
 
-	// Convert plate number using mapping
-	convertedPlate := convertPlateNumber(record.Vehicle)
-	if convertedPlate == "" {
-		return nil, fmt.Errorf("converted plate number is empty")
+	// Find the car linked to the PetroApp vehicle
+	car, err := findPetroAppCar(record)
+	if err != nil {
+		return nil, err
 	}
+	convertedPlate := car.CarNoPlate
 
 	// Parse and validate date
 	parsedDate, parsedTime, err := parsePetroAppDate(record.Date)
@@ -563,21 +489,16 @@ func convertPetroAppToFuelEvent(record Models.PetroAppRecord) (*Models.FuelEvent
 	// Calculate fuel rate from actual distance traveled
 	fuelRate := calculateFuelRate(odometerBefore, record.Odo, liters)
 
-	// Get driver name with timeout to prevent hanging
-	var driverID uint
-	var driverName string
+	// Get the name of the driver assigned to the car at fueling time, with timeout to prevent hanging
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := Models.DB.WithContext(ctx).Model(&Models.Car{}).Where("car_no_plate = ?", convertedPlate).Select("driver_id").Scan(&driverID).Error; err != nil {
-		log.Printf("Warning: Error getting driver ID for vehicle %s: %v", convertedPlate, err)
-	}
-
-	if driverID != 0 {
-		if err := Models.DB.WithContext(ctx).Model(&Models.Driver{}).Where("id = ?", driverID).Select("name").Scan(&driverName).Error; err != nil {
-			log.Printf("Warning: Error getting driver name for ID %d: %v", driverID, err)
-		}
+	fueledAt, _ := time.Parse("2006-01-02 15:04:05", strings.TrimSpace(record.Date))
+	driver, err := Models.ResolveDriver(Models.DB.WithContext(ctx), car.ID, fueledAt)
+	if err != nil {
+		log.Printf("Warning: Error getting driver for vehicle %s: %v", convertedPlate, err)
 	}
+	driverName := driver.Name
 
 	if driverName == "" {
 		driverName = strings.TrimSpace(record.DelegateName)
@@ -592,6 +513,7 @@ func convertPetroAppToFuelEvent(record Models.PetroAppRecord) (*Models.FuelEvent
 	}
 
 	fuelEvent := &Models.FuelEvent{
+		CarID:          car.ID,
 		CarNoPlate:     convertedPlate,
 		DriverName:     driverName,
 		Date:           parsedDate,
@@ -609,45 +531,40 @@ func convertPetroAppToFuelEvent(record Models.PetroAppRecord) (*Models.FuelEvent
 	return fuelEvent, nil
 }
 
-// convertPlateNumber converts PetroApp plate format to Arabic format
-func convertPlateNumber(petroAppPlate string) string {
-	trimmedPlate := strings.TrimSpace(petroAppPlate)
-	if trimmedPlate == "" {
-		log.Printf("Warning: Empty plate number provided")
-		return ""
+// findPetroAppCar returns the car confirmed for the PetroApp vehicle of a record
+func findPetroAppCar(record Models.PetroAppRecord) (*Models.Car, error) {
+	car, err := Models.FindExternalVehicle(Models.DB, Models.ExternalSourcePetroApp, strconv.Itoa(record.VehicleID))
+	if err != nil {
+		return nil, fmt.Errorf("error finding car of PetroApp vehicle %d: %w", record.VehicleID, err)
 	}
-
-	if converted, exists := PlateNumberMap[trimmedPlate]; exists {
-		return converted.PlateNumberDB
+	if car == nil {
+		return nil, fmt.Errorf("no car linked to PetroApp vehicle %d (%s)", record.VehicleID, strings.TrimSpace(record.Vehicle))
 	}
-
-	log.Printf("Warning: No plate mapping found for '%s', using original format", trimmedPlate)
-	return trimmedPlate
+	return car, nil
 }
 
 // UpdatePetroAppOdometerFromManualFuelEvent updates odometer in PetroApp system
 func UpdatePetroAppOdometerFromManualFuelEvent(plateNumber string, odometer int) error {
-	var PlateObj PlateNumberObj
-	found := false
-	for _, v := range PlateNumberMap {
-		if v.PlateNumberDB == plateNumber {
-			PlateObj = v
-			found = true
-			break
-		}
+	var car Models.Car
+	if err := Models.DB.Select("id").Where("car_no_plate = ?", plateNumber).Limit(1).Find(&car).Error; err != nil {
+		return fmt.Errorf("failed to find car %s: %w", plateNumber, err)
 	}
-
-	if !found {
+	vehicleID, err := Models.ExternalVehicleID(Models.DB, Models.ExternalSourcePetroApp, car.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find PetroApp vehicle of %s: %w", plateNumber, err)
+	}
+	petroAppVehicleID, err := strconv.Atoi(vehicleID)
+	if car.ID == 0 || err != nil {
 		return fmt.Errorf("no PetroApp vehicle mapping found for plate %s", plateNumber)
 	}
 
-	log.Printf("Updating PetroApp odometer for vehicle ID %d to %d", PlateObj.PetroAppVehicleID, odometer)
+	log.Printf("Updating PetroApp odometer for vehicle ID %d to %d", petroAppVehicleID, odometer)
 
 	url := baseUrl + "/edit_odometer"
 	log.Printf("Request URL: %s", url)
 
 	// Create request body
-	reqBodyStr := fmt.Sprintf(`{"vehicle_id": %d, "odometer": %d}`, PlateObj.PetroAppVehicleID, odometer)
+	reqBodyStr := fmt.Sprintf(`{"vehicle_id": %d, "odometer": %d}`, petroAppVehicleID, odometer)
 	reqBody := bytes.NewBuffer([]byte(reqBodyStr))
 
 	// Create HTTP request with timeout
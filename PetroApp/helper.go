@@ -1,27 +0,0 @@
-package PetroApp
-
-import "math"
-
-func CalculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
-	const earthRadiusKm = 6371.0
-
-	// Convert degrees to radians
-	lat1Rad := lat1 * math.Pi / 180
-	lng1Rad := lng1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	lng2Rad := lng2 * math.Pi / 180
-
-	// Calculate differences
-	deltaLat := lat2Rad - lat1Rad
-	deltaLng := lng2Rad - lng1Rad
-
-	// Apply Haversine formula
-	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
-		math.Cos(lat1Rad)*math.Cos(lat2Rad)*
-			math.Sin(deltaLng/2)*math.Sin(deltaLng/2)
-
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	distance := earthRadiusKm * c
-
-	return distance
-}
@@ -0,0 +1,66 @@
+package PetroApp
+
+import (
+	"Falcon/Models"
+	"Falcon/Scrapper"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fillTrack returns the GPS fixes of a car around the fill time, from the stored positions or,
+// when none were stored, from the telematics provider
+func fillTrack(car Models.Car, at time.Time) ([]Models.VehiclePosition, error) {
+	from, to := at.Add(-Models.FillLocationWindow), at.Add(Models.FillLocationWindow)
+
+	track, err := Models.VehicleTrack(Models.DB, car.ID, from, to)
+	if err != nil || len(track) > 0 || car.EtitCarID == "" {
+		return track, err
+	}
+
+	points, err := Scrapper.Telematics.SpeedPoints(car.EtitCarID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, point := range points {
+		recordedAt, err := Scrapper.ParseGPSTimestamp(point.Timestamp)
+		if err != nil {
+			continue
+		}
+		lat, latErr := strconv.ParseFloat(point.Latitude, 64)
+		lng, lngErr := strconv.ParseFloat(point.Longitude, 64)
+		if latErr != nil || lngErr != nil {
+			continue
+		}
+		track = append(track, Models.VehiclePosition{CarID: car.ID, Latitude: lat, Longitude: lng, RecordedAt: recordedAt})
+	}
+	return track, nil
+}
+
+// verifyFillLocation checks that the car was at the station of a PetroApp record when it was
+// filled, setting the location status of the fuel event
+func verifyFillLocation(fuelEvent *Models.FuelEvent, record Models.PetroAppRecord) {
+	fuelEvent.LocationStatus = Models.FillLocationUnverified
+
+	var car Models.Car
+	if err := Models.DB.Select("id", "etit_car_id").Limit(1).Find(&car, fuelEvent.CarID).Error; err != nil || car.ID == 0 {
+		return
+	}
+	filledAt, err := time.Parse("2006-01-02 15:04:05", strings.TrimSpace(record.Date))
+	if err != nil {
+		return
+	}
+
+	track, err := fillTrack(car, filledAt)
+	if err != nil {
+		log.Printf("Warning: Error fetching GPS track of vehicle %s: %v", fuelEvent.CarNoPlate, err)
+		return
+	}
+
+	fuelEvent.LocationStatus, fuelEvent.LocationDistanceKm = Models.VerifyFillLocation(record.Lat, record.Lng, track, filledAt)
+	if fuelEvent.LocationStatus == Models.FillLocationMismatched {
+		log.Printf("Fill location mismatch for vehicle %s: %.1f km from %s (record ID %d)",
+			fuelEvent.CarNoPlate, *fuelEvent.LocationDistanceKm, fuelEvent.Transporter, record.ID)
+	}
+}
@@ -0,0 +1,260 @@
+package PetroApp
+
+import (
+	"Falcon/Models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Fetch retry settings, the delay doubles after each failed attempt
+const (
+	fetchAttempts     = 4
+	fetchRetryDelay   = 2 * time.Second
+	maxFetchRetryWait = 30 * time.Second
+	billsPageLimit    = 1000
+)
+
+// syncMutex keeps scheduled runs and backfills from fetching at the same time
+var syncMutex sync.Mutex
+
+// ErrSyncRunning is returned when a run is requested while another one is in progress
+var ErrSyncRunning = errors.New("a PetroApp sync is already running")
+
+// permanentError is a fetch failure retrying cannot fix, such as rejected credentials
+type permanentError struct {
+	error
+}
+
+// fetchBillsPage fetches one page of the bills between two days
+func fetchBillsPage(from, to time.Time, page int) (*PetroAppAPIResponse, error) {
+	params := fmt.Sprintf("?dates=%s-%s&limit=%d&page=%d", from.Format("2006/01/02"), to.Format("2006/01/02"), billsPageLimit, page)
+	url := baseUrl + "/bills" + params
+
+	log.Printf("Request URL: %s", url)
+
+	// Create HTTP request with shorter timeout to prevent hangs
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, permanentError{fmt.Errorf("failed to create request: %w", err)}
+	}
+
+	// Set required headers - validate they're not empty
+	if token == "" {
+		return nil, permanentError{fmt.Errorf("authorization token is not set")}
+	}
+	if cookie == "" {
+		log.Println("Warning: cookie is empty, this might cause authentication issues")
+	}
+
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Cookie", cookie)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Falcon-PetroApp-Sync/1.0")
+
+	// Reduced timeout to prevent hanging - fail fast
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Printf("Error closing response body: %v", closeErr)
+		}
+	}()
+
+	log.Printf("PetroApp API response status: %d %s", resp.StatusCode, resp.Status)
+
+	// Handle different HTTP status codes
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		// Continue processing
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, permanentError{fmt.Errorf("authentication failed - check token and cookie")}
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, permanentError{fmt.Errorf("access forbidden - insufficient permissions")}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("rate limit exceeded - try again later")
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, resp.Status)
+	default:
+		return nil, permanentError{fmt.Errorf("API returned status %d: %s", resp.StatusCode, resp.Status)}
+	}
+
+	// Decode response
+	var result PetroAppAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Validate response structure
+	if !result.Status {
+		return nil, fmt.Errorf("API returned unsuccessful status")
+	}
+	return &result, nil
+}
+
+// fetchBillsPageWithRetry fetches a page, retrying failures that may be temporary
+func fetchBillsPageWithRetry(from, to time.Time, page int) (*PetroAppAPIResponse, error) {
+	delay := fetchRetryDelay
+	for attempt := 1; ; attempt++ {
+		result, err := fetchBillsPage(from, to, page)
+		var permanent permanentError
+		if err == nil || errors.As(err, &permanent) || attempt == fetchAttempts {
+			return result, err
+		}
+
+		log.Printf("Error fetching PetroApp page %d (attempt %d of %d), retrying in %s: %v", page, attempt, fetchAttempts, delay, err)
+		time.Sleep(delay)
+		delay = min(2*delay, maxFetchRetryWait)
+	}
+}
+
+// FetchPetroAppRecords fetches and stores the records between two days, page by page until
+// the last one. Counts and record errors are added to the run.
+func FetchPetroAppRecords(run *Models.SyncRun, from, to time.Time) ([]Models.PetroAppRecord, error) {
+	log.Printf("Fetching PetroApp records from %s to %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	var fetched []Models.PetroAppRecord
+	for page, lastPage := 1, 1; page <= lastPage; page++ {
+		result, err := fetchBillsPageWithRetry(from, to, page)
+		if err != nil {
+			run.AddError(0, Models.SyncStageFetch, fmt.Errorf("page %d: %w", page, err))
+			return fetched, fmt.Errorf("failed to fetch page %d: %w", page, err)
+		}
+		lastPage = result.Meta.LastPage
+		run.Pages++
+		run.Fetched += len(result.Data)
+
+		// Validate each record before storing
+		validRecords := make([]Models.PetroAppRecord, 0, len(result.Data))
+		for _, record := range result.Data {
+			if err := validatePetroAppRecord(record); err != nil {
+				log.Printf("Skipping invalid record ID %d: %v", record.ID, err)
+				run.Skipped++
+				run.AddError(record.ID, Models.SyncStageValidate, err)
+				continue
+			}
+			validRecords = append(validRecords, record)
+		}
+
+		// Each page is stored as it comes, so an interrupted run keeps what it fetched
+		stored, skipped, err := StoreUniquePetroAppRecords(validRecords)
+		if err != nil {
+			run.AddError(0, Models.SyncStageStore, fmt.Errorf("page %d: %w", page, err))
+			return fetched, fmt.Errorf("failed to store records: %w", err)
+		}
+		run.Stored += stored
+		run.Skipped += skipped
+		fetched = append(fetched, validRecords...)
+	}
+
+	log.Printf("Successfully fetched %d records from PetroApp API in %d pages", run.Fetched, run.Pages)
+	return fetched, nil
+}
+
+// runSync fetches a range of records, converts the unsynced ones to fuel events and records
+// the run. Scheduled runs are only recorded when they stored, synced or failed something, as
+// most of them find nothing new.
+func runSync(kind string, from, to time.Time) (*Models.SyncRun, error) {
+	run := Models.NewSyncRun(Models.ExternalSourcePetroApp, kind, from, to)
+	if kind == Models.SyncRunBackfill {
+		var err error
+		if run, err = Models.StartSyncRun(Models.DB, Models.ExternalSourcePetroApp, kind, from, to); err != nil {
+			return nil, fmt.Errorf("failed to start sync run: %w", err)
+		}
+	}
+
+	_, fetchErr := FetchPetroAppRecords(run, from, to)
+
+	// Records stored by earlier runs are synced even when this fetch failed
+	syncErr := SyncPetroAppRecordsToFuelEvents(run)
+
+	if err := Models.FinishSyncRun(Models.DB, run, errors.Join(fetchErr, syncErr)); err != nil {
+		log.Printf("Error recording PetroApp sync run %d: %v", run.ID, err)
+	}
+	if fetchErr == nil && kind == Models.SyncRunScheduled {
+		if err := Models.AdvanceSyncCursor(Models.DB, Models.ExternalSourcePetroApp, to, run.ID); err != nil {
+			log.Printf("Error advancing PetroApp sync cursor: %v", err)
+		}
+	}
+	log.Printf("PetroApp %s sync %s: %d fetched, %d stored, %d synced, %d failed",
+		kind, run.Status, run.Fetched, run.Stored, run.Synced, run.Failed)
+	return run, errors.Join(fetchErr, syncErr)
+}
+
+// RunScheduledSync fetches the records since the cursor of the last successful run, so
+// records missed while the server was down are caught up
+func RunScheduledSync() (*Models.SyncRun, error) {
+	if !syncMutex.TryLock() {
+		return nil, ErrSyncRunning
+	}
+	defer syncMutex.Unlock()
+
+	cursor, err := Models.LoadSyncCursor(Models.DB, Models.ExternalSourcePetroApp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync cursor: %w", err)
+	}
+	from, to := Models.SyncFetchRange(cursor, time.Now())
+	return runSync(Models.SyncRunScheduled, from, to)
+}
+
+// RunBackfill fetches the records between two days, leaving the cursor as it is
+func RunBackfill(from, to time.Time) (*Models.SyncRun, error) {
+	if !syncMutex.TryLock() {
+		return nil, ErrSyncRunning
+	}
+	defer syncMutex.Unlock()
+
+	return runSync(Models.SyncRunBackfill, from, to)
+}
+
+// StartBackfillAsync starts a backfill in the background, holding the sync lock from now
+// until it finishes. It returns ErrSyncRunning when another run holds it.
+func StartBackfillAsync(from, to time.Time) error {
+	if !syncMutex.TryLock() {
+		return ErrSyncRunning
+	}
+
+	go func() {
+		defer syncMutex.Unlock()
+		if _, err := runSync(Models.SyncRunBackfill, from, to); err != nil {
+			log.Printf("Error backfilling PetroApp records: %v", err)
+		}
+	}()
+	return nil
+}
+
+// parseBackfillRange parses the days of a backfill, given as 2006-01-02
+func parseBackfillRange(start, end string) (time.Time, time.Time, error) {
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date: %w", err)
+	}
+	to, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date: %w", err)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end date is before start date")
+	}
+	return from, to, nil
+}
+
+// Backfill runs a backfill between two days given as 2006-01-02, for the command line
+func Backfill(start, end string) (*Models.SyncRun, error) {
+	from, to, err := parseBackfillRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return RunBackfill(from, to)
+}
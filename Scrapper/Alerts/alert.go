@@ -5,10 +5,45 @@ import (
 	"log"
 )
 
+// StoreUniqueAlerts stores the alerts not stored yet and notifies them. The speed check
+// rescans the whole day, so most alerts of a run are already known.
 func StoreUniqueAlerts(alerts []Models.SpeedAlert) error {
-	if err := Models.DB.Model(&Models.SpeedAlert{}).Create(&alerts).Error; err != nil {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, 0, len(alerts))
+	for i := range alerts {
+		if alerts[i].Hash == "" {
+			alerts[i].Hash = alerts[i].ComputeHash()
+		}
+		hashes = append(hashes, alerts[i].Hash)
+	}
+
+	var existing []string
+	if err := Models.DB.Model(&Models.SpeedAlert{}).Where("hash IN ?", hashes).Pluck("hash", &existing).Error; err != nil {
+		log.Println(err)
+		return err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, hash := range existing {
+		known[hash] = true
+	}
+
+	var newAlerts []Models.SpeedAlert
+	for _, alert := range alerts {
+		if !known[alert.Hash] {
+			known[alert.Hash] = true
+			newAlerts = append(newAlerts, alert)
+		}
+	}
+	if len(newAlerts) == 0 {
+		return nil
+	}
+
+	if err := Models.DB.Model(&Models.SpeedAlert{}).Create(&newAlerts).Error; err != nil {
 		log.Println(err)
 		return err
 	}
-	return ProcessAlertsWithHighestExceed(alerts, Models.DB)
+	return ProcessAlertsWithHighestExceed(newAlerts, Models.DB)
 }
@@ -0,0 +1,106 @@
+package Alerts
+
+import (
+	"Falcon/Constants"
+	"Falcon/Models"
+	"Falcon/Whatsapp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotifyMaintenanceDue sends the cars whose maintenance became due or overdue since the last
+// run to the maintenance WhatsApp group, each due point once
+func NotifyMaintenanceDue(db *gorm.DB) error {
+	dues, err := Models.MaintenanceStatuses(db, 0, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to compute maintenance statuses: %w", err)
+	}
+	pending, err := Models.PendingMaintenanceNotices(db, dues)
+	if err != nil {
+		return fmt.Errorf("failed to check maintenance notices: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := Whatsapp.SendMessage(Constants.WhatsAppGPIDMaintenance, createMaintenanceMessage(pending)); err != nil {
+		return fmt.Errorf("failed to send maintenance alert: %w", err)
+	}
+	if err := Models.MarkMaintenanceNotified(db, pending); err != nil {
+		return fmt.Errorf("failed to record maintenance notices: %w", err)
+	}
+	log.Printf("Maintenance alert sent for %d tasks", len(pending))
+	return nil
+}
+
+// Create WhatsApp message listing overdue tasks first, then the ones due soon
+func createMaintenanceMessage(dues []Models.MaintenanceDue) string {
+	var messageBuilder strings.Builder
+	messageBuilder.WriteString("🛠️ *PREVENTIVE MAINTENANCE*\n")
+
+	for _, status := range []string{Models.MaintenanceOverdue, Models.MaintenanceDueSoon} {
+		header := "\n🔴 *Overdue:*\n"
+		if status == Models.MaintenanceDueSoon {
+			header = "\n🟡 *Due soon:*\n"
+		}
+		written := false
+		for _, due := range dues {
+			if due.Status != status {
+				continue
+			}
+			if !written {
+				messageBuilder.WriteString(header)
+				written = true
+			}
+			messageBuilder.WriteString(fmt.Sprintf("- %s: %s%s\n", due.CarNoPlate, maintenanceTaskName(due.Task), maintenanceRemaining(due)))
+		}
+	}
+
+	// Properly escape for JSON
+	jsonBytes, _ := json.Marshal(messageBuilder.String())
+	return string(jsonBytes[1 : len(jsonBytes)-1])
+}
+
+func maintenanceTaskName(task string) string {
+	switch task {
+	case Models.MaintenanceOil:
+		return "Oil change"
+	case Models.MaintenanceFilters:
+		return "Filters"
+	case Models.MaintenanceBrakes:
+		return "Brakes"
+	case Models.MaintenanceTankCalibration:
+		return "Tank calibration"
+	case Models.MaintenanceTireRotation:
+		return "Tire rotation"
+	}
+	return task
+}
+
+// maintenanceRemaining describes how far the task is, in km and days
+func maintenanceRemaining(due Models.MaintenanceDue) string {
+	var parts []string
+	if due.RemainingKm != nil {
+		if *due.RemainingKm < 0 {
+			parts = append(parts, fmt.Sprintf("%d km over", -*due.RemainingKm))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d km left", *due.RemainingKm))
+		}
+	}
+	if due.RemainingDays != nil {
+		if *due.RemainingDays < 0 {
+			parts = append(parts, fmt.Sprintf("%d days late", -*due.RemainingDays))
+		} else {
+			parts = append(parts, fmt.Sprintf("due %s", *due.DueDate))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
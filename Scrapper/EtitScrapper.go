@@ -68,6 +68,17 @@ type RouteData struct {
 }
 
 func (app *App) GetVehicleHistoryData(VehicleID string, from, to string) (*RouteData, error) {
+	// Get authenticated clients
+	clients, err := GetClients(username, password)
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+	return getRouteData(clients, VehicleID, from, to)
+}
+
+// getRouteData fetches the coordinates, stops and trip summary of a vehicle from the GPS portal.
+// from and to are already formatted for the portal's query string.
+func getRouteData(clients *AuthenticatedClients, VehicleID string, from, to string) (*RouteData, error) {
 	// Create the URL for history data
 	historyURL := fmt.Sprintf(
 		"https://fms-gps.etit-eg.com/WebPages/GetAllHistoryData.aspx?id=%s&time=6&from=%s&to=%s",
@@ -76,23 +87,7 @@ func (app *App) GetVehicleHistoryData(VehicleID string, from, to string) (*Route
 		to,
 	)
 
-	// Create the URL for trip summary
-	summaryURL := fmt.Sprintf(
-		"https://fms-gps.etit-eg.com/WebPages/GetHistoryTripSummary.ashx?id=%s&time=6&from=%s&to=%s&t=%d",
-		VehicleID,
-		from,
-		to,
-		time.Now().UnixMilli(),
-	)
-
 	fmt.Println("History URL:", historyURL)
-	fmt.Println("Summary URL:", summaryURL)
-
-	// Get authenticated clients
-	clients, err := GetClients(username, password)
-	if err != nil {
-		return nil, fmt.Errorf("login failed: %w", err)
-	}
 
 	log.Printf("Fetching history data from: %s", historyURL)
 
@@ -140,7 +135,7 @@ func (app *App) GetVehicleHistoryData(VehicleID string, from, to string) (*Route
 
 	// Check if response is HTML instead of JSON (error case)
 	if strings.Contains(jsonString, "<!DOCTYPE HTML") || strings.Contains(jsonString, "<html") {
-		return nil, fmt.Errorf("received HTML instead of JSON, authentication may have failed")
+		return nil, fmt.Errorf("received HTML instead of JSON: %w", errETITSessionRejected)
 	}
 
 	// Check for empty or invalid responses
@@ -253,18 +248,35 @@ func (app *App) GetVehicleHistoryData(VehicleID string, from, to string) (*Route
 		return nil, fmt.Errorf("no valid coordinates found in response")
 	}
 
-	// Now fetch trip summary
+	// Now fetch trip summary, the route is still returned without it
+	tripSummary, err := fetchTripSummary(clients, VehicleID, from, to)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	log.Printf("Successfully parsed %d coordinates, %d stops, and trip summary", len(coordinates), len(stops))
+
+	return &RouteData{
+		Coordinates: coordinates,
+		Stops:       stops,
+		TripSummary: tripSummary,
+	}, nil
+}
+
+// fetchTripSummary fetches the mileage and timing summary of a vehicle's route from the GPS portal
+func fetchTripSummary(clients *AuthenticatedClients, VehicleID string, from, to string) (*Structs.TripSummary, error) {
+	summaryURL := fmt.Sprintf(
+		"https://fms-gps.etit-eg.com/WebPages/GetHistoryTripSummary.ashx?id=%s&time=6&from=%s&to=%s&t=%d",
+		VehicleID,
+		from,
+		to,
+		time.Now().UnixMilli(),
+	)
 	log.Printf("Fetching trip summary from: %s", summaryURL)
 
 	summaryReq, err := http.NewRequest("GET", summaryURL, nil)
 	if err != nil {
-		log.Printf("Warning: error creating summary request: %v", err)
-		// Continue without summary
-		return &RouteData{
-			Coordinates: coordinates,
-			Stops:       stops,
-			TripSummary: nil,
-		}, nil
+		return nil, fmt.Errorf("error creating summary request: %w", err)
 	}
 
 	// Add headers for summary request
@@ -274,49 +286,37 @@ func (app *App) GetVehicleHistoryData(VehicleID string, from, to string) (*Route
 
 	summaryResp, err := clients.HttpClient.Do(summaryReq)
 	if err != nil {
-		log.Printf("Warning: error fetching trip summary: %v", err)
-		// Continue without summary
-		return &RouteData{
-			Coordinates: coordinates,
-			Stops:       stops,
-			TripSummary: nil,
-		}, nil
+		return nil, fmt.Errorf("error fetching trip summary: %w", err)
 	}
 	defer summaryResp.Body.Close()
 
-	var tripSummary *Structs.TripSummary = nil
-	if summaryResp.StatusCode == http.StatusOK {
-		summaryBody, err := io.ReadAll(summaryResp.Body)
-		if err != nil {
-			log.Printf("Warning: error reading summary response: %v", err)
-		} else {
-			summaryBodyStr := string(summaryBody)
-			fmt.Println("Raw summary response:", summaryBodyStr)
-
-			// Fix malformed JSON for trip summary
-			fixedSummaryJSON := fixMalformedJSON(summaryBodyStr)
-			fmt.Println("Fixed summary JSON:", fixedSummaryJSON)
-
-			var summaryResponse Structs.TripSummaryResponse
-			if err := json.Unmarshal([]byte(fixedSummaryJSON), &summaryResponse); err != nil {
-				log.Printf("Warning: error parsing trip summary: %v", err)
-				fmt.Println("Failed to parse summary JSON:", fixedSummaryJSON)
-			} else if len(summaryResponse.TripSummary) > 0 {
-				tripSummary = &summaryResponse.TripSummary[0]
-				log.Printf("Successfully fetched trip summary: %+v", tripSummary)
-			}
-		}
-	} else {
-		log.Printf("Warning: trip summary API returned status %d", summaryResp.StatusCode)
+	if summaryResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("trip summary API returned status %d", summaryResp.StatusCode)
 	}
 
-	log.Printf("Successfully parsed %d coordinates, %d stops, and trip summary", len(coordinates), len(stops))
+	summaryBody, err := io.ReadAll(summaryResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading summary response: %w", err)
+	}
+	summaryBodyStr := string(summaryBody)
+	if strings.Contains(summaryBodyStr, "<!DOCTYPE HTML") || strings.Contains(summaryBodyStr, "<html") {
+		return nil, fmt.Errorf("received HTML instead of JSON: %w", errETITSessionRejected)
+	}
 
-	return &RouteData{
-		Coordinates: coordinates,
-		Stops:       stops,
-		TripSummary: tripSummary,
-	}, nil
+	// Fix malformed JSON for trip summary
+	fixedSummaryJSON := fixMalformedJSON(summaryBodyStr)
+
+	var summaryResponse Structs.TripSummaryResponse
+	if err := json.Unmarshal([]byte(fixedSummaryJSON), &summaryResponse); err != nil {
+		return nil, fmt.Errorf("error parsing trip summary: %w", err)
+	}
+	if len(summaryResponse.TripSummary) == 0 {
+		return nil, nil
+	}
+
+	tripSummary := &summaryResponse.TripSummary[0]
+	log.Printf("Successfully fetched trip summary: %+v", tripSummary)
+	return tripSummary, nil
 }
 
 // fixMalformedJSON fixes the malformed JSON from the ETIT API
@@ -443,8 +443,15 @@ func StoreVehicleRouteData(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get vehicle history data from ETIT API
-	routeData, err := app.GetVehicleHistoryData(car.EtitCarID, from, to)
+	start, end, err := parseTelematicsRange(from, to)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Get vehicle history data from the telematics provider
+	routeData, err := Telematics.History(car.EtitCarID, start, end)
 	if err != nil {
 		log.Println(err)
 		return c.Status(500).JSON(fiber.Map{
@@ -651,8 +658,15 @@ func GetVehicleRouteByDate(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get vehicle history data using the updated function
-	routeData, err := app.GetVehicleHistoryData(car.EtitCarID, from, to)
+	start, end, err := parseTelematicsRange(from, to)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Get vehicle history data from the telematics provider
+	routeData, err := Telematics.History(car.EtitCarID, start, end)
 
 	if err != nil {
 		log.Println(err)
@@ -851,15 +865,6 @@ type MileageStruct struct {
 // }
 
 func GetVehicleMileageHistory(c *fiber.Ctx) error {
-	if err := app.GetCurrentLocationData(GlobalClient); err != nil {
-		var loginErr error
-		GlobalClient, loginErr = app.Login()
-		if loginErr != nil {
-			log.Println(err.Error())
-			return err
-		}
-		app.GetCurrentLocationData(GlobalClient)
-	}
 	var data MileageStruct
 	err := c.BodyParser(&data)
 	if err != nil {
@@ -868,8 +873,13 @@ func GetVehicleMileageHistory(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
-	fmt.Println(len(VehicleStatusList))
-	fmt.Println(data.VehiclePlateNo)
+
+	positions, err := Telematics.CurrentPositions()
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+	VehicleStatusList = positions
 	for _, s := range VehicleStatusList {
 		if s.PlateNo == data.VehiclePlateNo {
 			data.VehicleID = s.ID
@@ -907,85 +917,18 @@ func (jar *Jar) Cookies(u *url.URL) []*http.Cookie {
 	return jar.cookies[u.Host]
 }
 
-func trimLeftChars(s string, n int) string {
-	m := 0
-	for i := range s {
-		if m >= n {
-			return s[i:]
-		}
-		m++
-	}
-	return s[:0]
-}
-
+// GetFeeRate returns the fee tier and mileage of a vehicle between the start and end times
 func GetFeeRate(data MileageStruct) (float64, float64, error) {
-	GlobalClient, _ = app.Login()
-	app.GetCurrentLocationData(GlobalClient)
-	// reqString := fmt.Sprintf("https://fms-gps.etit-eg.com/WebPages/GetHistoryTripSummary.ashx?id=%s&time=6&from=%s&to=%s", data.VehicleID, "11/1/2022%2000:00:00", "11/1/2022%2023:59:59")
-	reqString := fmt.Sprintf("https://fms-gps.etit-eg.com/WebPages/GetHistoryTripSummary.ashx?id=%s&time=6&from=%s&to=%s", data.VehicleID, data.StartTime, data.EndTime)
-	GlobalClient.Request("GET", "https://fms-gps.etit-eg.com", nil, nil, http.Header{})
-	cookies := GlobalClient.Cookies("https://fms-gps.etit-eg.com")
-	req, _ := http.NewRequest("GET", reqString, nil)
-	req.Header.Set("Cookie", fmt.Sprintf("%s;", cookies[4]))
-	res, err := app.Client.Do(req)
-	if err != nil {
-		log.Println(err.Error())
-		return 0, 0, err
-	}
-	defer res.Body.Close()
-	buf, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Println(err.Error())
-		return 0, 0, err
-	}
-	jsonData, err := json.Marshal(fmt.Sprintf("%s", buf))
+	start, end, err := parseTelematicsRange(data.StartTime, data.EndTime)
 	if err != nil {
-		log.Println(err.Error())
-		return 0, 0, err
-	}
-	var jsonString string
-	err = json.Unmarshal(jsonData, &jsonString)
-	if err != nil {
-		log.Println(err.Error())
-		return 0, 0, err
-	}
-	jsonString = trimLeftChars(jsonString, 13)
-	stringLen := len(jsonString)
-	fmt.Println(stringLen)
-	if len(jsonString) > 0 {
-		jsonString = jsonString[:len(jsonString)-5]
-	} else {
 		return 0, 0, err
 	}
 
-	// jsonString = strings.Trim(jsonString, ", ")
-	jsonString = jsonString + "\n}"
-	fmt.Println(jsonString)
-	// fmt.Println(jsonString)
-	var unMarshalledData struct {
-		TotalMilage string `json:"TotalMileage"`
-	}
-	err = json.Unmarshal([]byte(jsonString), &unMarshalledData)
+	mileage, err := Telematics.Mileage(data.VehicleID, start, end)
 	if err != nil {
-		log.Println(err.Error())
 		return 0, 0, err
 	}
-
-	fmt.Println(unMarshalledData.TotalMilage)
-	mileage, err := strconv.ParseFloat(unMarshalledData.TotalMilage, 64)
-	if err != nil {
-		log.Println(err.Error())
-	}
-	if mileage == 0 {
-		GlobalClient, err = app.Login()
-		if err != nil {
-			return 0, 0, err
-		}
-		return GetFeeRate(data)
-	}
-	feeRate := GetFeeFromMilage(mileage)
-
-	return feeRate, mileage, nil
+	return GetFeeFromMilage(mileage), mileage, nil
 }
 
 func GetFeeFromMilage(mileage float64) float64 {
@@ -4,7 +4,6 @@ import (
 	"Falcon/Models"
 	"Falcon/Slack"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -33,9 +32,12 @@ type VehicleStatusStruct struct {
 var VehicleStatusList []VehicleStatusStruct
 var VehicleStatusListTemp []VehicleStatusStruct
 var isLoaded bool = false
-var GlobalClient *colly.Collector
 
-var Data struct {
+var Data transportersGrid
+
+// transportersGrid is the response of the portal's transporter search, giving the
+// vehicle ID of each plate
+type transportersGrid struct {
 	Data struct {
 		Rows []struct {
 			PlateNo string `json:"plateNo"`
@@ -45,85 +47,67 @@ var Data struct {
 	} `json:"d"`
 }
 
-// GetCurrentLocationData fetches the current location data for vehicles
-func GetCurrentLocationData(client *colly.Collector) error {
-	client.OnHTML("#ctl00_ContentPlaceHolder1_grd_TransportersData_ctl00", func(h *colly.HTMLElement) {
-		h.ForEach("tr.rgRow", func(_ int, tr *colly.HTMLElement) {
-			var CurrentVehicleStatus VehicleStatusStruct
-			tr.ForEach("td", func(i int, td *colly.HTMLElement) {
-				if i == 2 {
-					CurrentVehicleStatus.PlateNo = td.Text
-				} else if i == 7 {
-					CurrentVehicleStatus.Latitude = td.Text
-				} else if i == 8 {
-					CurrentVehicleStatus.Longitude = td.Text
-				} else if i == 11 {
-					// Convert to 2006-01-02 15:04:05
-					// Example input: "\n                                        10-07-2025 06:59:16 PM\n"
-					raw := strings.TrimSpace(td.Text)
-					parsedTime, err := time.Parse("02-01-2006 03:04:05 PM", raw)
-					if err == nil {
-						CurrentVehicleStatus.Timestamp = parsedTime.Format("2006-01-02 15:04:05")
-					} else {
-						CurrentVehicleStatus.Timestamp = raw // fallback to raw if parsing fails
-					}
-				} else if i == 12 {
-					CurrentVehicleStatus.EngineStatus = td.Text
-				} else if i == 13 {
-					id, _ := strconv.Atoi(td.Text)
-					CurrentVehicleStatus.Speed = id
-					VehicleStatusListTemp = append(VehicleStatusListTemp, CurrentVehicleStatus)
-				}
-			})
-		})
-		h.ForEach("tr.rgAltRow", func(_ int, tr *colly.HTMLElement) {
-			var CurrentVehicleStatus VehicleStatusStruct
-			tr.ForEach("td", func(i int, td *colly.HTMLElement) {
-				if i == 2 {
-					CurrentVehicleStatus.PlateNo = td.Text
-				} else if i == 7 {
-					CurrentVehicleStatus.Latitude = td.Text
-				} else if i == 8 {
-					CurrentVehicleStatus.Longitude = td.Text
-				} else if i == 11 {
-					// Convert to 2006-01-02 15:04:05
-					// Example input: "\n                                        10-07-2025 06:59:16 PM\n"
-					raw := strings.TrimSpace(td.Text)
-					parsedTime, err := time.Parse("02-01-2006 03:04:05 PM", raw)
-					if err == nil {
-						CurrentVehicleStatus.Timestamp = parsedTime.Format("2006-01-02 15:04:05")
-					} else {
-						CurrentVehicleStatus.Timestamp = raw // fallback to raw if parsing fails
-					}
-				} else if i == 12 {
-					CurrentVehicleStatus.EngineStatus = td.Text
-				} else if i == 13 {
-					id, _ := strconv.Atoi(td.Text)
-					CurrentVehicleStatus.Speed = id
-					VehicleStatusListTemp = append(VehicleStatusListTemp, CurrentVehicleStatus)
+// GetCurrentLocationData scrapes the current status of every vehicle from the transporters
+// grid. The client should be fresh, as the grid callbacks are added to it. An empty grid
+// means the portal no longer accepts the session.
+func GetCurrentLocationData(client *colly.Collector) ([]VehicleStatusStruct, error) {
+	var statuses []VehicleStatusStruct
+	readRow := func(_ int, tr *colly.HTMLElement) {
+		var CurrentVehicleStatus VehicleStatusStruct
+		tr.ForEach("td", func(i int, td *colly.HTMLElement) {
+			if i == 2 {
+				CurrentVehicleStatus.PlateNo = td.Text
+			} else if i == 7 {
+				CurrentVehicleStatus.Latitude = td.Text
+			} else if i == 8 {
+				CurrentVehicleStatus.Longitude = td.Text
+			} else if i == 11 {
+				// Convert to 2006-01-02 15:04:05
+				// Example input: "\n                                        10-07-2025 06:59:16 PM\n"
+				raw := strings.TrimSpace(td.Text)
+				parsedTime, err := time.Parse("02-01-2006 03:04:05 PM", raw)
+				if err == nil {
+					CurrentVehicleStatus.Timestamp = parsedTime.Format("2006-01-02 15:04:05")
+				} else {
+					CurrentVehicleStatus.Timestamp = raw // fallback to raw if parsing fails
 				}
-			})
+			} else if i == 12 {
+				CurrentVehicleStatus.EngineStatus = td.Text
+			} else if i == 13 {
+				id, _ := strconv.Atoi(td.Text)
+				CurrentVehicleStatus.Speed = id
+				statuses = append(statuses, CurrentVehicleStatus)
+			}
 		})
+	}
+	client.OnHTML("#ctl00_ContentPlaceHolder1_grd_TransportersData_ctl00", func(h *colly.HTMLElement) {
+		h.ForEach("tr.rgRow", readRow)
+		h.ForEach("tr.rgAltRow", readRow)
 	})
 	err := client.Request("GET", "https://fms-gps.etit-eg.com/WebPages/UpdateTransportersData.aspx", nil, nil, http.Header{"Content-Type": []string{"text/html; charset=utf-8"}})
 	if err != nil {
 		log.Println(err)
-		return err
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("empty transporters grid: %w", errETITSessionRejected)
 	}
+
 	client.OnResponse(func(r *colly.Response) {
 		jsonString := string(r.Body)
 		jsonString = strings.Replace(jsonString, "\\", "", -1)
 		jsonString = strings.Replace(jsonString, "\"{", "{", -1)
 		jsonString = strings.Replace(jsonString, "\"}", "}", -1)
 
-		err := json.Unmarshal([]byte(jsonString), &Data)
+		var grid transportersGrid
+		err := json.Unmarshal([]byte(jsonString), &grid)
 		if err != nil {
 			log.Println(err.Error())
 		}
-		for i := 0; i < len(Data.Data.Rows); i++ {
-			for i2 := 0; i2 < len(VehicleStatusListTemp); i2++ {
-				if Data.Data.Rows[i].PlateNo == VehicleStatusListTemp[i2].PlateNo {
-					VehicleStatusListTemp[i2].ID = Data.Data.Rows[i].ID
+		for i := 0; i < len(grid.Data.Rows); i++ {
+			for i2 := 0; i2 < len(statuses); i2++ {
+				if grid.Data.Rows[i].PlateNo == statuses[i2].PlateNo {
+					statuses[i2].ID = grid.Data.Rows[i].ID
 				}
 			}
 		}
@@ -133,14 +117,9 @@ func GetCurrentLocationData(client *colly.Collector) error {
 	err = client.Request("POST", baseURL+"/WebPages/Transporters/List.aspx/GetAllTransporterBySearchCriteria", strings.NewReader(jsonString), nil, http.Header{"Content-Type": []string{"application/json; charset=utf-8"}})
 	if err != nil {
 		log.Println(err)
-		return err
+		return nil, err
 	}
-
-	if len(VehicleStatusListTemp) == 0 {
-		return errors.New("Empty")
-	}
-	VehicleStatusList = VehicleStatusListTemp
-	return nil
+	return statuses, nil
 }
 
 type NominatimResponse struct {
@@ -188,21 +167,12 @@ func GetAllVehicleData() []VehicleStatusStruct {
 
 // GetVehicleData - enhanced version with geofence-only updates and individual vehicle change detection
 func GetVehicleData() {
-	// Your existing vehicle data fetching logic
-	clients, err := GetClients(username, password)
-	if err != nil {
-		log.Println("Login failed:", err.Error())
-		return
-	}
-
-	GlobalClient = clients.Collector
-	VehicleStatusListTemp = []VehicleStatusStruct{}
-
-	err = GetCurrentLocationData(GlobalClient)
+	statuses, err := Telematics.CurrentPositions()
 	if err != nil {
-		log.Println("Failed to get current location data:", err.Error())
+		log.Printf("Failed to get current location data from %s: %v", Telematics.Name(), err)
 		return
 	}
+	VehicleStatusList = statuses
 
 	if VehicleStatusList != nil {
 		isLoaded = true
@@ -215,7 +185,7 @@ func GetVehicleData() {
 		return
 	}
 
-	// Create map of cars by ETIT ID for faster lookup
+	// Create map of cars by telematics vehicle ID for faster lookup
 	carsByEtitID := make(map[string]*Models.Car)
 	for i := range allCars {
 		carsByEtitID[allCars[i].EtitCarID] = &allCars[i]
@@ -225,11 +195,14 @@ func GetVehicleData() {
 	var changedVehicles []string
 	var statusChanges bool = false
 
+	// Fixes of this poll, kept in the position history
+	var positions []Models.VehiclePosition
+
 	// Step 2: Process each vehicle status and update corresponding car
 	for _, vehicleStatus := range VehicleStatusList {
 		car, exists := carsByEtitID[vehicleStatus.ID]
 		if !exists {
-			log.Printf("Car not found for ETIT ID: %s", vehicleStatus.ID)
+			log.Printf("Car not found for telematics ID: %s", vehicleStatus.ID)
 			continue
 		}
 
@@ -260,6 +233,20 @@ func GetVehicleData() {
 		}
 		car.Location = address
 
+		if recordedAt, err := time.Parse(Models.PositionTimeLayout, vehicleStatus.Timestamp); err == nil {
+			positions = append(positions, Models.VehiclePosition{
+				CarID:        car.ID,
+				CarNoPlate:   car.CarNoPlate,
+				VehicleID:    vehicleStatus.ID,
+				Latitude:     lat,
+				Longitude:    lng,
+				Speed:        vehicleStatus.Speed,
+				EngineStatus: vehicleStatus.EngineStatus,
+				Location:     address,
+				RecordedAt:   recordedAt,
+			})
+		}
+
 		// Update geofence ONLY if timestamp is newer AND vehicle has a geofence
 		// This returns true only if status actually changed
 		if Slack.UpdateCarGeofence(car, lat, lng, vehicleStatus.Timestamp) {
@@ -273,6 +260,10 @@ func GetVehicleData() {
 		}
 	}
 
+	if err := Models.RecordVehiclePositions(Models.DB, positions); err != nil {
+		log.Printf("Error recording vehicle positions: %v", err)
+	}
+
 	// Step 3: Only send Slack update if there were actual status changes
 	if statusChanges {
 		log.Printf("Status changes detected for vehicles: %v", changedVehicles)
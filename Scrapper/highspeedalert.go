@@ -35,8 +35,7 @@ func formatDateForURL(date time.Time) string {
 	return url.QueryEscape(formatted)
 }
 
-// GetSpeedData retrieves speed data for a specific vehicle on a specific date
-// GetSpeedData retrieves speed data for a specific vehicle on a specific date
+// GetSpeedData retrieves today's speed data for a specific vehicle
 func GetSpeedData(vehicleID string) (*SpeedData, error) {
 	// Use GMT (UTC) time zone
 	gmtLoc, err := time.LoadLocation("GMT")
@@ -44,12 +43,20 @@ func GetSpeedData(vehicleID string) (*SpeedData, error) {
 		return nil, fmt.Errorf("failed to load GMT timezone: %w", err)
 	}
 
-	// Get current time in Cairo
-	nowCairo := time.Now().In(gmtLoc)
 	// Start date is the start of the current day in GMT
-	startDate := time.Date(nowCairo.Year(), nowCairo.Month(), nowCairo.Day(), 0, 0, 0, 0, gmtLoc)
-	endDate := nowCairo
+	now := time.Now().In(gmtLoc)
+	startDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, gmtLoc)
 
+	// Get authenticated clients
+	clients, err := GetClients(username, password)
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+	return getSpeedData(clients, vehicleID, startDate, now)
+}
+
+// getSpeedData retrieves the speed data of a vehicle between two times from the GPS portal
+func getSpeedData(clients *AuthenticatedClients, vehicleID string, startDate, endDate time.Time) (*SpeedData, error) {
 	// Format the dates for the URL
 	startStr := formatDateForURL(startDate)
 	endStr := formatDateForURL(endDate)
@@ -62,11 +69,6 @@ func GetSpeedData(vehicleID string) (*SpeedData, error) {
 		endStr,
 	)
 	fmt.Println(url)
-	// Get authenticated clients
-	clients, err := GetClients(username, password)
-	if err != nil {
-		return nil, fmt.Errorf("login failed: %w", err)
-	}
 
 	// Ensure we're using the authenticated client
 	log.Printf("Fetching speed data from: %s", url)
@@ -111,7 +113,7 @@ func GetSpeedData(vehicleID string) (*SpeedData, error) {
 	}
 	// Check if response is HTML instead of JSON (error case)
 	if strings.Contains(string(body), "<!DOCTYPE HTML") {
-		return nil, fmt.Errorf("received HTML instead of JSON: %s", string(body))
+		return nil, fmt.Errorf("received HTML instead of JSON: %w", errETITSessionRejected)
 	}
 
 	// Parse the JSON response
@@ -232,15 +234,22 @@ func ParseGPSTimestamp(timestamp string) (time.Time, error) {
 
 // CheckHighSpeedAlerts checks for high-speed alerts for a vehicle
 func CheckHighSpeedAlerts(vehicleID, plateNo string, speedThreshold int) ([]Models.SpeedAlert, error) {
-	// Get the speed data
-	speedData, err := GetSpeedData(vehicleID)
+	// Today's speed data
+	now := time.Now().UTC()
+	startDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return checkHighSpeedAlerts(vehicleID, plateNo, startDate, now, speedThreshold)
+}
+
+// checkHighSpeedAlerts finds the speed points of a vehicle above the threshold between two times
+func checkHighSpeedAlerts(vehicleID, plateNo string, startDate, endDate time.Time, speedThreshold int) ([]Models.SpeedAlert, error) {
+	points, err := Telematics.SpeedPoints(vehicleID, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
 
 	// Find high-speed points
 	var alerts []Models.SpeedAlert
-	for _, point := range speedData.Points {
+	for _, point := range points {
 		speed, err := strconv.Atoi(point.Speed)
 		if err != nil {
 			log.Printf("Error parsing speed value '%s': %v", point.Speed, err)
@@ -313,7 +322,7 @@ func CheckVehicleSpeedByDateRange(vehicleID, plateNo string, startDate, endDate
 
 	// Iterate through each day in the range
 	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
-		alerts, err := CheckHighSpeedAlerts(vehicleID, plateNo, speedThreshold)
+		alerts, err := checkHighSpeedAlerts(vehicleID, plateNo, d, d.AddDate(0, 0, 1).Add(-time.Second), speedThreshold)
 		if err != nil {
 			log.Printf("Error checking date %s: %v", d.Format("2006-01-02"), err)
 			continue
@@ -328,27 +337,62 @@ func CheckVehicleSpeedByDateRange(vehicleID, plateNo string, startDate, endDate
 	return allAlerts, nil
 }
 
-// RunDailySpeedAlertCheck performs a daily check for speed alerts and returns the report
-// speedThreshold: The speed threshold in km/h (default 80)
-// saveToFile: Whether to save the report to a file
-// Returns the report as a string and any error that occurred
+// RunSpeedCheckJob checks today's speed points of every vehicle against the speed rules,
+// stores the violations and notifies their peak speeds.
+// speedThreshold: The limit in km/h where no speed rule applies (default 80)
 func RunSpeedCheckJob(speedThreshold int, saveToFile bool) error {
-	// Get yesterday's date
+	vehicles := GetAllVehicleData()
+	if len(vehicles) == 0 {
+		return fmt.Errorf("no vehicles found in the list")
+	}
 
-	// Step 1: Initialize authentication
-	_, err := GetClients(username, password)
+	rules, err := loadSpeedRuleSet(speedThreshold)
 	if err != nil {
-		return fmt.Errorf("failed to authenticate: %w", err)
+		return fmt.Errorf("failed to load speed rules: %w", err)
 	}
 
-	alerts, err := CheckAllVehiclesForSpeedAlerts(speedThreshold)
-	var allAlerts []Models.SpeedAlert
-	for _, vehicleAlerts := range alerts {
-		allAlerts = append(allAlerts, vehicleAlerts...)
+	var cars []Models.Car
+	if err := Models.DB.Where("etit_car_id <> ''").Find(&cars).Error; err != nil {
+		return fmt.Errorf("failed to load cars: %w", err)
 	}
-	Alerts.StoreUniqueAlerts(allAlerts)
-	if err != nil {
-		return err
+	carsByVehicleID := make(map[string]Models.Car, len(cars))
+	for _, car := range cars {
+		carsByVehicleID[car.EtitCarID] = car
+	}
+
+	now := time.Now().UTC()
+	startDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var allAlerts []Models.SpeedAlert
+	for _, vehicle := range vehicles {
+		if vehicle.ID == "" {
+			log.Printf("Skipping vehicle with empty ID: %s", vehicle.PlateNo)
+			continue
+		}
+
+		car, ok := carsByVehicleID[vehicle.ID]
+		if !ok {
+			car = Models.Car{CarNoPlate: vehicle.PlateNo}
+		}
+
+		events, err := rules.violations(vehicle.ID, &car, startDate, now)
+		if err != nil {
+			log.Printf("Error checking vehicle %s: %v", vehicle.PlateNo, err)
+			continue
+		}
+		if err := Models.RecordSpeedViolations(Models.DB, events); err != nil {
+			log.Printf("Error storing speed violations of vehicle %s: %v", vehicle.PlateNo, err)
+		}
+		for _, event := range events {
+			allAlerts = append(allAlerts, speedAlertFromViolation(event))
+		}
+		if len(events) > 0 {
+			log.Printf("Found %d speed violations for vehicle %s", len(events), vehicle.PlateNo)
+		}
+
+		// Sleep a bit to avoid overwhelming the server
+		time.Sleep(500 * time.Millisecond)
 	}
-	return nil
+
+	return Alerts.StoreUniqueAlerts(allAlerts)
 }
@@ -0,0 +1,57 @@
+package Scrapper
+
+import (
+	"Falcon/Models"
+	"fmt"
+	"log"
+)
+
+// RefreshOdometers estimates the odometer of every tracked car as its last fuel odometer plus
+// the GPS mileage driven since that fill. Cars without a GPS unit or a timed fill with an
+// odometer keep their fuel odometer.
+func RefreshOdometers() error {
+	var cars []Models.Car
+	if err := Models.DB.Where("etit_car_id <> '' AND last_fuel_odometer > 0").Find(&cars).Error; err != nil {
+		return fmt.Errorf("failed to fetch cars: %w", err)
+	}
+
+	now := Models.WallClockNow()
+	refreshed := 0
+	for _, car := range cars {
+		var event Models.FuelEvent
+		if err := Models.DB.Where("car_id = ? AND odometer_after > 0", car.ID).
+			Order("date DESC, id DESC").Limit(1).Find(&event).Error; err != nil {
+			log.Printf("Failed to fetch the last fill of %s: %v", car.CarNoPlate, err)
+			continue
+		}
+		if event.ID == 0 {
+			continue
+		}
+		filledAt, ok := Models.FuelEventTime(event)
+		if !ok || !filledAt.Before(now) {
+			continue
+		}
+
+		mileage, err := Telematics.Mileage(car.EtitCarID, filledAt, now)
+		if err != nil {
+			log.Printf("Failed to get the mileage of %s from %s: %v", car.CarNoPlate, Telematics.Name(), err)
+			continue
+		}
+
+		estimate := Models.VehicleOdometer{
+			CarID:      car.ID,
+			OdometerKm: event.OdometerAfter + int(mileage),
+			BaseKm:     event.OdometerAfter,
+			BaseAt:     filledAt,
+			ReadAt:     now,
+		}
+		if err := Models.SaveVehicleOdometer(Models.DB, estimate); err != nil {
+			log.Printf("Failed to save the odometer of %s: %v", car.CarNoPlate, err)
+			continue
+		}
+		refreshed++
+	}
+
+	log.Printf("Refreshed the odometer of %d of %d cars", refreshed, len(cars))
+	return nil
+}
@@ -0,0 +1,143 @@
+package Scrapper
+
+import (
+	"Falcon/Models"
+	"log"
+	"strconv"
+	"time"
+)
+
+// speedRuleSet is what the speed check needs to pick the rule of each speed point
+type speedRuleSet struct {
+	rules     []Models.SpeedRule
+	geofences map[string]Models.Geofence // Geofences named by rules, by name
+	fallback  Models.SpeedRule
+}
+
+// loadSpeedRuleSet loads the enabled speed rules and the geofences they refer to, the
+// fallback limit applying where no rule does
+func loadSpeedRuleSet(fallbackSpeed int) (*speedRuleSet, error) {
+	rules, err := Models.LoadSpeedRules(Models.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &speedRuleSet{
+		rules:     rules,
+		geofences: make(map[string]Models.Geofence),
+		fallback:  Models.DefaultSpeedRule(fallbackSpeed),
+	}
+
+	geofences, err := Models.LoadGeofences(Models.DB)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.Geofence == "" {
+			continue
+		}
+		for _, geofence := range geofences {
+			if geofence.Name == rule.Geofence {
+				set.geofences[geofence.Name] = geofence
+				break
+			}
+		}
+	}
+	return set, nil
+}
+
+// samples pairs the speed points of a car with the rule applying at each of them, the car
+// being loaded at the points within its trip windows
+func (s *speedRuleSet) samples(car *Models.Car, points []SpeedPoint, trips []Models.TripWindow) []Models.SpeedSample {
+	samples := make([]Models.SpeedSample, 0, len(points))
+	for _, point := range points {
+		speed, err := strconv.Atoi(point.Speed)
+		if err != nil {
+			log.Printf("Error parsing speed value '%s': %v", point.Speed, err)
+			continue
+		}
+		at, err := ParseGPSTimestamp(point.Timestamp)
+		if err != nil {
+			continue
+		}
+		lat, _ := strconv.ParseFloat(point.Latitude, 64)
+		lng, _ := strconv.ParseFloat(point.Longitude, 64)
+
+		ctx := Models.SpeedContext{
+			CarID:   car.ID,
+			Company: car.OperatingCompany,
+			CarType: car.CarType,
+			Loaded:  Models.LoadedAt(trips, at),
+			Zones:   make(map[string]bool),
+		}
+		for name, geofence := range s.geofences {
+			if geofence.AppliesTo(car.OperatingCompany) && geofence.ActiveAt(at) && geofence.Contains(lat, lng) {
+				ctx.Zones[name] = true
+			}
+		}
+
+		rule := Models.SelectSpeedRule(s.rules, ctx)
+		if rule == nil {
+			rule = &s.fallback
+		}
+		samples = append(samples, Models.SpeedSample{At: at, Speed: speed, Latitude: lat, Longitude: lng, Rule: rule})
+	}
+	return samples
+}
+
+// CheckSpeedViolations returns the speed violations of a car between two times
+func CheckSpeedViolations(vehicleID string, car *Models.Car, startDate, endDate time.Time, fallbackSpeed int) ([]Models.SpeedViolationEvent, error) {
+	set, err := loadSpeedRuleSet(fallbackSpeed)
+	if err != nil {
+		return nil, err
+	}
+	return set.violations(vehicleID, car, startDate, endDate)
+}
+
+func (s *speedRuleSet) violations(vehicleID string, car *Models.Car, startDate, endDate time.Time) ([]Models.SpeedViolationEvent, error) {
+	points, err := Telematics.SpeedPoints(vehicleID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var trips []Models.TripWindow
+	if car.ID != 0 {
+		if trips, err = Models.LoadTripWindows(Models.DB, car.ID, startDate, endDate); err != nil {
+			return nil, err
+		}
+	}
+
+	events := Models.DetectSpeedViolations(s.samples(car, points, trips), Models.MaxSpeedSampleGap)
+	for i := range events {
+		events[i].VehicleID = vehicleID
+		events[i].CarID = car.ID
+		events[i].CarNoPlate = car.CarNoPlate
+		events[i].Company = car.OperatingCompany
+		if car.ID == 0 {
+			continue
+		}
+		driver, err := Models.ResolveDriver(Models.DB, car.ID, events[i].StartedAt)
+		if err != nil {
+			log.Printf("Error resolving the driver of car %s: %v", car.CarNoPlate, err)
+		}
+		events[i].DriverID = driver.ID
+		events[i].DriverName = driver.Name
+	}
+	return events, nil
+}
+
+// speedAlertFromViolation is the alert notified for a violation, at its peak speed. It is
+// identified by the violation start so that it is not notified again as the violation grows.
+func speedAlertFromViolation(event Models.SpeedViolationEvent) Models.SpeedAlert {
+	return Models.SpeedAlert{
+		Hash:       Models.SpeedViolationHash(event),
+		VehicleID:  event.VehicleID,
+		PlateNo:    event.CarNoPlate,
+		Speed:      event.PeakSpeed,
+		Timestamp:  event.PeakAt.Format(Models.PositionTimeLayout),
+		ParsedTime: event.PeakAt,
+		Latitude:   strconv.FormatFloat(event.PeakLatitude, 'f', -1, 64),
+		Longitude:  strconv.FormatFloat(event.PeakLongitude, 'f', -1, 64),
+		ExceedsBy:  event.PeakSpeed - event.SpeedLimit,
+	}
+}
@@ -0,0 +1,83 @@
+package Scrapper
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// TelematicsProvider is a source of GPS tracking data. Vehicle IDs are the provider's own
+// unit identifiers, stored on the car as EtitCarID.
+type TelematicsProvider interface {
+	// Name identifies the provider in logs
+	Name() string
+	// CurrentPositions returns the last reported position of every tracked vehicle
+	CurrentPositions() ([]VehicleStatusStruct, error)
+	// History returns the route driven by a vehicle between two times
+	History(vehicleID string, from, to time.Time) (*RouteData, error)
+	// SpeedPoints returns the speed samples reported by a vehicle between two times
+	SpeedPoints(vehicleID string, from, to time.Time) ([]SpeedPoint, error)
+	// Mileage returns the kilometres driven by a vehicle between two times
+	Mileage(vehicleID string, from, to time.Time) (float64, error)
+}
+
+// Telematics is the provider used by the polling job, speed alerts and route storage
+var Telematics TelematicsProvider = NewETITProvider(username, password)
+
+// ConfigureTelematics selects the provider from the environment. TELEMATICS_FIXTURE points
+// to a fixture file replacing the live provider, ETIT_USERNAME and ETIT_PASSWORD override
+// the default ETIT credentials.
+func ConfigureTelematics() error {
+	if path := os.Getenv("TELEMATICS_FIXTURE"); path != "" {
+		provider, err := LoadFixtureProvider(path)
+		if err != nil {
+			return err
+		}
+		Telematics = provider
+	} else if user := os.Getenv("ETIT_USERNAME"); user != "" {
+		Telematics = NewETITProvider(user, os.Getenv("ETIT_PASSWORD"))
+	}
+
+	log.Printf("Using %s telematics provider", Telematics.Name())
+	return nil
+}
+
+var telematicsTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseTelematicsTime reads a time range bound given either as an ISO date/time or in the
+// M/D/YYYY H:M:S form the GPS portal uses
+func ParseTelematicsTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(strings.ReplaceAll(value, "%20", " "))
+	for _, layout := range telematicsTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	if parsed, err := ParseGPSTimestamp(value); err == nil {
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// parseTelematicsRange parses both bounds of a time range
+func parseTelematicsRange(from, to string) (time.Time, time.Time, error) {
+	start, err := ParseTelematicsTime(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := ParseTelematicsTime(to)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("range end %s is before its start %s", to, from)
+	}
+	return start, end, nil
+}
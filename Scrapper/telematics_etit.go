@@ -0,0 +1,140 @@
+package Scrapper
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Falcon/Structs"
+)
+
+// errETITSessionRejected marks a response showing the portal no longer accepts the session
+var errETITSessionRejected = errors.New("ETIT session rejected")
+
+// ETITProvider reads tracking data by scraping the ETIT fleet management portal. The portal
+// session is kept between calls and renewed when the portal rejects it.
+type ETITProvider struct {
+	Username string
+	Password string
+
+	mu      sync.Mutex
+	clients *AuthenticatedClients
+}
+
+func NewETITProvider(username, password string) *ETITProvider {
+	return &ETITProvider{Username: username, Password: password}
+}
+
+func (p *ETITProvider) Name() string {
+	return "ETIT"
+}
+
+func (p *ETITProvider) login() (*AuthenticatedClients, error) {
+	clients, err := GetClients(p.Username, p.Password)
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+	return clients, nil
+}
+
+// session returns the cached portal session, logging in when there is none
+func (p *ETITProvider) session() (*AuthenticatedClients, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.clients == nil {
+		clients, err := p.login()
+		if err != nil {
+			return nil, err
+		}
+		p.clients = clients
+	}
+	return p.clients, nil
+}
+
+// withSession runs fetch on the cached session. When the portal rejects it, the session is
+// dropped and fetch runs once more on a new login.
+func (p *ETITProvider) withSession(fetch func(*AuthenticatedClients) error) error {
+	clients, err := p.session()
+	if err != nil {
+		return err
+	}
+	if err := fetch(clients); !errors.Is(err, errETITSessionRejected) {
+		return err
+	}
+
+	p.mu.Lock()
+	if p.clients == clients {
+		p.clients = nil
+	}
+	p.mu.Unlock()
+
+	if clients, err = p.session(); err != nil {
+		return err
+	}
+	return fetch(clients)
+}
+
+// CurrentPositions scrapes the transporters grid
+func (p *ETITProvider) CurrentPositions() ([]VehicleStatusStruct, error) {
+	var statuses []VehicleStatusStruct
+	err := p.withSession(func(clients *AuthenticatedClients) error {
+		// A fresh collector on the session, as the grid callbacks are added on every call
+		var err error
+		statuses, err = GetCurrentLocationData(clients.Collector.Clone())
+		return err
+	})
+	return statuses, err
+}
+
+func (p *ETITProvider) History(vehicleID string, from, to time.Time) (*RouteData, error) {
+	var route *RouteData
+	err := p.withSession(func(clients *AuthenticatedClients) error {
+		var err error
+		route, err = getRouteData(clients, vehicleID, etitQueryTime(from), etitQueryTime(to))
+		return err
+	})
+	return route, err
+}
+
+func (p *ETITProvider) SpeedPoints(vehicleID string, from, to time.Time) ([]SpeedPoint, error) {
+	var speedData *SpeedData
+	err := p.withSession(func(clients *AuthenticatedClients) error {
+		var err error
+		speedData, err = getSpeedData(clients, vehicleID, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return speedData.Points, nil
+}
+
+// Mileage reads the total mileage of the portal's trip summary
+func (p *ETITProvider) Mileage(vehicleID string, from, to time.Time) (float64, error) {
+	var summary *Structs.TripSummary
+	err := p.withSession(func(clients *AuthenticatedClients) error {
+		var err error
+		summary, err = fetchTripSummary(clients, vehicleID, etitQueryTime(from), etitQueryTime(to))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if summary == nil || strings.TrimSpace(summary.TotalMileage) == "" {
+		return 0, nil
+	}
+	mileage, err := strconv.ParseFloat(strings.TrimSpace(summary.TotalMileage), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid total mileage %q: %w", summary.TotalMileage, err)
+	}
+	return mileage, nil
+}
+
+// etitQueryTime formats a time as the portal's history endpoints expect it, e.g. 12/24/2022%2000:00:00
+func etitQueryTime(t time.Time) string {
+	return fmt.Sprintf("%02d/%02d/%04d%%20%02d:%02d:%02d",
+		t.Month(), t.Day(), t.Year(), t.Hour(), t.Minute(), t.Second())
+}
@@ -0,0 +1,115 @@
+package Scrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FixtureProvider serves recorded tracking data from a JSON file, for development and tests
+// without access to a GPS portal
+type FixtureProvider struct {
+	Positions []VehicleStatusStruct     `json:"positions"`
+	Vehicles  map[string]FixtureVehicle `json:"vehicles"` // Keyed by vehicle ID
+}
+
+// FixtureVehicle is the recorded route and speed samples of one vehicle
+type FixtureVehicle struct {
+	RouteData
+	SpeedPoints []SpeedPoint `json:"speed_points"`
+}
+
+// LoadFixtureProvider reads a fixture file
+func LoadFixtureProvider(path string) (*FixtureProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read telematics fixture: %w", err)
+	}
+
+	var provider FixtureProvider
+	if err := json.Unmarshal(data, &provider); err != nil {
+		return nil, fmt.Errorf("failed to parse telematics fixture %s: %w", path, err)
+	}
+	return &provider, nil
+}
+
+func (p *FixtureProvider) Name() string {
+	return "fixture"
+}
+
+func (p *FixtureProvider) CurrentPositions() ([]VehicleStatusStruct, error) {
+	positions := make([]VehicleStatusStruct, len(p.Positions))
+	copy(positions, p.Positions)
+	return positions, nil
+}
+
+func (p *FixtureProvider) vehicle(vehicleID string) (FixtureVehicle, error) {
+	vehicle, ok := p.Vehicles[vehicleID]
+	if !ok {
+		return FixtureVehicle{}, fmt.Errorf("vehicle %s not found in fixture", vehicleID)
+	}
+	return vehicle, nil
+}
+
+// History returns the recorded coordinates and stops falling within the range
+func (p *FixtureProvider) History(vehicleID string, from, to time.Time) (*RouteData, error) {
+	vehicle, err := p.vehicle(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	route := &RouteData{TripSummary: vehicle.TripSummary}
+	for _, coordinate := range vehicle.Coordinates {
+		if inFixtureRange(coordinate.DateTime, from, to) {
+			route.Coordinates = append(route.Coordinates, coordinate)
+		}
+	}
+	for _, stop := range vehicle.Stops {
+		if inFixtureRange(stop.From, from, to) {
+			route.Stops = append(route.Stops, stop)
+		}
+	}
+
+	if len(route.Coordinates) == 0 {
+		return nil, fmt.Errorf("no valid coordinates found in response")
+	}
+	return route, nil
+}
+
+func (p *FixtureProvider) SpeedPoints(vehicleID string, from, to time.Time) ([]SpeedPoint, error) {
+	vehicle, err := p.vehicle(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []SpeedPoint
+	for _, point := range vehicle.SpeedPoints {
+		if inFixtureRange(point.Timestamp, from, to) {
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
+
+// Mileage returns the recorded trip summary mileage, whatever the range
+func (p *FixtureProvider) Mileage(vehicleID string, from, to time.Time) (float64, error) {
+	vehicle, err := p.vehicle(vehicleID)
+	if err != nil {
+		return 0, err
+	}
+	if vehicle.TripSummary == nil || vehicle.TripSummary.TotalMileage == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.TrimSpace(vehicle.TripSummary.TotalMileage), 64)
+}
+
+func inFixtureRange(timestamp string, from, to time.Time) bool {
+	parsed, err := ParseTelematicsTime(timestamp)
+	if err != nil {
+		return false
+	}
+	return !parsed.Before(from) && !parsed.After(to)
+}
@@ -0,0 +1,96 @@
+package Scrapper
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTelematicsTime(t *testing.T) {
+	want := time.Date(2025, 7, 10, 8, 5, 0, 0, time.UTC)
+	for _, value := range []string{
+		"2025-07-10 08:05:00",
+		"2025-07-10T08:05:00",
+		"2025-07-10T08:05",
+		"7/10/2025 8:5:0",
+		"07/10/2025%2008:05:00",
+	} {
+		got, err := ParseTelematicsTime(value)
+		if err != nil {
+			t.Errorf("ParseTelematicsTime(%q) failed: %v", value, err)
+		} else if !got.Equal(want) {
+			t.Errorf("ParseTelematicsTime(%q) = %s, want %s", value, got, want)
+		}
+	}
+
+	if _, err := ParseTelematicsTime("yesterday"); err == nil {
+		t.Error("ParseTelematicsTime accepted an invalid time")
+	}
+}
+
+func TestETITQueryTime(t *testing.T) {
+	got := etitQueryTime(time.Date(2022, 12, 24, 23, 59, 59, 0, time.UTC))
+	if got != "12/24/2022%2023:59:59" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestETITProviderKeepsSession(t *testing.T) {
+	clients := &AuthenticatedClients{}
+	provider := &ETITProvider{clients: clients}
+
+	var used []*AuthenticatedClients
+	fetch := func(c *AuthenticatedClients) error {
+		used = append(used, c)
+		return errors.New("vehicle not found")
+	}
+	// Errors other than a rejected session neither log in again nor drop the session
+	for i := 0; i < 2; i++ {
+		if err := provider.withSession(fetch); err == nil || err.Error() != "vehicle not found" {
+			t.Fatalf("withSession() = %v", err)
+		}
+	}
+	if len(used) != 2 || used[0] != clients || used[1] != clients || provider.clients != clients {
+		t.Errorf("session not reused: %d fetches", len(used))
+	}
+}
+
+func TestFixtureProvider(t *testing.T) {
+	provider, err := LoadFixtureProvider("testdata/telematics_fixture.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	positions, err := provider.CurrentPositions()
+	if err != nil || len(positions) != 2 || positions[0].ID != "1001" || positions[0].Speed != 64 {
+		t.Fatalf("unexpected positions %+v, %v", positions, err)
+	}
+
+	from := time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 10, 23, 59, 59, 0, time.UTC)
+
+	route, err := provider.History("1001", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(route.Coordinates) != 2 || len(route.Stops) != 1 || route.TripSummary == nil {
+		t.Errorf("unexpected route %+v", route)
+	}
+
+	points, err := provider.SpeedPoints("1001", from, to)
+	if err != nil || len(points) != 2 || points[1].Speed != "95" {
+		t.Errorf("unexpected speed points %+v, %v", points, err)
+	}
+
+	mileage, err := provider.Mileage("1001", from, to)
+	if err != nil || mileage != 182.5 {
+		t.Errorf("got mileage %v, %v", mileage, err)
+	}
+
+	if _, err := provider.History("1002", from, to); err == nil {
+		t.Error("expected an error for a vehicle without recorded history")
+	}
+	if _, err := provider.SpeedPoints("9999", from, to); err == nil {
+		t.Error("expected an error for an unknown vehicle")
+	}
+}
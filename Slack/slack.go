@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"net/http"
 	"os"
 	"strconv"
@@ -581,6 +580,7 @@ func ManualUpdateVehicleStatus(carID uint, newStatus, location, updatedBy string
 	car.Location = location
 	car.SlackStatus = newStatus
 	car.GeoFence = "" // Clear geofence field on manual update (assumes manual status means not in any geofence)
+	car.GeoFenceType = ""
 	car.LastUpdatedSlackStatus = time.Now()
 
 	// Save to database
@@ -736,6 +736,8 @@ func generateStoppedVehiclesMessage(cars []Models.Car) string {
 	message.WriteString("*Vehicles currently resting, in maintenance, or in garage*\n\n")
 	message.WriteString("---\n\n")
 
+	geofences := geofencesByName()
+
 	// Group vehicles by status for better organization
 	statusGroups := make(map[string][]Models.Car)
 	for _, car := range cars {
@@ -761,22 +763,21 @@ func generateStoppedVehiclesMessage(cars []Models.Car) string {
 				displayLocation := car.Location
 				if car.GeoFence != "" {
 					// Check if it's a terminal, garage, or drop-off point
-					for _, geofence := range AllGeoFences {
-						if geofence.Name == car.GeoFence {
-							if geofence.Type == "garage" {
-								displayLocation = "Garage"
-							} else if geofence.Type == "terminal" {
-								// Don't add "Terminal" suffix if it already exists in the name
-								if strings.Contains(strings.ToLower(geofence.Name), "terminal") {
-									displayLocation = geofence.Name
-								} else {
-									displayLocation = fmt.Sprintf("%s Terminal", geofence.Name)
-								}
+					if geofence, ok := geofences[car.GeoFence]; ok {
+						if geofence.Type == "garage" {
+							displayLocation = "Garage"
+						} else if geofence.Type == "terminal" {
+							// Don't add "Terminal" suffix if it already exists in the name
+							if strings.Contains(strings.ToLower(geofence.Name), "terminal") {
+								displayLocation = geofence.Name
+							} else {
+								displayLocation = fmt.Sprintf("%s Terminal", geofence.Name)
 							}
-							break
+						} else if geofence.Type == Models.GeofenceStation || geofence.Type == Models.GeofenceRestricted {
+							displayLocation = geofence.Name
 						}
 					}
-					// If not found in static geofences, it might be a drop-off point
+					// Anything else is a drop-off point
 					if displayLocation == car.Location && car.GeoFence != "" {
 						displayLocation = fmt.Sprintf("%s Drop-Off Point", car.GeoFence)
 					}
@@ -942,6 +943,8 @@ func generateSlackMessage(cars []Models.Car, company string) string {
 	message.WriteString(fmt.Sprintf("*Last Updated: %s*\n\n", time.Now().Format("January 2, 2006 - 15:04:05 MST")))
 	message.WriteString("---\n\n")
 
+	geofences := geofencesByName()
+
 	// Vehicle details
 	for _, car := range cars {
 		// Get driver name
@@ -954,22 +957,21 @@ func generateSlackMessage(cars []Models.Car, company string) string {
 		displayLocation := car.Location
 		if car.GeoFence != "" {
 			// Check if it's a terminal, garage, or drop-off point
-			for _, geofence := range AllGeoFences {
-				if geofence.Name == car.GeoFence {
-					if geofence.Type == "garage" {
-						displayLocation = "Garage"
-					} else if geofence.Type == "terminal" {
-						// Don't add "Terminal" suffix if it already exists in the name
-						if strings.Contains(strings.ToLower(geofence.Name), "terminal") {
-							displayLocation = geofence.Name
-						} else {
-							displayLocation = fmt.Sprintf("%s Terminal", geofence.Name)
-						}
+			if geofence, ok := geofences[car.GeoFence]; ok {
+				if geofence.Type == "garage" {
+					displayLocation = "Garage"
+				} else if geofence.Type == "terminal" {
+					// Don't add "Terminal" suffix if it already exists in the name
+					if strings.Contains(strings.ToLower(geofence.Name), "terminal") {
+						displayLocation = geofence.Name
+					} else {
+						displayLocation = fmt.Sprintf("%s Terminal", geofence.Name)
 					}
-					break
+				} else if geofence.Type == Models.GeofenceStation || geofence.Type == Models.GeofenceRestricted {
+					displayLocation = geofence.Name
 				}
 			}
-			// If not found in static geofences, it might be a drop-off point
+			// Anything else is a drop-off point
 			if displayLocation == car.Location && car.GeoFence != "" {
 				displayLocation = fmt.Sprintf("%s Drop-Off Point", car.GeoFence)
 			}
@@ -1035,144 +1037,38 @@ func generateSlackMessage(cars []Models.Car, company string) string {
 	return message.String()
 }
 
-var AllGeoFences = []GeoFence{
-	// Garage
-	{
-		Name:      "garage",
-		Latitude:  30.128955,
-		Longitude: 31.298539,
-		Radius:    0.4, // 400 meters radius
-		Type:      "garage",
-	},
-	// Terminals
-	{
-		Name:      "Badr Terminal",
-		Latitude:  30.1020583,
-		Longitude: 31.81396,
-		Radius:    0.5, // 500 meters radius
-		Type:      "terminal",
-	},
-	{
-		Name:      "CPC Mostorod Terminal",
-		Latitude:  30.144197,
-		Longitude: 31.296322,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-	{
-		Name:      "Fayoum Terminal",
-		Latitude:  29.3391616,
-		Longitude: 30.9257033,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-	{
-		Name:      "Misr Petroleum Bor Saed Terminal",
-		Latitude:  31.235575,
-		Longitude: 32.301198,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-	{
-		Name:      "Mobil Bor Saed Terminal",
-		Latitude:  31.23365,
-		Longitude: 32.298082,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-	{
-		Name:      "Haykstep Terminal",
-		Latitude:  30.12486,
-		Longitude: 31.3580633,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-	{
-		Name:      "Somed Terminal",
-		Latitude:  29.594416,
-		Longitude: 32.329073,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-	{
-		Name:      "Agroud Terminal",
-		Latitude:  30.071958,
-		Longitude: 32.381296,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-	{
-		Name:      "TAQA Suez Terminal",
-		Latitude:  29.964054,
-		Longitude: 32.515200,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-	{
-		Name:      "TAQA Alex Terminal",
-		Latitude:  31.149223,
-		Longitude: 29.853037,
-		Radius:    0.5,
-		Type:      "terminal",
-	},
-}
-
-// GeoFence represents a geographical boundary
-type GeoFence struct {
-	Name      string
-	Latitude  float64
-	Longitude float64
-	Radius    float64 // in kilometers
-	Type      string  // "garage", "terminal", or "dropoff"
-}
-
 // isValidCoordinate validates latitude and longitude values
 func isValidCoordinate(lat, lng float64) bool {
 	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
 }
 
-// calculateDistance calculates distance between two coordinates using Haversine formula
-func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
-	// Validate coordinates
-	if !isValidCoordinate(lat1, lon1) || !isValidCoordinate(lat2, lon2) {
-		return math.Inf(1) // Return infinity for invalid coordinates
+// checkGeofences checks which geofence the vehicle is in (if any)
+func checkGeofences(lat, lng float64, car *Models.Car, at time.Time) (string, string, bool) {
+	geofences, err := Models.LoadGeofences(Models.DB)
+	if err != nil {
+		log.Printf("Error fetching geofences: %v", err)
 	}
 
-	const R = 6371 // Earth's radius in kilometers
-
-	dLat := (lat2 - lat1) * math.Pi / 180
-	dLon := (lon2 - lon1) * math.Pi / 180
-
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*
-			math.Sin(dLon/2)*math.Sin(dLon/2)
-
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	distance := R * c
-
-	return distance
-}
+	if geofence := Models.MatchGeofence(geofences, car.OperatingCompany, lat, lng, at); geofence != nil {
+		return geofence.Name, geofence.Type, true
+	}
 
-// checkGeofences checks which geofence the vehicle is in (if any)
-func checkGeofences(lat, lng float64, car *Models.Car) (string, string, bool) {
-	// First check static geofences (garage and terminals)
-	for _, geofence := range AllGeoFences {
-		distance := calculateDistance(lat, lng, geofence.Latitude, geofence.Longitude)
-		if distance <= geofence.Radius {
-			return geofence.Name, geofence.Type, true
+	// Then check drop-off points that have no geofence of their own
+	fenced := make(map[string]bool)
+	for _, geofence := range geofences {
+		if geofence.Type == Models.GeofenceDropOff && geofence.AppliesTo(car.OperatingCompany) {
+			fenced[geofence.Name] = true
 		}
 	}
-
-	// Then check drop-off point geofences from database
-	dropoffName, found := checkDropOffPoints(lat, lng, car.OperatingCompany)
+	dropoffName, found := checkDropOffPoints(lat, lng, car.OperatingCompany, fenced)
 	if found {
-		return dropoffName, "dropoff", true
+		return dropoffName, Models.GeofenceDropOff, true
 	}
 
 	return "", "", false
 }
 
-func checkDropOffPoints(lat, lng float64, company string) (string, bool) {
+func checkDropOffPoints(lat, lng float64, company string, fenced map[string]bool) (string, bool) {
 	var feeMappings []Models.FeeMapping
 
 	// Get all fee mappings for this company
@@ -1181,10 +1077,15 @@ func checkDropOffPoints(lat, lng float64, company string) (string, bool) {
 		return "", false
 	}
 
-	// Check each drop-off point (500m radius)
+	// Check each drop-off point within the default radius
 	for _, mapping := range feeMappings {
-		distance := calculateDistance(lat, lng, mapping.Latitude, mapping.Longitude)
-		if distance <= 0.5 { // 500 meters radius
+		if fenced[mapping.DropOffPoint] {
+			continue
+		}
+		if !isValidCoordinate(lat, lng) || !isValidCoordinate(mapping.Latitude, mapping.Longitude) {
+			continue
+		}
+		if Models.DistanceKm(lat, lng, mapping.Latitude, mapping.Longitude) <= Models.DefaultDropOffRadius {
 			return mapping.DropOffPoint, true
 		}
 	}
@@ -1192,6 +1093,40 @@ func checkDropOffPoints(lat, lng float64, company string) (string, bool) {
 	return "", false
 }
 
+// geofencesByName returns the geofences keyed by name, to label car locations
+func geofencesByName() map[string]Models.Geofence {
+	var geofences []Models.Geofence
+	if err := Models.DB.Find(&geofences).Error; err != nil {
+		log.Printf("Error fetching geofences: %v", err)
+	}
+
+	byName := make(map[string]Models.Geofence, len(geofences))
+	for _, geofence := range geofences {
+		byName[geofence.Name] = geofence
+	}
+	return byName
+}
+
+// currentGeofenceType returns the type of the fence a car is in, recorded when it entered.
+// Cars that entered before the type was recorded fall back to the enabled fence of that name
+// applying to their company, or to a drop-off point.
+func currentGeofenceType(car *Models.Car) string {
+	if car.GeoFenceType != "" {
+		return car.GeoFenceType
+	}
+
+	geofences, err := Models.LoadGeofences(Models.DB)
+	if err != nil {
+		log.Printf("Error fetching geofences: %v", err)
+	}
+	for _, geofence := range geofences {
+		if geofence.Name == car.GeoFence && geofence.AppliesTo(car.OperatingCompany) {
+			return geofence.Type
+		}
+	}
+	return Models.GeofenceDropOff
+}
+
 // UpdateCarGeofence updates car's geofence based on current location
 // Only applies geofencing logic if GPS signal is at least 30 minutes after last status update
 func UpdateCarGeofence(car *Models.Car, lat, lng float64, timestamp string) bool {
@@ -1215,15 +1150,20 @@ func UpdateCarGeofence(car *Models.Car, lat, lng float64, timestamp string) bool
 		car.CarNoPlate, timestamp, car.LastUpdatedSlackStatus.Format("2006-01-02 15:04:05"))
 
 	// Check all geofences (including drop-off points)
-	geofenceName, geofenceType, inGeofence := checkGeofences(lat, lng, car)
+	geofenceName, geofenceType, inGeofence := checkGeofences(lat, lng, car, newTimestamp)
 
 	// Store previous values for comparison
 	previousStatus := car.SlackStatus
 	previousGeoFence := car.GeoFence
+	previousGeofenceType := ""
+	if previousGeoFence != "" {
+		previousGeofenceType = currentGeofenceType(car)
+	}
 
 	if inGeofence {
 		// Vehicle entered a geofence - update status based on geofence type
 		car.GeoFence = geofenceName
+		car.GeoFenceType = geofenceType
 		switch geofenceType {
 		case "garage":
 			car.SlackStatus = "In Garage"
@@ -1231,6 +1171,10 @@ func UpdateCarGeofence(car *Models.Car, lat, lng float64, timestamp string) bool
 			car.SlackStatus = "In Terminal"
 		case "dropoff":
 			car.SlackStatus = "In Drop-Off"
+		case "station":
+			car.SlackStatus = "In Station"
+		case "restricted":
+			car.SlackStatus = "In Restricted Area"
 		}
 		car.LastUpdatedSlackStatus = newTimestamp
 		log.Printf("Car %s entered geofence: %s (type: %s)", car.CarNoPlate, geofenceName, geofenceType)
@@ -1238,19 +1182,6 @@ func UpdateCarGeofence(car *Models.Car, lat, lng float64, timestamp string) bool
 		// Vehicle not in any geofence
 		if car.GeoFence != "" {
 			// Vehicle left a geofence - set "Left" status based on previous geofence type
-			previousGeofenceType := ""
-			for _, geofence := range AllGeoFences {
-				if geofence.Name == car.GeoFence {
-					previousGeofenceType = geofence.Type
-					break
-				}
-			}
-
-			// If not found in static geofences, check if it was a drop-off point
-			if previousGeofenceType == "" && car.GeoFence != "" {
-				previousGeofenceType = "dropoff"
-			}
-
 			// Set appropriate "Left" status
 			switch previousGeofenceType {
 			case "garage":
@@ -1259,14 +1190,48 @@ func UpdateCarGeofence(car *Models.Car, lat, lng float64, timestamp string) bool
 				car.SlackStatus = "Left Terminal"
 			case "dropoff":
 				car.SlackStatus = "Left Drop-Off"
+			case "station":
+				car.SlackStatus = "Left Station"
+			case "restricted":
+				car.SlackStatus = "Left Restricted Area"
 			}
 
 			log.Printf("Car %s left geofence: %s (type: %s)", car.CarNoPlate, car.GeoFence, previousGeofenceType)
 			car.GeoFence = ""
+			car.GeoFenceType = ""
 			car.LastUpdatedSlackStatus = newTimestamp
 		}
 	}
 
+	// A car on an active work order stays stopped for maintenance whatever fence it is in
+	if previousStatus == Models.MaintenanceSlackStatus && car.SlackStatus != previousStatus &&
+		Models.HasActiveWorkOrder(Models.DB, car.ID) {
+		car.SlackStatus = previousStatus
+	}
+
+	// Geofence transitions are logged and drive the automatic trip detection
+	if previousGeoFence != car.GeoFence {
+		var transitions []Models.GeofenceTransition
+		if previousGeoFence != "" {
+			transitions = append(transitions, Models.GeofenceTransition{
+				Type: previousGeofenceType, Name: previousGeoFence,
+				Latitude: lat, Longitude: lng, At: newTimestamp,
+			})
+		}
+		if car.GeoFence != "" {
+			transitions = append(transitions, Models.GeofenceTransition{
+				Entered: true, Type: car.GeoFenceType, Name: car.GeoFence,
+				Latitude: lat, Longitude: lng, At: newTimestamp,
+			})
+		}
+		if err := Models.RecordGeofenceEvents(Models.DB, car, transitions); err != nil {
+			log.Printf("Error recording geofence events for car %s: %v", car.CarNoPlate, err)
+		}
+		if err := Models.DetectTrips(Models.DB, car, transitions...); err != nil {
+			log.Printf("Error detecting trips for car %s: %v", car.CarNoPlate, err)
+		}
+	}
+
 	// Return true only if status or geofence actually changed
 	statusChanged := previousStatus != car.SlackStatus || previousGeoFence != car.GeoFence
 
@@ -1298,7 +1263,11 @@ func getStatusEmoji(status string) string {
 		return "🔴"
 	case "driver resting":
 		return "💤"
-	case "left terminal", "left garage", "left drop-off":
+	case "in station":
+		return "⛽"
+	case "in restricted area":
+		return "⛔"
+	case "left terminal", "left garage", "left drop-off", "left station", "left restricted area":
 		return "🚫"
 	default:
 		return "❓"
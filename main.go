@@ -5,6 +5,7 @@ import (
 	"Falcon/Models"
 	"Falcon/PetroApp"
 	"Falcon/Scrapper"
+	"Falcon/Scrapper/Alerts"
 	"Falcon/Slack"
 	"log"
 	"os"
@@ -12,6 +13,20 @@ import (
 )
 
 func main() {
+	// go run . petroapp-backfill 2025-01-01 2025-01-31
+	if len(os.Args) == 4 && os.Args[1] == "petroapp-backfill" {
+		Models.Connect()
+		run, err := PetroApp.Backfill(os.Args[2], os.Args[3])
+		if run != nil {
+			log.Printf("Backfill run %d %s: %d fetched, %d stored, %d synced, %d failed",
+				run.ID, run.Status, run.Fetched, run.Stored, run.Synced, run.Failed)
+		}
+		if err != nil {
+			log.Fatal("Backfill failed:", err)
+		}
+		return
+	}
+
 	// CheckExpirationDates Each Minute
 	// go func() {
 	// 	for {
@@ -35,23 +50,19 @@ func main() {
 	// 		time.Sleep(time.Hour)
 	// 	}
 	// }()
+	if err := Scrapper.ConfigureTelematics(); err != nil {
+		log.Fatal("Failed to configure the telematics provider:", err)
+	}
 	go func() {
 		// if err := Alerts.InitFirebase(); err != nil {
 		// 	log.Fatal("Failed to initialize Firebase:", err)
 		// }
 		for {
-			// Handle errors properly to prevent crashes
-			if records, err := PetroApp.FetchPetroAppRecords(); err != nil {
-				log.Printf("Error fetching PetroApp records: %v", err)
-				// Continue loop instead of crashing
-			} else {
-				log.Printf("Fetched %d PetroApp records", len(records))
-			}
-
+			// Gives the database time to connect on startup
 			time.Sleep(time.Minute)
 
-			// Sync PetroApp records to FuelEvents
-			if err := PetroApp.SyncPetroAppRecordsToFuelEvents(); err != nil {
+			// Fetches from the cursor of the last successful run and syncs the new records
+			if _, err := PetroApp.RunScheduledSync(); err != nil {
 				log.Printf("Error syncing PetroApp records: %v", err)
 				// Continue loop instead of crashing
 			}
@@ -60,6 +71,58 @@ func main() {
 			time.Sleep(time.Minute * 5)
 		}
 	}()
+	go func() {
+		// Gives the database time to connect, then runs right away so rates due today apply
+		// after a restart
+		time.Sleep(time.Minute)
+		for {
+			// Scheduled fee mapping rates come into force on their start date
+			if err := Models.ApplyDueFeeMappingRates(Models.DB); err != nil {
+				log.Printf("Error applying scheduled fee mapping rates: %v", err)
+			}
+
+			if removed, err := Models.PruneVehiclePositions(Models.DB, Models.WallClockNow()); err != nil {
+				log.Printf("Error pruning vehicle positions: %v", err)
+			} else {
+				log.Printf("Pruned %d vehicle positions", removed)
+			}
+			if removed, err := Models.PruneSyncRuns(Models.DB, time.Now()); err != nil {
+				log.Printf("Error pruning sync runs: %v", err)
+			} else {
+				log.Printf("Pruned %d sync runs", removed)
+			}
+			if count, err := Models.ExpireTripSuggestions(Models.DB, Models.WallClockNow()); err != nil {
+				log.Printf("Error expiring trip suggestions: %v", err)
+			} else {
+				log.Printf("Expired %d trip suggestions", count)
+			}
+
+			// Scores follow the wall clock time positions are recorded in
+			today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+			if err := Models.RefreshDriverScores(Models.DB, today); err != nil {
+				log.Printf("Error computing driver scores: %v", err)
+			}
+
+			if err := Models.ExportCostWorkbooks(Models.DB, Models.CostWorkbookDir); err != nil {
+				log.Printf("Error exporting cost workbooks: %v", err)
+			}
+
+			if count, err := Models.RefreshVehicleCosts(Models.DB); err != nil {
+				log.Printf("Error refreshing vehicle costs: %v", err)
+			} else {
+				log.Printf("Refreshed %d vehicle cost entries", count)
+			}
+
+			if err := Scrapper.RefreshOdometers(); err != nil {
+				log.Printf("Error refreshing odometers: %v", err)
+			}
+
+			if err := Alerts.NotifyMaintenanceDue(Models.DB); err != nil {
+				log.Printf("Error sending maintenance alerts: %v", err)
+			}
+			time.Sleep(time.Hour * 6)
+		}
+	}()
 	// go func() {
 	// 	time.Sleep(time.Second * 30)
 	// 	for {